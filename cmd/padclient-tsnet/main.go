@@ -0,0 +1,35 @@
+// main.go
+// Package main is the padclient command with an embedded Tailscale node for -tsnet. It is a
+// module of its own so the Tailscale libraries, and the newer Go they need, stay out of the
+// main module.
+
+package main
+
+import (
+	"context"
+
+	"github.com/drewwalton19216801/padclient/ui"
+	"tailscale.com/tsnet"
+)
+
+func main() {
+	ui.StartTsnet = startTsnet
+	ui.Main()
+}
+
+// startTsnet brings a tsnet node up for the client to dial through
+func startTsnet(ctx context.Context, config ui.TsnetConfig) (ui.TsnetNode, error) {
+	srv := &tsnet.Server{
+		Hostname:  config.Hostname,
+		Dir:       config.Dir,
+		AuthKey:   config.AuthKey,
+		Ephemeral: config.Ephemeral,
+		Logf:      func(string, ...any) {},
+		UserLogf:  config.UserLogf,
+	}
+	if _, err := srv.Up(ctx); err != nil {
+		srv.Close()
+		return nil, err
+	}
+	return srv, nil
+}
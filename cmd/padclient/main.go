@@ -0,0 +1,11 @@
+// main.go
+// Package main is the padclient command. The client lives in package ui, which builds on the
+// importable crypto, protocol, and transport packages.
+
+package main
+
+import "github.com/drewwalton19216801/padclient/ui"
+
+func main() {
+	ui.Main()
+}
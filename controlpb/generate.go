@@ -0,0 +1,6 @@
+// generate.go
+// Package controlpb holds the generated code of the daemon's gRPC control API.
+
+package controlpb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative control.proto
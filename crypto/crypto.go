@@ -0,0 +1,113 @@
+// crypto.go
+// Package crypto implements the pad protocol's ciphers: AES-256-CBC with the shared secret for
+// broadcasts, and XOR with a one-time key or pad bytes for direct messages, with HMAC-SHA256 keyed
+// from secret key material to authenticate them.
+
+package crypto
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"fmt"
+	"io"
+)
+
+// EncryptAES encrypts the plaintext using AES encryption with the provided key.
+func EncryptAES(key, plaintext []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	// Add padding to the plaintext to make its length a multiple of the block size
+	padding := aes.BlockSize - len(plaintext)%aes.BlockSize
+	padtext := bytes.Repeat([]byte{byte(padding)}, padding)
+	plaintext = append(plaintext, padtext...)
+
+	// Prepare the ciphertext slice with space for the IV
+	ciphertext := make([]byte, aes.BlockSize+len(plaintext))
+	iv := ciphertext[:aes.BlockSize]
+
+	// Generate a random initialization vector (IV)
+	_, err = io.ReadFull(rand.Reader, iv)
+	if err != nil {
+		return nil, err
+	}
+
+	// Encrypt the plaintext using AES-CBC mode
+	mode := cipher.NewCBCEncrypter(block, iv)
+	mode.CryptBlocks(ciphertext[aes.BlockSize:], plaintext)
+	return ciphertext, nil
+}
+
+// DecryptAES decrypts the ciphertext using AES encryption with the provided key.
+func DecryptAES(key, ciphertext []byte) ([]byte, error) {
+	if len(ciphertext) < 2*aes.BlockSize || len(ciphertext)%aes.BlockSize != 0 {
+		return nil, fmt.Errorf("ciphertext is not a whole number of blocks")
+	}
+	iv := ciphertext[:aes.BlockSize]
+	ciphertextData := ciphertext[aes.BlockSize:]
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	// Decrypt the ciphertext using AES-CBC mode
+	mode := cipher.NewCBCDecrypter(block, iv)
+	mode.CryptBlocks(ciphertextData, ciphertextData)
+
+	// Remove the PKCS#7 padding, every byte of which must hold its length
+	paddingLength := int(ciphertextData[len(ciphertextData)-1])
+	if paddingLength == 0 || paddingLength > aes.BlockSize {
+		return nil, fmt.Errorf("invalid padding")
+	}
+	plaintext, padding := ciphertextData[:len(ciphertextData)-paddingLength], ciphertextData[len(ciphertextData)-paddingLength:]
+	if !bytes.Equal(padding, bytes.Repeat([]byte{byte(paddingLength)}, paddingLength)) {
+		return nil, fmt.Errorf("invalid padding")
+	}
+
+	return plaintext, nil
+}
+
+// EncryptXOR performs OTP encryption (XOR cipher) on the message using the provided key.
+// The key must be at least as long as the message; XOR is its own inverse, so this also decrypts.
+func EncryptXOR(message, key []byte) []byte {
+	ciphertext := make([]byte, len(message))
+	for i := range message {
+		ciphertext[i] = message[i] ^ key[i]
+	}
+	return ciphertext
+}
+
+// MACSize is the length of a message authentication code, and of the key material one is derived from
+const MACSize = sha256.Size
+
+// macContext is mixed into every MAC key, so key material is never used as is for both
+// encryption and authentication
+const macContext = "padclient-xor-mac-v1\x00"
+
+// MACKey derives the key that authenticates a message from secret key material: pad bytes or an
+// agreed key, never a key that travels with the message
+func MACKey(keyMaterial []byte) []byte {
+	digest := sha256.New()
+	digest.Write([]byte(macContext))
+	digest.Write(keyMaterial)
+	return digest.Sum(nil)
+}
+
+// SumMAC returns the HMAC-SHA256 of the ciphertext under macKey.
+func SumMAC(macKey, ciphertext []byte) []byte {
+	mac := hmac.New(sha256.New, macKey)
+	mac.Write(ciphertext)
+	return mac.Sum(nil)
+}
+
+// CheckMAC reports whether mac is the HMAC-SHA256 of the ciphertext under macKey, in constant time.
+func CheckMAC(macKey, ciphertext, mac []byte) bool {
+	return hmac.Equal(SumMAC(macKey, ciphertext), mac)
+}
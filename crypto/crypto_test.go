@@ -0,0 +1,150 @@
+package crypto
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+)
+
+// testKey is a fixed AES-256 key
+var testKey = bytes.Repeat([]byte{7}, 32)
+
+// encryptRaw encrypts blocks with AES-CBC under testKey and a zero IV without adding padding, so
+// tests can build ciphertexts whose padding is wrong
+func encryptRaw(t *testing.T, blocks []byte) []byte {
+	t.Helper()
+	block, err := aes.NewCipher(testKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ciphertext := make([]byte, aes.BlockSize+len(blocks))
+	cipher.NewCBCEncrypter(block, ciphertext[:aes.BlockSize]).CryptBlocks(ciphertext[aes.BlockSize:], blocks)
+	return ciphertext
+}
+
+// TestAESRoundTrip encrypts and decrypts plaintexts of every length around a block boundary
+func TestAESRoundTrip(t *testing.T) {
+	for n := 0; n <= 2*aes.BlockSize+1; n++ {
+		plaintext := bytes.Repeat([]byte{'x'}, n)
+		ciphertext, err := EncryptAES(testKey, plaintext)
+		if err != nil {
+			t.Fatalf("length %d: %v", n, err)
+		}
+		if len(ciphertext)%aes.BlockSize != 0 || len(ciphertext) <= n+aes.BlockSize {
+			t.Errorf("length %d: ciphertext of %d bytes", n, len(ciphertext))
+		}
+		decrypted, err := DecryptAES(testKey, ciphertext)
+		if err != nil || !bytes.Equal(decrypted, plaintext) {
+			t.Errorf("length %d: decrypted %q, %v", n, decrypted, err)
+		}
+	}
+}
+
+// TestDecryptAESRejects checks that ciphertexts of the wrong length or with any padding byte
+// wrong are refused
+func TestDecryptAESRejects(t *testing.T) {
+	padded := func(data []byte) []byte {
+		return append(bytes.Repeat([]byte{'x'}, aes.BlockSize), data...)
+	}
+	tests := []struct {
+		name       string
+		ciphertext []byte
+	}{
+		{"empty", nil},
+		{"IV only", make([]byte, aes.BlockSize)},
+		{"partial block", make([]byte, 2*aes.BlockSize+1)},
+		{"zero padding", encryptRaw(t, padded(make([]byte, aes.BlockSize)))},
+		{"padding too long", encryptRaw(t, padded(append(make([]byte, aes.BlockSize-1), aes.BlockSize+1)))},
+		{"inconsistent padding", encryptRaw(t, padded(append(bytes.Repeat([]byte{'y'}, aes.BlockSize-3), 1, 3, 3)))},
+		{"first padding byte wrong", encryptRaw(t, padded(append(bytes.Repeat([]byte{'y'}, aes.BlockSize-4), 5, 4, 4, 4)))},
+	}
+	for _, tt := range tests {
+		if plaintext, err := DecryptAES(testKey, tt.ciphertext); err == nil {
+			t.Errorf("%s: decrypted %q", tt.name, plaintext)
+		}
+	}
+	if _, err := DecryptAES(testKey, encryptRaw(t, padded(append(bytes.Repeat([]byte{'y'}, aes.BlockSize-3), 3, 3, 3)))); err != nil {
+		t.Errorf("valid padding: %v", err)
+	}
+}
+
+// TestEncryptXOR checks that XOR with the same key decrypts
+func TestEncryptXOR(t *testing.T) {
+	key := []byte{1, 2, 3, 4, 5, 6}
+	ciphertext := EncryptXOR([]byte("hello"), key)
+	if bytes.Equal(ciphertext, []byte("hello")) {
+		t.Error("the ciphertext equals the plaintext")
+	}
+	if got := EncryptXOR(ciphertext, key); string(got) != "hello" {
+		t.Errorf("decrypted %q", got)
+	}
+}
+
+// TestMAC checks that MAC keys are derived with the context rather than used as is, and that a MAC
+// only checks under the same key and ciphertext
+func TestMAC(t *testing.T) {
+	material := bytes.Repeat([]byte{9}, MACSize)
+	key := MACKey(material)
+	if len(key) != MACSize || bytes.Equal(key, material) {
+		t.Fatalf("MAC key %x from %x", key, material)
+	}
+	if raw := sha256.Sum256(material); bytes.Equal(key, raw[:]) {
+		t.Error("the MAC key is the plain SHA-256 of the key material")
+	}
+	if !bytes.Equal(key, MACKey(material)) {
+		t.Error("MACKey is not deterministic")
+	}
+	mac := SumMAC(key, []byte("ciphertext"))
+	tests := []struct {
+		name       string
+		key        []byte
+		ciphertext string
+		mac        []byte
+		want       bool
+	}{
+		{"genuine", key, "ciphertext", mac, true},
+		{"other key", MACKey([]byte("other")), "ciphertext", mac, false},
+		{"altered ciphertext", key, "ciphertexT", mac, false},
+		{"truncated MAC", key, "ciphertext", mac[:MACSize-1], false},
+		{"no MAC", key, "ciphertext", nil, false},
+	}
+	for _, tt := range tests {
+		if got := CheckMAC(tt.key, []byte(tt.ciphertext), tt.mac); got != tt.want {
+			t.Errorf("%s: CheckMAC = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+// TestSharedKey checks that both ends of the exchange derive the same key, and that malformed
+// public keys are refused
+func TestSharedKey(t *testing.T) {
+	client, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ours, err := SharedKey(client, hex.EncodeToString(server.PublicKey().Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	theirs, err := SharedKey(server, hex.EncodeToString(client.PublicKey().Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ours) != 32 || !bytes.Equal(ours, theirs) {
+		t.Errorf("client derived %x, server derived %x", ours, theirs)
+	}
+	for _, invalid := range []string{"not hex", "04", hex.EncodeToString(make([]byte, 65))} {
+		if _, err := SharedKey(client, invalid); err == nil {
+			t.Errorf("accepted public key %q", invalid)
+		}
+	}
+}
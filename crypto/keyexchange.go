@@ -0,0 +1,34 @@
+// keyexchange.go
+// Package crypto derives the secret shared with the server from an ECDH P-256 key exchange.
+
+package crypto
+
+import (
+	"crypto/ecdh"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+)
+
+// SharedKey computes the ECDH shared secret with the server's hex-encoded public key and hashes
+// it with SHA-256 into the symmetric key used for broadcasts
+func SharedKey(clientPrivKey *ecdh.PrivateKey, pubKeyHex string) ([]byte, error) {
+	// Parse the server's public key
+	serverPubKeyBytes, err := hex.DecodeString(pubKeyHex)
+	if err != nil {
+		return nil, fmt.Errorf("error decoding server's public key: %v", err)
+	}
+	serverPubKey, err := ecdh.P256().NewPublicKey(serverPubKeyBytes)
+	if err != nil {
+		return nil, fmt.Errorf("error creating server's public key: %v", err)
+	}
+
+	// Compute shared secret using ECDH
+	sharedSecret, err := clientPrivKey.ECDH(serverPubKey)
+	if err != nil {
+		return nil, fmt.Errorf("error computing shared secret: %v", err)
+	}
+	// Hash the shared secret to derive a symmetric key
+	hashedSecret := sha256.Sum256(sharedSecret)
+	return hashedSecret[:], nil
+}
@@ -0,0 +1,121 @@
+// lines.go
+// Package protocol reads and parses the pad server's line protocol, so other Go programs can speak
+// it without the padclient UI.
+
+package protocol
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+)
+
+// DefaultMaxLineLength is the longest line accepted by default, in bytes
+const DefaultMaxLineLength = 64 * 1024
+
+// readBufferSize is the size of the pooled read buffers; lines that fit are read without copying
+const readBufferSize = 16 * 1024
+
+// readerPool holds read buffers released by finished connections
+var readerPool = sync.Pool{
+	New: func() any { return bufio.NewReaderSize(nil, readBufferSize) },
+}
+
+// ErrLineTooLong is returned for a line longer than the reader's limit; the rest of the line is
+// discarded, so the next read starts on the following line
+type ErrLineTooLong struct {
+	Length int // Length of the whole line, in bytes
+	Limit  int // Longest line the reader accepts, in bytes
+}
+
+func (e ErrLineTooLong) Error() string {
+	return fmt.Sprintf("line of %d bytes exceeds the %d-byte limit", e.Length, e.Limit)
+}
+
+// LineReader reads protocol lines through a pooled read buffer, so a malicious server cannot
+// exhaust memory. A line that fits in the buffer costs a single allocation, for the returned
+// string; longer lines are assembled in a scratch buffer kept for the next one.
+type LineReader struct {
+	reader    *bufio.Reader
+	scratch   []byte
+	maxLength int
+}
+
+// NewLineReader returns a line reader for r that accepts lines of up to maxLength bytes.
+// Call Release once it is no longer used.
+func NewLineReader(r io.Reader, maxLength int) *LineReader {
+	reader := readerPool.Get().(*bufio.Reader)
+	reader.Reset(r)
+	return &LineReader{reader: reader, maxLength: maxLength}
+}
+
+// Release returns the read buffer to the pool
+func (l *LineReader) Release() {
+	l.reader.Reset(nil)
+	readerPool.Put(l.reader)
+	l.reader, l.scratch = nil, nil
+}
+
+// Next reads one line without its line ending. It never keeps more than the limit and reports an
+// overlong line as ErrLineTooLong.
+func (l *LineReader) Next() (string, error) {
+	chunk, err := l.reader.ReadSlice('\n')
+	if err == nil && len(chunk) <= l.maxLength {
+		// The common case: the whole line is in the read buffer
+		return string(bytes.TrimRight(chunk, "\r\n")), nil
+	}
+	length := len(chunk)
+	l.scratch = append(l.scratch[:0], chunk...)
+	for errors.Is(err, bufio.ErrBufferFull) {
+		chunk, err = l.reader.ReadSlice('\n')
+		length += len(chunk)
+		if length <= l.maxLength {
+			l.scratch = append(l.scratch, chunk...)
+		}
+	}
+	switch {
+	case err == nil && length > l.maxLength:
+		return "", ErrLineTooLong{Length: length, Limit: l.maxLength}
+	case err == nil:
+		return string(bytes.TrimRight(l.scratch, "\r\n")), nil
+	default:
+		return "", err
+	}
+}
+
+// Message is a MESSAGE or BROADCAST line relayed from another client, still encrypted
+type Message struct {
+	Sender    string // Client ID of the sender
+	Broadcast bool   // Sent to ALL rather than directly
+	Payload   string // Encrypted payload: ciphertext hex, key_hex|ciphertext_hex, or a pad reference
+}
+
+// IsMessage reports whether line relays a message from another client
+func IsMessage(line string) bool {
+	return strings.HasPrefix(line, "MESSAGE from") || strings.HasPrefix(line, "BROADCAST from")
+}
+
+// ParseMessage parses "MESSAGE from <ID>: <payload>" or "BROADCAST from <ID>: <payload>"
+func ParseMessage(line string) (Message, bool) {
+	senderInfo, payload, ok := strings.Cut(line, ": ")
+	if !ok || !IsMessage(line) {
+		return Message{}, false
+	}
+	sender, broadcast := strings.CutPrefix(senderInfo, "BROADCAST from ")
+	if !broadcast {
+		sender = strings.TrimPrefix(senderInfo, "MESSAGE from ")
+	}
+	return Message{Sender: sender, Broadcast: broadcast, Payload: payload}, true
+}
+
+// Source returns the line type the message arrived as, MESSAGE or BROADCAST
+func (m Message) Source() string {
+	if m.Broadcast {
+		return "BROADCAST"
+	}
+	return "MESSAGE"
+}
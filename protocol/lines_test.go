@@ -0,0 +1,78 @@
+package protocol
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+// TestLineReader reads lines of various lengths, including ones longer than the read buffer, and
+// checks that an overlong line is reported and skipped so the next line still reads
+func TestLineReader(t *testing.T) {
+	long := strings.Repeat("a", readBufferSize+10)
+	tooLong := strings.Repeat("b", 2*readBufferSize)
+	input := "one\r\ntwo\n\n" + long + "\n" + tooLong + "\nthree\n" + "unterminated"
+	reader := NewLineReader(strings.NewReader(input), readBufferSize+100)
+	defer reader.Release()
+	tests := []struct {
+		want    string
+		tooLong bool
+	}{
+		{"one", false},
+		{"two", false},
+		{"", false},
+		{long, false},
+		{"", true},
+		{"three", false},
+	}
+	for i, tt := range tests {
+		line, err := reader.Next()
+		var lineErr ErrLineTooLong
+		if tt.tooLong {
+			if !errors.As(err, &lineErr) || lineErr.Length != len(tooLong)+1 || lineErr.Limit != readBufferSize+100 {
+				t.Errorf("line %d: got %d bytes, %v; want ErrLineTooLong", i, len(line), err)
+			}
+			continue
+		}
+		if err != nil || line != tt.want {
+			t.Errorf("line %d: got %d bytes, %v; want %d bytes", i, len(line), err, len(tt.want))
+		}
+	}
+	// A line cut off by the end of the stream is not returned as a whole line
+	if line, err := reader.Next(); err != io.EOF {
+		t.Errorf("unterminated line: got %q, %v; want io.EOF", line, err)
+	}
+}
+
+// TestParseMessage parses relayed messages and refuses other lines
+func TestParseMessage(t *testing.T) {
+	tests := []struct {
+		line string
+		want Message
+		ok   bool
+	}{
+		{"MESSAGE from bob: 00|11", Message{Sender: "bob", Payload: "00|11"}, true},
+		{"BROADCAST from carol: abcd", Message{Sender: "carol", Broadcast: true, Payload: "abcd"}, true},
+		{"MESSAGE from bob: a: b", Message{Sender: "bob", Payload: "a: b"}, true},
+		{"MESSAGE from bob:00", Message{}, false},
+		{"ERROR Recipient not found", Message{}, false},
+		{"NOTICE MESSAGE from bob: 00", Message{}, false},
+		{"", Message{}, false},
+	}
+	for _, tt := range tests {
+		got, ok := ParseMessage(tt.line)
+		if ok != tt.ok || got != tt.want {
+			t.Errorf("ParseMessage(%q) = %+v, %v; want %+v, %v", tt.line, got, ok, tt.want, tt.ok)
+		}
+		if ok && IsMessage(tt.line) != true {
+			t.Errorf("IsMessage(%q) = false", tt.line)
+		}
+	}
+	if source := (Message{Broadcast: true}).Source(); source != "BROADCAST" {
+		t.Errorf("broadcast source %q", source)
+	}
+	if source := (Message{}).Source(); source != "MESSAGE" {
+		t.Errorf("direct message source %q", source)
+	}
+}
@@ -0,0 +1,93 @@
+// payload.go
+// Package protocol encodes the encrypted payloads carried by SEND, MESSAGE, and BROADCAST lines.
+// Each binary field is hex, or base64 on servers with the BASE64 extension, which cuts the size
+// of large messages on the wire from 2x to 4/3 of the ciphertext.
+
+package protocol
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/drewwalton19216801/padclient/crypto"
+)
+
+// Base64Prefix marks a payload field encoded in base64; fields without it are hex. Hex never
+// contains a colon, so both can be told apart in any line.
+const Base64Prefix = "b64:"
+
+// Encoder encodes one binary field of an encrypted payload for the wire
+type Encoder func([]byte) string
+
+// EncodeHex encodes a field in hex, which every server understands
+func EncodeHex(data []byte) string {
+	return hex.EncodeToString(data)
+}
+
+// EncodeBase64 encodes a field in base64 for servers with the BASE64 extension
+func EncodeBase64(data []byte) string {
+	return Base64Prefix + base64.StdEncoding.EncodeToString(data)
+}
+
+// DecodeField decodes a payload field in either encoding
+func DecodeField(field string) ([]byte, error) {
+	if encoded, ok := strings.CutPrefix(field, Base64Prefix); ok {
+		return base64.StdEncoding.DecodeString(encoded)
+	}
+	return hex.DecodeString(field)
+}
+
+// SealBroadcast encrypts text for every client with AES under the secret shared with the server.
+// The payload is the ciphertext, as sent in "SEND ALL <payload>".
+func SealBroadcast(encode Encoder, secret []byte, text string) (string, error) {
+	ciphertext, err := crypto.EncryptAES(secret, []byte(text))
+	if err != nil {
+		return "", fmt.Errorf("error encrypting message: %v", err)
+	}
+	return encode(ciphertext), nil
+}
+
+// OpenBroadcast decrypts the payload of a BROADCAST line sealed by SealBroadcast
+func OpenBroadcast(secret []byte, payload string) ([]byte, error) {
+	ciphertext, err := DecodeField(payload)
+	if err != nil {
+		return nil, fmt.Errorf("error decoding broadcast: %v", err)
+	}
+	plaintext, err := crypto.DecryptAES(secret, ciphertext)
+	if err != nil {
+		return nil, fmt.Errorf("error decrypting broadcast: %v", err)
+	}
+	return plaintext, nil
+}
+
+// SealOneTimeKey encrypts text for one client by XOR with a random key as long as the text. The
+// payload is "<key>|<ciphertext>"; the key is returned too, for describing the cipher.
+func SealOneTimeKey(encode Encoder, text string) (string, []byte, error) {
+	key := make([]byte, len(text))
+	if _, err := rand.Read(key); err != nil {
+		return "", nil, fmt.Errorf("error generating OTP key: %v", err)
+	}
+	ciphertext := crypto.EncryptXOR([]byte(text), key)
+	return encode(key) + "|" + encode(ciphertext), key, nil
+}
+
+// OpenOneTimeKey decrypts the key and ciphertext fields of a payload sealed by SealOneTimeKey,
+// returning the plaintext and the key. The key travels with the message, so the result is only
+// as trustworthy as a signature inside it.
+func OpenOneTimeKey(keyField, ciphertextField string) ([]byte, []byte, error) {
+	key, err := DecodeField(keyField)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error decoding key: %v", err)
+	}
+	ciphertext, err := DecodeField(ciphertextField)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error decoding ciphertext: %v", err)
+	}
+	if len(key) != len(ciphertext) {
+		return nil, nil, fmt.Errorf("key and ciphertext lengths do not match")
+	}
+	return crypto.EncryptXOR(ciphertext, key), key, nil
+}
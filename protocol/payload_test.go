@@ -0,0 +1,115 @@
+package protocol
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// testSecret is a fixed shared secret
+var testSecret = bytes.Repeat([]byte{3}, 32)
+
+// TestDecodeField decodes fields in hex and base64 and refuses malformed ones
+func TestDecodeField(t *testing.T) {
+	tests := []struct {
+		field string
+		want  []byte
+		ok    bool
+	}{
+		{"68656c6c6f", []byte("hello"), true},
+		{Base64Prefix + "aGVsbG8=", []byte("hello"), true},
+		{"", []byte{}, true},
+		{"6", nil, false},
+		{"zz", nil, false},
+		{Base64Prefix + "!!!", nil, false},
+		{"aGVsbG8=", nil, false},
+	}
+	for _, tt := range tests {
+		got, err := DecodeField(tt.field)
+		if (err == nil) != tt.ok || (tt.ok && !bytes.Equal(got, tt.want)) {
+			t.Errorf("DecodeField(%q) = %q, %v", tt.field, got, err)
+		}
+	}
+	for _, encode := range []Encoder{EncodeHex, EncodeBase64} {
+		if got, err := DecodeField(encode([]byte{0, 1, 255})); err != nil || !bytes.Equal(got, []byte{0, 1, 255}) {
+			t.Errorf("round trip: %v, %v", got, err)
+		}
+	}
+}
+
+// TestBroadcastRoundTrip seals broadcasts in both encodings and opens them, and refuses payloads
+// that are malformed or cut short
+func TestBroadcastRoundTrip(t *testing.T) {
+	for _, encoding := range []struct {
+		encode Encoder
+		base64 bool
+	}{{EncodeHex, false}, {EncodeBase64, true}} {
+		payload, err := SealBroadcast(encoding.encode, testSecret, "hello everyone")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.HasPrefix(payload, Base64Prefix) != encoding.base64 {
+			t.Errorf("payload %q is not in the requested encoding", payload)
+		}
+		plaintext, err := OpenBroadcast(testSecret, payload)
+		if err != nil || string(plaintext) != "hello everyone" {
+			t.Errorf("opened %q, %v", plaintext, err)
+		}
+	}
+	if _, err := SealBroadcast(EncodeHex, []byte("short"), "hello"); err == nil {
+		t.Error("sealed a broadcast under an invalid key")
+	}
+	valid, err := SealBroadcast(EncodeHex, testSecret, "hello")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		payload string
+		want    string
+	}{
+		{"not hex", "xyz", "error decoding broadcast"},
+		{"bad base64", Base64Prefix + "%%", "error decoding broadcast"},
+		{"too short", "00112233", "error decrypting broadcast"},
+		{"truncated", valid[:len(valid)-32], "error decrypting broadcast"},
+	}
+	for _, tt := range tests {
+		if _, err := OpenBroadcast(testSecret, tt.payload); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: got %v, want an error containing %q", tt.name, err, tt.want)
+		}
+	}
+}
+
+// TestOneTimeKeyRoundTrip seals direct messages in both encodings and opens them, and refuses
+// payloads with malformed fields or a key that does not match the ciphertext
+func TestOneTimeKeyRoundTrip(t *testing.T) {
+	for _, encode := range []Encoder{EncodeHex, EncodeBase64} {
+		payload, key, err := SealOneTimeKey(encode, "hello bob")
+		if err != nil {
+			t.Fatal(err)
+		}
+		keyField, ciphertextField, ok := strings.Cut(payload, "|")
+		if !ok || len(key) != len("hello bob") {
+			t.Fatalf("payload %q with a %d-byte key", payload, len(key))
+		}
+		plaintext, opened, err := OpenOneTimeKey(keyField, ciphertextField)
+		if err != nil || string(plaintext) != "hello bob" || !bytes.Equal(opened, key) {
+			t.Errorf("opened %q with key %x, %v", plaintext, opened, err)
+		}
+	}
+	tests := []struct {
+		name                  string
+		keyField, cipherField string
+		want                  string
+	}{
+		{"bad key", "zz", "00", "error decoding key"},
+		{"bad ciphertext", "00", Base64Prefix + "%%", "error decoding ciphertext"},
+		{"short key", "00", "0011", "lengths do not match"},
+		{"long key", "001122", "0011", "lengths do not match"},
+	}
+	for _, tt := range tests {
+		if _, _, err := OpenOneTimeKey(tt.keyField, tt.cipherField); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: got %v, want an error containing %q", tt.name, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+// client.go
+// Package transport sends and receives messages over a Conn with the pad protocol's default
+// ciphers only: AES with the shared secret for broadcasts and a one-time key for direct messages.
+
+package transport
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/drewwalton19216801/padclient/protocol"
+)
+
+// Message is a message from another client, decrypted. padclient wraps the text it sends in
+// headers and a signature, which are left in Text.
+type Message struct {
+	From      string // Client ID of the sender
+	Broadcast bool   // Sent to ALL rather than directly
+	Text      string // Decrypted text
+}
+
+// DecryptError reports a message that could not be decrypted, such as one encrypted with a pad
+// or a key agreed with the sender, which Client does not hold. Receive can be called again.
+type DecryptError struct {
+	From string // Client ID of the sender
+	Err  error
+}
+
+func (e *DecryptError) Error() string {
+	return fmt.Sprintf("message from %s: %v", e.From, e.Err)
+}
+
+func (e *DecryptError) Unwrap() error {
+	return e.Err
+}
+
+// Client sends and receives messages over a Conn. Send may be called from several goroutines,
+// while Receive is called from one.
+type Client struct {
+	conn   *Conn
+	reader *protocol.LineReader
+	encode protocol.Encoder
+	mu     sync.Mutex // Serializes writes
+}
+
+// NewClient returns a client for conn, encoding payloads in hex, which every server understands
+func NewClient(conn *Conn) *Client {
+	return &Client{conn: conn, reader: protocol.NewLineReader(conn, conn.MaxLineLength), encode: protocol.EncodeHex}
+}
+
+// Send encrypts text and sends it to the client with ID to, or to every client if to is "ALL"
+func (c *Client) Send(to, text string) error {
+	var payload string
+	var err error
+	if to == "ALL" {
+		payload, err = protocol.SealBroadcast(c.encode, c.conn.Secret, text)
+	} else {
+		payload, _, err = protocol.SealOneTimeKey(c.encode, text)
+	}
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, err = fmt.Fprintf(c.conn, "SEND %s %s\n", to, payload)
+	return err
+}
+
+// Receive reads server lines until a message from another client arrives and returns it
+// decrypted. Other server lines are skipped. A message it cannot decrypt is reported as a
+// *DecryptError; a failed or closed connection as any other error, after which the read buffer
+// is released and Receive must not be called again.
+func (c *Client) Receive() (Message, error) {
+	for {
+		line, err := c.reader.Next()
+		var tooLong protocol.ErrLineTooLong
+		if errors.As(err, &tooLong) {
+			// The rest of the line was discarded, so the next one can be read
+			continue
+		}
+		if err != nil {
+			c.reader.Release()
+			return Message{}, err
+		}
+		relayed, ok := protocol.ParseMessage(line)
+		if !ok {
+			continue
+		}
+		text, err := c.open(relayed)
+		if err != nil {
+			return Message{}, &DecryptError{From: relayed.Sender, Err: err}
+		}
+		return Message{From: relayed.Sender, Broadcast: relayed.Broadcast, Text: string(text)}, nil
+	}
+}
+
+// open decrypts a relayed payload encrypted with the shared secret or a one-time key
+func (c *Client) open(relayed protocol.Message) ([]byte, error) {
+	keyField, ciphertextField, direct := strings.Cut(relayed.Payload, "|")
+	if !direct {
+		if !relayed.Broadcast {
+			return nil, errors.New("invalid message format")
+		}
+		return protocol.OpenBroadcast(c.conn.Secret, relayed.Payload)
+	}
+	if strings.Contains(ciphertextField, "|") {
+		return nil, errors.New("encrypted with a pad or agreed key")
+	}
+	plaintext, _, err := protocol.OpenOneTimeKey(keyField, ciphertextField)
+	return plaintext, err
+}
+
+// Close closes the connection, which ends a Receive in progress
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
@@ -0,0 +1,123 @@
+package transport
+
+import (
+	"bufio"
+	"crypto/ecdh"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/drewwalton19216801/padclient/crypto"
+	"github.com/drewwalton19216801/padclient/protocol"
+)
+
+// fakeServer answers the handshake on conn as a pad server would and returns the shared secret
+func fakeServer(conn net.Conn, lines *bufio.Scanner) ([]byte, error) {
+	key, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	if !lines.Scan() || lines.Text() != "REGISTER alice" {
+		return nil, fmt.Errorf("expected REGISTER alice, got %q", lines.Text())
+	}
+	fmt.Fprintf(conn, "REGISTERED\nPUBLICKEY\n%s\nEND PUBLICKEY\n", hex.EncodeToString(key.PublicKey().Bytes()))
+	var clientKey string
+	for lines.Scan() && lines.Text() != "END CLIENTPUBKEY" {
+		if lines.Text() != "CLIENTPUBKEY" {
+			clientKey = lines.Text()
+		}
+	}
+	secret, err := crypto.SharedKey(key, clientKey)
+	if err != nil {
+		return nil, err
+	}
+	fmt.Fprintln(conn, "CLIENTPUBKEY_RECEIVED")
+	return secret, nil
+}
+
+// TestClientRoundTrip registers with a fake server, then checks that a broadcast sent by the
+// client decrypts with the server's secret and that broadcasts and one-time key messages from
+// other clients are received decrypted, while a pad message is reported as a DecryptError
+func TestClientRoundTrip(t *testing.T) {
+	server, client := net.Pipe()
+	defer server.Close()
+	lines := bufio.NewScanner(server)
+	secrets := make(chan []byte, 1)
+	go func() {
+		secret, err := fakeServer(server, lines)
+		if err != nil {
+			t.Error(err)
+			server.Close()
+		}
+		secrets <- secret
+	}()
+
+	var verified string
+	conn, err := Handshake(client, "alice", protocol.DefaultMaxLineLength, func(pubKeyHex string) (string, error) {
+		verified = pubKeyHex
+		return "fingerprint", nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	secret := <-secrets
+	if verified == "" || conn.Fingerprint != "fingerprint" || conn.Operator || string(conn.Secret) != string(secret) {
+		t.Fatalf("handshake gave %+v", conn)
+	}
+	c := NewClient(conn)
+	defer c.Close()
+
+	go func() {
+		if err := c.Send("ALL", "hello all"); err != nil {
+			t.Error(err)
+		}
+	}()
+	if !lines.Scan() {
+		t.Fatal("the client sent nothing")
+	}
+	payload, ok := strings.CutPrefix(lines.Text(), "SEND ALL ")
+	if !ok {
+		t.Fatalf("sent %q", lines.Text())
+	}
+	if text, err := protocol.OpenBroadcast(secret, payload); err != nil || string(text) != "hello all" {
+		t.Fatalf("broadcast decrypted to %q, %v", text, err)
+	}
+
+	broadcast, err := protocol.SealBroadcast(protocol.EncodeBase64, secret, "hi from bob")
+	if err != nil {
+		t.Fatal(err)
+	}
+	direct, _, err := protocol.SealOneTimeKey(protocol.EncodeHex, "hi from carol")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go fmt.Fprintf(server, "INFO ignored\nBROADCAST from bob: %s\nMESSAGE from carol: %s\nMESSAGE from dave: pad:p1:0|aa|bb\n", broadcast, direct)
+	for _, want := range []Message{{From: "bob", Broadcast: true, Text: "hi from bob"}, {From: "carol", Text: "hi from carol"}} {
+		msg, err := c.Receive()
+		if err != nil || msg != want {
+			t.Fatalf("received %+v, %v; want %+v", msg, err, want)
+		}
+	}
+	var undecryptable *DecryptError
+	if _, err := c.Receive(); !errors.As(err, &undecryptable) || undecryptable.From != "dave" {
+		t.Fatalf("a pad message gave %v", err)
+	}
+}
+
+// TestHandshakeBanned checks that a banned client gets ErrBanned
+func TestHandshakeBanned(t *testing.T) {
+	server, client := net.Pipe()
+	defer server.Close()
+	go func() {
+		lines := bufio.NewScanner(server)
+		lines.Scan()
+		fmt.Fprintln(server, "BANNED You have been banned by the operator")
+	}()
+	if _, err := Handshake(client, "alice", protocol.DefaultMaxLineLength, nil); !errors.Is(err, ErrBanned) {
+		t.Fatalf("got %v, want ErrBanned", err)
+	}
+}
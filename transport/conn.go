@@ -0,0 +1,134 @@
+// conn.go
+// Package transport connects to a pad server: it registers the client, agrees the shared secret
+// with an ECDH key exchange, and then sends and receives broadcasts and one-time-key direct
+// messages, so other Go programs can talk to padclient users without its UI. Pads, agreed direct
+// message keys, and signatures are padclient's own and are not handled here.
+
+package transport
+
+import (
+	"context"
+	"crypto/ecdh"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/drewwalton19216801/padclient/crypto"
+	"github.com/drewwalton19216801/padclient/protocol"
+)
+
+// ErrBanned reports that the server refused us because we are banned
+var ErrBanned = errors.New("banned by the server")
+
+// VerifyFunc checks the server's hex-encoded public key before the client answers it. It returns
+// the fingerprint to report for the key, or an error to refuse the server.
+type VerifyFunc func(pubKeyHex string) (string, error)
+
+// Conn is a connection to a pad server that has registered the client and agreed the secret
+// shared with the server
+type Conn struct {
+	net.Conn
+	Secret        []byte // Key shared with the server, used for broadcasts
+	Operator      bool   // Whether the server registered us as its operator
+	Fingerprint   string // Fingerprint of the server's key, as the VerifyFunc returned it
+	MaxLineLength int    // Longest line accepted from the server, in bytes
+}
+
+// Dial connects to the server at address and registers as clientID, accepting lines of up to
+// protocol.DefaultMaxLineLength bytes. verify may be nil to accept any server key.
+func Dial(ctx context.Context, address, clientID string, verify VerifyFunc) (*Conn, error) {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return nil, err
+	}
+	c, err := Handshake(conn, clientID, protocol.DefaultMaxLineLength, verify)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// Handshake registers clientID over conn and performs the key exchange, reading lines of up to
+// maxLineLength bytes so a hostile server cannot exhaust memory. The server's key is checked
+// with verify, if set, before the client answers it. The caller closes conn on error.
+func Handshake(conn net.Conn, clientID string, maxLineLength int, verify VerifyFunc) (*Conn, error) {
+	// Generate ECDH key pair for key exchange
+	clientPrivKey, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("error generating ECDH key: %v", err)
+	}
+	clientPubKey := clientPrivKey.PublicKey()
+
+	// Register with the server
+	fmt.Fprintf(conn, "REGISTER %s\n", clientID)
+
+	// Read server response and public key, bounding each line
+	reader := protocol.NewLineReader(conn, maxLineLength)
+	defer reader.Release()
+
+	// Wait for "REGISTERED" response
+	response, err := reader.Next()
+	if err != nil {
+		return nil, fmt.Errorf("error reading server response: %v", err)
+	}
+	response = strings.TrimSpace(response)
+	c := &Conn{Conn: conn, MaxLineLength: maxLineLength}
+	if strings.HasPrefix(response, "BANNED") {
+		return nil, fmt.Errorf("%w: %s", ErrBanned, response)
+	}
+	if response == "REGISTERED as operator" {
+		c.Operator = true
+	} else if response != "REGISTERED" {
+		return nil, fmt.Errorf("failed to register with server: %s", response)
+	}
+
+	// Read the server's public key
+	pubKeyHex := ""
+	for {
+		line, err := reader.Next()
+		if err != nil {
+			return nil, fmt.Errorf("error reading public key from server: %v", err)
+		}
+		line = strings.TrimSpace(line)
+		if line == "END PUBLICKEY" {
+			break
+		}
+		if line == "PUBLICKEY" {
+			continue
+		}
+		pubKeyHex = line
+	}
+
+	// Refuse a server whose key the caller does not trust before answering it
+	if verify != nil {
+		if c.Fingerprint, err = verify(pubKeyHex); err != nil {
+			return nil, err
+		}
+	}
+
+	// Derive the symmetric key from the server's public key
+	if c.Secret, err = crypto.SharedKey(clientPrivKey, pubKeyHex); err != nil {
+		return nil, err
+	}
+
+	// Send the client's public key to the server
+	fmt.Fprintf(conn, "CLIENTPUBKEY\n")
+	fmt.Fprintf(conn, "%s\n", hex.EncodeToString(clientPubKey.Bytes()))
+	fmt.Fprintf(conn, "END CLIENTPUBKEY\n")
+
+	// Wait for confirmation from the server
+	line, err := reader.Next()
+	if err != nil {
+		return nil, fmt.Errorf("error reading server response: %v", err)
+	}
+	if line = strings.TrimSpace(line); line != "CLIENTPUBKEY_RECEIVED" {
+		// Unexpected response from the server
+		return nil, fmt.Errorf("unexpected server response: %s", line)
+	}
+	return c, nil
+}
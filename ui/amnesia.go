@@ -0,0 +1,35 @@
+// amnesia.go
+// Package ui keeps all session state in memory and wipes it on exit when started with -amnesia.
+
+package ui
+
+// amnesia disables everything that writes session data to disk
+var amnesia bool
+
+// wipe overwrites key material and drops all session state held in memory
+func (m *model) wipe() {
+	for i := range m.hashedSecret {
+		m.hashedSecret[i] = 0
+	}
+	m.hashedSecret = nil
+	if m.keys != nil {
+		m.keys.stage(nil)
+		for i := range m.keys.current {
+			m.keys.current[i] = 0
+		}
+	}
+	m.entries = nil
+	m.entryLines = nil
+	m.history = nil
+	m.outbox = nil
+	m.pins = nil
+	m.watched = nil
+	m.buffers = nil
+	m.quarantine = nil
+	m.search = nil
+	m.polls = nil
+	m.responses = nil
+	m.motd = nil
+	m.input.Reset()
+	m.viewport.SetContent("")
+}
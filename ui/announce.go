@@ -0,0 +1,48 @@
+// announce.go
+// Package ui lets operators send announcements that every client shows as a banner.
+
+package ui
+
+import (
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// announceStyle frames operator announcements so they stand out from ordinary messages
+var announceStyle = lipgloss.NewStyle().Border(lipgloss.ThickBorder()).BorderForeground(lipgloss.Color("9")).Bold(true).Padding(0, 1)
+
+func init() {
+	registerCommand("/announce", commandSpec{
+		usage:   "/announce <message>",
+		help:    "Broadcast an announcement shown as a banner on every client (operator only)",
+		minArgs: 1,
+		run: func(m *model, args []string) tea.Cmd {
+			if !m.isOperator {
+				m.appendMessage("Only the server operator can send announcements.")
+				return nil
+			}
+			return m.queueSend("ALL", envelope{body: strings.Join(args, " "), announce: true}.seal())
+		},
+	})
+}
+
+// isOperatorPeer reports whether the roster lists the client as the server operator
+func (m *model) isOperatorPeer(id string) bool {
+	if id == m.clientID {
+		return m.isOperator
+	}
+	info, ok := m.roster[id]
+	return ok && info.Operator
+}
+
+// renderAnnouncement formats an announcement as a banner
+func renderAnnouncement(entry chatEntry) string {
+	from := entry.sender
+	if entry.kind == entryOutgoing {
+		from = "you"
+	}
+	return announceStyle.Render(fmt.Sprintf("Announcement from %s\n%s", from, entry.content))
+}
@@ -0,0 +1,68 @@
+// archive.go
+// Package ui archives conversations: they are hidden from the recipient list until a new message arrives.
+
+package ui
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func init() {
+	registerCommand("/archive", commandSpec{
+		usage: "/archive [conversation]",
+		help:  "Hide a conversation from the recipient list until it gets a new message, or list archived ones",
+		run: func(m *model, args []string) tea.Cmd {
+			if len(args) == 0 {
+				m.listArchived()
+				return nil
+			}
+			conversation := args[0]
+			if conversation == m.clientID {
+				m.appendMessage("You cannot archive your own conversation.")
+				return nil
+			}
+			m.archived[conversation] = true
+			m.updateSuggestions()
+			m.appendMessage(fmt.Sprintf("Archived %s. Its messages stay in the buffer; it returns when a new message arrives.", describeConversation(conversation)))
+			return nil
+		},
+	})
+	registerCommand("/unarchive", commandSpec{
+		usage:   "/unarchive <conversation>",
+		help:    "Return an archived conversation to the recipient list",
+		minArgs: 1,
+		run: func(m *model, args []string) tea.Cmd {
+			if !m.archived[args[0]] {
+				m.appendMessage(fmt.Sprintf("%s is not archived.", args[0]))
+				return nil
+			}
+			m.unarchive(args[0])
+			return nil
+		},
+	})
+}
+
+// unarchive returns a conversation to the recipient list
+func (m *model) unarchive(conversation string) {
+	delete(m.archived, conversation)
+	m.updateSuggestions()
+	m.appendMessage(fmt.Sprintf("%s is no longer archived.", describeConversation(conversation)))
+}
+
+// listArchived shows the archived conversations
+func (m *model) listArchived() {
+	if len(m.archived) == 0 {
+		m.appendMessage("No archived conversations.")
+		return
+	}
+	conversations := make([]string, 0, len(m.archived))
+	for conversation := range m.archived {
+		conversations = append(conversations, conversation)
+	}
+	sort.Strings(conversations)
+	m.appendMessage("Archived: " + strings.Join(conversations, ", "))
+}
@@ -0,0 +1,110 @@
+// bench.go
+// Package ui implements the bench subcommand, which measures how fast the reader decodes
+// server lines and how much it allocates per message.
+
+package ui
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"runtime"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/drewwalton19216801/padclient/protocol"
+)
+
+// runBench feeds generated MESSAGE or BROADCAST lines through the reader over an in-memory
+// connection and reports the message rate and allocations:
+// padclient bench [-messages <n>] [-size <bytes>] [-direct] [-base64]
+func runBench(args []string) error {
+	flags := flag.NewFlagSet("bench", flag.ContinueOnError)
+	count := flags.Int("messages", 100000, "messages to read")
+	size := flags.Int("size", 256, "plaintext size of each message, in bytes")
+	direct := flags.Bool("direct", false, "send direct messages with one-time keys instead of broadcasts")
+	useBase64 := flags.Bool("base64", false, "encode payloads in base64, as with the BASE64 extension, instead of hex")
+	if err := readSubcommandConfig(flags, args); err != nil {
+		return err
+	}
+	if err := flags.Parse(args); err != nil {
+		return &fatalError{code: exitUsage, err: err}
+	}
+	if *count < 1 || *size < 1 || flags.NArg() > 0 {
+		return &fatalError{code: exitUsage, err: errors.New("usage: padclient bench [-messages <n>] [-size <bytes>] [-direct] [-base64]")}
+	}
+
+	secret := make([]byte, 32)
+	plaintext := make([]byte, *size)
+	if _, err := rand.Read(secret); err != nil {
+		return err
+	}
+	for i := range plaintext {
+		plaintext[i] = 'a' + byte(i%26)
+	}
+	encode := protocol.EncodeHex
+	if *useBase64 {
+		encode = protocol.EncodeBase64
+	}
+	line, err := benchLine(encode, secret, plaintext, *direct)
+	if err != nil {
+		return err
+	}
+
+	server, client := net.Pipe()
+	go func() {
+		writer := bufio.NewWriter(server)
+		for i := 0; i < *count; i++ {
+			writer.WriteString(line)
+		}
+		writer.Flush()
+		server.Close()
+	}()
+	messages := make(chan tea.Msg, messageBuffer)
+	go readMessages(context.Background(), client, "bench", newSessionKey(secret), nil, nil, messages)
+
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	start := time.Now()
+	received := 0
+	for msg := range messages {
+		if _, ok := msg.(disconnectMsg); ok {
+			break
+		}
+		if _, ok := msg.(incomingMessage); ok {
+			received++
+		}
+	}
+	elapsed := time.Since(start)
+	runtime.ReadMemStats(&after)
+	if received != *count {
+		return fmt.Errorf("decoded %d of %d messages", received, *count)
+	}
+
+	perMessage := func(total uint64) float64 { return float64(total) / float64(received) }
+	fmt.Printf("Read %d messages of %d bytes (%d-byte lines) in %v\n", received, *size, len(line), elapsed.Round(time.Millisecond))
+	fmt.Printf("%.0f messages/s, %.1f MB/s\n", float64(received)/elapsed.Seconds(), float64(received*len(line))/elapsed.Seconds()/1e6)
+	fmt.Printf("%.1f allocations and %.0f bytes allocated per message\n", perMessage(after.Mallocs-before.Mallocs), perMessage(after.TotalAlloc-before.TotalAlloc))
+	return nil
+}
+
+// benchLine returns one server line carrying plaintext, as a broadcast or as a direct message
+func benchLine(encode protocol.Encoder, secret, plaintext []byte, direct bool) (string, error) {
+	if !direct {
+		payload, err := protocol.SealBroadcast(encode, secret, string(plaintext))
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("BROADCAST from bench: %s\n", payload), nil
+	}
+	payload, _, err := protocol.SealOneTimeKey(encode, string(plaintext))
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("MESSAGE from bench: %s\n", payload), nil
+}
@@ -0,0 +1,107 @@
+// bookmarks.go
+// Package ui lets users bookmark messages and jump back to them from a picker.
+
+package ui
+
+import (
+	"fmt"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func init() {
+	registerCommand("/bookmark", commandSpec{
+		usage:   "/bookmark <n>",
+		help:    "Bookmark the nth most recent message (1 is the latest)",
+		minArgs: 1,
+		run: func(m *model, args []string) tea.Cmd {
+			entry, err := m.messageByNumber(args[0])
+			if err != nil {
+				m.appendMessage(err.Error())
+				return nil
+			}
+			m.toggleBookmark(entry.seq)
+			return nil
+		},
+	})
+	registerCommand("/bookmarks", commandSpec{
+		usage: "/bookmarks",
+		help:  "Pick a bookmarked message to jump to",
+		run: func(m *model, args []string) tea.Cmd {
+			m.openBookmarkPicker()
+			return nil
+		},
+	})
+}
+
+// bookmarkVisible bookmarks the message at the top of the viewport, or the latest message when
+// the viewport is scrolled to the bottom.
+func (m *model) bookmarkVisible() {
+	index := -1
+	if m.viewport.AtBottom() {
+		for i := len(m.entries) - 1; i >= 0; i-- {
+			if m.entries[i].kind != entrySystem {
+				index = i
+				break
+			}
+		}
+	} else {
+		index = m.entryAtLine(m.viewport.YOffset)
+	}
+	if index < 0 {
+		m.flash = "No message to bookmark."
+		return
+	}
+	m.toggleBookmark(m.entries[index].seq)
+}
+
+// toggleBookmark adds or removes the entry from the jump list
+func (m *model) toggleBookmark(seq int) {
+	for i, bookmarked := range m.bookmarks {
+		if bookmarked == seq {
+			m.bookmarks = append(m.bookmarks[:i], m.bookmarks[i+1:]...)
+			m.flash = "Bookmark removed."
+			return
+		}
+	}
+	m.bookmarks = append(m.bookmarks, seq)
+	m.flash = fmt.Sprintf("Bookmarked. %d bookmark(s); type /bookmarks to jump.", len(m.bookmarks))
+}
+
+// openBookmarkPicker opens a picker over the bookmarks that scrolls to the chosen message
+func (m *model) openBookmarkPicker() {
+	var labels []string
+	var seqs []int
+	for _, seq := range m.bookmarks {
+		index := m.entryBySeq(seq)
+		if index < 0 {
+			continue
+		}
+		entry := m.entries[index]
+		labels = append(labels, fmt.Sprintf("[%s] %s", entry.at.Format("Jan 2 15:04"), entry.render()))
+		seqs = append(seqs, seq)
+	}
+	if len(labels) == 0 {
+		m.appendMessage("No bookmarks. Press Alt+B or use /bookmark <n> to add one.")
+		return
+	}
+	m.openPicker("Jump to bookmark", labels, func(m *model, candidate int) {
+		m.jumpToEntry(seqs[candidate])
+	})
+}
+
+// jumpToEntry scrolls the viewport so the entry is at the top
+func (m *model) jumpToEntry(seq int) {
+	if m.renderPending {
+		m.flushRender()
+	}
+	index := m.entryBySeq(seq)
+	if index < 0 || index >= len(m.entryLines) {
+		m.appendMessage("That message is no longer in the buffer.")
+		return
+	}
+	if !m.inActiveTab(m.entries[index]) {
+		m.switchTab(m.entries[index].conversation())
+	}
+	m.viewport.SetYOffset(m.entryLines[index])
+}
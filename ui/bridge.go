@@ -0,0 +1,169 @@
+// bridge.go
+// Package ui serves the daemon's optional HTTP bridge on a loopback address: POST /send sends a
+// message and GET /events streams events as Server-Sent Events, for web dashboards and shortcut apps.
+
+package ui
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// bridgeHeartbeat is how often an idle event stream sends a comment to keep the connection open
+const bridgeHeartbeat = 30 * time.Second
+
+// maxBridgeBody is the largest request body POST /send accepts
+const maxBridgeBody = 64 * 1024
+
+// bridgeSendRequest is the JSON body of POST /send
+type bridgeSendRequest struct {
+	To   string `json:"to"`
+	Text string `json:"text"`
+}
+
+// isLoopbackHost reports whether host names this machine only
+func isLoopbackHost(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// serveBridge starts the HTTP bridge on a loopback address until ctx ends
+func serveBridge(ctx context.Context, d *daemon, addr string) error {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return err
+	}
+	if !isLoopbackHost(host) {
+		return fmt.Errorf("the HTTP bridge only listens on loopback addresses, not %s", host)
+	}
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/send", d.bridgeAuth(d.serveBridgeSend))
+	mux.HandleFunc("/events", d.bridgeAuth(d.serveBridgeEvents))
+	server := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		<-ctx.Done()
+		server.Close()
+	}()
+	go server.Serve(listener)
+	return nil
+}
+
+// bridgeAuth rejects requests without the daemon's token, given as "Authorization: Bearer <token>"
+// or, for EventSource clients that cannot set headers, as ?token=. Requests naming another host
+// are rejected too, so a web page cannot reach the bridge through DNS rebinding.
+func (d *daemon) bridgeAuth(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		if !isLoopbackHost(strings.Trim(host, "[]")) {
+			writeBridgeError(w, http.StatusForbidden, errors.New("requests must be addressed to localhost"))
+			return
+		}
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok {
+			token = r.URL.Query().Get("token")
+		}
+		if !d.validToken(token) {
+			writeBridgeError(w, http.StatusUnauthorized, errors.New("invalid token"))
+			return
+		}
+		next(w, r)
+	}
+}
+
+// serveBridgeSend handles POST /send with a JSON body {"to": "<ID|ALL>", "text": "..."}
+func (d *daemon) serveBridgeSend(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		writeBridgeError(w, http.StatusMethodNotAllowed, errors.New("use POST"))
+		return
+	}
+	var request bridgeSendRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBridgeBody)).Decode(&request); err != nil {
+		writeBridgeError(w, http.StatusBadRequest, fmt.Errorf("invalid JSON body: %v", err))
+		return
+	}
+	if request.To == "" || strings.TrimSpace(request.Text) == "" {
+		writeBridgeError(w, http.StatusBadRequest, errors.New("to and text are required"))
+		return
+	}
+	info, err := d.send(request.To, request.Text)
+	if err != nil {
+		writeBridgeError(w, http.StatusBadGateway, err)
+		return
+	}
+	writeBridgeJSON(w, http.StatusOK, SendReply{Cipher: info.cipher})
+}
+
+// serveBridgeEvents handles GET /events, streaming events as Server-Sent Events. A reconnecting
+// client's Last-Event-ID resumes the stream where it left off.
+func (d *daemon) serveBridgeEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeBridgeError(w, http.StatusInternalServerError, errors.New("streaming is not supported"))
+		return
+	}
+	next := d.nextEventSeq()
+	if last, err := strconv.ParseInt(r.Header.Get("Last-Event-ID"), 10, 64); err == nil {
+		next = last + 1
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(bridgeHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		events, changed := d.eventsAfter(next)
+		for _, event := range events {
+			data, err := json.Marshal(event)
+			if err != nil {
+				return
+			}
+			// Format: id, event type, and one line of JSON data, ended by a blank line
+			if _, err := fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.Seq, event.Kind, data); err != nil {
+				return
+			}
+			next = event.Seq + 1
+		}
+		flusher.Flush()
+		select {
+		case <-changed:
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+				return
+			}
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// writeBridgeJSON writes a JSON response
+func writeBridgeJSON(w http.ResponseWriter, status int, value any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(value)
+}
+
+// writeBridgeError writes a JSON error response: {"error": "..."}
+func writeBridgeError(w http.ResponseWriter, status int, err error) {
+	writeBridgeJSON(w, status, map[string]string{"error": err.Error()})
+}
@@ -0,0 +1,86 @@
+// broadcast.go
+// Package ui broadcasts messages to everyone except chosen clients.
+
+package ui
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// exceptPrefix marks a broadcast recipient that excludes some clients, e.g. ALL-EXCEPT:bob,carol
+const exceptPrefix = "ALL-EXCEPT:"
+
+func init() {
+	registerCommand("/broadcast-except", commandSpec{
+		usage:   "/broadcast-except <ID,ID...> <message>",
+		help:    "Broadcast a message to everyone except the listed clients",
+		minArgs: 2,
+		run: func(m *model, args []string) tea.Cmd {
+			var excluded []string
+			for _, id := range strings.Split(args[0], ",") {
+				if id = strings.TrimSpace(id); id != "" {
+					excluded = append(excluded, id)
+				}
+			}
+			if len(excluded) == 0 {
+				m.appendMessage("Usage: /broadcast-except <ID,ID...> <message>")
+				return nil
+			}
+			return m.broadcastExcept(excluded, strings.Join(args[1:], " "))
+		},
+	})
+}
+
+// parseExcept returns the clients excluded by a broadcast recipient, reporting whether it is one
+func parseExcept(recipientID string) ([]string, bool) {
+	list, ok := strings.CutPrefix(recipientID, exceptPrefix)
+	if !ok {
+		return nil, false
+	}
+	return strings.Split(list, ","), true
+}
+
+// describeRecipient formats a recipient for display
+func describeRecipient(recipientID string) string {
+	if excluded, ok := parseExcept(recipientID); ok {
+		return "ALL except " + strings.Join(excluded, ", ")
+	}
+	return recipientID
+}
+
+// broadcastExcept sends a message to everyone but the excluded clients. Servers supporting the
+// EXCEPT extension do the filtering; otherwise the message is sent to each client on the roster.
+func (m *model) broadcastExcept(excluded []string, messageText string) tea.Cmd {
+	if m.serverCaps["EXCEPT"] {
+		return m.queueSend(exceptPrefix+strings.Join(excluded, ","), messageText)
+	}
+	skip := map[string]bool{m.clientID: true}
+	for _, id := range excluded {
+		skip[id] = true
+	}
+	var recipients []string
+	for id := range m.roster {
+		if !skip[id] {
+			recipients = append(recipients, id)
+		}
+	}
+	if len(m.roster) == 0 {
+		m.appendMessage("The roster is empty, so there is no one to send to. Run LIST first.")
+		return nil
+	}
+	if len(recipients) == 0 {
+		m.appendMessage("Everyone on the roster is excluded.")
+		return nil
+	}
+	sort.Strings(recipients)
+	m.appendMessage(fmt.Sprintf("The server cannot exclude recipients; sending to %d client(s) individually.", len(recipients)))
+	cmds := make([]tea.Cmd, 0, len(recipients))
+	for _, id := range recipients {
+		cmds = append(cmds, m.queueSend(id, messageText))
+	}
+	return tea.Batch(cmds...)
+}
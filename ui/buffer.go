@@ -0,0 +1,327 @@
+// buffer.go
+// Package ui stores the conversation as structured entries and renders them into the viewport.
+
+package ui
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/x/ansi"
+)
+
+// entryKind identifies what produced a buffer entry
+type entryKind int
+
+const (
+	entrySystem    entryKind = iota // Client or server notice
+	entryDirect                     // Direct message from another client
+	entryBroadcast                  // Broadcast from another client
+	entryOutgoing                   // Message we sent
+)
+
+// deliveryStatus tracks an outgoing message until the server acknowledges it
+type deliveryStatus int
+
+const (
+	statusNone      deliveryStatus = iota // Not an outgoing message
+	statusQueued                          // Waiting out the undo window
+	statusPending                         // Written to the server, waiting for an ACK
+	statusDelivered                       // Acknowledged by the server
+	statusFailed                          // Rejected by the server
+	statusCancelled                       // Cancelled with /undo
+)
+
+// chatEntry is a single line of the conversation buffer
+type chatEntry struct {
+	seq           int             // Buffer sequence number, unique for the session
+	kind          entryKind       // What produced the entry
+	sender        string          // Sender ID for incoming messages
+	recipient     string          // Recipient ID for outgoing messages
+	content       string          // Message text
+	at            time.Time       // When the entry was added
+	status        deliveryStatus  // Delivery state for outgoing messages
+	outboxID      int             // Outbox sequence number for outgoing messages
+	expanded      bool            // Whether a long entry is shown in full
+	highlight     bool            // Whether the entry mentions us or matches a watch keyword
+	folded        bool            // Whether a filter rule folded the entry into a placeholder
+	color         string          // Color set by a filter rule
+	repeats       []time.Time     // Arrival times of identical copies collapsed into this entry
+	revealed      bool            // Whether masked words are shown for this entry
+	translation   string          // Translation requested with /translate
+	info          cipherInfo      // How the message was encrypted
+	forwardedFrom string          // Original sender of a forwarded message
+	thread        string          // Key of the thread the message replies in
+	pollID        string          // ID of the poll the message asks
+	announcement  bool            // Whether the message is an operator announcement
+	signature     signatureStatus // Result of checking an incoming message's signature
+	signer        string          // Fingerprint of the identity key that signed an incoming message
+}
+
+// appendMessage adds a notice to the viewport and updates the content
+func (m *model) appendMessage(msg string) {
+	m.appendEntry(chatEntry{kind: entrySystem, content: msg})
+}
+
+// appendEntry adds an entry to the buffer and scrolls to show it
+func (m *model) appendEntry(entry chatEntry) {
+	if entry.at.IsZero() {
+		entry.at = time.Now()
+	}
+	m.entrySeq++
+	entry.seq = m.entrySeq
+	// The view jumps to the bottom, so history paged in from the archive is no longer needed
+	if m.pagedIn > 0 {
+		m.entries = m.entries[m.pagedIn:]
+		m.pagedIn = 0
+	}
+	m.entries = append(m.entries, entry)
+	if entry.kind != entryOutgoing {
+		// Outgoing messages are saved once they are sent, so cancelled ones are not kept
+		m.saveHistory(entry)
+	}
+	m.trackTab(entry)
+	m.evictScrollback()
+	m.requestRender(m.inActiveTab(entry))
+}
+
+// refreshViewport re-renders every entry into the viewport
+func (m *model) refreshViewport() {
+	m.lastRender = time.Now()
+	lines := make([]string, 0, len(m.entries))
+	m.entryLines = m.entryLines[:0]
+	lineCount := 0
+	parents, replies := m.threadIndex()
+	prev := -1 // Index of the previous entry shown in place
+	for i, entry := range m.entries {
+		if !m.inActiveTab(entry) {
+			// Shown in another tab
+			m.entryLines = append(m.entryLines, lineCount)
+			continue
+		}
+		parent, isReply := parents[entry.thread]
+		isReply = isReply && parent < i
+		if isReply && m.threadView {
+			// Shown under the message it replies to
+			m.entryLines = append(m.entryLines, lineCount)
+			continue
+		}
+		// Separate entries from different days
+		if prev >= 0 && !sameDay(m.entries[prev].at, entry.at) {
+			lines = append(lines, daySeparator(entry.at))
+			lineCount++
+		}
+		// Mask listed words unless the user revealed this entry
+		if entry.kind != entrySystem && !entry.revealed {
+			entry.content, _ = m.mask.apply(entry.content)
+		}
+		var line string
+		continuation := prev >= 0 && continuesGroup(m.entries[prev], entry)
+		if continuation {
+			line = entry.renderContinuation()
+		} else {
+			line = entry.render()
+		}
+		// Collapse long entries to a preview until they are expanded
+		if !entry.expanded {
+			if entry.folded {
+				line = fmt.Sprintf("(folded message from %s, Ctrl+E to expand)", entry.sender)
+			} else if preview, collapsed := collapseLines(line, m.viewport.Width); collapsed {
+				line = preview
+			}
+		}
+		if entry.announcement {
+			line = renderAnnouncement(entry)
+		}
+		line = entry.renderRepeats(line)
+		if entry.translation != "" {
+			line += "\n  ↳ translation: " + entry.translation
+		}
+		if entry.pollID != "" {
+			line += m.pollTally(entry.pollID)
+		}
+		if isReply {
+			line += "\n  ↪ in reply to " + threadPreview(m.entries[parent])
+		} else if m.threadView {
+			for _, j := range replies[i] {
+				line += m.renderReply(m.entries[j])
+			}
+		}
+		if entry.color != "" {
+			line = colorStyle(entry.color).Render(line)
+		}
+		if entry.highlight {
+			line = highlightStyle.Render(line)
+		}
+		// Added after the styles above, which its own colors would otherwise cut short
+		line = entry.identicon(continuation) + line
+		if m.viewport.Width > 0 {
+			// Wrap to the viewport, which would otherwise cut long lines off
+			line = ansi.Wrap(line, m.viewport.Width, "")
+		}
+		m.entryLines = append(m.entryLines, lineCount)
+		lineCount += strings.Count(line, "\n") + 1
+		lines = append(lines, line)
+		prev = i
+	}
+	m.viewport.SetContent(strings.Join(lines, "\n"))
+}
+
+// entryBySeq returns the index of the entry with the given sequence number, or -1
+func (m *model) entryBySeq(seq int) int {
+	for i := len(m.entries) - 1; i >= 0; i-- {
+		if m.entries[i].seq == seq {
+			return i
+		}
+	}
+	return -1
+}
+
+// entryAtLine returns the index of the entry shown at the given viewport line
+func (m *model) entryAtLine(line int) int {
+	index := -1
+	for i, start := range m.entryLines {
+		if start > line {
+			break
+		}
+		index = i
+	}
+	return index
+}
+
+// collapseThreshold is the number of display lines above which an entry is collapsed
+const collapseThreshold = 8
+
+// collapsePreviewLines is the number of display lines shown for a collapsed entry
+const collapsePreviewLines = 4
+
+// displayLines splits rendered text into the lines it occupies at the given width
+func displayLines(text string, width int) []string {
+	var lines []string
+	for _, line := range strings.Split(text, "\n") {
+		runes := []rune(line)
+		for width > 0 && len(runes) > width {
+			lines = append(lines, string(runes[:width]))
+			runes = runes[width:]
+		}
+		lines = append(lines, string(runes))
+	}
+	return lines
+}
+
+// collapseLines shortens text longer than collapseThreshold display lines to a preview,
+// reporting whether it was collapsed.
+func collapseLines(text string, width int) (string, bool) {
+	lines := displayLines(text, width)
+	if len(lines) <= collapseThreshold {
+		return text, false
+	}
+	hidden := len(lines) - collapsePreviewLines
+	preview := append(lines[:collapsePreviewLines:collapsePreviewLines],
+		fmt.Sprintf("… %d more lines (Ctrl+E to expand)", hidden))
+	return strings.Join(preview, "\n"), true
+}
+
+// expandLatestCollapsed shows the most recent collapsed or folded entry in full
+func (m *model) expandLatestCollapsed() {
+	for i := len(m.entries) - 1; i >= 0; i-- {
+		entry := &m.entries[i]
+		if entry.expanded {
+			continue
+		}
+		if _, collapsed := collapseLines(entry.render(), m.viewport.Width); collapsed || entry.folded || len(entry.repeats) > 0 {
+			entry.expanded = true
+			m.refreshViewport()
+			return
+		}
+	}
+}
+
+// groupWindow is how close together consecutive messages from one sender must be to share a prefix
+const groupWindow = 2 * time.Minute
+
+// continuesGroup reports whether entry continues a run of messages from the same sender as prev
+func continuesGroup(prev, entry chatEntry) bool {
+	if entry.kind != entryDirect && entry.kind != entryBroadcast {
+		return false
+	}
+	return prev.kind == entry.kind &&
+		prev.sender == entry.sender &&
+		prev.signer == entry.signer &&
+		entry.at.Sub(prev.at) <= groupWindow &&
+		sameDay(prev.at, entry.at)
+}
+
+// renderContinuation formats a grouped entry without repeating the sender prefix
+func (e chatEntry) renderContinuation() string {
+	prefixWidth := len([]rune(e.render())) - len([]rune(e.content))
+	return strings.Repeat(" ", prefixWidth) + e.content
+}
+
+// sameDay reports whether two times fall on the same local calendar day
+func sameDay(a, b time.Time) bool {
+	ay, am, ad := a.Local().Date()
+	by, bm, bd := b.Local().Date()
+	return ay == by && am == bm && ad == bd
+}
+
+// daySeparator returns the line inserted when the conversation crosses into a new day
+func daySeparator(t time.Time) string {
+	return fmt.Sprintf("── %s ──", t.Local().Format("Monday, January 2"))
+}
+
+// render formats the entry as it appears in the viewport
+func (e chatEntry) render() string {
+	switch e.kind {
+	case entryDirect:
+		return fmt.Sprintf("Message from %s%s%s: %s", e.sender, e.signature.mark(), e.forwardNote(), e.content)
+	case entryBroadcast:
+		return fmt.Sprintf("Broadcast from %s%s%s: %s", e.sender, e.signature.mark(), e.forwardNote(), e.content)
+	case entryOutgoing:
+		line := fmt.Sprintf("To %s%s: %s", describeRecipient(e.recipient), e.forwardNote(), e.content)
+		switch e.status {
+		case statusQueued:
+			line += " (queued, /undo to cancel)"
+		case statusPending:
+			line += " (pending)"
+		case statusFailed:
+			line += " (failed)"
+		case statusCancelled:
+			line += " (cancelled)"
+		}
+		return line
+	default:
+		return e.content
+	}
+}
+
+// outgoingEntry returns the buffer entry for an outbox message
+func (m *model) outgoingEntry(outboxID int) *chatEntry {
+	for i := len(m.entries) - 1; i >= 0; i-- {
+		if m.entries[i].kind == entryOutgoing && m.entries[i].outboxID == outboxID {
+			return &m.entries[i]
+		}
+	}
+	return nil
+}
+
+// setDeliveryStatus updates the delivery state shown for an outbox message
+func (m *model) setDeliveryStatus(outboxID int, status deliveryStatus) {
+	if status == statusDelivered || status == statusFailed || status == statusCancelled {
+		// The message no longer needs to survive a crash
+		m.logSettled(outboxID)
+	}
+	if entry := m.outgoingEntry(outboxID); entry != nil {
+		entry.status = status
+		m.requestRender(false)
+	}
+}
+
+// forwardNote returns the annotation shown after the sender or recipient of a forwarded message
+func (e chatEntry) forwardNote() string {
+	if e.forwardedFrom == "" {
+		return ""
+	}
+	return fmt.Sprintf(" (forwarded from %s)", e.forwardedFrom)
+}
@@ -0,0 +1,144 @@
+// bulk.go
+// Package ui keeps bulk traffic such as file transfer chunks from holding up chat messages: bulk
+// lines wait behind interactive ones, and go over a second connection when the server offers one.
+
+package ui
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/drewwalton19216801/padclient/protocol"
+)
+
+// bulkAttachTimeout bounds dialing and attaching the bulk stream
+const bulkAttachTimeout = 10 * time.Second
+
+// bulkOfferMsg carries the token the server issued for attaching a bulk stream: BULK <token>
+type bulkOfferMsg struct {
+	token string
+}
+
+// bulkAttachedMsg reports a bulk stream attached to the session
+type bulkAttachedMsg struct {
+	conn net.Conn
+}
+
+// bulkClosedMsg reports that the bulk stream could not be attached or stopped
+type bulkClosedMsg struct {
+	err error
+}
+
+// parseBulkOffer parses the server's answer to BULK
+func parseBulkOffer(message string) (bulkOfferMsg, bool) {
+	token, ok := strings.CutPrefix(message, "BULK ")
+	if !ok || token == "" || strings.ContainsAny(token, " \t") {
+		return bulkOfferMsg{}, false
+	}
+	return bulkOfferMsg{token: token}, true
+}
+
+// attachBulkStream dials the server again and attaches the connection to the session as its
+// bulk stream: ATTACH <token>, answered by ATTACHED
+func attachBulkStream(token string) tea.Cmd {
+	return func() tea.Msg {
+		conn, err := dialTCP(address, bulkAttachTimeout)
+		if err != nil {
+			return bulkClosedMsg{err}
+		}
+		conn.SetDeadline(time.Now().Add(bulkAttachTimeout))
+		if _, err := fmt.Fprintf(conn, "ATTACH %s\n", token); err != nil {
+			conn.Close()
+			return bulkClosedMsg{err}
+		}
+		reader := protocol.NewLineReader(conn, maxLineLength)
+		defer reader.Release()
+		reply, err := reader.Next()
+		if err != nil {
+			conn.Close()
+			return bulkClosedMsg{err}
+		}
+		if reply = strings.TrimSpace(reply); reply != "ATTACHED" {
+			conn.Close()
+			return bulkClosedMsg{fmt.Errorf("unexpected reply to ATTACH: %s", reply)}
+		}
+		conn.SetDeadline(time.Time{})
+		return bulkAttachedMsg{conn: conn}
+	}
+}
+
+// startBulk adopts an attached bulk stream and returns a command that reports when it stops.
+// The server sends nothing on the stream, so reading only waits for it to close.
+func (m *model) startBulk(conn net.Conn) tea.Cmd {
+	m.closeBulk()
+	ctx, cancel := context.WithCancel(context.Background())
+	m.bulkConn, m.bulkCancel = conn, cancel
+	failures := make(chan tea.Msg, 1)
+	m.bulkWriter = startWriter(ctx, conn, failures)
+	return func() tea.Msg {
+		reader := protocol.NewLineReader(conn, maxLineLength)
+		defer reader.Release()
+		var err error
+		for err == nil {
+			_, err = reader.Next()
+		}
+		if ctx.Err() != nil {
+			// Closed on purpose
+			return nil
+		}
+		select {
+		case msg := <-failures:
+			err = msg.(writeErrorMsg).err
+		default:
+		}
+		return bulkClosedMsg{err}
+	}
+}
+
+// closeBulk shuts the bulk stream down; bulk lines then share the main connection again
+func (m *model) closeBulk() {
+	m.bulkWriter = nil
+	if m.bulkCancel != nil {
+		m.bulkCancel()
+		m.bulkCancel = nil
+	}
+	if m.bulkConn != nil {
+		m.bulkConn.Close()
+		m.bulkConn = nil
+	}
+}
+
+// writeBulk queues a bulk protocol line, such as a file transfer chunk, and reports whether it was
+// queued. It goes over the bulk stream when one is attached, and otherwise over the main
+// connection behind any interactive lines.
+func (m *model) writeBulk(line string) bool {
+	writer := m.bulkWriter
+	if writer == nil {
+		writer = m.writer
+	}
+	if writer == nil {
+		m.appendMessage("Not connected to the server.")
+		return false
+	}
+	select {
+	case writer.bulk <- line:
+		return true
+	default:
+		m.appendMessage("Too many bulk lines are waiting to be sent; try again shortly.")
+		return false
+	}
+}
+
+// bulkStreamClosed reports a bulk stream that failed to attach or stopped
+func (m *model) bulkStreamClosed(err error) {
+	m.closeBulk()
+	if err == nil || errors.Is(err, net.ErrClosed) {
+		err = errors.New("closed by the server")
+	}
+	m.appendMessage(fmt.Sprintf("Bulk stream unavailable (%v); bulk transfers share the main connection.", err))
+}
@@ -0,0 +1,189 @@
+// chanstats.go
+// Package ui implements /chanstats, which charts message counts per conversation, the most
+// active hours, and the top senders from the messages kept locally.
+
+package ui
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// statsBarWidth is the width of the longest bar in a chart, in cells
+const statsBarWidth = 24
+
+// statsTop is how many conversations, hours, and senders each chart lists
+const statsTop = 8
+
+func init() {
+	registerCommand("/chanstats", commandSpec{
+		usage: "/chanstats [conversation]",
+		help:  "Chart message counts, active hours, and top senders from local history, for all conversations or one",
+		run: func(m *model, args []string) tea.Cmd {
+			conversation := ""
+			if len(args) > 0 {
+				conversation = args[0]
+			}
+			m.showChanStats(conversation)
+			return nil
+		},
+	})
+}
+
+// statsEntries calls visit for every message kept locally: the history file when -history is on,
+// since it holds every session including this one, and otherwise this session's scrollback
+// archive and buffer
+func (m *model) statsEntries(visit func(chatEntry)) (string, error) {
+	if m.chatHistory != nil {
+		for i := range m.chatHistory.ids {
+			entry, err := m.chatHistory.read(i)
+			if err != nil {
+				return "", err
+			}
+			visit(entry)
+		}
+		return "history", nil
+	}
+	if m.archive != nil {
+		archived, err := m.archive.read(0, len(m.archive.offsets))
+		if err != nil {
+			return "", fmt.Errorf("error reading the scrollback archive: %v", err)
+		}
+		for _, entry := range archived {
+			visit(entry)
+		}
+	}
+	// Entries paged in from the archive were visited above
+	for _, entry := range m.entries[m.pagedIn:] {
+		visit(entry)
+	}
+	return "this session", nil
+}
+
+// showChanStats charts the messages kept locally, optionally only those of one conversation
+func (m *model) showChanStats(only string) {
+	conversations := make(map[string]int)
+	senders := make(map[string]int)
+	var hours [24]int
+	total := 0
+	source, err := m.statsEntries(func(entry chatEntry) {
+		conversation := entry.conversation()
+		if conversation == "" || entry.status == statusCancelled || entry.status == statusFailed {
+			return
+		}
+		if only != "" && !strings.EqualFold(conversation, only) {
+			return
+		}
+		sender := entry.sender
+		if entry.kind == entryOutgoing {
+			sender = m.clientID
+		}
+		total++
+		conversations[conversation]++
+		senders[sender]++
+		hours[entry.at.Local().Hour()]++
+	})
+	if err != nil {
+		m.appendMessage(err.Error())
+		return
+	}
+	scope := "all conversations"
+	if only != "" {
+		scope = describeConversation(only)
+	}
+	if total == 0 {
+		m.appendMessage(fmt.Sprintf("No messages in %s from %s.", source, scope))
+		return
+	}
+
+	lines := []string{fmt.Sprintf("Statistics for %s, %d message(s) from %s:", scope, total, source)}
+	if only == "" {
+		lines = append(lines, "Messages by conversation:")
+		lines = append(lines, barChart(conversations, describeConversation)...)
+	}
+	lines = append(lines, "Active hours: "+hourSparkline(hours), "Most active hours:")
+	byHour := make(map[string]int)
+	for hour, count := range hours {
+		if count > 0 {
+			byHour[fmt.Sprintf("%02d:00", hour)] = count
+		}
+	}
+	lines = append(lines, barChart(byHour, nil)...)
+	lines = append(lines, "Top senders:")
+	lines = append(lines, barChart(senders, nil)...)
+	m.appendMessage(strings.Join(lines, "\n"))
+}
+
+// barChart renders the largest counts as labelled bars, largest first. label formats the keys;
+// nil shows them as they are.
+func barChart(counts map[string]int, label func(string) string) []string {
+	keys := make([]string, 0, len(counts))
+	for key := range counts {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if counts[keys[i]] != counts[keys[j]] {
+			return counts[keys[i]] > counts[keys[j]]
+		}
+		return keys[i] < keys[j]
+	})
+	keys = keys[:min(len(keys), statsTop)]
+	if len(keys) == 0 {
+		return nil
+	}
+	largest := counts[keys[0]]
+	labels := make([]string, len(keys))
+	labelWidth := 0
+	for i, key := range keys {
+		labels[i] = key
+		if label != nil {
+			labels[i] = label(key)
+		}
+		labelWidth = max(labelWidth, len([]rune(labels[i])))
+	}
+	lines := make([]string, len(keys))
+	for i, key := range keys {
+		padding := strings.Repeat(" ", labelWidth-len([]rune(labels[i])))
+		lines[i] = fmt.Sprintf("  %s%s %s %d", labels[i], padding, bar(counts[key], largest, statsBarWidth), counts[key])
+	}
+	return lines
+}
+
+// barEighths draws the fraction of a cell left over at the end of a bar
+var barEighths = []string{"", "▏", "▎", "▍", "▌", "▋", "▊", "▉"}
+
+// bar draws value as a bar scaled so largest fills width cells, in eighths of a cell
+func bar(value, largest, width int) string {
+	if largest <= 0 {
+		return ""
+	}
+	eighths := value * width * 8 / largest
+	if eighths == 0 && value > 0 {
+		// Any message at all gets a sliver
+		eighths = 1
+	}
+	return strings.Repeat("█", eighths/8) + barEighths[eighths%8]
+}
+
+// sparkLevels draws a count relative to the busiest hour
+var sparkLevels = []rune(" ▁▂▃▄▅▆▇█")
+
+// hourSparkline draws the 24 hours of the day, midnight first, as one line of bars
+func hourSparkline(hours [24]int) string {
+	largest := 0
+	for _, count := range hours {
+		largest = max(largest, count)
+	}
+	line := make([]rune, len(hours))
+	for hour, count := range hours {
+		level := 0
+		if largest > 0 && count > 0 {
+			level = max(1, count*(len(sparkLevels)-1)/largest)
+		}
+		line[hour] = sparkLevels[level]
+	}
+	return "00h |" + string(line) + "| 23h"
+}
@@ -0,0 +1,76 @@
+// clock.go
+// Package ui compares server-provided timestamps with the local clock and warns about skew.
+
+package ui
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// maxClockSkew is the clock difference with the server above which the user is warned
+var maxClockSkew = 30 * time.Second
+
+// serverTimeMsg carries a timestamp reported by the server and when it was received
+type serverTimeMsg struct {
+	serverTime time.Time
+	receivedAt time.Time
+}
+
+func init() {
+	registerCommand("/clock", commandSpec{
+		usage: "/clock",
+		help:  "Show the measured clock difference with the server",
+		run: func(m *model, args []string) tea.Cmd {
+			if !m.clockMeasured {
+				if m.serverCaps["TIME"] {
+					m.writeLine("TIME")
+					m.appendMessage("Asked the server for its time.")
+					return nil
+				}
+				m.appendMessage("The server has not reported its time.")
+				return nil
+			}
+			m.appendMessage(fmt.Sprintf("Local clock is %s the server's (threshold %s).", describeSkew(m.clockSkew), maxClockSkew))
+			return nil
+		},
+	})
+}
+
+// parseServerTime recognizes "TIME <RFC 3339 | Unix seconds>" lines from the server
+func parseServerTime(line string, now time.Time) (serverTimeMsg, bool) {
+	value, ok := strings.CutPrefix(line, "TIME ")
+	if !ok {
+		return serverTimeMsg{}, false
+	}
+	serverTime := parseTimestamp(strings.TrimSpace(value), now)
+	if serverTime.IsZero() {
+		return serverTimeMsg{}, false
+	}
+	return serverTimeMsg{serverTime: serverTime, receivedAt: now}, true
+}
+
+// describeSkew formats a skew (local minus server) for notices
+func describeSkew(skew time.Duration) string {
+	switch {
+	case skew > 0:
+		return fmt.Sprintf("%s ahead of", skew.Round(time.Millisecond))
+	case skew < 0:
+		return fmt.Sprintf("%s behind", (-skew).Round(time.Millisecond))
+	default:
+		return "in sync with"
+	}
+}
+
+// checkClockSkew records the skew against the server and warns when it exceeds the threshold
+func (m *model) checkClockSkew(msg serverTimeMsg) {
+	skew := msg.receivedAt.Sub(msg.serverTime)
+	m.clockSkew = skew
+	m.clockMeasured = true
+	if skew > maxClockSkew || skew < -maxClockSkew {
+		m.appendMessage(fmt.Sprintf("Warning: your clock is %s the server's. Clock skew breaks TOTP codes, scheduled messages, and replay protection; please sync your clock.", describeSkew(skew)))
+	}
+}
@@ -0,0 +1,108 @@
+// completion.go
+// Package ui generates shell completion scripts from the command-line flag definitions.
+
+package ui
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// subcommands are the words accepted in place of <YourID> that run something other than the client
+var subcommands = []string{"completion", "daemon", "pad", "relay", "send", "tail", "update"}
+
+// completionShells are the shells completion scripts can be generated for
+var completionShells = []string{"bash", "zsh", "fish"}
+
+// runCompletion handles "padclient completion <shell>"
+func runCompletion(out io.Writer, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: padclient completion %s", strings.Join(completionShells, "|"))
+	}
+	switch args[0] {
+	case "bash":
+		writeBashCompletion(out)
+	case "zsh":
+		writeZshCompletion(out)
+	case "fish":
+		writeFishCompletion(out)
+	default:
+		return fmt.Errorf("unsupported shell %q; use one of %s", args[0], strings.Join(completionShells, ", "))
+	}
+	return nil
+}
+
+// isBoolFlag reports whether the flag takes no value
+func isBoolFlag(f *flag.Flag) bool {
+	b, ok := f.Value.(interface{ IsBoolFlag() bool })
+	return ok && b.IsBoolFlag()
+}
+
+// writeBashCompletion writes a bash completion function
+func writeBashCompletion(out io.Writer) {
+	var flags, valueFlags []string
+	flag.VisitAll(func(f *flag.Flag) {
+		flags = append(flags, "-"+f.Name)
+		if !isBoolFlag(f) {
+			valueFlags = append(valueFlags, "-"+f.Name)
+		}
+	})
+	fmt.Fprintf(out, `# bash completion for padclient
+_padclient() {
+	local cur="${COMP_WORDS[COMP_CWORD]}" prev="${COMP_WORDS[COMP_CWORD-1]}"
+	if [[ ${COMP_WORDS[1]} == completion ]]; then
+		COMPREPLY=($(compgen -W "%s" -- "$cur"))
+		return
+	fi
+	case "$prev" in
+		%s) return ;; # Flag values fall back to file completion
+	esac
+	if [[ $cur == -* ]]; then
+		COMPREPLY=($(compgen -W "%s" -- "$cur"))
+	elif [[ $COMP_CWORD -eq 1 ]]; then
+		COMPREPLY=($(compgen -W "%s" -- "$cur"))
+	fi
+}
+complete -o default -F _padclient padclient
+`, strings.Join(completionShells, " "), strings.Join(valueFlags, "|"), strings.Join(flags, " "), strings.Join(subcommands, " "))
+}
+
+// writeZshCompletion writes a zsh completion function
+func writeZshCompletion(out io.Writer) {
+	fmt.Fprintln(out, "#compdef padclient")
+	fmt.Fprintln(out, "_padclient() {")
+	fmt.Fprintln(out, "\tif [[ $words[2] == completion ]]; then")
+	fmt.Fprintf(out, "\t\t_values 'shell' %s\n", strings.Join(completionShells, " "))
+	fmt.Fprintln(out, "\t\treturn")
+	fmt.Fprintln(out, "\tfi")
+	fmt.Fprintln(out, "\t_arguments \\")
+	flag.VisitAll(func(f *flag.Flag) {
+		usage := strings.NewReplacer("'", `'\''`, "[", `\[`, "]", `\]`).Replace(f.Usage)
+		if isBoolFlag(f) {
+			fmt.Fprintf(out, "\t\t'-%s[%s]' \\\n", f.Name, usage)
+		} else {
+			fmt.Fprintf(out, "\t\t'-%s[%s]:%s:_files' \\\n", f.Name, usage, f.Name)
+		}
+	})
+	fmt.Fprintf(out, "\t\t'1:ID or subcommand:(%s)' \\\n", strings.Join(subcommands, " "))
+	fmt.Fprintln(out, "\t\t'2:Tailscale server:_hosts'")
+	fmt.Fprintln(out, "}")
+	fmt.Fprintln(out, `_padclient "$@"`)
+}
+
+// writeFishCompletion writes fish completion commands
+func writeFishCompletion(out io.Writer) {
+	fmt.Fprintln(out, "# fish completion for padclient")
+	fmt.Fprintf(out, "complete -c padclient -n '__fish_use_subcommand' -f -a '%s'\n", strings.Join(subcommands, " "))
+	fmt.Fprintf(out, "complete -c padclient -n '__fish_seen_subcommand_from completion' -f -a '%s'\n", strings.Join(completionShells, " "))
+	flag.VisitAll(func(f *flag.Flag) {
+		usage := strings.NewReplacer(`\`, `\\`, "'", `\'`).Replace(f.Usage)
+		if isBoolFlag(f) {
+			fmt.Fprintf(out, "complete -c padclient -o %s -d '%s'\n", f.Name, usage)
+		} else {
+			fmt.Fprintf(out, "complete -c padclient -o %s -r -d '%s'\n", f.Name, usage)
+		}
+	})
+}
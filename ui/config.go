@@ -0,0 +1,236 @@
+// config.go
+// Package ui loads default settings from a config file so they need not be passed on every launch.
+
+package ui
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// serverPort is the TCP port the server listens on
+var serverPort = 12345
+
+// clientConfig holds the settings read from the config file
+type clientConfig struct {
+	id       string                       // Client ID used when none is given on the command line
+	server   string                       // Server used when none is given on the command line
+	flags    map[string]string            // Flag defaults by flag name
+	lines    map[string]int               // Line each flag setting came from, for error messages
+	sections map[string]map[string]string // Flag defaults for one subcommand, by [section] and flag name
+}
+
+// defaultConfigPath returns the config file in the user's config directory
+func defaultConfigPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "padclient", "config.toml")
+}
+
+// configPathFromArgs finds -config in the arguments before they are parsed, since the config
+// file supplies the defaults the rest of the flags are parsed against. It walks the arguments as
+// flags.Parse would, stepping over the values of flags that take one, and stops at the first
+// argument that is not a flag.
+func configPathFromArgs(flags *flag.FlagSet, args []string) string {
+	path := defaultConfigPath()
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" || arg == "-" || !strings.HasPrefix(arg, "-") {
+			break
+		}
+		name, value, hasValue := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+		switch {
+		case hasValue:
+			if name == "config" {
+				path = value
+			}
+		case name == "config" && i+1 < len(args):
+			path = args[i+1]
+			i++
+		case takesValue(flags.Lookup(name)):
+			i++
+		}
+	}
+	return path
+}
+
+// takesValue reports whether a flag is given a value in the next argument, which every flag but
+// a boolean one is
+func takesValue(f *flag.Flag) bool {
+	if f == nil {
+		return false
+	}
+	boolean, ok := f.Value.(interface{ IsBoolFlag() bool })
+	return !ok || !boolean.IsBoolFlag()
+}
+
+// loadConfig reads the config file. A missing file is an empty config. The file uses a small
+// subset of TOML: one key = value per line, with strings in double quotes and # comments. Settings
+// after a [section] line apply only to the subcommand of that name.
+//
+//	id = "alice"
+//	server = "100.64.0.1"
+//	port = 12345
+//	undo-window = "5s"
+//	no-title = true
+//
+//	[daemon]
+//	socket = "/run/user/1000/padclient.sock"
+func loadConfig(path string) (clientConfig, error) {
+	config := clientConfig{flags: make(map[string]string), lines: make(map[string]int), sections: make(map[string]map[string]string)}
+	if path == "" {
+		return config, nil
+	}
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return config, nil
+	}
+	if err != nil {
+		return config, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	section := ""
+	for number := 1; scanner.Scan(); number++ {
+		line := strings.TrimSpace(stripConfigComment(scanner.Text()))
+		if line == "" {
+			continue
+		}
+		if name, ok := strings.CutPrefix(line, "["); ok {
+			name, ok = strings.CutSuffix(name, "]")
+			if section = strings.TrimSpace(name); !ok || !configSections[section] {
+				return config, fmt.Errorf("%s:%d: unknown section %s", path, number, line)
+			}
+			if config.sections[section] == nil {
+				config.sections[section] = make(map[string]string)
+			}
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return config, fmt.Errorf("%s:%d: expected key = value", path, number)
+		}
+		key = strings.TrimSpace(key)
+		value, err := parseConfigValue(strings.TrimSpace(value))
+		if err != nil {
+			return config, fmt.Errorf("%s:%d: %v", path, number, err)
+		}
+		switch {
+		case section != "":
+			config.sections[section][key] = value
+			config.lines[section+"."+key] = number
+		case key == "id":
+			config.id = value
+		case key == "server":
+			config.server = value
+		default:
+			config.flags[key] = value
+			config.lines[key] = number
+		}
+	}
+	return config, scanner.Err()
+}
+
+// configSections are the subcommands that may have a [section] in the config file
+var configSections = map[string]bool{"send": true, "tail": true, "daemon": true, "relay": true, "pad": true, "bench": true, "update": true}
+
+// stripConfigComment removes a # comment that is not inside a quoted string
+func stripConfigComment(line string) string {
+	quoted := false
+	for i := 0; i < len(line); i++ {
+		switch {
+		case line[i] == '\\' && quoted:
+			i++
+		case line[i] == '"':
+			quoted = !quoted
+		case line[i] == '#' && !quoted:
+			return line[:i]
+		}
+	}
+	return line
+}
+
+// parseConfigValue unquotes a string value; numbers, booleans, and durations may be left unquoted
+func parseConfigValue(value string) (string, error) {
+	if strings.HasPrefix(value, `"`) {
+		unquoted, err := strconv.Unquote(value)
+		if err != nil {
+			return "", fmt.Errorf("invalid string %s", value)
+		}
+		return unquoted, nil
+	}
+	if value == "" || strings.ContainsAny(value, ` "'[]{}`) {
+		return "", fmt.Errorf("invalid value %q; quote strings", value)
+	}
+	return value, nil
+}
+
+// readSubcommandConfig loads the config file named by -config in args, or the default one, and
+// sets the defaults of a subcommand's flags from it. Call it once the flags are defined and before
+// they are parsed. The top-level server sets -server and other top-level settings apply where the
+// subcommand has a flag of that name; the top-level id does not, since subcommands register under
+// IDs of their own so they can run beside the UI. The subcommand's own [section] must only name
+// its flags.
+func readSubcommandConfig(flags *flag.FlagSet, args []string) error {
+	if flags.Lookup("config") == nil {
+		flags.String("config", defaultConfigPath(), "config file with default settings; command-line flags override it")
+	}
+	path := configPathFromArgs(flags, args)
+	config, err := loadConfig(path)
+	if err == nil {
+		err = config.applySubcommand(flags, path)
+	}
+	if err != nil {
+		return &fatalError{code: exitUsage, err: fmt.Errorf("error reading config: %v", err)}
+	}
+	return nil
+}
+
+// applySubcommand sets a subcommand's flag defaults from the config
+func (c clientConfig) applySubcommand(flags *flag.FlagSet, path string) error {
+	if c.server != "" && flags.Lookup("server") != nil {
+		flags.Set("server", c.server)
+	}
+	for name, value := range c.flags {
+		if name == "config" || flags.Lookup(name) == nil {
+			continue
+		}
+		if err := flags.Set(name, value); err != nil {
+			return fmt.Errorf("%s:%d: %s: %v", path, c.lines[name], name, err)
+		}
+	}
+	section := strings.Fields(flags.Name())[0]
+	for name, value := range c.sections[section] {
+		line := c.lines[section+"."+name]
+		if name == "config" || flags.Lookup(name) == nil {
+			return fmt.Errorf("%s:%d: unknown setting %q in [%s]", path, line, name, section)
+		}
+		if err := flags.Set(name, value); err != nil {
+			return fmt.Errorf("%s:%d: %s: %v", path, line, name, err)
+		}
+	}
+	return nil
+}
+
+// apply sets flag defaults from the config. Flags given on the command line are parsed afterwards
+// and so take precedence.
+func (c clientConfig) apply(flags *flag.FlagSet, path string) error {
+	for name, value := range c.flags {
+		if name == "config" || flags.Lookup(name) == nil {
+			return fmt.Errorf("%s:%d: unknown setting %q", path, c.lines[name], name)
+		}
+		if err := flags.Set(name, value); err != nil {
+			return fmt.Errorf("%s:%d: %s: %v", path, c.lines[name], name, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+package ui
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestConfigPathFromArgs checks that -config is found after flags that take a value, and not in
+// the value of another flag or past the first argument that is not a flag
+func TestConfigPathFromArgs(t *testing.T) {
+	flags := flag.NewFlagSet("tail", flag.ContinueOnError)
+	flags.String("server", "", "")
+	flags.Bool("json", false, "")
+	flags.String("config", "", "")
+	for _, test := range []struct {
+		args []string
+		want string
+	}{
+		{[]string{"-server", "host", "-config", "a.toml"}, "a.toml"},
+		{[]string{"-json", "-config=b.toml"}, "b.toml"},
+		{[]string{"--config", "c.toml", "-json"}, "c.toml"},
+		{[]string{"-server", "-config", "-json"}, defaultConfigPath()},
+		{[]string{"alice", "-config", "d.toml"}, defaultConfigPath()},
+	} {
+		if got := configPathFromArgs(flags, test.args); got != test.want {
+			t.Errorf("configPathFromArgs(%q) = %q, want %q", test.args, got, test.want)
+		}
+	}
+}
+
+// TestReadSubcommandConfig checks that a subcommand takes the top-level server and the settings it
+// has a flag for, then its own section, and that the command line still overrides them
+func TestReadSubcommandConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.toml")
+	config := `id = "alice"
+server = "100.64.0.1"
+port = 4000
+theme = "light" # the UI's, which tail has no flag for
+
+[tail]
+json = true
+id = "tail-bot"
+`
+	if err := os.WriteFile(path, []byte(config), 0600); err != nil {
+		t.Fatal(err)
+	}
+	flags := flag.NewFlagSet("tail", flag.ContinueOnError)
+	server := flags.String("server", "", "")
+	id := flags.String("id", "tail-default", "")
+	port := flags.Int("port", 0, "")
+	asJSON := flags.Bool("json", false, "")
+	args := []string{"-config", path, "-port", "5000"}
+	if err := readSubcommandConfig(flags, args); err != nil {
+		t.Fatal(err)
+	}
+	if err := flags.Parse(args); err != nil {
+		t.Fatal(err)
+	}
+	if *server != "100.64.0.1" || *id != "tail-bot" || *port != 5000 || !*asJSON {
+		t.Errorf("got server %q, id %q, port %d, json %v", *server, *id, *port, *asJSON)
+	}
+
+	// A section naming a flag the subcommand lacks is an error
+	if err := os.WriteFile(path, []byte("[tail]\nsocket = \"x\"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	flags = flag.NewFlagSet("tail", flag.ContinueOnError)
+	if err := readSubcommandConfig(flags, []string{"-config", path}); err == nil {
+		t.Error("an unknown setting in [tail] was accepted")
+	}
+}
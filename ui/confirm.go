@@ -0,0 +1,78 @@
+// confirm.go
+// Package ui requires a confirmation keystroke before destructive operator commands and other risky actions.
+
+package ui
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// destructiveCommands are the operator commands that need confirmation
+var destructiveCommands = map[string]bool{
+	"KICK":     true,
+	"BAN":      true,
+	"SHUTDOWN": true,
+}
+
+// pendingConfirmation is an action waiting for the user to confirm it
+type pendingConfirmation struct {
+	description string                 // Short description of the action, shown when cancelled
+	prompt      string                 // Prompt describing the action and its target
+	onConfirm   func(m *model) tea.Cmd // Runs the action once confirmed
+	onCancel    func(m *model) tea.Cmd // Runs when the action is cancelled, if set
+}
+
+// askConfirmation holds an action until the user presses y
+func (m *model) askConfirmation(description, prompt string, onConfirm func(m *model) tea.Cmd) {
+	m.confirm = &pendingConfirmation{
+		description: description,
+		prompt:      prompt + " Press y to confirm, any other key to cancel.",
+		onConfirm:   onConfirm,
+	}
+}
+
+// requestConfirmation holds a destructive command until the user confirms it
+func (m *model) requestConfirmation(input string, parts []string) {
+	var b strings.Builder
+	switch parts[0] {
+	case "SHUTDOWN":
+		b.WriteString("Shut down the server for all connected clients?")
+	default:
+		target := ""
+		if len(parts) > 1 {
+			target = parts[1]
+		}
+		fmt.Fprintf(&b, "%s %s? %s", parts[0], target, m.describePeer(target))
+	}
+	m.askConfirmation(input, b.String(), func(m *model) tea.Cmd {
+		m.writeLine(input)
+		return nil
+	})
+}
+
+// describePeer summarizes what the client knows about a peer
+func (m *model) describePeer(peerID string) string {
+	lastSeen, ok := m.peerLastSeen[peerID]
+	if !ok {
+		return fmt.Sprintf("(%s has not messaged you this session)", peerID)
+	}
+	return fmt.Sprintf("(%s last messaged you %s ago)", peerID, time.Since(lastSeen).Round(time.Second))
+}
+
+// updateConfirmation handles the keystroke answering a pending confirmation
+func (m *model) updateConfirmation(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	pending := m.confirm
+	m.confirm = nil
+	if msg.Type == tea.KeyRunes && (string(msg.Runes) == "y" || string(msg.Runes) == "Y") {
+		return m, pending.onConfirm(m)
+	}
+	m.appendMessage(fmt.Sprintf("Cancelled: %s", pending.description))
+	if pending.onCancel != nil {
+		return m, pending.onCancel(m)
+	}
+	return m, nil
+}
@@ -0,0 +1,120 @@
+// connection.go
+// Package ui ties the goroutines serving each server connection to a context cancelled on disconnect.
+
+package ui
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// writeQueue is how many outgoing lines can wait for the writer goroutine
+const writeQueue = 256
+
+// writeErrorMsg reports that writing to the server failed
+type writeErrorMsg struct {
+	err error
+}
+
+// connWriter is the only goroutine writing to a connection, so lines go out in the order queued.
+// Bulk lines, such as file transfer chunks, only go out while no interactive line is waiting.
+type connWriter struct {
+	lines chan string
+	bulk  chan string   // Low-priority lines, see writeBulk
+	done  chan struct{} // Closed once the writer has stopped
+}
+
+// startWriter starts the writer for conn; it stops when ctx is cancelled or its queue is closed
+func startWriter(ctx context.Context, conn net.Conn, messageChan chan<- tea.Msg) *connWriter {
+	w := &connWriter{lines: make(chan string, writeQueue), bulk: make(chan string, writeQueue), done: make(chan struct{})}
+	write := func(line string) bool {
+		if _, err := fmt.Fprintf(conn, "%s\n", line); err != nil {
+			select {
+			case messageChan <- writeErrorMsg{err: err}:
+			case <-ctx.Done():
+			}
+			return false
+		}
+		return true
+	}
+	go func() {
+		defer close(w.done)
+		for {
+			// Interactive lines go first, so chat never waits behind a transfer
+			select {
+			case line, ok := <-w.lines:
+				if !ok || !write(line) {
+					return
+				}
+				continue
+			default:
+			}
+			select {
+			case line, ok := <-w.lines:
+				if !ok || !write(line) {
+					return
+				}
+			case line := <-w.bulk:
+				if !write(line) {
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return w
+}
+
+// startConnection adopts a new server connection and starts reading from it.
+// Anything left over from a previous connection is shut down first.
+func (m *model) startConnection(conn net.Conn) {
+	m.closeConnection()
+	ctx, cancel := context.WithCancel(context.Background())
+	m.conn = conn
+	m.connCancel = cancel
+	m.messageChan = make(chan tea.Msg, messageBuffer)
+	m.writer = startWriter(ctx, conn, m.messageChan)
+	go readMessages(ctx, conn, m.clientID, m.keys, m.pads, m.filters, m.messageChan)
+}
+
+// closeConnection cancels the goroutines serving the connection and closes it
+func (m *model) closeConnection() {
+	m.closeBulk()
+	m.writer = nil
+	if m.connCancel != nil {
+		m.connCancel()
+		m.connCancel = nil
+	}
+	if m.conn != nil {
+		m.conn.Close()
+	}
+}
+
+// closeAfterWrites stops accepting new lines and returns a command that quits once the queued
+// lines have been written, or after a short timeout.
+func (m *model) closeAfterWrites() tea.Cmd {
+	writer, conn, cancel := m.writer, m.conn, m.connCancel
+	m.writer, m.connCancel = nil, nil
+	m.closeBulk()
+	return func() tea.Msg {
+		if writer != nil {
+			close(writer.lines)
+			select {
+			case <-writer.done:
+			case <-time.After(2 * time.Second):
+			}
+		}
+		if cancel != nil {
+			cancel()
+		}
+		if conn != nil {
+			conn.Close()
+		}
+		return tea.Quit()
+	}
+}
@@ -0,0 +1,140 @@
+package ui
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/drewwalton19216801/padclient/crypto"
+	"go.uber.org/goleak"
+)
+
+// TestConnectionGoroutinesExitOnCancel cancels a connection while the reader is busy and checks
+// that the reader, the ordered delivery, the jobs handed to the crypto pool, and the writer all stop
+func TestConnectionGoroutinesExitOnCancel(t *testing.T) {
+	// The pool's own workers run for the life of the process
+	defer goleak.VerifyNone(t, goleak.IgnoreTopFunction("github.com/drewwalton19216801/padclient/ui.newCryptoPool.func1"))
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		t.Fatal(err)
+	}
+	ciphertext, err := crypto.EncryptAES(secret, []byte("hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	server, client := net.Pipe()
+	defer server.Close()
+
+	// Keep the server sending broadcasts, so jobs are queued on the pool when the cancel comes
+	sent := make(chan struct{})
+	go func() {
+		defer close(sent)
+		for {
+			if _, err := fmt.Fprintf(server, "BROADCAST from bob: %s\n", hex.EncodeToString(ciphertext)); err != nil {
+				return
+			}
+		}
+	}()
+	// Drain what the server is sent, as the writer would otherwise block on the pipe
+	go func() {
+		buf := make([]byte, 1024)
+		for {
+			if _, err := server.Read(buf); err != nil {
+				return
+			}
+		}
+	}()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	// The UI reads one message and then stops, so the delivery backs up behind it
+	messages := make(chan tea.Msg)
+	writer := startWriter(ctx, client, messages)
+	writer.lines <- "LIST"
+	writer.bulk <- "SEND bob chunk"
+	read := make(chan struct{})
+	go func() {
+		readMessages(ctx, client, "alice", newSessionKey(secret), nil, nil, messages)
+		close(read)
+	}()
+	select {
+	case msg := <-messages:
+		if _, ok := msg.(incomingMessage); !ok {
+			t.Fatalf("first message is %T, want incomingMessage", msg)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no message was delivered")
+	}
+
+	cancel()
+	for name, done := range map[string]<-chan struct{}{"reader": read, "writer": writer.done, "server": sent} {
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("%s did not stop after the connection was cancelled", name)
+		}
+	}
+	server.Close()
+}
+
+// TestWriterInteractiveAndBulkConcurrently queues interactive and bulk lines from several
+// goroutines at once and checks that every line is written whole, each sender's lines in order.
+// Run it with -race.
+func TestWriterInteractiveAndBulkConcurrently(t *testing.T) {
+	const senders, perSender = 4, 200
+	server, client := net.Pipe()
+	defer server.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	messages := make(chan tea.Msg, 1)
+	writer := startWriter(ctx, client, messages)
+
+	var wg sync.WaitGroup
+	for s := 0; s < senders; s++ {
+		for _, lane := range []struct {
+			name  string
+			queue chan string
+		}{{"line", writer.lines}, {"bulk", writer.bulk}} {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for i := 0; i < perSender; i++ {
+					lane.queue <- fmt.Sprintf("%s %d %d", lane.name, s, i)
+				}
+			}()
+		}
+	}
+
+	next := make(map[string]int)
+	scanner := bufio.NewScanner(server)
+	for received := 0; received < 2*senders*perSender; received++ {
+		if !scanner.Scan() {
+			t.Fatalf("the writer stopped after %d lines: %v", received, scanner.Err())
+		}
+		var lane string
+		var s, i int
+		if _, err := fmt.Sscanf(scanner.Text(), "%s %d %d", &lane, &s, &i); err != nil {
+			t.Fatalf("garbled line %q: %v", scanner.Text(), err)
+		}
+		key := fmt.Sprintf("%s %d", lane, s)
+		if i != next[key] {
+			t.Fatalf("%s sent line %d after line %d", key, i, next[key]-1)
+		}
+		next[key]++
+	}
+	wg.Wait()
+	select {
+	case msg := <-messages:
+		t.Fatalf("unexpected %#v", msg)
+	default:
+	}
+	cancel()
+	<-writer.done
+}
@@ -0,0 +1,237 @@
+// control.go
+// Package ui serves the daemon's local control API: a versioned gRPC service on a Unix socket
+// that frontends and scripts use to send messages, follow events, and query the session.
+
+package ui
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/drewwalton19216801/padclient/controlpb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// controlAPIVersion is the version of the control API. Incompatible changes go in a new proto
+// package (padclient.control.v2) served alongside the old one.
+const controlAPIVersion = 1
+
+// controlTokenKey is the metadata key every control API call carries the token in
+const controlTokenKey = "token"
+
+// SendReply is the HTTP bridge's answer to a send
+type SendReply struct {
+	Cipher string `json:"cipher"` // How the message was encrypted
+}
+
+// ControlEvent is something that happened on the connection
+type ControlEvent struct {
+	Seq           int64     `json:"seq"`
+	Time          time.Time `json:"time"`
+	Kind          string    `json:"kind"` // "message", "server", or "integrity" (a message that failed to decrypt)
+	From          string    `json:"from,omitempty"`
+	Broadcast     bool      `json:"broadcast,omitempty"`
+	Text          string    `json:"text"`
+	ForwardedFrom string    `json:"forwarded_from,omitempty"`
+	Signature     string    `json:"signature,omitempty"` // Signature status of a message: "valid", "verified", "new-key", "changed", "invalid", or "missing"
+}
+
+// controlService implements the control API methods
+type controlService struct {
+	controlpb.UnimplementedPadClientServer
+	d *daemon
+}
+
+// controlListener removes the socket when closed. The socket was renamed into place, so the
+// listener would otherwise only unlink the path it was created at.
+type controlListener struct {
+	net.Listener
+	path string
+}
+
+func (l controlListener) Close() error {
+	err := l.Listener.Close()
+	os.Remove(l.path)
+	return err
+}
+
+// defaultControlSocket returns the control socket in the user's config directory
+func defaultControlSocket() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "padclient", "control.sock")
+}
+
+// listenPrivate creates a Unix socket only the user can open at socketPath. It is created in a
+// new directory with mode 0700 and renamed into place once its own mode is 0600, so there is no
+// moment at which another user could connect.
+func listenPrivate(socketPath string) (net.Listener, error) {
+	dir, err := os.MkdirTemp(filepath.Dir(socketPath), ".control-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+	tmp := filepath.Join(dir, "control.sock")
+	listener, err := net.Listen("unix", tmp)
+	if err != nil {
+		return nil, err
+	}
+	listener.(*net.UnixListener).SetUnlinkOnClose(false)
+	if err := os.Chmod(tmp, 0600); err != nil {
+		listener.Close()
+		return nil, err
+	}
+	if err := os.Rename(tmp, socketPath); err != nil {
+		listener.Close()
+		return nil, err
+	}
+	return controlListener{Listener: listener, path: socketPath}, nil
+}
+
+// serveControl listens on the socket and serves the control API until ctx ends. Only the user
+// can open the socket, and each call must present the token written to <socket>.token.
+func serveControl(ctx context.Context, d *daemon, socketPath string) (net.Listener, error) {
+	if err := os.MkdirAll(filepath.Dir(socketPath), 0700); err != nil {
+		return nil, err
+	}
+	// A socket left behind by a daemon that did not exit cleanly would be replaced
+	if conn, err := net.Dial("unix", socketPath); err == nil {
+		conn.Close()
+		return nil, errors.New("another daemon is already serving " + socketPath)
+	}
+	listener, err := listenPrivate(socketPath)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(socketPath+".token", []byte(d.token+"\n"), 0600); err != nil {
+		listener.Close()
+		return nil, err
+	}
+
+	service := &controlService{d: d}
+	server := grpc.NewServer(
+		grpc.UnaryInterceptor(func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+			if err := service.authorize(ctx); err != nil {
+				return nil, err
+			}
+			return handler(ctx, req)
+		}),
+		grpc.StreamInterceptor(func(srv any, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+			if err := service.authorize(stream.Context()); err != nil {
+				return err
+			}
+			return handler(srv, stream)
+		}),
+	)
+	controlpb.RegisterPadClientServer(server, service)
+	// Lets tools such as grpcurl list and call the methods without the .proto file
+	reflection.Register(server)
+
+	go func() {
+		<-ctx.Done()
+		server.Stop()
+	}()
+	go server.Serve(listener)
+	return listener, nil
+}
+
+// authorize checks the token presented with a call
+func (s *controlService) authorize(ctx context.Context) error {
+	md, _ := metadata.FromIncomingContext(ctx)
+	if tokens := md.Get(controlTokenKey); len(tokens) != 1 || !s.d.validToken(tokens[0]) {
+		return status.Error(codes.Unauthenticated, "invalid token")
+	}
+	return nil
+}
+
+// Send encrypts and sends a message
+func (s *controlService) Send(ctx context.Context, req *controlpb.SendRequest) (*controlpb.SendReply, error) {
+	if req.To == "" || strings.TrimSpace(req.Text) == "" {
+		return nil, status.Error(codes.InvalidArgument, "to and text are required")
+	}
+	info, err := s.d.send(req.To, req.Text)
+	if err != nil {
+		return nil, status.Error(codes.Unavailable, err.Error())
+	}
+	return &controlpb.SendReply{Cipher: info.cipher}, nil
+}
+
+// Subscribe streams the kept events from req.After on, then new events as they are published,
+// until the caller cancels
+func (s *controlService) Subscribe(req *controlpb.SubscribeRequest, stream grpc.ServerStreamingServer[controlpb.Event]) error {
+	after := req.After
+	for {
+		events, changed := s.d.eventsAfter(after)
+		for _, event := range events {
+			if err := stream.Send(event.proto()); err != nil {
+				return err
+			}
+			after = event.Seq + 1
+		}
+		select {
+		case <-changed:
+		case <-stream.Context().Done():
+			return nil
+		}
+	}
+}
+
+// Roster returns the clients connected to the server, as of the last LIST
+func (s *controlService) Roster(ctx context.Context, req *controlpb.RosterRequest) (*controlpb.RosterReply, error) {
+	s.d.mu.Lock()
+	defer s.d.mu.Unlock()
+	reply := &controlpb.RosterReply{}
+	for _, client := range s.d.roster {
+		info := &controlpb.Client{Id: client.ID, Address: client.Address, Operator: client.Operator}
+		if client.Idle > 0 {
+			info.Idle = durationpb.New(client.Idle)
+		}
+		if !client.ConnectedAt.IsZero() {
+			info.ConnectedAt = timestamppb.New(client.ConnectedAt)
+		}
+		reply.Clients = append(reply.Clients, info)
+	}
+	return reply, nil
+}
+
+// Status describes the daemon's session
+func (s *controlService) Status(ctx context.Context, req *controlpb.StatusRequest) (*controlpb.StatusReply, error) {
+	s.d.mu.Lock()
+	defer s.d.mu.Unlock()
+	return &controlpb.StatusReply{
+		ApiVersion: controlAPIVersion,
+		Version:    version,
+		ClientId:   s.d.clientID,
+		Server:     s.d.server,
+		Connected:  s.d.connected,
+		Since:      timestamppb.New(s.d.started),
+		Received:   int64(s.d.received),
+	}, nil
+}
+
+// proto converts an event to its control API message
+func (e ControlEvent) proto() *controlpb.Event {
+	return &controlpb.Event{
+		Seq:           e.Seq,
+		Time:          timestamppb.New(e.Time),
+		Kind:          e.Kind,
+		From:          e.From,
+		Broadcast:     e.Broadcast,
+		Text:          e.Text,
+		ForwardedFrom: e.ForwardedFrom,
+		Signature:     e.Signature,
+	}
+}
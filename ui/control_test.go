@@ -0,0 +1,68 @@
+package ui
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/drewwalton19216801/padclient/controlpb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// TestControlAPI serves the control API on a socket and checks its mode, that calls without the
+// token are refused, and that a subscriber sees published events
+func TestControlAPI(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	d := &daemon{clientID: "alice", token: "secret", started: time.Now(), changed: make(chan struct{})}
+	socketPath := filepath.Join(t.TempDir(), "control.sock")
+	listener, err := serveControl(ctx, d, socketPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	info, err := os.Stat(socketPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mode := info.Mode().Perm(); mode != 0600 {
+		t.Errorf("socket mode is %o, want 600", mode)
+	}
+
+	conn, err := grpc.NewClient("unix:"+socketPath, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	client := controlpb.NewPadClientClient(conn)
+	if _, err := client.Status(ctx, &controlpb.StatusRequest{}); status.Code(err) != codes.Unauthenticated {
+		t.Fatalf("Status without a token: %v, want Unauthenticated", err)
+	}
+	authed := metadata.AppendToOutgoingContext(ctx, controlTokenKey, "secret")
+	reply, err := client.Status(authed, &controlpb.StatusRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reply.ClientId != "alice" || reply.ApiVersion != controlAPIVersion {
+		t.Errorf("Status = %v", reply)
+	}
+
+	stream, err := client.Subscribe(authed, &controlpb.SubscribeRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.publish(ControlEvent{Kind: "message", From: "bob", Text: "hi"})
+	event, err := stream.Recv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if event.From != "bob" || event.Text != "hi" {
+		t.Errorf("event = %v, want hi from bob", event)
+	}
+}
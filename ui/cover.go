@@ -0,0 +1,119 @@
+// cover.go
+// Package ui sends encrypted no-op messages at randomized intervals as cover traffic.
+
+package ui
+
+import (
+	"crypto/rand"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// coverInterval is the average time between cover messages (0 disables cover traffic)
+var coverInterval time.Duration
+
+// coverMarker starts the plaintext of every cover message so receivers can drop it
+const coverMarker = "\x00cover\x00"
+
+// coverTickMsg fires when the next cover message is due. Ticks from an older generation are ignored.
+type coverTickMsg struct {
+	gen int
+}
+
+func init() {
+	registerCommand("/cover", commandSpec{
+		usage:   "/cover on [interval] | off",
+		help:    "Send encrypted no-op messages at randomized intervals to hide message timing",
+		minArgs: 1,
+		run: func(m *model, args []string) tea.Cmd {
+			switch {
+			case args[0] == "on" && len(args) <= 2:
+				interval := coverInterval
+				if len(args) == 2 {
+					d, err := time.ParseDuration(args[1])
+					if err != nil || d <= 0 {
+						m.appendMessage(fmt.Sprintf("Invalid interval %q; use a duration such as 30s.", args[1]))
+						return nil
+					}
+					interval = d
+				}
+				if interval <= 0 {
+					interval = 30 * time.Second
+				}
+				coverInterval = interval
+				m.appendMessage(fmt.Sprintf("Cover traffic on, about one message every %s.", coverInterval))
+				return m.startCoverTraffic()
+			case args[0] == "off" && len(args) == 1:
+				coverInterval = 0
+				m.coverGen++ // Stop the pending tick
+				m.appendMessage("Cover traffic off.")
+			default:
+				m.appendMessage("Usage: " + knownCommands["/cover"].usage)
+			}
+			return nil
+		},
+	})
+}
+
+// startCoverTraffic starts a new cover traffic schedule, replacing any running one
+func (m *model) startCoverTraffic() tea.Cmd {
+	m.coverGen++
+	if coverInterval <= 0 {
+		return nil
+	}
+	return coverTick(m.coverGen)
+}
+
+// coverTick waits a random time between half and one and a half times the cover interval
+func coverTick(gen int) tea.Cmd {
+	delay := coverInterval / 2
+	if spread, err := rand.Int(rand.Reader, big.NewInt(int64(coverInterval)+1)); err == nil {
+		delay += time.Duration(spread.Int64())
+	}
+	return tea.Tick(delay, func(time.Time) tea.Msg {
+		return coverTickMsg{gen: gen}
+	})
+}
+
+// sendCover sends one cover message addressed to ourselves and schedules the next
+func (m *model) sendCover(msg coverTickMsg) tea.Cmd {
+	if msg.gen != m.coverGen || coverInterval <= 0 {
+		// Cover traffic was turned off or restarted
+		return nil
+	}
+	if m.writer != nil && !m.holdOutbox {
+		line, _, err := m.encodeSend(m.clientID, coverMarker+coverFiller())
+		if err == nil && m.writeLine(line) {
+			// Outbox ID 0 matches no entry, so the ACK is consumed silently
+			m.awaitingAck = append(m.awaitingAck, 0)
+		}
+	}
+	return coverTick(m.coverGen)
+}
+
+// coverFiller returns random printable text of a length typical for chat messages
+func coverFiller() string {
+	const alphabet = "abcdefghijklmnopqrstuvwxyz "
+	length := 8
+	if n, err := rand.Int(rand.Reader, big.NewInt(112)); err == nil {
+		length += int(n.Int64())
+	}
+	var b strings.Builder
+	for range length {
+		i, err := rand.Int(rand.Reader, big.NewInt(int64(len(alphabet))))
+		if err != nil {
+			break
+		}
+		b.WriteByte(alphabet[i.Int64()])
+	}
+	return b.String()
+}
+
+// isCover reports whether a decrypted message is cover traffic
+func isCover(content string) bool {
+	return strings.HasPrefix(content, coverMarker)
+}
@@ -0,0 +1,169 @@
+// cryptopool.go
+// Package ui runs message encryption and decryption on a bounded pool of workers, so large
+// payloads never hold up the reader goroutine or the Update loop. Results are still delivered and
+// written in the order the messages arrived or were sent.
+
+package ui
+
+import (
+	"context"
+	"runtime"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/drewwalton19216801/padclient/protocol"
+)
+
+// cryptoQueue is how many crypto jobs can wait for a worker before submitters wait
+const cryptoQueue = 64
+
+// cryptoPool is a fixed set of workers running crypto jobs
+type cryptoPool struct {
+	jobs chan func()
+}
+
+// cryptoWorkers is the pool shared by every connection, one worker per CPU
+var cryptoWorkers = newCryptoPool(runtime.GOMAXPROCS(0))
+
+// newCryptoPool starts a pool of workers that run for the life of the process
+func newCryptoPool(workers int) *cryptoPool {
+	p := &cryptoPool{jobs: make(chan func(), cryptoQueue)}
+	for i := 0; i < max(workers, 1); i++ {
+		go func() {
+			for job := range p.jobs {
+				job()
+			}
+		}()
+	}
+	return p
+}
+
+// submit queues a job, waiting while the queue is full, and returns a channel closed once it has run
+func (p *cryptoPool) submit(job func()) <-chan struct{} {
+	done := make(chan struct{})
+	p.jobs <- func() {
+		defer close(done)
+		job()
+	}
+	return done
+}
+
+// do runs a job on the pool and waits for it
+func (p *cryptoPool) do(job func()) {
+	<-p.submit(job)
+}
+
+// orderedDelivery hands messages from the reader to the UI in the order the reader produced them,
+// while messages still being decrypted by the pool hold back the ones behind them
+type orderedDelivery struct {
+	queue chan func() tea.Msg // Each waits for its message; nil messages are dropped
+	done  chan struct{}       // Closed once every queued message has been delivered
+}
+
+// newOrderedDelivery starts delivering to messageChan until close is called or ctx ends
+func newOrderedDelivery(ctx context.Context, messageChan chan<- tea.Msg) *orderedDelivery {
+	d := &orderedDelivery{queue: make(chan func() tea.Msg, cryptoQueue), done: make(chan struct{})}
+	go func() {
+		defer close(d.done)
+		for next := range d.queue {
+			msg := next()
+			if msg == nil {
+				continue
+			}
+			select {
+			case messageChan <- msg:
+			case <-ctx.Done():
+			}
+		}
+	}()
+	return d
+}
+
+// send queues a message that is ready now
+func (d *orderedDelivery) send(msg tea.Msg) {
+	d.queue <- func() tea.Msg { return msg }
+}
+
+// decrypt queues a message produced by a job on the pool; the job returns nil to drop it
+func (d *orderedDelivery) decrypt(job func() tea.Msg) {
+	var msg tea.Msg
+	done := cryptoWorkers.submit(func() { msg = job() })
+	d.queue <- func() tea.Msg {
+		<-done
+		return msg
+	}
+}
+
+// close stops accepting messages and waits for the queued ones to be delivered
+func (d *orderedDelivery) close() {
+	close(d.queue)
+	<-d.done
+}
+
+// pendingSend is an outgoing message being encrypted on the pool
+type pendingSend struct {
+	queued  queuedSend
+	started bool          // Whether its job has been handed to the pool
+	done    chan struct{} // Closed once line, info, and err are set
+	line    string
+	info    cipherInfo
+	err     error
+}
+
+// sealedMsg reports that an outgoing message finished encrypting
+type sealedMsg struct{}
+
+// seal signs the message as sender and encrypts it with the given key and pads
+func (p *pendingSend) seal(encode protocol.Encoder, hashedSecret []byte, pads *padStore, id *identity, sender string) {
+	text := id.sign(sender, p.queued.recipientID, dmKeys.offer(sender, p.queued.recipientID, withMessageID(p.queued.messageText)))
+	p.line, p.info, p.err = encodeSendLineWith(encode, hashedSecret, pads, p.queued.recipientID, text)
+	close(p.done)
+}
+
+// startSeals hands outgoing messages queued since the last update to the pool. The Update
+// wrapper calls it, so sendQueued can be used anywhere without returning a command.
+func (m *model) startSeals() tea.Cmd {
+	var cmds []tea.Cmd
+	for _, p := range m.sealing {
+		if p.started {
+			continue
+		}
+		p.started = true
+		// The hello goes out now, ahead of the message it precedes
+		m.startPadSync(p.queued.recipientID)
+		encode, hashedSecret, pads, id, sender := m.payloadEncoder(), m.hashedSecret, m.pads, m.identity, m.clientID
+		cmds = append(cmds, func() tea.Msg {
+			cryptoWorkers.do(func() { p.seal(encode, hashedSecret, pads, id, sender) })
+			return sealedMsg{}
+		})
+	}
+	return tea.Batch(cmds...)
+}
+
+// writeSealed writes encrypted messages in the order they were sent, stopping at the first that
+// is still being encrypted
+func (m *model) writeSealed() {
+	for len(m.sealing) > 0 {
+		p := m.sealing[0]
+		select {
+		case <-p.done:
+		default:
+			return
+		}
+		m.sealing = m.sealing[1:]
+		m.finishSend(p)
+	}
+}
+
+// sealAll encrypts any outgoing messages not yet handed to the pool, waits for the rest, and
+// writes them all, for when the client is about to quit
+func (m *model) sealAll() {
+	for _, p := range m.sealing {
+		if !p.started {
+			p.started = true
+			p.seal(m.payloadEncoder(), m.hashedSecret, m.pads, m.identity, m.clientID)
+		}
+		<-p.done
+		m.finishSend(p)
+	}
+	m.sealing = nil
+}
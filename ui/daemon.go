@@ -0,0 +1,321 @@
+// daemon.go
+// Package ui implements the daemon subcommand, which keeps a connection to the server open
+// without the UI and serves the local control API to frontends and scripts.
+
+package ui
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/drewwalton19216801/padclient/protocol"
+	"github.com/drewwalton19216801/padclient/transport"
+)
+
+// daemonRosterInterval is how often the daemon refreshes the roster with LIST
+const daemonRosterInterval = time.Minute
+
+// daemonEventLimit is how many recent events the daemon keeps for subscribers
+const daemonEventLimit = 1000
+
+// daemon is the state of a connection run without the UI
+type daemon struct {
+	mu           sync.Mutex
+	conn         net.Conn
+	clientID     string
+	server       string
+	hashedSecret []byte
+	pads         *padStore
+	identity     *identity
+	signatures   *signatureChecker
+	started      time.Time
+	connected    bool
+	received     int            // Messages received from other clients
+	roster       []ClientInfo   // Clients from the last LIST
+	events       []ControlEvent // Recent events, oldest first
+	nextSeq      int64          // Sequence number of the next event
+	changed      chan struct{}  // Closed when a new event arrives, then replaced
+	token        string         // Token local API clients must present
+	relay        *relayer       // Relays mentions to the user's interactive client, if set
+}
+
+// runDaemon connects and serves the control API until interrupted or disconnected:
+// padclient daemon -server <host> [-id <YourID>] [-socket <path>] [-http <addr>]
+func runDaemon(args []string) error {
+	flags := flag.NewFlagSet("daemon", flag.ContinueOnError)
+	server := flags.String("server", "", "Tailscale server to connect to")
+	flags.Var(portValue{}, "port", "TCP port the server listens on (default 12345, or as found through DNS)")
+	hostname, _ := os.Hostname()
+	clientID := flags.String("id", "daemon-"+hostname, "client ID to register as")
+	socketPath := flags.String("socket", defaultControlSocket(), "Unix socket to serve the control API on")
+	httpAddr := flags.String("http", "", "also serve the HTTP bridge on this loopback address, e.g. 127.0.0.1:8787")
+	relayTo := flags.String("relay-to", "", "relay messages that mention this client ID to it as direct messages")
+	relayWords := flags.String("relay-words", "", "comma-separated keywords that are relayed as well as mentions")
+	timeout := flags.Duration("timeout", 30*time.Second, "how long to wait for the connection and key exchange")
+	flags.StringVar(&padDir, "pad-dir", defaultPadDir(), "directory holding one-time pads shared with peers")
+	flags.StringVar(&knownKeysPath, "known-keys", knownKeysPath, "file of identity keys pinned for peers the first time each signs a message")
+	flags.BoolVar(&jsonErrors, "json-errors", true, "write errors to stderr as JSON objects")
+	flags.BoolVar(&allowNonTailscale, "allow-non-tailscale", false, "connect without Tailscale; traffic is only protected by the client's own encryption")
+	addTsnetFlags(flags)
+	addAllowlistFlags(flags)
+	if err := readSubcommandConfig(flags, args); err != nil {
+		return err
+	}
+	if err := flags.Parse(args); err != nil {
+		return &fatalError{code: exitUsage, err: err}
+	}
+	if *server == "" || *socketPath == "" || flags.NArg() > 0 {
+		return &fatalError{code: exitUsage, err: errors.New("usage: padclient daemon -server <host> [-id <YourID>] [-socket <path>] [-http <addr>]")}
+	}
+	if err := checkServersAllowed(*server); err != nil {
+		return err
+	}
+	if err := requireTailscale(*server); err != nil {
+		return err
+	}
+	pads, err := loadPads(padDir)
+	if err != nil {
+		return fmt.Errorf("error loading pads: %v", err)
+	}
+	id, err := loadSigningIdentity(*clientID)
+	if err != nil {
+		return err
+	}
+	if dmKeys, err = newKeyAgreement(id); err != nil {
+		return err
+	}
+	signatures, err := newSignatureChecker()
+	if err != nil {
+		return err
+	}
+
+	conn, hashedSecret, err := dialServer(*server, *clientID, *timeout)
+	if err != nil {
+		return err
+	}
+	conn.SetDeadline(time.Time{})
+	d := &daemon{
+		conn:         conn,
+		clientID:     *clientID,
+		server:       *server,
+		hashedSecret: hashedSecret,
+		pads:         pads,
+		identity:     id,
+		signatures:   signatures,
+		started:      time.Now(),
+		connected:    true,
+		changed:      make(chan struct{}),
+	}
+	token := make([]byte, 32)
+	if _, err := rand.Read(token); err != nil {
+		conn.Close()
+		return err
+	}
+	d.token = hex.EncodeToString(token)
+	if *relayTo != "" {
+		d.relay = newRelayer(*relayTo, *relayWords)
+	}
+
+	// Stop on Ctrl+C or SIGTERM; the reader closes the connection when the context ends
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	listener, err := serveControl(ctx, d, *socketPath)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer listener.Close()
+	defer os.Remove(*socketPath + ".token")
+	if *httpAddr != "" {
+		if err := serveBridge(ctx, d, *httpAddr); err != nil {
+			conn.Close()
+			return fmt.Errorf("error starting the HTTP bridge: %v", err)
+		}
+	}
+
+	messages := make(chan tea.Msg, messageBuffer)
+	done := make(chan struct{})
+	go func() {
+		readMessages(ctx, conn, *clientID, newSessionKey(hashedSecret), pads, nil, messages)
+		close(done)
+	}()
+	refresh := time.NewTicker(daemonRosterInterval)
+	defer refresh.Stop()
+	d.writeLine("LIST")
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-done:
+			d.setConnected(false)
+			return &fatalError{code: exitConnect, err: errors.New("disconnected from the server")}
+		case <-refresh.C:
+			d.writeLine("LIST")
+		case msg := <-messages:
+			if err := d.handle(msg); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// handle records a message from the reader as an event for subscribers, after answering any key
+// agreement it carries
+func (d *daemon) handle(msg tea.Msg) error {
+	reply, notice := answerKeyAgreement(protocol.EncodeHex, d.identity, d.signatures.keys, d.clientID, msg)
+	if reply != "" {
+		d.writeLine(reply)
+	}
+	if notice != "" {
+		d.publish(ControlEvent{Kind: "server", Text: notice})
+	}
+	switch msg := msg.(type) {
+	case incomingMessage:
+		env := openEnvelope(msg.content)
+		if env.padSync != "" {
+			reply, err := answerPadSync(protocol.EncodeHex, d.pads, d.identity, d.signatures.keys, d.clientID, msg, env)
+			if reply != "" {
+				d.writeLine(reply)
+			}
+			if err != nil {
+				d.publish(ControlEvent{Kind: "server", Text: fmt.Sprintf("Warning: %v.", err)})
+			}
+			return nil
+		}
+		if isControlEnvelope(msg.content, env) {
+			return nil
+		}
+		d.mu.Lock()
+		d.received++
+		d.mu.Unlock()
+		status, notice := d.signatures.check(msg.senderID, d.clientID, msg)
+		if notice != "" {
+			d.publish(ControlEvent{Kind: "server", From: msg.senderID, Text: notice})
+		}
+		// A mention that may be spoofed is not relayed, as the relay would vouch for it with our signature
+		if d.relay != nil && msg.senderID != d.clientID && !status.suspect() {
+			if text, ok := d.relay.alert(msg.senderID, env); ok {
+				if _, err := d.send(d.relay.to, text); err != nil {
+					d.publish(ControlEvent{Kind: "server", Text: fmt.Sprintf("Error relaying a message to %s: %v", d.relay.to, err)})
+				}
+			}
+		}
+		d.publish(ControlEvent{Kind: "message", From: msg.senderID, Broadcast: msg.isBroadcast, Text: env.body, ForwardedFrom: env.forwardedFrom, Signature: status.name()})
+	case serverMsg:
+		if msg.isResponse {
+			// LIST is the only command the daemon sends that has a multi-line response
+			if clients := parseClientList(msg.content, time.Now()); len(clients) > 0 {
+				d.mu.Lock()
+				d.roster = clients
+				d.mu.Unlock()
+			}
+			return nil
+		}
+		if !isAckLine(msg.content) {
+			d.publish(ControlEvent{Kind: "server", Text: msg.content})
+		}
+	case integrityFailureMsg:
+		d.publish(ControlEvent{Kind: "integrity", From: msg.senderID, Text: msg.err.Error()})
+	case kickedMsg:
+		return &fatalError{code: exitBanned, err: errors.New("kicked by the operator")}
+	case bannedMsg:
+		return transport.ErrBanned
+	}
+	return nil
+}
+
+// publish appends an event and wakes waiting subscribers
+func (d *daemon) publish(event ControlEvent) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	event.Seq = d.nextSeq
+	event.Time = time.Now()
+	d.nextSeq++
+	d.events = append(d.events, event)
+	if len(d.events) > daemonEventLimit {
+		d.events = d.events[len(d.events)-daemonEventLimit:]
+	}
+	close(d.changed)
+	d.changed = make(chan struct{})
+}
+
+// eventsAfter returns the events with a sequence number of at least seq, and a channel that is
+// closed when another event arrives
+func (d *daemon) eventsAfter(seq int64) ([]ControlEvent, chan struct{}) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	var events []ControlEvent
+	for _, event := range d.events {
+		if event.Seq >= seq {
+			events = append(events, event)
+		}
+	}
+	return events, d.changed
+}
+
+// nextEventSeq returns the sequence number the next event will have
+func (d *daemon) nextEventSeq() int64 {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.nextSeq
+}
+
+// send encrypts a message and writes it to the server
+func (d *daemon) send(recipientID, text string) (cipherInfo, error) {
+	if d.pads.startSync(recipientID) {
+		line, err := padSyncLine(protocol.EncodeHex, d.pads, d.identity, d.clientID, recipientID, "hello")
+		if err != nil {
+			return cipherInfo{}, err
+		}
+		if err := d.writeLine(line); err != nil {
+			return cipherInfo{}, err
+		}
+	}
+	text = d.identity.sign(d.clientID, recipientID, dmKeys.offer(d.clientID, recipientID, withMessageID(text)))
+	line, info, err := encodeSendLine(d.hashedSecret, d.pads, recipientID, text)
+	if err != nil {
+		return cipherInfo{}, err
+	}
+	if err := d.writeLine(line); err != nil {
+		return cipherInfo{}, err
+	}
+	return info, nil
+}
+
+// writeLine writes one line to the server. The write happens outside mu, so a slow server cannot
+// hold up API calls; each line goes out in a single Write, which net.Conn serializes, so lines
+// from concurrent calls cannot interleave.
+func (d *daemon) writeLine(line string) error {
+	d.mu.Lock()
+	conn, connected := d.conn, d.connected
+	d.mu.Unlock()
+	if !connected {
+		return errors.New("not connected to the server")
+	}
+	_, err := conn.Write([]byte(line + "\n"))
+	return err
+}
+
+// validToken reports whether a local API client presented the daemon's token
+func (d *daemon) validToken(token string) bool {
+	return subtle.ConstantTimeCompare([]byte(token), []byte(d.token)) == 1
+}
+
+// setConnected records whether the server connection is up
+func (d *daemon) setConnected(connected bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.connected = connected
+}
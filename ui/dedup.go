@@ -0,0 +1,59 @@
+// dedup.go
+// Package ui drops messages the server delivers again after a reconnect, so a replayed backlog
+// does not print a conversation twice.
+
+package ui
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+)
+
+// replayDedupWindow is how long a shown message is remembered to recognize a replayed copy
+const replayDedupWindow = 30 * time.Minute
+
+// replayGrace is how long after a reconnect messages without an ID are matched by their content.
+// Outside it an identical message is a genuine repeat and is shown.
+const replayGrace = time.Minute
+
+// newMessageID returns a random ID for an outgoing message
+func newMessageID() string {
+	id := make([]byte, 8)
+	rand.Read(id)
+	return hex.EncodeToString(id)
+}
+
+// withMessageID adds a fresh message ID to the envelope of outgoing plaintext
+func withMessageID(plaintext string) string {
+	headers, body := splitHeaders(plaintext)
+	headers.Set("mid", newMessageID())
+	return joinHeaders(headers, body)
+}
+
+// replayedDuplicate reports whether an incoming message was already shown this session. Messages
+// are matched by sender and message ID; those from older clients, which carry no ID, are matched
+// by sender and content only while the server may be replaying messages after a reconnect.
+func (m *model) replayedDuplicate(sender string, broadcast bool, env envelope) bool {
+	now := time.Now()
+	for key, at := range m.shownMessages {
+		if now.Sub(at) > replayDedupWindow {
+			delete(m.shownMessages, key)
+		}
+	}
+	var key string
+	if env.id != "" {
+		key = "id\x00" + sender + "\x00" + env.id
+	} else {
+		source := "MESSAGE"
+		if broadcast {
+			source = "BROADCAST"
+		}
+		key = "text\x00" + source + "\x00" + messageKey(sender, env.body)
+	}
+	if _, shown := m.shownMessages[key]; shown && (env.id != "" || now.Sub(m.reconnectedAt) <= replayGrace) {
+		return true
+	}
+	m.shownMessages[key] = now
+	return false
+}
@@ -0,0 +1,116 @@
+// desktop.go
+// Package ui shows desktop notifications for direct messages and mentions while the terminal
+// is not focused, through the notification tool of each operating system.
+
+package ui
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// desktopNotify turns on desktop notifications
+var desktopNotify bool
+
+// desktopErrorMsg reports a desktop notification that could not be shown
+type desktopErrorMsg struct{ err error }
+
+// windowsBalloonScript shows a balloon notification from the system tray on Windows. The title
+// and text come from the environment so they are never parsed as PowerShell.
+const windowsBalloonScript = `Add-Type -AssemblyName System.Windows.Forms
+$icon = New-Object System.Windows.Forms.NotifyIcon
+$icon.Icon = [System.Drawing.SystemIcons]::Information
+$icon.Visible = $true
+$icon.ShowBalloonTip(5000, $env:PADCLIENT_NOTIFY_TITLE, $env:PADCLIENT_NOTIFY_TEXT, 'None')
+Start-Sleep -Seconds 6
+$icon.Dispose()`
+
+func init() {
+	registerCommand("/desktop", commandSpec{
+		usage:   "/desktop on|off [conversation]",
+		help:    "Turn desktop notifications on or off, for all conversations or one",
+		minArgs: 1,
+		run: func(m *model, args []string) tea.Cmd {
+			if !desktopNotify {
+				m.appendMessage("Desktop notifications are off. Start the client with -desktop-notify.")
+				return nil
+			}
+			conversation := "*"
+			if len(args) > 1 {
+				conversation = args[1]
+			}
+			switch args[0] {
+			case "on":
+				if conversation == "*" {
+					// Turning everything on also clears per-conversation mutes
+					m.desktopMuted = make(map[string]bool)
+				} else {
+					m.desktopMuted[conversation] = false
+				}
+			case "off":
+				m.desktopMuted[conversation] = true
+			default:
+				m.appendMessage("Usage: " + knownCommands["/desktop"].usage)
+				return nil
+			}
+			m.appendMessage(fmt.Sprintf("Desktop notifications %s for %s.", args[0], describeConversation(conversation)))
+			return nil
+		},
+	})
+}
+
+// desktopMutedFor reports whether desktop notifications are muted for the conversation
+func (m *model) desktopMutedFor(conversation string) bool {
+	if muted, ok := m.desktopMuted[conversation]; ok {
+		return muted
+	}
+	return m.desktopMuted["*"]
+}
+
+// notifyDesktop shows a desktop notification for an incoming direct message or mention when the
+// terminal is not focused. In amnesia mode the text is left out, since notification centers keep it.
+func (m *model) notifyDesktop(entry chatEntry) tea.Cmd {
+	if !desktopNotify || !m.unfocused || m.desktopMutedFor(entry.conversation()) || !m.shouldNotify(entry) {
+		return nil
+	}
+	title := "Message from " + entry.sender
+	if entry.kind == entryBroadcast {
+		title = entry.sender + " mentioned you"
+	}
+	text, _ := m.mask.apply(entry.content)
+	if amnesia {
+		text = "Open padclient to read it."
+	}
+	return showDesktopNotification(title, text)
+}
+
+// showDesktopNotification runs the system's notification tool in the background
+func showDesktopNotification(title, text string) tea.Cmd {
+	return func() tea.Msg {
+		var cmd *exec.Cmd
+		switch runtime.GOOS {
+		case "darwin":
+			cmd = exec.Command("osascript",
+				"-e", "on run argv", "-e", "display notification (item 2 of argv) with title (item 1 of argv)", "-e", "end run",
+				title, text)
+		case "windows":
+			cmd = exec.Command("powershell", "-NoProfile", "-NonInteractive", "-Command", windowsBalloonScript)
+			cmd.Env = append(os.Environ(), "PADCLIENT_NOTIFY_TITLE="+title, "PADCLIENT_NOTIFY_TEXT="+text)
+		default:
+			// notify-send from libnotify, on Linux and the BSDs
+			cmd = exec.Command("notify-send", "--app-name=padclient", "--", title, text)
+		}
+		if err := cmd.Run(); err != nil {
+			if errors.Is(err, exec.ErrNotFound) {
+				err = fmt.Errorf("%s is not installed", cmd.Path)
+			}
+			return desktopErrorMsg{err}
+		}
+		return nil
+	}
+}
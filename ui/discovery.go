@@ -0,0 +1,91 @@
+// discovery.go
+// Package ui finds the server's host and port from _padserver._tcp SRV and TXT records, so a
+// deployment can move the server to another port without breaking saved configs.
+
+package ui
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// discoveryTimeout bounds the DNS lookups made before connecting
+const discoveryTimeout = 5 * time.Second
+
+// portSet records whether the port was given with -port or in the config file, which turns
+// discovery off
+var portSet bool
+
+// portValue is the -port flag; setting it also turns discovery off
+type portValue struct{}
+
+func (portValue) String() string { return strconv.Itoa(serverPort) }
+
+func (portValue) Set(s string) error {
+	port, err := strconv.Atoi(s)
+	if err != nil || port < 1 || port > 65535 {
+		return &strconv.NumError{Func: "port", Num: s, Err: strconv.ErrRange}
+	}
+	serverPort, portSet = port, true
+	return nil
+}
+
+// discoveredServer is where the DNS records say the server listens
+type discoveredServer struct {
+	host   string   // Host to dial
+	port   int      // Port to dial
+	caps   []string // Capabilities the TXT record advertises, as a hint before connecting
+	source string   // Which records supplied the address, for the startup message
+}
+
+// discoverServer looks up the SRV record _padserver._tcp.<host> and the TXT record of the same
+// name, which may hold space- or semicolon-separated hints such as "port=12345 caps=PIN,TOPIC".
+// The SRV record wins over a TXT port. Nothing is looked up when the port was set, or for IP
+// addresses and loopback names.
+func discoverServer(host string) (discoveredServer, bool) {
+	if portSet || net.ParseIP(host) != nil || isLoopbackHost(host) {
+		return discoveredServer{}, false
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), discoveryTimeout)
+	defer cancel()
+
+	found := discoveredServer{host: host, port: serverPort}
+	ok := false
+	if txts, err := net.DefaultResolver.LookupTXT(ctx, "_padserver._tcp."+host); err == nil {
+		for _, txt := range txts {
+			for _, field := range strings.FieldsFunc(txt, func(r rune) bool { return r == ' ' || r == ';' }) {
+				key, value, _ := strings.Cut(field, "=")
+				switch strings.ToLower(key) {
+				case "port":
+					if port, err := strconv.Atoi(value); err == nil && port > 0 && port <= 65535 {
+						found.port, found.source, ok = port, "TXT", true
+					}
+				case "caps":
+					found.caps = strings.Split(strings.ToUpper(value), ",")
+					if found.source == "" {
+						found.source, ok = "TXT", true
+					}
+				}
+			}
+		}
+	}
+	// LookupSRV orders the records by priority and, within a priority, randomly by weight.
+	// A target of "." means the domain explicitly offers no server.
+	if _, srvs, err := net.DefaultResolver.LookupSRV(ctx, "padserver", "tcp", host); err == nil && len(srvs) > 0 && srvs[0].Target != "." {
+		found.host = strings.TrimSuffix(srvs[0].Target, ".")
+		found.port = int(srvs[0].Port)
+		found.source, ok = "SRV", true
+	}
+	return found, ok
+}
+
+// resolveServer returns the address to dial for host, from its DNS records unless the port was set
+func resolveServer(host string) string {
+	if found, ok := discoverServer(host); ok {
+		return net.JoinHostPort(found.host, strconv.Itoa(found.port))
+	}
+	return net.JoinHostPort(host, strconv.Itoa(serverPort))
+}
@@ -0,0 +1,354 @@
+// dmkeys.go
+// Package ui agrees an X25519 key with each peer for direct messages, so they are no longer sent
+// with their one-time key on the same line, where the server can read it. Clients offer their key
+// on the first direct message to a peer; once both sides have the other's key, direct messages are
+// encrypted with AES under the agreed key.
+
+package ui
+
+import (
+	"crypto/ecdh"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/drewwalton19216801/padclient/crypto"
+	"github.com/drewwalton19216801/padclient/protocol"
+)
+
+// dhRefPrefix starts the key field of a direct message encrypted with an agreed key
+const dhRefPrefix = "dh:"
+
+// dmKeyContext is mixed into every agreed key, so it is only ever used for direct messages
+const dmKeyContext = "padclient-dm-v1"
+
+// dmKeys holds this process's key agreements; nil until an identity key is loaded, which leaves
+// direct messages on one-time keys. The UI, headless, daemon, and tail modes set it; the send and
+// relay subcommands never offer or answer a key, so peers keep using one-time keys with them.
+var dmKeys *keyAgreement
+
+// keyAgreement holds our X25519 key and the keys agreed with peers
+type keyAgreement struct {
+	mu        sync.Mutex
+	private   *ecdh.PrivateKey
+	peers     map[string]*agreedKey // By peer ID
+	offered   map[string]bool       // Peers sent our key this session
+	requested map[string]bool       // Peers asked to agree a new key since the last agreement
+}
+
+// agreedKey is the key shared with one peer
+type agreedKey struct {
+	public []byte // The peer's X25519 public key
+	key    []byte // AES key derived from the shared secret
+	id     string // Short ID of the key, sent with each message so a stale key is detected
+}
+
+// noAgreedKeyError reports a direct message encrypted with a key we do not have, usually because
+// this client restarted since the key was agreed
+type noAgreedKeyError struct {
+	peer string
+}
+
+func (e noAgreedKeyError) Error() string {
+	return fmt.Sprintf("no key agreed with %s matches the message; asking them to agree a new one", e.peer)
+}
+
+// newKeyAgreement derives our X25519 key from the identity key, so it stays the same across
+// restarts and peers' agreed keys remain valid
+func newKeyAgreement(id *identity) (*keyAgreement, error) {
+	seed := sha256.Sum256(append([]byte("padclient-x25519\x00"), id.private.Seed()...))
+	private, err := ecdh.X25519().NewPrivateKey(seed[:])
+	if err != nil {
+		return nil, fmt.Errorf("error deriving key agreement key: %v", err)
+	}
+	return &keyAgreement{private: private, peers: make(map[string]*agreedKey), offered: make(map[string]bool), requested: make(map[string]bool)}, nil
+}
+
+// publicKey returns our X25519 public key, base64-encoded for the kx envelope header
+func (k *keyAgreement) publicKey() string {
+	return base64.StdEncoding.EncodeToString(k.private.PublicKey().Bytes())
+}
+
+// agree derives the key shared with peer from their public key, replacing any earlier one. It
+// reports whether the peer's key is new or changed, in which case they may not have ours.
+func (k *keyAgreement) agree(peer, encodedKey string) (bool, error) {
+	public, err := base64.StdEncoding.DecodeString(encodedKey)
+	if err != nil {
+		return false, fmt.Errorf("invalid key agreement key from %s", peer)
+	}
+	peerKey, err := ecdh.X25519().NewPublicKey(public)
+	if err != nil {
+		return false, fmt.Errorf("invalid key agreement key from %s: %v", peer, err)
+	}
+	shared, err := k.private.ECDH(peerKey)
+	if err != nil {
+		return false, fmt.Errorf("error agreeing a key with %s: %v", peer, err)
+	}
+	// Both sides hash the two public keys in the same order
+	ours := k.private.PublicKey().Bytes()
+	first, second := ours, public
+	if string(first) > string(second) {
+		first, second = second, first
+	}
+	digest := sha256.New()
+	digest.Write([]byte(dmKeyContext))
+	digest.Write(shared)
+	digest.Write(first)
+	digest.Write(second)
+	key := digest.Sum(nil)
+	sum := sha256.Sum256(key)
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	previous := k.peers[peer]
+	k.peers[peer] = &agreedKey{public: public, key: key, id: hex.EncodeToString(sum[:4])}
+	delete(k.requested, peer)
+	return previous == nil || string(previous.public) != string(public), nil
+}
+
+// agreed returns the key agreed with peer, or nil
+func (k *keyAgreement) agreed(peer string) *agreedKey {
+	if k == nil {
+		return nil
+	}
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	return k.peers[peer]
+}
+
+// agreedPeers returns the peers a key is agreed with, sorted
+func (k *keyAgreement) agreedPeers() []string {
+	if k == nil {
+		return nil
+	}
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	peers := make([]string, 0, len(k.peers))
+	for peer := range k.peers {
+		peers = append(peers, peer)
+	}
+	sort.Strings(peers)
+	return peers
+}
+
+// offer adds our public key to the envelope of the first direct message to a peer we have no key
+// with, so a client that supports key agreement answers with theirs
+func (k *keyAgreement) offer(sender, recipientID, plaintext string) string {
+	if k == nil || recipientID == sender || recipientID == "ALL" {
+		return plaintext
+	}
+	if _, ok := parseExcept(recipientID); ok {
+		return plaintext
+	}
+	k.mu.Lock()
+	if k.peers[recipientID] != nil || k.offered[recipientID] {
+		k.mu.Unlock()
+		return plaintext
+	}
+	k.offered[recipientID] = true
+	k.mu.Unlock()
+	headers, body := splitHeaders(plaintext)
+	headers.Set("kx", k.publicKey())
+	return joinHeaders(headers, body)
+}
+
+// markOffered records that our key was sent to peer, and reports whether it had been already
+func (k *keyAgreement) markOffered(peer string) bool {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	sent := k.offered[peer]
+	k.offered[peer] = true
+	return sent
+}
+
+// request records that peer is asked to agree a new key, and reports whether they already were
+// since the last agreement, so a burst of undecryptable messages sends one request
+func (k *keyAgreement) request(peer string) bool {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	asked := k.requested[peer]
+	k.requested[peer] = true
+	return asked
+}
+
+// forget drops the key agreed with peer, so messages to them go back to one-time keys until a new
+// one is agreed
+func (k *keyAgreement) forget(peer string) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	delete(k.peers, peer)
+	delete(k.offered, peer)
+}
+
+// encryptAgreed encrypts a direct message with the key agreed with the recipient and returns the
+// MAC of the ciphertext, keyed from the agreed key
+func encryptAgreed(agreed *agreedKey, plaintext []byte) (string, []byte, []byte, cipherInfo, error) {
+	ciphertext, err := crypto.EncryptAES(agreed.key, plaintext)
+	if err != nil {
+		return "", nil, nil, cipherInfo{}, fmt.Errorf("error encrypting message: %v", err)
+	}
+	mac := crypto.SumMAC(crypto.MACKey(agreed.key), ciphertext)
+	return dhRefPrefix + agreed.id, ciphertext, mac, agreedKeyInfo(agreed.key), nil
+}
+
+// decryptAgreed checks the MAC of a direct message from peer and decrypts it with the key agreed
+// with them. A MAC that does not match fails with errIntegrityCheck.
+func decryptAgreed(peer, ref string, ciphertext, mac []byte) ([]byte, cipherInfo, error) {
+	agreed := dmKeys.agreed(peer)
+	if agreed == nil || ref != dhRefPrefix+agreed.id {
+		return nil, cipherInfo{}, noAgreedKeyError{peer: peer}
+	}
+	if !crypto.CheckMAC(crypto.MACKey(agreed.key), ciphertext, mac) {
+		return nil, cipherInfo{}, fmt.Errorf("%w: the message was altered or corrupted on the way", errIntegrityCheck)
+	}
+	plaintext, err := crypto.DecryptAES(agreed.key, ciphertext)
+	if err != nil {
+		return nil, cipherInfo{}, fmt.Errorf("error decrypting message: %v", err)
+	}
+	return plaintext, agreedKeyInfo(agreed.key), nil
+}
+
+// agreedKeyInfo describes a message encrypted with a key agreed with the peer
+func agreedKeyInfo(key []byte) cipherInfo {
+	return cipherInfo{
+		cipher:      "AES-256-CBC with an X25519 key agreed with the peer, authenticated with HMAC-SHA256",
+		fingerprint: keyFingerprint(key),
+	}
+}
+
+// keyHandshakeLine returns the line carrying our key to peer in a message that is not shown:
+// "hello" asks for theirs in return, "reply" answers one. Handshakes always use a one-time key,
+// since the peer may not have the agreed key yet.
+func keyHandshakeLine(encode protocol.Encoder, id *identity, clientID, peer, kind string) (string, error) {
+	dmKeys.markOffered(peer)
+	text := envelope{keyOffer: dmKeys.publicKey(), handshake: kind}.seal()
+	line, _, err := encodeOneTimeKey(encode, peer, id.sign(clientID, peer, withMessageID(text)))
+	return line, err
+}
+
+// answerKeyOffer agrees a key with the sender of a direct message carrying theirs. It returns our
+// handshake to send back when they may not have our key, and whether their key is new or changed.
+// Offers are only accepted with a valid signature from the key pinned for the sender, so nobody
+// else can slip in a key of their own.
+func answerKeyOffer(encode protocol.Encoder, id *identity, keys *keyStore, clientID string, msg incomingMessage, env envelope) (string, bool, error) {
+	if dmKeys == nil || msg.isBroadcast || msg.senderID == clientID {
+		return "", false, nil
+	}
+	key, status := verifySignature(msg.senderID, clientID, msg.content)
+	if status == signatureValid {
+		status, _ = keys.check(msg.senderID, key)
+	}
+	if !status.trusted() {
+		return "", false, fmt.Errorf("ignored a key agreement offer from %s that is not signed with their pinned identity key", msg.senderID)
+	}
+	changed, err := dmKeys.agree(msg.senderID, env.keyOffer)
+	if err != nil {
+		return "", false, err
+	}
+	// Replies are never answered, so two clients cannot keep sending keys back and forth
+	if env.handshake == "hello" || (env.handshake == "" && (changed || !dmKeys.markOffered(msg.senderID))) {
+		reply, err := keyHandshakeLine(encode, id, clientID, msg.senderID, "reply")
+		return reply, changed, err
+	}
+	return "", changed, nil
+}
+
+// answerKeyAgreement handles the key agreement part of a message from the reader in the modes
+// without the UI. It agrees a key with a peer who offers theirs, and asks a peer whose message was
+// encrypted with a key we lack to agree a new one; that message is lost, as there is no quarantine
+// to hold it. It returns the line to send, if any, and what to tell the user, if anything.
+func answerKeyAgreement(encode protocol.Encoder, id *identity, keys *keyStore, clientID string, msg tea.Msg) (string, string) {
+	switch msg := msg.(type) {
+	case incomingMessage:
+		env := openEnvelope(msg.content)
+		if env.keyOffer == "" {
+			return "", ""
+		}
+		reply, changed, err := answerKeyOffer(encode, id, keys, clientID, msg, env)
+		switch {
+		case err != nil:
+			return reply, fmt.Sprintf("Warning: %v.", err)
+		case changed:
+			return reply, fmt.Sprintf("Agreed a direct message key with %s; messages to them no longer carry their key.", msg.senderID)
+		}
+		return reply, ""
+	case integrityFailureMsg:
+		var noKey noAgreedKeyError
+		if dmKeys == nil || !errors.As(msg.err, &noKey) || dmKeys.request(noKey.peer) {
+			return "", ""
+		}
+		dmKeys.forget(noKey.peer)
+		hello, err := keyHandshakeLine(encode, id, clientID, noKey.peer, "hello")
+		if err != nil {
+			return "", fmt.Sprintf("Error sending key agreement to %s: %v", noKey.peer, err)
+		}
+		return hello, ""
+	}
+	return "", ""
+}
+
+// acceptKeyOffer agrees a key with the sender of a direct message carrying theirs, and sends ours
+// back when they may not have it. Messages from the sender quarantined for lack of the key are
+// delivered once it is agreed.
+func (m *model) acceptKeyOffer(msg incomingMessage, env envelope) tea.Cmd {
+	reply, changed, err := answerKeyOffer(m.payloadEncoder(), m.identity, m.knownKeys, m.clientID, msg, env)
+	if reply != "" {
+		m.writeLine(reply)
+	}
+	if err != nil {
+		m.appendMessage(fmt.Sprintf("Warning: %v.", err))
+		return nil
+	}
+	if changed {
+		m.appendMessage(fmt.Sprintf("Agreed a direct message key with %s; messages to them no longer carry their key.", msg.senderID))
+	}
+	return m.retryAgreedFrom(msg.senderID)
+}
+
+// sendKeyHandshake sends our key to peer in a handshake message; see keyHandshakeLine
+func (m *model) sendKeyHandshake(peer, kind string) {
+	line, err := keyHandshakeLine(m.payloadEncoder(), m.identity, m.clientID, peer, kind)
+	if err != nil {
+		m.appendMessage(fmt.Sprintf("Error sending key agreement to %s: %v", peer, err))
+		return
+	}
+	m.writeLine(line)
+}
+
+// missingAgreedKey handles a direct message encrypted with a key we lack. The reader decrypts ahead
+// of the UI, so the key may have been agreed since, in which case the message is decrypted again.
+// Otherwise it is quarantined and the sender is asked to agree a new key; it is delivered from the
+// quarantine once they do.
+func (m *model) missingAgreedKey(msg integrityFailureMsg, peer string) tea.Cmd {
+	if decoded, err := decodeMessage(msg.source, msg.senderID, m.clientID, msg.payload, m.hashedSecret, m.pads); err == nil {
+		return m.receiveMessage(decoded)
+	}
+	m.quarantineMessage(msg)
+	if dmKeys != nil && !dmKeys.request(peer) {
+		dmKeys.forget(peer)
+		m.sendKeyHandshake(peer, "hello")
+	}
+	return nil
+}
+
+// retryAgreedFrom delivers quarantined direct messages from peer that now decrypt
+func (m *model) retryAgreedFrom(peer string) tea.Cmd {
+	var cmds []tea.Cmd
+	kept := m.quarantine[:0]
+	for _, held := range m.quarantine {
+		if held.senderID == peer && held.source == "MESSAGE" {
+			if msg, err := decodeMessage(held.source, held.senderID, m.clientID, held.payload, m.hashedSecret, m.pads); err == nil {
+				cmds = append(cmds, m.receiveMessage(msg))
+				continue
+			}
+		}
+		kept = append(kept, held)
+	}
+	m.quarantine = kept
+	return tea.Batch(cmds...)
+}
@@ -0,0 +1,220 @@
+package ui
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/drewwalton19216801/padclient/protocol"
+)
+
+// newTestKeyAgreement returns an identity and the key agreement derived from it
+func newTestKeyAgreement(t *testing.T) (*identity, *keyAgreement) {
+	t.Helper()
+	id, err := newIdentity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys, err := newKeyAgreement(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return id, keys
+}
+
+// useKeyAgreement makes keys this process's key agreement for the rest of the test
+func useKeyAgreement(t *testing.T, keys *keyAgreement) {
+	previous := dmKeys
+	dmKeys = keys
+	t.Cleanup(func() { dmKeys = previous })
+}
+
+// TestKeyAgreement checks that both sides derive the same key and key ID from each other's public
+// keys, that a restart derives the same X25519 key, and that a message encrypted with the agreed key
+// decrypts only with it
+func TestKeyAgreement(t *testing.T) {
+	aliceID, alice := newTestKeyAgreement(t)
+	_, bob := newTestKeyAgreement(t)
+	for _, side := range []struct {
+		keys      *keyAgreement
+		peer, key string
+	}{{alice, "bob", bob.publicKey()}, {bob, "alice", alice.publicKey()}} {
+		changed, err := side.keys.agree(side.peer, side.key)
+		if err != nil || !changed {
+			t.Fatalf("agreeing with %s: changed %v, %v", side.peer, changed, err)
+		}
+		if changed, err := side.keys.agree(side.peer, side.key); err != nil || changed {
+			t.Errorf("agreeing with %s again: changed %v, %v", side.peer, changed, err)
+		}
+	}
+	ours, theirs := alice.agreed("bob"), bob.agreed("alice")
+	if string(ours.key) != string(theirs.key) || ours.id != theirs.id {
+		t.Fatalf("alice agreed key %s, bob agreed key %s", ours.id, theirs.id)
+	}
+
+	restarted, err := newKeyAgreement(aliceID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if restarted.publicKey() != alice.publicKey() {
+		t.Error("the key agreement key changed across a restart")
+	}
+
+	for _, invalid := range []string{"not base64!", "AAAA"} {
+		if _, err := alice.agree("carol", invalid); err == nil {
+			t.Errorf("agreed a key with %q", invalid)
+		}
+	}
+
+	ref, ciphertext, mac, _, err := encryptAgreed(ours, []byte("hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ref != dhRefPrefix+ours.id {
+		t.Errorf("reference %q, want %q", ref, dhRefPrefix+ours.id)
+	}
+	useKeyAgreement(t, bob)
+	if plaintext, _, err := decryptAgreed("alice", ref, ciphertext, mac); err != nil || string(plaintext) != "hello" {
+		t.Errorf("bob decrypted %q, %v", plaintext, err)
+	}
+	if _, _, err := decryptAgreed("alice", dhRefPrefix+"00000000", ciphertext, mac); err == nil || !strings.Contains(err.Error(), "no key agreed") {
+		t.Errorf("a stale key ID gave %v", err)
+	}
+	mac[0] ^= 1
+	if _, _, err := decryptAgreed("alice", ref, ciphertext, mac); err == nil || !strings.Contains(err.Error(), "integrity check failed") {
+		t.Errorf("an altered MAC gave %v", err)
+	}
+}
+
+// TestKeyOffer checks that our public key goes out in the kx header of the first direct message to
+// a peer only, survives signing, and is read back from the envelope
+func TestKeyOffer(t *testing.T) {
+	id, alice := newTestKeyAgreement(t)
+	tests := []struct {
+		recipient string
+		want      bool
+	}{
+		{"bob", true},
+		{"bob", false}, // Offered already
+		{"ALL", false},
+		{"alice", false},
+		{exceptPrefix + "bob", false},
+	}
+	for _, tt := range tests {
+		content := id.sign("alice", tt.recipient, alice.offer("alice", tt.recipient, withMessageID("hello")))
+		env := openEnvelope(content)
+		if got := env.keyOffer == alice.publicKey(); got != tt.want {
+			t.Errorf("to %s: offered %v, want %v", tt.recipient, got, tt.want)
+		}
+		if env.body != "hello" {
+			t.Errorf("to %s: body %q, want hello", tt.recipient, env.body)
+		}
+		if _, status := verifySignature("alice", signatureRecipient(tt.recipient), content); status != signatureValid {
+			t.Errorf("to %s: signature %q", tt.recipient, status.name())
+		}
+	}
+}
+
+// TestAnswerKeyOffer checks that an offer is only agreed when signed by the key pinned for the
+// sender, or the first key seen from them, and that a hello is answered with our key
+func TestAnswerKeyOffer(t *testing.T) {
+	bobID, bob := newTestKeyAgreement(t)
+	impostor, _ := newTestKeyAgreement(t)
+	aliceID, _ := newTestKeyAgreement(t)
+	tests := []struct {
+		name   string
+		signer *identity // nil for an unsigned offer
+		pin    *identity // Key pinned for bob beforehand, if any
+		agreed bool
+	}{
+		{"pinned key", bobID, bobID, true},
+		{"first key", bobID, nil, true},
+		{"unsigned", nil, nil, false},
+		{"other key", impostor, bobID, false},
+	}
+	for _, tt := range tests {
+		_, alice := newTestKeyAgreement(t)
+		useKeyAgreement(t, alice)
+		keys, err := loadKnownKeys("")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tt.pin != nil {
+			if _, err := keys.check("bob", tt.pin.public); err != nil {
+				t.Fatal(err)
+			}
+		}
+		content := withMessageID(envelope{keyOffer: bob.publicKey(), handshake: "hello"}.seal())
+		if tt.signer != nil {
+			content = tt.signer.sign("bob", "alice", content)
+		}
+		msg := incomingMessage{senderID: "bob", content: content}
+		reply, changed, err := answerKeyOffer(protocol.EncodeHex, aliceID, keys, "alice", msg, openEnvelope(content))
+		if got := alice.agreed("bob") != nil; got != tt.agreed || changed != tt.agreed {
+			t.Errorf("%s: agreed %v, changed %v; want %v", tt.name, got, changed, tt.agreed)
+		}
+		if tt.agreed && (err != nil || !strings.HasPrefix(reply, "SEND bob ")) {
+			t.Errorf("%s: reply %q, %v", tt.name, reply, err)
+		}
+		if !tt.agreed && (reply != "" || err == nil || !strings.Contains(err.Error(), "pinned identity key")) {
+			t.Errorf("%s: reply %q, %v; want no reply and an error", tt.name, reply, err)
+		}
+	}
+}
+
+// TestQuarantineUntilKeyAgreed checks that a direct message encrypted with a key we lack is
+// quarantined, the sender is asked once to agree a new key, and the message is delivered from the
+// quarantine when they answer
+func TestQuarantineUntilKeyAgreed(t *testing.T) {
+	aliceID, alice := newTestKeyAgreement(t)
+	bobID, bob := newTestKeyAgreement(t)
+	keys, err := loadKnownKeys("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := &model{
+		clientID:  "alice",
+		identity:  aliceID,
+		knownKeys: keys,
+		writer:    &connWriter{lines: make(chan string, writeQueue)},
+		// Leave the viewport, which the test does not set up, alone
+		renderScheduled: true,
+	}
+
+	// Bob agreed a key with alice before she restarted and lost it. The message is cover traffic,
+	// which is dropped once delivered, so delivering it needs no more of the model.
+	if _, err := bob.agree("alice", alice.publicKey()); err != nil {
+		t.Fatal(err)
+	}
+	useKeyAgreement(t, bob)
+	line, _, err := encodeSendLineWith(protocol.EncodeHex, nil, nil, "alice", coverMarker)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dmKeys = alice
+	payload := strings.TrimPrefix(line, "SEND alice ")
+	if !strings.HasPrefix(payload, dhRefPrefix) {
+		t.Fatalf("bob sent %q, want a message encrypted with the agreed key", line)
+	}
+	_, err = decodeMessage("MESSAGE", "bob", "alice", payload, nil, nil)
+	failure := integrityFailureMsg{source: "MESSAGE", senderID: "bob", payload: payload, err: err}
+	for i := 0; i < 2; i++ {
+		m.missingAgreedKey(failure, "bob")
+	}
+	if len(m.quarantine) != 2 {
+		t.Fatalf("%d messages quarantined, want 2", len(m.quarantine))
+	}
+	if n := len(m.writer.lines); n != 1 {
+		t.Fatalf("%d lines sent, want one key agreement hello", n)
+	}
+	hello := <-m.writer.lines
+	if !strings.HasPrefix(hello, "SEND bob ") {
+		t.Fatalf("sent %q, want a key agreement hello to bob", hello)
+	}
+
+	// Bob answers with their key, and the held messages now decrypt
+	reply := bobID.sign("bob", "alice", withMessageID(envelope{keyOffer: bob.publicKey(), handshake: "reply"}.seal()))
+	m.acceptKeyOffer(incomingMessage{senderID: "bob", content: reply}, openEnvelope(reply))
+	if len(m.quarantine) != 0 {
+		t.Errorf("%d messages still quarantined after the key was agreed", len(m.quarantine))
+	}
+}
@@ -0,0 +1,150 @@
+// envelope.go
+// Package ui wraps message text in an envelope carrying metadata such as forwarding annotations.
+
+package ui
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// envelopeMarker starts the plaintext of every message that carries envelope headers.
+// Messages without it are plain text, as sent by older clients.
+const envelopeMarker = "\x00env\x00"
+
+// envelope is a message body together with its metadata. It is sealed into the plaintext as
+// the marker, URL-encoded headers, a NUL byte, and the body.
+type envelope struct {
+	body          string
+	id            string   // Random ID that recognizes the message if the server delivers it again
+	forwardedFrom string   // Original sender of a forwarded message
+	thread        string   // Key of the thread a reply belongs to
+	poll          string   // ID of the poll this message asks
+	options       []string // Options of a poll
+	vote          string   // ID of the poll this message votes in
+	announce      bool     // Whether the message is an operator announcement
+	relay         string   // Key of the message a relayed alert repeats
+	hops          int      // Number of relays between servers the message has passed through
+	sync          bool     // Whether the message carries state synced between the user's own devices
+	keyOffer      string   // Sender's X25519 public key, offered to agree a direct message key
+	handshake     string   // "hello" or "reply" for a message that only carries keyOffer or padSync
+	padSync       string   // State of the pad shared with the recipient: <id>:<sent>:<received>
+	padDesync     string   // Why the sender refuses the pad shared with the recipient
+	transfer      string   // ID of the file transfer the message belongs to
+	transferOp    string   // What the message does in the transfer: offer, accept, decline, chunk, cancel, or done
+	chunk         int      // Index of the file chunk the message carries
+	chunkSum      string   // Hex SHA-256 of the chunk's data
+}
+
+// seal encodes the envelope as message plaintext, leaving messages without headers as plain text
+func (e envelope) seal() string {
+	headers := url.Values{}
+	if e.id != "" {
+		headers.Set("mid", e.id)
+	}
+	if e.forwardedFrom != "" {
+		headers.Set("fwd", e.forwardedFrom)
+	}
+	if e.thread != "" {
+		headers.Set("thread", e.thread)
+	}
+	if e.poll != "" {
+		headers.Set("poll", e.poll)
+		headers["opt"] = e.options
+	}
+	if e.vote != "" {
+		headers.Set("vote", e.vote)
+	}
+	if e.announce {
+		headers.Set("announce", "1")
+	}
+	if e.relay != "" {
+		headers.Set("relay", e.relay)
+	}
+	if e.hops > 0 {
+		headers.Set("hops", strconv.Itoa(e.hops))
+	}
+	if e.sync {
+		headers.Set("sync", "1")
+	}
+	if e.keyOffer != "" {
+		headers.Set("kx", e.keyOffer)
+	}
+	if e.handshake != "" {
+		headers.Set("handshake", e.handshake)
+	}
+	if e.padSync != "" {
+		headers.Set("padsync", e.padSync)
+	}
+	if e.padDesync != "" {
+		headers.Set("padbad", e.padDesync)
+	}
+	if e.transfer != "" {
+		headers.Set("xfer", e.transfer)
+		headers.Set("xop", e.transferOp)
+		if e.transferOp == "chunk" {
+			headers.Set("xseq", strconv.Itoa(e.chunk))
+			headers.Set("xsum", e.chunkSum)
+		}
+	}
+	if len(headers) == 0 {
+		return e.body
+	}
+	return envelopeMarker + headers.Encode() + "\x00" + e.body
+}
+
+// openEnvelope decodes message plaintext. Plain text and malformed envelopes are returned as the body.
+func openEnvelope(plaintext string) envelope {
+	rest, ok := strings.CutPrefix(plaintext, envelopeMarker)
+	if !ok {
+		return envelope{body: plaintext}
+	}
+	encoded, body, ok := strings.Cut(rest, "\x00")
+	if !ok {
+		return envelope{body: plaintext}
+	}
+	headers, err := url.ParseQuery(encoded)
+	if err != nil {
+		return envelope{body: plaintext}
+	}
+	hops, _ := strconv.Atoi(headers.Get("hops"))
+	chunk, _ := strconv.Atoi(headers.Get("xseq"))
+	return envelope{
+		body:          body,
+		id:            headers.Get("mid"),
+		forwardedFrom: headers.Get("fwd"),
+		thread:        headers.Get("thread"),
+		poll:          headers.Get("poll"),
+		options:       headers["opt"],
+		vote:          headers.Get("vote"),
+		announce:      headers.Get("announce") == "1",
+		relay:         headers.Get("relay"),
+		hops:          hops,
+		sync:          headers.Get("sync") == "1",
+		keyOffer:      headers.Get("kx"),
+		handshake:     headers.Get("handshake"),
+		padSync:       headers.Get("padsync"),
+		padDesync:     headers.Get("padbad"),
+		transfer:      headers.Get("xfer"),
+		transferOp:    headers.Get("xop"),
+		chunk:         chunk,
+		chunkSum:      headers.Get("xsum"),
+	}
+}
+
+// isControlEnvelope reports whether a decrypted message is traffic between clients rather than
+// something to show: cover traffic, a vote, device sync, a key or pad sync handshake, or part of
+// a file transfer
+func isControlEnvelope(content string, env envelope) bool {
+	return isCover(content) || env.vote != "" || env.sync || env.handshake != "" || env.transfer != "" || env.padSync != ""
+}
+
+// annotate fills in an entry's text and the metadata carried by the envelope
+func (e envelope) annotate(entry *chatEntry) {
+	entry.content = e.body
+	entry.forwardedFrom = e.forwardedFrom
+	entry.thread = e.thread
+	entry.pollID = e.poll
+	entry.announcement = e.announce
+}
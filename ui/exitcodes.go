@@ -0,0 +1,99 @@
+// exitcodes.go
+// Package ui defines the exit codes scripts can branch on and reports fatal errors as text or JSON.
+
+package ui
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+
+	"github.com/drewwalton19216801/padclient/transport"
+)
+
+// Exit codes are stable: a new kind of failure gets a new code rather than reusing one.
+const (
+	exitFailure      = 1 // A failure without a more specific code
+	exitUsage        = 2 // Invalid arguments
+	exitConnect      = 3 // The server could not be reached
+	exitAuth         = 4 // Registration or the key exchange failed
+	exitBanned       = 5 // The server banned or kicked this client
+	exitTimeout      = 6 // The server did not answer in time
+	exitNotTailscale = 7 // This machine is not on a Tailscale network
+	exitUntrusted    = 8 // The server is not in the allowlist or its key does not match the pin
+	exitBadRelease   = 9 // An update's signature does not verify, or it is not newer than the running version
+)
+
+// exitKinds names each exit code in JSON error reports
+var exitKinds = map[int]string{
+	exitFailure:      "failure",
+	exitUsage:        "usage",
+	exitConnect:      "connect",
+	exitAuth:         "auth",
+	exitBanned:       "banned",
+	exitTimeout:      "timeout",
+	exitNotTailscale: "not_tailscale",
+	exitUntrusted:    "untrusted_server",
+	exitBadRelease:   "bad_release",
+}
+
+// jsonErrors writes fatal errors to stderr as JSON objects instead of text
+var jsonErrors bool
+
+// fatalError is an error that ends the program with a specific exit code
+type fatalError struct {
+	code int
+	err  error
+}
+
+func (e *fatalError) Error() string { return e.err.Error() }
+func (e *fatalError) Unwrap() error { return e.err }
+
+// authFailure marks an error from registration or the key exchange, unless it already has a more specific code
+func authFailure(err error) error {
+	if code := exitCodeFor(err); code != exitFailure {
+		return err
+	}
+	return &fatalError{code: exitAuth, err: err}
+}
+
+// exitCodeFor picks the exit code for an error
+func exitCodeFor(err error) int {
+	var fatal *fatalError
+	if errors.As(err, &fatal) {
+		return fatal.code
+	}
+	if errors.Is(err, transport.ErrBanned) {
+		return exitBanned
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return exitTimeout
+	}
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "dial" {
+		return exitConnect
+	}
+	return exitFailure
+}
+
+// exitWith reports a fatal error and exits with its code. With -json-errors the report is a JSON
+// object on stderr: {"error": "<kind>", "code": <code>, "message": "<text>"}.
+func exitWith(err error) {
+	code := exitCodeFor(err)
+	if jsonErrors {
+		encoder := json.NewEncoder(os.Stderr)
+		encoder.SetEscapeHTML(false)
+		encoder.Encode(struct {
+			Error   string `json:"error"`
+			Code    int    `json:"code"`
+			Message string `json:"message"`
+		}{exitKinds[code], code, err.Error()})
+	} else {
+		fmt.Println(err)
+	}
+	stopTsnet()
+	os.Exit(code)
+}
@@ -0,0 +1,88 @@
+// export.go
+// Package ui exports the conversation buffer to a Markdown file.
+
+package ui
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func init() {
+	registerCommand("/export", commandSpec{
+		usage: "/export [path]",
+		help:  "Export the conversation to a Markdown file",
+		run: func(m *model, args []string) tea.Cmd {
+			if amnesia {
+				m.appendMessage("Export is disabled in amnesia mode.")
+				return nil
+			}
+			path := fmt.Sprintf("padclient-export-%s.md", time.Now().Format("20060102-150405"))
+			if len(args) > 0 {
+				path = args[0]
+			}
+			if err := os.WriteFile(path, []byte(exportMarkdown(m.clientID, m.entries)), 0600); err != nil {
+				m.appendMessage(fmt.Sprintf("Error exporting conversation: %v", err))
+				return nil
+			}
+			m.appendMessage(fmt.Sprintf("Conversation exported to %s", path))
+			return nil
+		},
+	})
+}
+
+// exportMarkdown formats the entries as Markdown with a header per day and
+// consecutive messages from the same sender grouped together.
+func exportMarkdown(clientID string, entries []chatEntry) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# padclient conversation (%s)\n", clientID)
+
+	var day, group string
+	for _, entry := range entries {
+		if entry.status == statusCancelled {
+			continue
+		}
+		if d := entry.at.Format("2006-01-02"); d != day {
+			day = d
+			group = ""
+			fmt.Fprintf(&b, "\n## %s\n", entry.at.Format("Monday, January 2, 2006"))
+		}
+		if entry.kind == entrySystem {
+			group = ""
+			fmt.Fprintf(&b, "\n_%s %s_\n", entry.at.Format("15:04"), markdownEscape(entry.content))
+			continue
+		}
+		if heading := markdownGroupHeading(entry); heading != group {
+			group = heading
+			fmt.Fprintf(&b, "\n**%s** · %s\n\n", heading, entry.at.Format("15:04"))
+		}
+		for _, line := range strings.Split(entry.content, "\n") {
+			fmt.Fprintf(&b, "> %s\n", line)
+		}
+	}
+	return b.String()
+}
+
+// markdownGroupHeading returns the sender heading used to group an entry
+func markdownGroupHeading(entry chatEntry) string {
+	switch entry.kind {
+	case entryOutgoing:
+		if entry.recipient == "ALL" {
+			return "You (broadcast)"
+		}
+		return "You → " + describeRecipient(entry.recipient)
+	case entryBroadcast:
+		return entry.sender + " (broadcast)"
+	default:
+		return entry.sender
+	}
+}
+
+// markdownEscape escapes characters that would change the meaning of inline Markdown
+func markdownEscape(s string) string {
+	return strings.NewReplacer("_", "\\_", "*", "\\*", "`", "\\`").Replace(s)
+}
@@ -0,0 +1,615 @@
+// filetransfer.go
+// Package ui implements SENDFILE, which offers a file to a peer and, once they accept, streams it
+// in chunks as encrypted direct messages over the bulk stream. The receiver checks every chunk and
+// the whole file against the hashes the sender gave before saving it.
+
+package ui
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/progress"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// downloadDir is the -download-dir setting: where accepted files are saved
+var downloadDir = defaultDownloadDir()
+
+// fileChunkSize is the file data carried by each chunk message. Once encoded, encrypted, and
+// signed, a chunk stays well under the server's line length limit.
+const fileChunkSize = 8 << 10
+
+// transferPumpDelay is how long the sender waits for the bulk queue to drain before queuing more
+// chunks
+const transferPumpDelay = 50 * time.Millisecond
+
+// transferBarWidth is the width of the progress bars shown above the input, in cells
+const transferBarWidth = 30
+
+// maxTransferSize bounds the size of a file that is sent or accepted
+const maxTransferSize = 4 << 30
+
+// transferKey identifies a transfer: IDs are chosen by the sender, so they are only unique per peer
+type transferKey struct {
+	peer string
+	id   string
+}
+
+// fileTransfer is a file being sent to or received from a peer
+type fileTransfer struct {
+	id       string
+	peer     string
+	outgoing bool
+	name     string    // Base name of the file
+	path     string    // File being sent, or the partial file being received
+	size     int64     // Size of the file in bytes
+	sum      string    // Hex SHA-256 of the whole file
+	chunks   int       // Number of chunks the file is sent in
+	done     int       // Chunks queued or received so far
+	accepted bool      // Whether the receiver accepted the offer
+	file     *os.File  // Open file while the transfer is running
+	hash     hash.Hash // Hash of the data received so far
+}
+
+// transferOffer is the body of an offer, describing the file to the receiver
+type transferOffer struct {
+	Name   string `json:"name"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+	Chunks int    `json:"chunks"`
+}
+
+// transferPreparedMsg carries a file opened and hashed for SENDFILE, ready to be offered
+type transferPreparedMsg struct {
+	transfer *fileTransfer
+	err      error
+}
+
+// transferTickMsg queues more chunks once the bulk queue has drained
+type transferTickMsg struct{}
+
+func init() {
+	registerCommand("SENDFILE", commandSpec{
+		usage:   "SENDFILE <RecipientID> <path>",
+		help:    "Offer a file to a client and send it in encrypted chunks once they accept",
+		minArgs: 2,
+		run: func(m *model, args []string) tea.Cmd {
+			peer := args[0]
+			if _, except := parseExcept(peer); except || peer == "ALL" || peer == m.clientID {
+				m.appendMessage("Files can only be sent to one other client.")
+				return nil
+			}
+			return prepareTransfer(peer, expandHome(strings.Join(args[1:], " ")))
+		},
+	})
+	registerCommand("/transfers", commandSpec{
+		usage: "/transfers [accept|decline|cancel <id>]",
+		help:  "List file transfers, or accept, decline, or cancel one",
+		run: func(m *model, args []string) tea.Cmd {
+			if len(args) == 0 {
+				m.listTransfers()
+				return nil
+			}
+			if len(args) < 2 {
+				m.appendMessage("Usage: " + knownCommands["/transfers"].usage)
+				return nil
+			}
+			t, err := m.findTransfer(args[1])
+			if err != nil {
+				m.appendMessage(err.Error())
+				return nil
+			}
+			switch strings.ToLower(args[0]) {
+			case "accept":
+				if t.outgoing || t.accepted {
+					m.appendMessage(fmt.Sprintf("Transfer %s is not waiting for you to accept it.", t.id))
+					return nil
+				}
+				m.acceptTransfer(t)
+			case "decline":
+				if t.outgoing || t.accepted {
+					m.appendMessage(fmt.Sprintf("Transfer %s is not waiting for you to accept it.", t.id))
+					return nil
+				}
+				m.declineTransfer(t)
+			case "cancel":
+				m.cancelTransfer(t, "cancelled by "+m.clientID)
+			default:
+				m.appendMessage("Usage: " + knownCommands["/transfers"].usage)
+			}
+			return nil
+		},
+	})
+}
+
+// defaultDownloadDir returns ~/Downloads, or "" when the home directory is unknown
+func defaultDownloadDir() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, "Downloads")
+}
+
+// expandHome replaces a leading ~/ with the home directory
+func expandHome(path string) string {
+	if rest, ok := strings.CutPrefix(path, "~/"); ok {
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, rest)
+		}
+	}
+	return path
+}
+
+// prepareTransfer opens and hashes a file off the UI goroutine, so a large file does not stall it
+func prepareTransfer(peer, path string) tea.Cmd {
+	return func() tea.Msg {
+		file, err := os.Open(path)
+		if err != nil {
+			return transferPreparedMsg{err: fmt.Errorf("error opening %s: %v", path, err)}
+		}
+		info, err := file.Stat()
+		switch {
+		case err != nil:
+		case !info.Mode().IsRegular():
+			err = fmt.Errorf("not a regular file")
+		case info.Size() > maxTransferSize:
+			err = fmt.Errorf("larger than the %s limit", formatSize(maxTransferSize))
+		}
+		if err != nil {
+			file.Close()
+			return transferPreparedMsg{err: fmt.Errorf("error sending %s: %v", path, err)}
+		}
+		digest := sha256.New()
+		size, err := io.Copy(digest, file)
+		if err != nil {
+			file.Close()
+			return transferPreparedMsg{err: fmt.Errorf("error reading %s: %v", path, err)}
+		}
+		return transferPreparedMsg{transfer: &fileTransfer{
+			id:       newMessageID(),
+			peer:     peer,
+			outgoing: true,
+			name:     filepath.Base(path),
+			path:     path,
+			size:     size,
+			sum:      hex.EncodeToString(digest.Sum(nil)),
+			chunks:   int((size + fileChunkSize - 1) / fileChunkSize),
+			file:     file,
+		}}
+	}
+}
+
+// offerTransfer sends the offer for a prepared file and waits for the peer to answer
+func (m *model) offerTransfer(msg transferPreparedMsg) {
+	if msg.err != nil {
+		m.appendMessage(msg.err.Error())
+		return
+	}
+	t := msg.transfer
+	offer, _ := json.Marshal(transferOffer{Name: t.name, Size: t.size, SHA256: t.sum, Chunks: t.chunks})
+	if !m.sendTransferMessage(t, envelope{body: string(offer), transferOp: "offer"}) {
+		t.file.Close()
+		return
+	}
+	m.transfers[t.key()] = t
+	m.appendMessage(fmt.Sprintf("Offered %s (%s) to %s as transfer %s; waiting for them to accept.", t.name, formatSize(t.size), t.peer, t.id))
+}
+
+// sendTransferMessage sends a control message of a transfer to the peer. Transfers never use the
+// pad: a file could use up every byte of it, so they go with the agreed key or a one-time key.
+func (m *model) sendTransferMessage(t *fileTransfer, env envelope) bool {
+	env.transfer = t.id
+	line, _, err := m.encodeSendWith(nil, t.peer, env.seal())
+	if err != nil {
+		m.appendMessage(fmt.Sprintf("Error sending file transfer message to %s: %v", t.peer, err))
+		return false
+	}
+	return m.writeLine(line)
+}
+
+// receiveTransfer handles a file transfer message from a peer
+func (m *model) receiveTransfer(msg incomingMessage, env envelope) tea.Cmd {
+	if msg.isBroadcast {
+		return nil
+	}
+	// Only the pinned key may offer a file or move a transfer along, so a spoofed sender ID cannot
+	// push a file or cancel one
+	key, status, err := m.knownKeys.checkMessage(msg.senderID, msg.senderID, m.clientID, msg.content)
+	if err != nil {
+		m.appendMessage(fmt.Sprintf("Error saving known keys: %v", err))
+	}
+	if !status.trusted() {
+		if env.transferOp != "chunk" {
+			m.appendMessage(fmt.Sprintf("Ignored a file transfer message from %s that is not signed with their pinned identity key.", msg.senderID))
+		}
+		return nil
+	}
+	// Transfers are looked up under the sender, so only the peer a transfer is with can move it along
+	t := m.transfers[transferKey{peer: msg.senderID, id: env.transfer}]
+	if env.transferOp == "offer" {
+		if t == nil {
+			m.receiveOffer(msg.senderID, env, status.describe(keyFingerprint(key)))
+		}
+		return nil
+	}
+	if t == nil {
+		return nil
+	}
+	switch env.transferOp {
+	case "accept":
+		if t.outgoing && !t.accepted {
+			t.accepted = true
+			if _, err := t.file.Seek(0, io.SeekStart); err != nil {
+				m.cancelTransfer(t, fmt.Sprintf("error reading %s: %v", t.name, err))
+				return nil
+			}
+			m.appendMessage(fmt.Sprintf("%s accepted %s; sending.", t.peer, t.name))
+			return m.pumpTransfers()
+		}
+	case "decline":
+		if t.outgoing {
+			m.endTransfer(t)
+			m.appendMessage(fmt.Sprintf("%s declined %s.", t.peer, t.name))
+		}
+	case "chunk":
+		if !t.outgoing && t.accepted {
+			m.receiveChunk(t, env)
+		}
+	case "done":
+		if t.outgoing && t.done == t.chunks {
+			m.endTransfer(t)
+			m.appendMessage(fmt.Sprintf("%s received %s and its checksum matched.", t.peer, t.name))
+		}
+	case "cancel":
+		m.endTransfer(t)
+		m.appendMessage(fmt.Sprintf("Transfer of %s %s %s stopped: %s", t.name, t.direction(), t.peer, env.body))
+	}
+	return nil
+}
+
+// receiveOffer records a file offered by a peer and tells the user how to accept it. The offer
+// waits for /transfers rather than opening a prompt, so a peer cannot grab the next keypress.
+// trust describes the signature on the offer.
+func (m *model) receiveOffer(peer string, env envelope, trust string) {
+	var offer transferOffer
+	if err := json.Unmarshal([]byte(env.body), &offer); err != nil {
+		m.appendMessage(fmt.Sprintf("Ignored a malformed file offer from %s: %v", peer, err))
+		return
+	}
+	name := filepath.Base(filepath.Clean("/" + strings.ReplaceAll(offer.Name, "\\", "/")))
+	t := &fileTransfer{id: env.transfer, peer: peer, name: name, size: offer.Size, sum: strings.ToLower(offer.SHA256), chunks: offer.Chunks}
+	if _, err := hex.DecodeString(t.sum); err != nil || len(t.sum) != 2*sha256.Size || t.size < 0 ||
+		int64(t.chunks) != (t.size+fileChunkSize-1)/fileChunkSize || name == "/" || name == "." {
+		m.appendMessage(fmt.Sprintf("Ignored a malformed file offer from %s.", peer))
+		return
+	}
+	m.transfers[t.key()] = t
+	switch {
+	case t.size > maxTransferSize:
+		m.appendMessage(fmt.Sprintf("Declined %s (%s) from %s: files over %s are not accepted.", name, formatSize(t.size), peer, formatSize(maxTransferSize)))
+		m.declineTransfer(t)
+		return
+	case amnesia:
+		m.appendMessage(fmt.Sprintf("Declined %s from %s: amnesia mode writes nothing to disk.", name, peer))
+		m.declineTransfer(t)
+		return
+	case downloadDir == "":
+		m.appendMessage(fmt.Sprintf("Declined %s from %s: no download directory is set (-download-dir).", name, peer))
+		m.declineTransfer(t)
+		return
+	}
+	ref := m.transferRef(t)
+	m.appendMessage(fmt.Sprintf("%s wants to send you %s (%s); the offer's signature is %s. Type /transfers accept %s to save it to %s, or /transfers decline %s.", peer, name, formatSize(t.size), trust, ref, downloadDir, ref))
+}
+
+// key returns the key the transfer is stored under
+func (t *fileTransfer) key() transferKey {
+	return transferKey{peer: t.peer, id: t.id}
+}
+
+// transferRef returns how commands refer to a transfer: its ID, or <peer>/<id> when another
+// transfer has the same ID
+func (m *model) transferRef(t *fileTransfer) string {
+	for key := range m.transfers {
+		if key.id == t.id && key.peer != t.peer {
+			return t.peer + "/" + t.id
+		}
+	}
+	return t.id
+}
+
+// findTransfer returns the transfer a command refers to, as <id> or <peer>/<id>
+func (m *model) findTransfer(ref string) (*fileTransfer, error) {
+	if slash := strings.LastIndex(ref, "/"); slash >= 0 {
+		if t := m.transfers[transferKey{peer: ref[:slash], id: ref[slash+1:]}]; t != nil {
+			return t, nil
+		}
+		return nil, fmt.Errorf("no file transfer %s; type /transfers to list them", ref)
+	}
+	var found *fileTransfer
+	for key, t := range m.transfers {
+		if key.id != ref {
+			continue
+		}
+		if found != nil {
+			return nil, fmt.Errorf("transfers with %s and %s have ID %s; give it as <peer>/%s", found.peer, t.peer, ref, ref)
+		}
+		found = t
+	}
+	if found == nil {
+		return nil, fmt.Errorf("no file transfer %s; type /transfers to list them", ref)
+	}
+	return found, nil
+}
+
+// acceptTransfer opens the partial file for an offered transfer and tells the sender to start
+func (m *model) acceptTransfer(t *fileTransfer) {
+	if err := os.MkdirAll(downloadDir, 0o700); err != nil {
+		m.cancelTransfer(t, fmt.Sprintf("error creating %s: %v", downloadDir, err))
+		return
+	}
+	file, err := os.CreateTemp(downloadDir, ".padclient-*.part")
+	if err != nil {
+		m.cancelTransfer(t, fmt.Sprintf("error creating a file in %s: %v", downloadDir, err))
+		return
+	}
+	t.file, t.path, t.hash, t.accepted = file, file.Name(), sha256.New(), true
+	if !m.sendTransferMessage(t, envelope{transferOp: "accept"}) {
+		m.endTransfer(t)
+		return
+	}
+	m.appendMessage(fmt.Sprintf("Receiving %s from %s.", t.name, t.peer))
+	if t.chunks == 0 {
+		m.finishTransfer(t)
+	}
+}
+
+// declineTransfer tells the sender the offer was declined
+func (m *model) declineTransfer(t *fileTransfer) {
+	m.endTransfer(t)
+	m.sendTransferMessage(t, envelope{transferOp: "decline"})
+}
+
+// receiveChunk checks a chunk and appends it to the partial file. Chunks must arrive in order and
+// match the hash sent with them; otherwise the transfer is cancelled.
+func (m *model) receiveChunk(t *fileTransfer, env envelope) {
+	if env.chunk != t.done {
+		m.cancelTransfer(t, fmt.Sprintf("chunk %d arrived when chunk %d was expected", env.chunk, t.done))
+		return
+	}
+	data, err := base64.StdEncoding.DecodeString(env.body)
+	sum := sha256.Sum256(data)
+	if err != nil || hex.EncodeToString(sum[:]) != env.chunkSum {
+		m.cancelTransfer(t, fmt.Sprintf("integrity check failed for chunk %d", env.chunk))
+		return
+	}
+	if t.received()+int64(len(data)) > t.size {
+		m.cancelTransfer(t, fmt.Sprintf("chunk %d runs past the offered size", env.chunk))
+		return
+	}
+	if _, err := t.file.Write(data); err != nil {
+		m.cancelTransfer(t, fmt.Sprintf("error writing %s: %v", t.path, err))
+		return
+	}
+	t.hash.Write(data)
+	t.done++
+	if t.done == t.chunks {
+		m.finishTransfer(t)
+	}
+}
+
+// finishTransfer checks the whole file against the offered hash and moves it into the download
+// directory under a name that does not replace an existing file
+func (m *model) finishTransfer(t *fileTransfer) {
+	if sum := hex.EncodeToString(t.hash.Sum(nil)); sum != t.sum {
+		m.cancelTransfer(t, "integrity check failed: the file's SHA-256 does not match the offer")
+		return
+	}
+	if err := t.file.Close(); err != nil {
+		m.cancelTransfer(t, fmt.Sprintf("error writing %s: %v", t.path, err))
+		return
+	}
+	t.file = nil
+	target := freeDownloadPath(downloadDir, t.name)
+	if err := os.Rename(t.path, target); err != nil {
+		m.cancelTransfer(t, fmt.Sprintf("error saving %s: %v", target, err))
+		return
+	}
+	delete(m.transfers, t.key())
+	m.sendTransferMessage(t, envelope{transferOp: "done"})
+	m.appendMessage(fmt.Sprintf("Received %s (%s) from %s; SHA-256 verified. Saved to %s.", t.name, formatSize(t.size), t.peer, target))
+}
+
+// freeDownloadPath returns a path for name in dir that is not taken, adding " (1)", " (2)", and so
+// on before the extension as needed
+func freeDownloadPath(dir, name string) string {
+	ext := filepath.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+	path := filepath.Join(dir, name)
+	for i := 1; ; i++ {
+		if _, err := os.Lstat(path); os.IsNotExist(err) {
+			return path
+		}
+		path = filepath.Join(dir, fmt.Sprintf("%s (%d)%s", base, i, ext))
+	}
+}
+
+// cancelTransfer stops a transfer and tells the peer why
+func (m *model) cancelTransfer(t *fileTransfer, reason string) {
+	m.endTransfer(t)
+	m.sendTransferMessage(t, envelope{body: reason, transferOp: "cancel"})
+	m.appendMessage(fmt.Sprintf("Transfer of %s %s %s stopped: %s", t.name, t.direction(), t.peer, reason))
+}
+
+// endTransfer forgets a transfer, closing its file and removing a partial download
+func (m *model) endTransfer(t *fileTransfer) {
+	delete(m.transfers, t.key())
+	if t.file != nil {
+		t.file.Close()
+		t.file = nil
+	}
+	if !t.outgoing && t.path != "" {
+		os.Remove(t.path)
+	}
+}
+
+// pumpTransfers queues chunks of accepted outgoing transfers while the bulk queue has room, and
+// schedules itself again until every chunk is queued
+func (m *model) pumpTransfers() tea.Cmd {
+	waiting := false
+	for _, t := range m.sortedTransfers() {
+		if !t.outgoing || !t.accepted || t.done == t.chunks {
+			continue
+		}
+		for t.done < t.chunks && !m.bulkQueueFull() {
+			if !m.sendChunk(t) {
+				break
+			}
+		}
+		if t.done < t.chunks && m.transfers[t.key()] == t {
+			waiting = true
+		} else if t.file != nil {
+			t.file.Close()
+			t.file = nil
+		}
+	}
+	if !waiting || m.transferScheduled {
+		return nil
+	}
+	m.transferScheduled = true
+	return tea.Tick(transferPumpDelay, func(time.Time) tea.Msg { return transferTickMsg{} })
+}
+
+// bulkQueueFull reports whether chunks should wait: the bulk queue is half full, leaving room for
+// other bulk traffic, or there is no connection to queue them on
+func (m *model) bulkQueueFull() bool {
+	writer := m.bulkWriter
+	if writer == nil {
+		writer = m.writer
+	}
+	return writer == nil || len(writer.bulk) >= writeQueue/2
+}
+
+// sendChunk reads, encrypts, and queues the next chunk of a transfer
+func (m *model) sendChunk(t *fileTransfer) bool {
+	data := make([]byte, fileChunkSize)
+	n, err := io.ReadFull(t.file, data)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		m.cancelTransfer(t, fmt.Sprintf("error reading %s: %v", t.name, err))
+		return false
+	}
+	data = data[:n]
+	sum := sha256.Sum256(data)
+	line, _, err := m.encodeSend(t.peer, envelope{
+		body:       base64.StdEncoding.EncodeToString(data),
+		transfer:   t.id,
+		transferOp: "chunk",
+		chunk:      t.done,
+		chunkSum:   hex.EncodeToString(sum[:]),
+	}.seal())
+	if err != nil {
+		m.cancelTransfer(t, fmt.Sprintf("error encrypting chunk %d: %v", t.done, err))
+		return false
+	}
+	if !m.writeBulk(line) {
+		m.cancelTransfer(t, fmt.Sprintf("could not queue chunk %d", t.done))
+		return false
+	}
+	t.done++
+	return true
+}
+
+// sortedTransfers returns the transfers in a stable order, by peer and then name
+func (m *model) sortedTransfers() []*fileTransfer {
+	transfers := make([]*fileTransfer, 0, len(m.transfers))
+	for _, t := range m.transfers {
+		transfers = append(transfers, t)
+	}
+	sort.Slice(transfers, func(i, j int) bool {
+		if transfers[i].peer != transfers[j].peer {
+			return transfers[i].peer < transfers[j].peer
+		}
+		if transfers[i].name != transfers[j].name {
+			return transfers[i].name < transfers[j].name
+		}
+		return transfers[i].id < transfers[j].id
+	})
+	return transfers
+}
+
+// received returns how many bytes of the file have been queued or received
+func (t *fileTransfer) received() int64 {
+	return min(int64(t.done)*fileChunkSize, t.size)
+}
+
+// direction describes which way the file is going, relative to the peer
+func (t *fileTransfer) direction() string {
+	if t.outgoing {
+		return "to"
+	}
+	return "from"
+}
+
+// listTransfers prints the running and offered transfers
+func (m *model) listTransfers() {
+	if len(m.transfers) == 0 {
+		m.appendMessage("No file transfers.")
+		return
+	}
+	lines := []string{"File transfers:"}
+	for _, t := range m.sortedTransfers() {
+		lines = append(lines, fmt.Sprintf("  %s  %s %s %s  %s", m.transferRef(t), t.name, t.direction(), t.peer, t.state()))
+	}
+	m.appendMessage(strings.Join(lines, "\n"))
+}
+
+// state describes how far a transfer has got
+func (t *fileTransfer) state() string {
+	switch {
+	case !t.accepted && t.outgoing:
+		return "waiting for " + t.peer + " to accept"
+	case !t.accepted:
+		return "waiting for you to accept"
+	case t.outgoing && t.done == t.chunks:
+		return "waiting for " + t.peer + " to verify"
+	default:
+		return fmt.Sprintf("%s of %s", formatSize(t.received()), formatSize(t.size))
+	}
+}
+
+// transferLines renders a progress bar for each accepted transfer, shown above the input
+func (m *model) transferLines() []string {
+	var lines []string
+	for _, t := range m.sortedTransfers() {
+		if !t.accepted {
+			continue
+		}
+		percent := 1.0
+		if t.chunks > 0 {
+			percent = float64(t.done) / float64(t.chunks)
+		}
+		arrow := "↓"
+		if t.outgoing {
+			arrow = "↑"
+		}
+		lines = append(lines, fmt.Sprintf("%s %s %s %s %s %s", arrow, t.name, t.direction(), t.peer, m.transferBar.ViewAs(percent), t.state()))
+	}
+	return lines
+}
+
+// newTransferBar returns the progress bar drawn for transfers
+func newTransferBar() progress.Model {
+	return progress.New(progress.WithDefaultGradient(), progress.WithWidth(transferBarWidth))
+}
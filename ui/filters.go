@@ -0,0 +1,215 @@
+// filters.go
+// Package ui applies regex filter rules (hide, fold, recolor, route) to incoming messages.
+
+package ui
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// filterAction is what a filter rule does with a matching message
+type filterAction string
+
+const (
+	filterHide    filterAction = "hide"    // Drop the message
+	filterFold    filterAction = "fold"    // Show a one-line placeholder until expanded
+	filterRecolor filterAction = "recolor" // Render the message in another color
+	filterRoute   filterAction = "route"   // Move the message into a named buffer
+)
+
+// filterRule matches incoming messages against a regular expression
+type filterRule struct {
+	action  filterAction   // What to do with matching messages
+	arg     string         // Color for recolor, buffer name for route
+	pattern *regexp.Regexp // Matched against "<SenderID>: <Message>"
+}
+
+// String formats the rule the way it is entered with /filter add
+func (r *filterRule) String() string {
+	action := string(r.action)
+	if r.arg != "" {
+		action += ":" + r.arg
+	}
+	return fmt.Sprintf("%s %s", action, r.pattern)
+}
+
+// messageFilters is the rule set shared between the UI and the message reader
+type messageFilters struct {
+	mu    sync.RWMutex
+	rules []*filterRule
+}
+
+func init() {
+	registerCommand("/filter", commandSpec{
+		usage:   "/filter add <hide|fold|recolor:<color>|route:<buffer>> <regex> | list | remove <i>",
+		help:    "Manage filter rules for incoming messages",
+		minArgs: 1,
+		run: func(m *model, args []string) tea.Cmd {
+			switch {
+			case args[0] == "add" && len(args) >= 3:
+				rule, err := parseFilterRule(args[1], strings.Join(args[2:], " "))
+				if err != nil {
+					m.appendMessage(fmt.Sprintf("Invalid filter: %v", err))
+					return nil
+				}
+				m.filters.add(rule)
+				m.appendMessage(fmt.Sprintf("Filter added: %s", rule))
+			case args[0] == "list":
+				rules := m.filters.list()
+				if len(rules) == 0 {
+					m.appendMessage("No filters.")
+				}
+				for i, rule := range rules {
+					m.appendMessage(fmt.Sprintf("%d. %s", i+1, rule))
+				}
+			case args[0] == "remove" && len(args) == 2:
+				i, err := strconv.Atoi(args[1])
+				if err != nil || !m.filters.remove(i-1) {
+					m.appendMessage(fmt.Sprintf("No filter %s.", args[1]))
+					return nil
+				}
+				m.appendMessage(fmt.Sprintf("Filter %d removed.", i))
+			default:
+				m.appendMessage("Usage: " + knownCommands["/filter"].usage)
+			}
+			return nil
+		},
+	})
+	registerCommand("/buffer", commandSpec{
+		usage:   "/buffer <name>",
+		help:    "Toggle the view of a buffer that filter rules route messages into",
+		minArgs: 1,
+		run: func(m *model, args []string) tea.Cmd {
+			m.toggleBuffer(args[0])
+			return nil
+		},
+	})
+}
+
+// parseFilterRule builds a rule from an action spec such as "route:bots" and a regex
+func parseFilterRule(actionSpec, pattern string) (*filterRule, error) {
+	action, arg, _ := strings.Cut(actionSpec, ":")
+	rule := &filterRule{action: filterAction(action), arg: arg}
+	switch rule.action {
+	case filterHide, filterFold:
+		if arg != "" {
+			return nil, fmt.Errorf("%s takes no argument", action)
+		}
+	case filterRecolor, filterRoute:
+		if arg == "" {
+			return nil, fmt.Errorf("%s needs an argument, e.g. %s:name", action, action)
+		}
+	default:
+		return nil, fmt.Errorf("unknown action %q", action)
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	rule.pattern = re
+	return rule, nil
+}
+
+// add appends a rule
+func (f *messageFilters) add(rule *filterRule) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.rules = append(f.rules, rule)
+}
+
+// remove deletes the rule at index i, reporting whether it existed
+func (f *messageFilters) remove(i int) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if i < 0 || i >= len(f.rules) {
+		return false
+	}
+	f.rules = append(f.rules[:i], f.rules[i+1:]...)
+	return true
+}
+
+// list returns a copy of the rules
+func (f *messageFilters) list() []*filterRule {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return append([]*filterRule(nil), f.rules...)
+}
+
+// match returns the first rule matching the message, or nil
+func (f *messageFilters) match(msg incomingMessage) *filterRule {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	subject := msg.senderID + ": " + msg.content
+	for _, rule := range f.rules {
+		if rule.pattern.MatchString(subject) {
+			return rule
+		}
+	}
+	return nil
+}
+
+// deliver applies the filter rules to an incoming message and passes it on unless it is hidden
+func (f *messageFilters) deliver(send func(tea.Msg), msg incomingMessage) {
+	if f != nil {
+		msg.filter = f.match(msg)
+		if msg.filter != nil && msg.filter.action == filterHide {
+			return
+		}
+	}
+	send(msg)
+}
+
+// applyFilter updates an entry according to the rule that matched it.
+// It reports whether the entry was routed to a buffer instead of the main view.
+func (m *model) applyFilter(rule *filterRule, entry *chatEntry) bool {
+	if rule == nil {
+		return false
+	}
+	switch rule.action {
+	case filterFold:
+		entry.folded = true
+	case filterRecolor:
+		entry.color = rule.arg
+	case filterRoute:
+		m.addToBuffer(rule.arg, *entry)
+		return true
+	}
+	return false
+}
+
+// bufferView renders the named filter buffer
+func (m *model) bufferView() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Buffer %s (/buffer %s to close):", m.bufferName, m.bufferName)
+	entries := m.buffers[m.bufferName]
+	if len(entries) == 0 {
+		b.WriteString("\n  (empty)")
+	}
+	if len(entries) > maxPanelEntries {
+		fmt.Fprintf(&b, "\n  ... %d earlier", len(entries)-maxPanelEntries)
+		entries = entries[len(entries)-maxPanelEntries:]
+	}
+	for _, entry := range entries {
+		fmt.Fprintf(&b, "\n  [%s] %s", entry.at.Format("15:04"), entry.render())
+	}
+	return b.String()
+}
+
+// colorStyle returns a style rendering text in the named or numbered color
+func colorStyle(color string) lipgloss.Style {
+	named := map[string]string{
+		"black": "0", "red": "1", "green": "2", "yellow": "3",
+		"blue": "4", "magenta": "5", "cyan": "6", "white": "7", "gray": "8", "grey": "8",
+	}
+	if code, ok := named[strings.ToLower(color)]; ok {
+		color = code
+	}
+	return lipgloss.NewStyle().Foreground(lipgloss.Color(color))
+}
@@ -0,0 +1,52 @@
+// flood.go
+// Package ui collapses repeated identical messages from a sender into a single counted line.
+
+package ui
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// floodWindow is how soon a repeat must follow the previous copy to be collapsed into it
+const floodWindow = time.Minute
+
+// collapseRepeat folds an incoming entry into the latest message when it repeats it verbatim.
+// It reports whether the entry was collapsed and should not be appended.
+func (m *model) collapseRepeat(entry chatEntry) bool {
+	for i := len(m.entries) - 1; i >= 0; i-- {
+		last := &m.entries[i]
+		if last.kind == entrySystem {
+			continue
+		}
+		lastAt := last.at
+		if n := len(last.repeats); n > 0 {
+			lastAt = last.repeats[n-1]
+		}
+		if last.kind != entry.kind || last.sender != entry.sender || last.content != entry.content ||
+			entry.at.Sub(lastAt) > floodWindow {
+			return false
+		}
+		last.repeats = append(last.repeats, entry.at)
+		m.refreshViewport()
+		m.viewport.GotoBottom()
+		return true
+	}
+	return false
+}
+
+// renderRepeats adds the repeat count to a collapsed line, or lists every copy when expanded
+func (e chatEntry) renderRepeats(line string) string {
+	if len(e.repeats) == 0 {
+		return line
+	}
+	if !e.expanded {
+		return fmt.Sprintf("%s ×%d (Ctrl+E to expand)", line, len(e.repeats)+1)
+	}
+	copies := []string{fmt.Sprintf("%s (×%d, first at %s)", line, len(e.repeats)+1, e.at.Format("15:04:05"))}
+	for _, at := range e.repeats {
+		copies = append(copies, fmt.Sprintf("  repeated at %s", at.Format("15:04:05")))
+	}
+	return strings.Join(copies, "\n")
+}
@@ -0,0 +1,36 @@
+// forward.go
+// Package ui forwards received messages to another recipient with a note of who sent them originally.
+
+package ui
+
+import (
+	"fmt"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func init() {
+	registerCommand("/forward", commandSpec{
+		usage:   "/forward <n> <RecipientID|ALL>",
+		help:    "Forward the nth most recent received message to another recipient",
+		minArgs: 2,
+		run: func(m *model, args []string) tea.Cmd {
+			entry, err := m.messageByNumber(args[0])
+			if err != nil {
+				m.appendMessage(err.Error())
+				return nil
+			}
+			if entry.kind != entryDirect && entry.kind != entryBroadcast {
+				m.appendMessage(fmt.Sprintf("Message %s was not received from another client.", args[0]))
+				return nil
+			}
+			// Keep the original sender when forwarding a forwarded message
+			origin := entry.forwardedFrom
+			if origin == "" {
+				origin = entry.sender
+			}
+			// The message is encrypted afresh for the new recipient when it is sent
+			return m.queueSend(args[1], envelope{body: entry.content, forwardedFrom: origin}.seal())
+		},
+	})
+}
@@ -0,0 +1,15 @@
+// framing.go
+// Package ui encodes encrypted payloads in base64 instead of hex on servers with the BASE64
+// extension; see protocol.EncodeBase64.
+
+package ui
+
+import "github.com/drewwalton19216801/padclient/protocol"
+
+// payloadEncoder returns how outgoing payloads are encoded for the current server
+func (m *model) payloadEncoder() protocol.Encoder {
+	if m.serverCaps["BASE64"] {
+		return protocol.EncodeBase64
+	}
+	return protocol.EncodeHex
+}
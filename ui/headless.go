@@ -0,0 +1,238 @@
+// headless.go
+// Package ui implements -headless, which drives a session from stdin and stdout without the UI,
+// for shell scripts and bots.
+
+package ui
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/drewwalton19216801/padclient/protocol"
+	"github.com/drewwalton19216801/padclient/transport"
+)
+
+// headless runs the session without Bubble Tea; headlessJSON prints events as JSON objects
+var headless, headlessJSON bool
+
+// headlessTimeout bounds the dial and key exchange in headless mode
+const headlessTimeout = 30 * time.Second
+
+// headlessSession is the state of a session driven from stdin
+type headlessSession struct {
+	clientID     string
+	hashedSecret []byte
+	pads         *padStore
+	identity     *identity
+	signatures   *signatureChecker
+	out          io.Writer
+	writer       *connWriter
+	nextSeq      int64 // Sequence number of the next JSON event
+}
+
+// runHeadless connects and then reads commands from stdin, one per line, until stdin ends:
+// SEND <RecipientID|ALL> <Message> sends an encrypted message, EXIT disconnects, and any other
+// line goes to the server as is. Messages and server lines are printed to stdout.
+func runHeadless(clientID, server string) error {
+	var pads *padStore
+	if !amnesia {
+		var err error
+		if pads, err = loadPads(padDir); err != nil {
+			return fmt.Errorf("error loading pads: %v", err)
+		}
+	}
+	id, err := loadSigningIdentity(clientID)
+	if err != nil {
+		return err
+	}
+	if dmKeys, err = newKeyAgreement(id); err != nil {
+		return err
+	}
+	signatures, err := newSignatureChecker()
+	if err != nil {
+		return err
+	}
+	conn, hashedSecret, err := dialServer(server, clientID, headlessTimeout)
+	if err != nil {
+		return err
+	}
+	conn.SetDeadline(time.Time{})
+
+	// Stop on Ctrl+C or SIGTERM; the reader closes the connection when the context ends
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	messages := make(chan tea.Msg, messageBuffer)
+	s := &headlessSession{
+		clientID:     clientID,
+		hashedSecret: hashedSecret,
+		pads:         pads,
+		identity:     id,
+		signatures:   signatures,
+		out:          os.Stdout,
+		writer:       startWriter(ctx, conn, messages),
+	}
+	done := make(chan struct{})
+	go func() {
+		readMessages(ctx, conn, clientID, newSessionKey(hashedSecret), pads, nil, messages)
+		close(done)
+	}()
+	commands := make(chan string)
+	go func() {
+		defer close(commands)
+		scanner := bufio.NewScanner(os.Stdin)
+		scanner.Buffer(make([]byte, 0, 4096), sendMaxSize)
+		for scanner.Scan() {
+			select {
+			case commands <- scanner.Text():
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-done:
+			return &fatalError{code: exitConnect, err: errors.New("disconnected from the server")}
+		case command, ok := <-commands:
+			if !ok || strings.TrimSpace(command) == "EXIT" {
+				// Stdin ended: leave once the queued lines are written
+				select {
+				case s.writer.lines <- "EXIT":
+				default:
+				}
+				close(s.writer.lines)
+				select {
+				case <-s.writer.done:
+				case <-time.After(2 * time.Second):
+				}
+				return nil
+			}
+			if err := s.run(command); err != nil {
+				fmt.Fprintf(os.Stderr, "%v\n", err)
+			}
+		case msg := <-messages:
+			if err := s.handle(msg); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// run carries out one command read from stdin
+func (s *headlessSession) run(command string) error {
+	parts := strings.Fields(command)
+	if len(parts) == 0 {
+		return nil
+	}
+	line := strings.TrimSpace(command)
+	if parts[0] == "SEND" {
+		if len(parts) < 3 {
+			return errors.New("invalid SEND command. Use: SEND <RecipientID|ALL> <Message>")
+		}
+		var err error
+		if s.pads.startSync(parts[1]) {
+			hello, err := padSyncLine(protocol.EncodeHex, s.pads, s.identity, s.clientID, parts[1], "hello")
+			if err != nil {
+				return err
+			}
+			if err := s.queueLine(hello); err != nil {
+				return err
+			}
+		}
+		text := s.identity.sign(s.clientID, parts[1], dmKeys.offer(s.clientID, parts[1], withMessageID(strings.Join(parts[2:], " "))))
+		line, _, err = encodeSendLine(s.hashedSecret, s.pads, parts[1], text)
+		if err != nil {
+			return err
+		}
+	} else if strings.HasPrefix(parts[0], "/") {
+		return fmt.Errorf("%s: slash commands are not available in headless mode", parts[0])
+	}
+	return s.queueLine(line)
+}
+
+// queueLine hands a line to the writer
+func (s *headlessSession) queueLine(line string) error {
+	select {
+	case s.writer.lines <- line:
+		return nil
+	default:
+		return errors.New("too many lines are waiting to be sent to the server")
+	}
+}
+
+// handle prints a message from the reader, after answering any key agreement it carries
+func (s *headlessSession) handle(msg tea.Msg) error {
+	reply, notice := answerKeyAgreement(protocol.EncodeHex, s.identity, s.signatures.keys, s.clientID, msg)
+	if reply != "" {
+		s.queueLine(reply)
+	}
+	if notice != "" {
+		if err := s.print(ControlEvent{Kind: "server", Text: notice}, notice); err != nil {
+			return err
+		}
+	}
+	switch msg := msg.(type) {
+	case incomingMessage:
+		env := openEnvelope(msg.content)
+		if env.padSync != "" {
+			reply, err := answerPadSync(protocol.EncodeHex, s.pads, s.identity, s.signatures.keys, s.clientID, msg, env)
+			if reply != "" {
+				s.queueLine(reply)
+			}
+			if err != nil {
+				return s.print(ControlEvent{Kind: "server", Text: err.Error()}, fmt.Sprintf("Warning: %v.", err))
+			}
+			return nil
+		}
+		if isControlEnvelope(msg.content, env) {
+			return nil
+		}
+		status, notice := s.signatures.check(msg.senderID, s.clientID, msg)
+		if notice != "" {
+			if err := s.print(ControlEvent{Kind: "server", From: msg.senderID, Text: notice}, notice); err != nil {
+				return err
+			}
+		}
+		entry := chatEntry{kind: entryDirect, sender: msg.senderID, forwardedFrom: env.forwardedFrom, content: env.body, signature: status}
+		if msg.isBroadcast {
+			entry.kind = entryBroadcast
+		}
+		return s.print(ControlEvent{Kind: "message", From: msg.senderID, Broadcast: msg.isBroadcast, Text: env.body, ForwardedFrom: env.forwardedFrom, Signature: status.name()}, entry.render())
+	case serverMsg:
+		return s.print(ControlEvent{Kind: "server", Text: msg.content}, msg.content)
+	case integrityFailureMsg:
+		text := fmt.Sprintf("Dropped a message from %s that could not be decrypted: %v", msg.senderID, msg.err)
+		return s.print(ControlEvent{Kind: "integrity", From: msg.senderID, Text: msg.err.Error()}, text)
+	case writeErrorMsg:
+		return &fatalError{code: exitConnect, err: fmt.Errorf("error writing to server: %v", msg.err)}
+	case kickedMsg:
+		return &fatalError{code: exitBanned, err: errors.New("kicked by the operator")}
+	case bannedMsg:
+		return transport.ErrBanned
+	}
+	return nil
+}
+
+// print writes an event to stdout, as a JSON object with -json or as text otherwise
+func (s *headlessSession) print(event ControlEvent, text string) error {
+	event.Time = time.Now()
+	if headlessJSON {
+		event.Seq = s.nextSeq
+		s.nextSeq++
+		return json.NewEncoder(s.out).Encode(event)
+	}
+	_, err := fmt.Fprintf(s.out, "[%s] %s\n", event.Time.Format("15:04:05"), text)
+	return err
+}
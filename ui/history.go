@@ -0,0 +1,382 @@
+// history.go
+// Package ui keeps conversations across sessions in a local history file, encrypted at rest with
+// a key derived from a passphrase, and pages older messages back in with /history.
+
+package ui
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/x/term"
+	bolt "go.etcd.io/bbolt"
+	"golang.org/x/crypto/pbkdf2"
+)
+
+// historyEnabled turns on the persistent history
+var historyEnabled bool
+
+// historyPath is the history file
+var historyPath string
+
+// historyIterations is the PBKDF2 iteration count for new history files
+const historyIterations = 600000
+
+// historyMaxIterations caps the iteration count read from a history file, so a damaged or
+// planted file cannot stall startup deriving the key
+const historyMaxIterations = 10000000
+
+// historyVerifier is sealed into the header to check the passphrase
+const historyVerifier = "padclient history"
+
+// historyRestore is how many recent messages are loaded into the viewport at startup
+const historyRestore = 50
+
+// historyPassphraseEnv names the environment variable that supplies the passphrase without a prompt
+const historyPassphraseEnv = "PADCLIENT_HISTORY_PASSPHRASE"
+
+// Buckets of the history database. The header bucket holds the salt, the PBKDF2 iteration count,
+// and the sealed verifier; the entries bucket holds each sealed entry under an 8-byte big-endian
+// sequence number, so entries are stored oldest first.
+var (
+	historyHeaderBucket  = []byte("header")
+	historyEntriesBucket = []byte("entries")
+)
+
+// historyStore is a bbolt database of entries, each sealed with AES-256-GCM under a key derived
+// from the passphrase with PBKDF2-HMAC-SHA256
+type historyStore struct {
+	db   *bolt.DB
+	aead cipher.AEAD
+	key  []byte
+	ids  []uint64 // Sequence number of each entry, oldest first
+}
+
+func init() {
+	registerCommand("/history", commandSpec{
+		usage:   "/history <peer|ALL> [n]",
+		help:    "Show the n (default 20) messages with a peer or in ALL before those already shown",
+		minArgs: 1,
+		run: func(m *model, args []string) tea.Cmd {
+			if m.chatHistory == nil {
+				m.appendMessage("History is off. Start the client with -history to keep conversations across sessions.")
+				return nil
+			}
+			n := 20
+			if len(args) > 1 {
+				var err error
+				if n, err = strconv.Atoi(args[1]); err != nil || n < 1 {
+					m.appendMessage("Usage: " + knownCommands["/history"].usage)
+					return nil
+				}
+			}
+			m.pageHistory(args[0], n)
+			return nil
+		},
+	})
+}
+
+// defaultHistoryPath returns the history file in the user's config directory
+func defaultHistoryPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "padclient", "history.db")
+}
+
+// readHistoryPassphrase reads the passphrase from the environment or prompts for it on the terminal.
+// A new history file asks for it twice.
+func readHistoryPassphrase(isNew bool) ([]byte, error) {
+	if passphrase := os.Getenv(historyPassphraseEnv); passphrase != "" {
+		return []byte(passphrase), nil
+	}
+	if !term.IsTerminal(os.Stdin.Fd()) {
+		return nil, fmt.Errorf("no terminal to ask for the history passphrase; set %s", historyPassphraseEnv)
+	}
+	fmt.Fprint(os.Stderr, "History passphrase: ")
+	passphrase, err := term.ReadPassword(os.Stdin.Fd())
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return nil, err
+	}
+	if len(passphrase) == 0 {
+		return nil, errors.New("the history passphrase cannot be empty")
+	}
+	if isNew {
+		fmt.Fprint(os.Stderr, "Repeat the passphrase: ")
+		again, err := term.ReadPassword(os.Stdin.Fd())
+		fmt.Fprintln(os.Stderr)
+		if err != nil {
+			return nil, err
+		}
+		if !bytes.Equal(passphrase, again) {
+			return nil, errors.New("the passphrases do not match")
+		}
+	}
+	return passphrase, nil
+}
+
+// openHistory opens the history database, creating it if needed, after asking for the passphrase
+func openHistory(path string) (*historyStore, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, err
+	}
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: time.Second})
+	if errors.Is(err, bolt.ErrTimeout) {
+		return nil, errors.New("the history file is in use by another padclient")
+	}
+	if err != nil {
+		return nil, err
+	}
+	h := &historyStore{db: db}
+	if err := h.unlock(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return h, nil
+}
+
+// unlock asks for the passphrase and derives the key, writing a new header to a new database or
+// checking the passphrase against the existing one, then indexes the saved entries
+func (h *historyStore) unlock() error {
+	return h.db.Update(func(tx *bolt.Tx) error {
+		header := tx.Bucket(historyHeaderBucket)
+		passphrase, err := readHistoryPassphrase(header == nil)
+		if err != nil {
+			return err
+		}
+		defer clear(passphrase)
+		if header == nil {
+			err = h.writeHeader(tx, passphrase)
+		} else {
+			err = h.readHeader(header, passphrase)
+		}
+		if err != nil {
+			return err
+		}
+		entries, err := tx.CreateBucketIfNotExists(historyEntriesBucket)
+		if err != nil {
+			return err
+		}
+		return entries.ForEach(func(k, _ []byte) error {
+			if len(k) != 8 {
+				return errors.New("the history file is damaged")
+			}
+			h.ids = append(h.ids, binary.BigEndian.Uint64(k))
+			return nil
+		})
+	})
+}
+
+// deriveKey derives the history key from the passphrase and sets up the cipher
+func (h *historyStore) deriveKey(passphrase, salt []byte, iterations int) error {
+	h.key = pbkdf2.Key(passphrase, salt, iterations, 32, sha256.New)
+	block, err := aes.NewCipher(h.key)
+	if err != nil {
+		return err
+	}
+	h.aead, err = cipher.NewGCM(block)
+	return err
+}
+
+// writeHeader starts a new history database
+func (h *historyStore) writeHeader(tx *bolt.Tx, passphrase []byte) error {
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return err
+	}
+	if err := h.deriveKey(passphrase, salt, historyIterations); err != nil {
+		return err
+	}
+	verifier, err := h.seal([]byte(historyVerifier), historyHeaderBucket)
+	if err != nil {
+		return err
+	}
+	header, err := tx.CreateBucket(historyHeaderBucket)
+	if err != nil {
+		return err
+	}
+	for key, value := range map[string][]byte{
+		"salt":       salt,
+		"iterations": binary.BigEndian.AppendUint32(nil, historyIterations),
+		"verifier":   verifier,
+	} {
+		if err := header.Put([]byte(key), value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readHeader derives the key from the passphrase and checks it against the verifier. The
+// iteration count comes from the file, within historyMaxIterations.
+func (h *historyStore) readHeader(header *bolt.Bucket, passphrase []byte) error {
+	salt, count, verifier := header.Get([]byte("salt")), header.Get([]byte("iterations")), header.Get([]byte("verifier"))
+	if len(salt) != 16 || len(count) != 4 || verifier == nil {
+		return errors.New("the history file header is damaged")
+	}
+	iterations := binary.BigEndian.Uint32(count)
+	if iterations == 0 || iterations > historyMaxIterations {
+		return fmt.Errorf("the history file asks for %d PBKDF2 iterations; at most %d are allowed", iterations, historyMaxIterations)
+	}
+	if err := h.deriveKey(passphrase, salt, int(iterations)); err != nil {
+		return err
+	}
+	if plaintext, err := h.open(verifier, historyHeaderBucket); err != nil || string(plaintext) != historyVerifier {
+		return errors.New("wrong history passphrase")
+	}
+	return nil
+}
+
+// seal encrypts plaintext with a fresh nonce, which it is prefixed with. The additional data ties
+// a sealed entry to its sequence number, so entries cannot be swapped around in the file.
+func (h *historyStore) seal(plaintext, additional []byte) ([]byte, error) {
+	nonce := make([]byte, h.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return h.aead.Seal(nonce, nonce, plaintext, additional), nil
+}
+
+// open decrypts what seal returned
+func (h *historyStore) open(sealed, additional []byte) ([]byte, error) {
+	if len(sealed) < h.aead.NonceSize() {
+		return nil, errors.New("sealed history record is too short")
+	}
+	nonce, ciphertext := sealed[:h.aead.NonceSize()], sealed[h.aead.NonceSize():]
+	return h.aead.Open(nil, nonce, ciphertext, additional)
+}
+
+// append saves a conversation entry. Notices are not kept.
+func (h *historyStore) append(entry chatEntry) error {
+	if h == nil || entry.kind == entrySystem {
+		return nil
+	}
+	entry.status = statusNone
+	entry.highlight = false
+	plaintext, err := marshalEntry(entry)
+	if err != nil {
+		return err
+	}
+	return h.db.Update(func(tx *bolt.Tx) error {
+		entries := tx.Bucket(historyEntriesBucket)
+		id, err := entries.NextSequence()
+		if err != nil {
+			return err
+		}
+		key := binary.BigEndian.AppendUint64(nil, id)
+		sealed, err := h.seal(plaintext, key)
+		if err != nil {
+			return err
+		}
+		if err := entries.Put(key, sealed); err != nil {
+			return err
+		}
+		h.ids = append(h.ids, id)
+		return nil
+	})
+}
+
+// read returns the ith saved entry, oldest first
+func (h *historyStore) read(i int) (chatEntry, error) {
+	var entry chatEntry
+	err := h.db.View(func(tx *bolt.Tx) error {
+		key := binary.BigEndian.AppendUint64(nil, h.ids[i])
+		sealed := tx.Bucket(historyEntriesBucket).Get(key)
+		if sealed == nil {
+			return errors.New("entry is missing")
+		}
+		plaintext, err := h.open(sealed, key)
+		if err != nil {
+			return err
+		}
+		entry, err = unmarshalEntry(plaintext)
+		return err
+	})
+	if err != nil {
+		return chatEntry{}, fmt.Errorf("error reading history: %v", err)
+	}
+	return entry, nil
+}
+
+// close closes the history database and forgets its key
+func (h *historyStore) close() {
+	if h == nil {
+		return
+	}
+	h.db.Close()
+	clear(h.key)
+}
+
+// saveHistory saves an entry, turning the history off if the file cannot be written
+func (m *model) saveHistory(entry chatEntry) {
+	if err := m.chatHistory.append(entry); err != nil {
+		m.chatHistory.close()
+		m.chatHistory = nil
+		m.appendMessage(fmt.Sprintf("Error saving history, history is off for this session: %v", err))
+	}
+}
+
+// restoreHistory loads the most recent saved messages into the buffer at startup
+func (m *model) restoreHistory() error {
+	m.historyStart = max(len(m.chatHistory.ids)-historyRestore, 0)
+	for i := m.historyStart; i < len(m.chatHistory.ids); i++ {
+		entry, err := m.chatHistory.read(i)
+		if err != nil {
+			return err
+		}
+		m.entrySeq++
+		entry.seq = m.entrySeq
+		m.entries = append(m.entries, entry)
+		if tab := entry.conversation(); tab != "" {
+			m.openTab(tab)
+		}
+	}
+	if restored := len(m.chatHistory.ids) - m.historyStart; restored > 0 {
+		m.appendMessage(fmt.Sprintf("Restored %d message(s) from history. /history <peer|ALL> shows older ones.", restored))
+	}
+	return nil
+}
+
+// pageHistory shows up to n saved messages of a conversation from before those already shown
+func (m *model) pageHistory(conversation string, n int) {
+	cursor, ok := m.historyCursor[conversation]
+	if !ok {
+		cursor = m.historyStart
+	}
+	var found []chatEntry
+	for cursor > 0 && len(found) < n {
+		cursor--
+		entry, err := m.chatHistory.read(cursor)
+		if err != nil {
+			m.appendMessage(err.Error())
+			return
+		}
+		if strings.EqualFold(entry.conversation(), conversation) {
+			found = append(found, entry)
+		}
+	}
+	m.historyCursor[conversation] = cursor
+	if len(found) == 0 {
+		m.appendMessage(fmt.Sprintf("No older messages with %s in history.", conversation))
+		return
+	}
+	lines := []string{fmt.Sprintf("History with %s, %d older message(s):", conversation, len(found))}
+	for i := len(found) - 1; i >= 0; i-- {
+		lines = append(lines, fmt.Sprintf("  [%s] %s", found[i].at.Format("2006-01-02 15:04"), found[i].render()))
+	}
+	m.appendMessage(strings.Join(lines, "\n"))
+}
@@ -0,0 +1,69 @@
+package ui
+
+import (
+	"encoding/binary"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// TestHistoryStore saves entries, reopens the history with the passphrase, and checks that they
+// come back in order, that a wrong passphrase is refused, and so is an iteration count over the cap
+func TestHistoryStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.db")
+	t.Setenv(historyPassphraseEnv, "correct horse")
+	h, err := openHistory(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	at := time.Date(2026, 10, 16, 9, 30, 0, 0, time.UTC)
+	for _, content := range []string{"one", "two", "three"} {
+		if err := h.append(chatEntry{kind: entryDirect, sender: "bob", recipient: "alice", content: content, at: at}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Notices are not kept
+	if err := h.append(chatEntry{kind: entrySystem, content: "Connected"}); err != nil {
+		t.Fatal(err)
+	}
+	h.close()
+
+	if h, err = openHistory(path); err != nil {
+		t.Fatal(err)
+	}
+	var contents []string
+	for i := range h.ids {
+		entry, err := h.read(i)
+		if err != nil {
+			t.Fatal(err)
+		}
+		contents = append(contents, entry.content)
+	}
+	h.close()
+	if got := strings.Join(contents, ","); got != "one,two,three" {
+		t.Errorf("read back %q, want one,two,three", got)
+	}
+
+	t.Setenv(historyPassphraseEnv, "wrong")
+	if _, err := openHistory(path); err == nil || !strings.Contains(err.Error(), "passphrase") {
+		t.Errorf("a wrong passphrase gave %v", err)
+	}
+
+	db, err := bolt.Open(path, 0600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(historyHeaderBucket).Put([]byte("iterations"), binary.BigEndian.AppendUint32(nil, historyMaxIterations+1))
+	})
+	db.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := openHistory(path); err == nil || !strings.Contains(err.Error(), "iterations") {
+		t.Errorf("an iteration count over the cap gave %v", err)
+	}
+}
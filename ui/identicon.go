@@ -0,0 +1,131 @@
+// identicon.go
+// Package ui draws a small identicon for each sender from the fingerprint of their identity key,
+// shown before message headers and in the roster, so peers are told apart at a glance and a
+// changed key stands out. Styles are pluggable through registerIdenticon.
+
+package ui
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+)
+
+// identiconStyle is the -identicon setting: the name of the style used, or "none"
+var identiconStyle = "blocks"
+
+// identiconRenderer draws an identicon for a key fingerprint. The result must have the same
+// display width for every fingerprint, so columns stay aligned.
+type identiconRenderer interface {
+	render(fingerprint string) string
+}
+
+// identiconStyles are the registered styles by name
+var identiconStyles = map[string]identiconRenderer{}
+
+// registerIdenticon makes an identicon style available to -identicon
+func registerIdenticon(name string, renderer identiconRenderer) {
+	identiconStyles[name] = renderer
+}
+
+// identiconStyleNames returns the registered styles, sorted, for usage messages
+func identiconStyleNames() []string {
+	names := make([]string, 0, len(identiconStyles))
+	for name := range identiconStyles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// identiconPalette holds 256-color codes that read well on dark and light backgrounds
+var identiconPalette = []string{"1", "2", "3", "4", "5", "6", "9", "10", "12", "13", "14", "130", "166", "172", "31", "97"}
+
+// identiconDigest hashes a fingerprint, so neighbouring fingerprints give unrelated identicons
+func identiconDigest(fingerprint string) [32]byte {
+	return sha256.Sum256([]byte("padclient-identicon\x00" + fingerprint))
+}
+
+// blockIdenticon draws four quadrant blocks in one color
+type blockIdenticon struct{}
+
+// quadrantBlocks are the block characters the block style draws from
+var quadrantBlocks = []rune("▖▗▘▝▚▞▙▛▜▟▀▄▌▐█▬")
+
+func (blockIdenticon) render(fingerprint string) string {
+	digest := identiconDigest(fingerprint)
+	glyphs := make([]rune, 4)
+	for i := range glyphs {
+		glyphs[i] = quadrantBlocks[digest[i]%byte(len(quadrantBlocks))]
+	}
+	color := identiconPalette[digest[4]%byte(len(identiconPalette))]
+	return lipgloss.NewStyle().Foreground(lipgloss.Color(color)).Render(string(glyphs))
+}
+
+// shapeIdenticon draws three shapes, each in its own color
+type shapeIdenticon struct{}
+
+// identiconShapes are the shapes the shape style draws from
+var identiconShapes = []rune("●■▲◆★♠♣♥♦✚✖◐◑◒◓▼")
+
+func (shapeIdenticon) render(fingerprint string) string {
+	digest := identiconDigest(fingerprint)
+	var b strings.Builder
+	for i := 0; i < 3; i++ {
+		shape := identiconShapes[digest[i]%byte(len(identiconShapes))]
+		color := identiconPalette[digest[3+i]%byte(len(identiconPalette))]
+		b.WriteString(lipgloss.NewStyle().Foreground(lipgloss.Color(color)).Render(string(shape)))
+	}
+	return b.String()
+}
+
+// hexIdenticon shows the start of the fingerprint, for terminals without block characters or color
+type hexIdenticon struct{}
+
+func (hexIdenticon) render(fingerprint string) string {
+	return "[" + strings.ReplaceAll(fingerprint, ":", "")[:4] + "]"
+}
+
+func init() {
+	registerIdenticon("blocks", blockIdenticon{})
+	registerIdenticon("shapes", shapeIdenticon{})
+	registerIdenticon("hex", hexIdenticon{})
+}
+
+// identicon returns the identicon for a key fingerprint followed by a space, blank padding of the
+// same width for a sender without a key, or "" when identicons are off
+func identicon(fingerprint string) string {
+	renderer := identiconStyles[identiconStyle]
+	if renderer == nil {
+		return ""
+	}
+	if fingerprint == "" {
+		return strings.Repeat(" ", ansi.StringWidth(renderer.render("00:00:00:00:00:00:00:00"))+1)
+	}
+	return renderer.render(fingerprint) + " "
+}
+
+// identicon returns the identicon shown before a message header, blank padding for the
+// following messages of a group, or "" for entries that are not messages
+func (e chatEntry) identicon(continuation bool) string {
+	if (e.kind != entryDirect && e.kind != entryBroadcast) || e.announcement {
+		return ""
+	}
+	icon := identicon(e.signer)
+	if continuation {
+		return strings.Repeat(" ", ansi.StringWidth(icon))
+	}
+	return icon
+}
+
+// checkIdenticonStyle validates the -identicon setting
+func checkIdenticonStyle(style string) error {
+	if style == "none" || identiconStyles[style] != nil {
+		return nil
+	}
+	return &fatalError{code: exitUsage, err: fmt.Errorf("unknown identicon style %q; use none or one of %s", style, strings.Join(identiconStyleNames(), ", "))}
+}
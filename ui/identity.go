@@ -0,0 +1,471 @@
+// identity.go
+// Package ui gives each client ID a persistent Ed25519 identity key, signs every outgoing
+// message with it, and checks the signatures on incoming messages against the keys first seen
+// from each peer, so a client registering under someone else's ID is caught.
+
+package ui
+
+import (
+	"bufio"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// identityDir holds one identity key file per client ID
+var identityDir = defaultIdentityDir()
+
+// knownKeysPath is the file of identity keys pinned for peers, one "<peer> <key>" pair per line
+var knownKeysPath = defaultKnownKeysPath()
+
+// signatureContext starts the signed data, so signatures cannot be reused by another protocol
+const signatureContext = "padclient-sig-v1"
+
+// identity is a client's signing keypair
+type identity struct {
+	private ed25519.PrivateKey
+	public  ed25519.PublicKey
+}
+
+// defaultIdentityDir returns ~/.config/padclient/identities, or the platform's equivalent
+func defaultIdentityDir() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "padclient", "identities")
+}
+
+// defaultKnownKeysPath returns ~/.config/padclient/known_keys, or the platform's equivalent
+func defaultKnownKeysPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "padclient", "known_keys")
+}
+
+// loadIdentity reads the identity key of a client ID from dir, generating and saving one on first
+// use. It reports whether the key was created. With no directory, or in amnesia mode, the key
+// only lives for the session.
+func loadIdentity(dir, clientID string) (*identity, bool, error) {
+	if dir == "" || amnesia {
+		id, err := newIdentity()
+		return id, true, err
+	}
+	if !validPeerName(clientID) {
+		return nil, false, fmt.Errorf("client ID %q cannot be used as a key file name", clientID)
+	}
+	path := filepath.Join(dir, clientID+".key")
+	data, err := os.ReadFile(path)
+	if err == nil {
+		seed, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(data)))
+		if err != nil || len(seed) != ed25519.SeedSize {
+			return nil, false, fmt.Errorf("%s is not an identity key", path)
+		}
+		private := ed25519.NewKeyFromSeed(seed)
+		return &identity{private: private, public: private.Public().(ed25519.PublicKey)}, false, nil
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return nil, false, err
+	}
+	id, err := newIdentity()
+	if err != nil {
+		return nil, false, err
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, false, err
+	}
+	encoded := base64.StdEncoding.EncodeToString(id.private.Seed()) + "\n"
+	if err := os.WriteFile(path, []byte(encoded), 0o600); err != nil {
+		return nil, false, err
+	}
+	return id, true, nil
+}
+
+// loadSigningIdentity loads the identity key of a client ID from identityDir, telling the user
+// when a new one is generated
+func loadSigningIdentity(clientID string) (*identity, error) {
+	id, created, err := loadIdentity(identityDir, clientID)
+	if err != nil {
+		return nil, fmt.Errorf("error loading identity key: %v", err)
+	}
+	if created && identityDir != "" && !amnesia {
+		fmt.Fprintf(os.Stderr, "Generated identity key %s for %s.\n", id.fingerprint(), clientID)
+	}
+	return id, nil
+}
+
+// newIdentity generates a fresh keypair
+func newIdentity() (*identity, error) {
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("error generating identity key: %v", err)
+	}
+	return &identity{private: private, public: public}, nil
+}
+
+// fingerprint returns the fingerprint of the public key, as shown to users
+func (id *identity) fingerprint() string {
+	return keyFingerprint(id.public)
+}
+
+// signedData binds a message's plaintext to its sender and recipient ("ALL" for broadcasts), so a
+// signed message cannot be replayed under another ID or to another conversation
+func signedData(sender, recipient, plaintext string) []byte {
+	return []byte(signatureContext + "\x00" + sender + "\x00" + recipient + "\x00" + plaintext)
+}
+
+// signatureRecipient returns the recipient a message to recipientID is signed for
+func signatureRecipient(recipientID string) string {
+	if _, ok := parseExcept(recipientID); ok {
+		return "ALL"
+	}
+	return recipientID
+}
+
+// splitHeaders separates message plaintext into its envelope headers and body. Plain text has no
+// headers.
+func splitHeaders(plaintext string) (url.Values, string) {
+	if rest, ok := strings.CutPrefix(plaintext, envelopeMarker); ok {
+		if encoded, body, ok := strings.Cut(rest, "\x00"); ok {
+			if headers, err := url.ParseQuery(encoded); err == nil {
+				return headers, body
+			}
+		}
+	}
+	return url.Values{}, plaintext
+}
+
+// joinHeaders is the inverse of splitHeaders
+func joinHeaders(headers url.Values, body string) string {
+	if len(headers) == 0 {
+		return body
+	}
+	return envelopeMarker + headers.Encode() + "\x00" + body
+}
+
+// sign adds the signature and public key to the envelope of a message from sender to recipientID.
+// A nil identity leaves the message unsigned.
+func (id *identity) sign(sender, recipientID, plaintext string) string {
+	if id == nil {
+		return plaintext
+	}
+	signature := ed25519.Sign(id.private, signedData(sender, signatureRecipient(recipientID), plaintext))
+	headers, body := splitHeaders(plaintext)
+	headers.Set("sig", base64.StdEncoding.EncodeToString(signature))
+	headers.Set("key", base64.StdEncoding.EncodeToString(id.public))
+	return joinHeaders(headers, body)
+}
+
+// signatureStatus is the result of checking a message's signature
+type signatureStatus int
+
+const (
+	signatureNone     signatureStatus = iota // The message was not signed
+	signatureValid                           // Signed by the key pinned for the sender, not yet verified with /verify
+	signatureVerified                        // Signed by the key pinned for the sender and verified with /verify
+	signatureNewKey                          // Signed by a sender seen for the first time; the key is now pinned
+	signatureChanged                         // Validly signed, but by a key other than the one pinned for the sender
+	signatureInvalid                         // The signature does not verify
+	signatureMissing                         // Not signed, although a key is pinned for the sender
+)
+
+// suspect reports whether the message may not be from its sender: its signature is bad, missing
+// although a key is pinned, or by a key other than the pinned one
+func (s signatureStatus) suspect() bool {
+	return s == signatureChanged || s == signatureInvalid || s == signatureMissing
+}
+
+// mark returns the indicator shown after the sender of a message
+func (s signatureStatus) mark() string {
+	switch s {
+	case signatureVerified:
+		return " ✓"
+	case signatureValid, signatureNewKey:
+		return " ?"
+	case signatureChanged:
+		return " !"
+	case signatureInvalid, signatureMissing:
+		return " ✗"
+	default:
+		return ""
+	}
+}
+
+// name returns the status as reported by tail -json and the control API
+func (s signatureStatus) name() string {
+	switch s {
+	case signatureValid:
+		return "valid"
+	case signatureVerified:
+		return "verified"
+	case signatureNewKey:
+		return "new-key"
+	case signatureChanged:
+		return "changed"
+	case signatureInvalid:
+		return "invalid"
+	case signatureMissing:
+		return "missing"
+	default:
+		return ""
+	}
+}
+
+// trusted reports whether the message was signed by the key pinned for the sender, or by the
+// first key seen from them
+func (s signatureStatus) trusted() bool {
+	return s == signatureValid || s == signatureVerified || s == signatureNewKey
+}
+
+// describe explains the status for /info
+func (s signatureStatus) describe(fingerprint string) string {
+	switch s {
+	case signatureValid:
+		return "valid, by the key pinned for the sender (" + fingerprint + "), not yet verified with /verify"
+	case signatureVerified:
+		return "valid, by the key verified for the sender (" + fingerprint + ")"
+	case signatureNewKey:
+		return "valid, by a key seen from the sender for the first time and now pinned (" + fingerprint + ")"
+	case signatureChanged:
+		return "valid, but by a different key than the one pinned for the sender (" + fingerprint + ")"
+	case signatureInvalid:
+		return "INVALID; the sender ID may be spoofed"
+	case signatureMissing:
+		return "MISSING, although a key is pinned for the sender; the sender ID may be spoofed"
+	default:
+		return ""
+	}
+}
+
+// verifySignature checks the signature on a message from sender to recipient ("ALL" for
+// broadcasts) and returns the signing key. signatureNewKey and signatureChanged are left to
+// the caller, which knows the pinned keys.
+func verifySignature(sender, recipient, plaintext string) (ed25519.PublicKey, signatureStatus) {
+	headers, body := splitHeaders(plaintext)
+	encodedSignature, encodedKey := headers.Get("sig"), headers.Get("key")
+	if encodedSignature == "" {
+		return nil, signatureNone
+	}
+	signature, err := base64.StdEncoding.DecodeString(encodedSignature)
+	if err != nil {
+		return nil, signatureInvalid
+	}
+	key, err := base64.StdEncoding.DecodeString(encodedKey)
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		return nil, signatureInvalid
+	}
+	// The signature covers the plaintext as it was before the signature was added
+	headers.Del("sig")
+	headers.Del("key")
+	if !ed25519.Verify(key, signedData(sender, recipient, joinHeaders(headers, body)), signature) {
+		return nil, signatureInvalid
+	}
+	return key, signatureValid
+}
+
+// keyStore holds the identity keys pinned for peers the first time each was seen
+type keyStore struct {
+	mu        sync.Mutex
+	path      string            // File the keys are saved to; "" keeps them in memory
+	keys      map[string]string // Base64 public key by peer ID
+	verified  map[string]bool   // Peers whose pinned key the user verified with /verify
+	presented map[string]string // Key each peer last signed with this session, which may differ from the pinned one
+}
+
+// loadKnownKeys reads the pinned keys from path. A missing file holds no keys.
+func loadKnownKeys(path string) (*keyStore, error) {
+	store := &keyStore{path: path, keys: make(map[string]string), verified: make(map[string]bool), presented: make(map[string]string)}
+	if path == "" {
+		return store, nil
+	}
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return store, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 2 || len(fields) > 3 || (len(fields) == 3 && fields[2] != "verified") {
+			return nil, fmt.Errorf("%s:%d: expected \"<peer> <key> [verified]\"", path, n)
+		}
+		store.keys[fields[0]] = fields[1]
+		store.verified[fields[0]] = len(fields) == 3
+	}
+	return store, scanner.Err()
+}
+
+// check compares the key that signed a message from peer with the one pinned for it, pinning the
+// key if the peer has none yet
+func (k *keyStore) check(peer string, key ed25519.PublicKey) (signatureStatus, error) {
+	encoded := base64.StdEncoding.EncodeToString(key)
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.presented[peer] = encoded
+	switch pinned, ok := k.keys[peer]; {
+	case !ok:
+		k.keys[peer] = encoded
+		return signatureNewKey, k.save()
+	case pinned != encoded:
+		return signatureChanged, nil
+	case k.verified[peer]:
+		return signatureVerified, nil
+	default:
+		return signatureValid, nil
+	}
+}
+
+// checkMessage verifies the signature on a message from sender to recipient ("ALL" for broadcasts)
+// and compares the key with the one pinned under pin, which is the sender's ID unless a relay keeps
+// the keys of several servers apart. A message that is not signed although a key is pinned is
+// signatureMissing, so dropping the signature cannot hide a spoofed sender.
+func (k *keyStore) checkMessage(pin, sender, recipient, plaintext string) (ed25519.PublicKey, signatureStatus, error) {
+	key, status := verifySignature(sender, recipient, plaintext)
+	switch {
+	case status == signatureValid:
+		status, err := k.check(pin, key)
+		return key, status, err
+	case status == signatureNone && k.pinned(pin) != "":
+		return nil, signatureMissing, nil
+	}
+	return key, status, nil
+}
+
+// pinned returns the fingerprint of the key pinned for peer, or "" if none is
+func (k *keyStore) pinned(peer string) string {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	key, err := base64.StdEncoding.DecodeString(k.keys[peer])
+	if err != nil || len(key) == 0 {
+		return ""
+	}
+	return keyFingerprint(key)
+}
+
+// save writes the pinned keys, sorted by peer. The caller holds mu.
+func (k *keyStore) save() error {
+	if k.path == "" || amnesia {
+		return nil
+	}
+	peers := make([]string, 0, len(k.keys))
+	for peer := range k.keys {
+		peers = append(peers, peer)
+	}
+	sort.Strings(peers)
+	var b strings.Builder
+	b.WriteString("# Identity keys pinned for peers; /verify <peer> compares and accepts keys\n")
+	for _, peer := range peers {
+		if k.verified[peer] {
+			fmt.Fprintf(&b, "%s %s verified\n", peer, k.keys[peer])
+		} else {
+			fmt.Fprintf(&b, "%s %s\n", peer, k.keys[peer])
+		}
+	}
+	if err := os.MkdirAll(filepath.Dir(k.path), 0o700); err != nil {
+		return err
+	}
+	return os.WriteFile(k.path, []byte(b.String()), 0o600)
+}
+
+// checkSignature verifies an incoming message's signature and records the result on its entry.
+// The first message from a peer pins its key; a changed key or a bad signature is reported once
+// per peer for the session.
+func (m *model) checkSignature(entry *chatEntry, plaintext string) {
+	recipient := m.clientID
+	if entry.kind == entryBroadcast {
+		recipient = "ALL"
+	}
+	key, status, err := m.knownKeys.checkMessage(entry.sender, entry.sender, recipient, plaintext)
+	if err != nil {
+		m.appendMessage(fmt.Sprintf("Error saving the key of %s: %v", entry.sender, err))
+	}
+	entry.signature = status
+	fingerprint := ""
+	if key != nil {
+		fingerprint = keyFingerprint(key)
+	}
+	entry.signer = fingerprint
+	entry.info.signature = status.describe(fingerprint)
+
+	if m.keyWarned[entry.sender] {
+		return
+	}
+	if notice := status.notice(entry.sender, fingerprint, m.knownKeys.pinned(entry.sender)); notice != "" {
+		// Pinning a first key is worth mentioning for each message; a warning is given once
+		m.keyWarned[entry.sender] = status != signatureNewKey
+		m.appendMessage(notice)
+	}
+}
+
+// signatureChecker checks the signatures on incoming messages for the modes without the UI, giving
+// each warning once per peer as the UI does
+type signatureChecker struct {
+	keys   *keyStore
+	warned map[string]bool
+}
+
+// newSignatureChecker loads the keys pinned for peers from knownKeysPath
+func newSignatureChecker() (*signatureChecker, error) {
+	keys, err := loadKnownKeys(knownKeysPath)
+	if err != nil {
+		return nil, fmt.Errorf("error loading known keys: %v", err)
+	}
+	return &signatureChecker{keys: keys, warned: make(map[string]bool)}, nil
+}
+
+// check returns the signature status of a message to clientID, with the key pinned under pin, and
+// what to tell the user about it, if anything
+func (c *signatureChecker) check(pin, clientID string, msg incomingMessage) (signatureStatus, string) {
+	recipient := clientID
+	if msg.isBroadcast {
+		recipient = "ALL"
+	}
+	key, status, err := c.keys.checkMessage(pin, msg.senderID, recipient, msg.content)
+	if err != nil {
+		return status, fmt.Sprintf("Error saving the key of %s: %v", msg.senderID, err)
+	}
+	if c.warned[pin] {
+		return status, ""
+	}
+	fingerprint := ""
+	if key != nil {
+		fingerprint = keyFingerprint(key)
+	}
+	notice := status.notice(msg.senderID, fingerprint, c.keys.pinned(pin))
+	c.warned[pin] = notice != "" && status != signatureNewKey
+	return status, notice
+}
+
+// notice returns what to tell the user about a message from peer with this status, or "" if
+// nothing: the fingerprint of a key pinned for the first time, or a warning that the sender may be
+// spoofed. Messages are marked as in the UI.
+func (s signatureStatus) notice(peer, fingerprint, pinned string) string {
+	switch s {
+	case signatureNewKey:
+		return fmt.Sprintf("First signed message from %s; pinned their identity key %s.", peer, fingerprint)
+	case signatureChanged:
+		return fmt.Sprintf("Warning: %s signed with key %s, not the pinned %s. Someone may be using their ID. Messages from them are marked !; compare keys with /verify %s before accepting the new one.", peer, fingerprint, pinned, peer)
+	case signatureInvalid:
+		return fmt.Sprintf("Warning: a message from %s has a bad signature and may not be from them. Such messages are marked ✗.", peer)
+	case signatureMissing:
+		return fmt.Sprintf("Warning: a message from %s is not signed, although their key %s is pinned, and may not be from them. Such messages are marked ✗.", peer, pinned)
+	}
+	return ""
+}
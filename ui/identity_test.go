@@ -0,0 +1,129 @@
+package ui
+
+import (
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestSignatureStatus checks the status given to a message from bob to alice for each way its
+// signature can match, or fail to match, the key pinned for bob
+func TestSignatureStatus(t *testing.T) {
+	bob, err := newIdentity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	impostor, err := newIdentity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	signed := bob.sign("bob", "alice", "hello")
+	tests := []struct {
+		name     string
+		content  string
+		pin      *identity // Key pinned for bob beforehand, if any
+		verified bool      // Whether the pinned key was verified with /verify
+		want     signatureStatus
+	}{
+		{"first key", signed, nil, false, signatureNewKey},
+		{"pinned key", signed, bob, false, signatureValid},
+		{"verified key", signed, bob, true, signatureVerified},
+		{"changed key", impostor.sign("bob", "alice", "hello"), bob, false, signatureChanged},
+		{"altered body", strings.Replace(signed, "hello", "hullo", 1), bob, false, signatureInvalid},
+		{"other recipient", bob.sign("bob", "carol", "hello"), bob, false, signatureInvalid},
+		{"other sender", bob.sign("mallory", "alice", "hello"), bob, false, signatureInvalid},
+		{"unsigned", "hello", nil, false, signatureNone},
+		{"unsigned from pinned", "hello", bob, false, signatureMissing},
+	}
+	for _, tt := range tests {
+		keys, err := loadKnownKeys("")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tt.pin != nil {
+			if _, err := keys.check("bob", tt.pin.public); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if tt.verified {
+			if err := keys.setVerified("bob", base64.StdEncoding.EncodeToString(tt.pin.public), true); err != nil {
+				t.Fatal(err)
+			}
+		}
+		_, status, err := keys.checkMessage("bob", "bob", "alice", tt.content)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+		}
+		if status != tt.want {
+			t.Errorf("%s: status %q, want %q", tt.name, status.name(), tt.want.name())
+		}
+		if want := tt.want == signatureNewKey || tt.want == signatureValid || tt.want == signatureVerified; status.trusted() != want {
+			t.Errorf("%s: trusted %v, want %v", tt.name, status.trusted(), want)
+		}
+		if status.suspect() && status.trusted() {
+			t.Errorf("%s: status is both suspect and trusted", tt.name)
+		}
+	}
+}
+
+// TestKnownKeysPersist checks that a first key is pinned to known_keys, readable only by us, and
+// that pinned and verified keys are read back
+func TestKnownKeysPersist(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "padclient", "known_keys")
+	bob, err := newIdentity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	carol, err := newIdentity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys, err := loadKnownKeys(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status, err := keys.check("bob", bob.public); err != nil || status != signatureNewKey {
+		t.Fatalf("first key from bob: %q, %v", status.name(), err)
+	}
+	if err := keys.setVerified("carol", base64.StdEncoding.EncodeToString(carol.public), true); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mode := info.Mode().Perm(); mode != 0600 {
+		t.Errorf("known_keys has mode %o, want 600", mode)
+	}
+
+	reloaded, err := loadKnownKeys(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := reloaded.pinned("bob"); got != bob.fingerprint() {
+		t.Errorf("reloaded bob's key as %q, want %q", got, bob.fingerprint())
+	}
+	tests := []struct {
+		peer string
+		key  *identity
+		want signatureStatus
+	}{
+		{"bob", bob, signatureValid},
+		{"carol", carol, signatureVerified},
+		{"bob", carol, signatureChanged},
+	}
+	for _, tt := range tests {
+		if status, err := reloaded.check(tt.peer, tt.key.public); err != nil || status != tt.want {
+			t.Errorf("reloaded %s: %q, %v; want %q", tt.peer, status.name(), err, tt.want.name())
+		}
+	}
+
+	if err := os.WriteFile(path, []byte("bob\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadKnownKeys(path); err == nil || !strings.Contains(err.Error(), ":1:") {
+		t.Errorf("a malformed known_keys gave %v", err)
+	}
+}
@@ -0,0 +1,87 @@
+// info.go
+// Package ui records how each message was encrypted and shows it with /info.
+
+package ui
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"strconv"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// cipherInfo records how a message was encrypted on the wire
+type cipherInfo struct {
+	cipher      string // Cipher suite, e.g. AES-256-CBC or a one-time XOR key
+	fingerprint string // Short fingerprint of the key that protected the message
+	signature   string // Signature verification result
+	pad         string // Pad ID and offset for pad-encrypted messages
+}
+
+func init() {
+	registerCommand("/info", commandSpec{
+		usage:   "/info <n>",
+		help:    "Show how the nth most recent message was encrypted",
+		minArgs: 1,
+		run: func(m *model, args []string) tea.Cmd {
+			entry, err := m.messageByNumber(args[0])
+			if err != nil {
+				m.appendMessage(err.Error())
+				return nil
+			}
+			m.appendMessage(entry.info.describe())
+			return nil
+		},
+	})
+}
+
+// sharedKeyInfo describes a message encrypted with AES under the shared secret
+func sharedKeyInfo(key []byte) cipherInfo {
+	return cipherInfo{
+		cipher:      "AES-" + strconv.Itoa(len(key)*8) + "-CBC with the shared secret",
+		fingerprint: keyFingerprint(key),
+	}
+}
+
+// oneTimeKeyInfo describes a message encrypted with a per-message XOR key
+func oneTimeKeyInfo(key []byte) cipherInfo {
+	return cipherInfo{
+		cipher:      fmt.Sprintf("XOR with a %d-byte one-time key sent with the message; only the signature protects its integrity", len(key)),
+		fingerprint: keyFingerprint(key),
+	}
+}
+
+// keyFingerprint returns the first 8 bytes of the key's SHA-256 digest as colon-separated hex
+func keyFingerprint(key []byte) string {
+	sum := sha256.Sum256(key)
+	// Built by hand since it runs for every incoming message
+	const digits = "0123456789abcdef"
+	var b strings.Builder
+	b.Grow(8*3 - 1)
+	for i, c := range sum[:8] {
+		if i > 0 {
+			b.WriteByte(':')
+		}
+		b.WriteByte(digits[c>>4])
+		b.WriteByte(digits[c&0x0f])
+	}
+	return b.String()
+}
+
+// describe formats the metadata for /info
+func (c cipherInfo) describe() string {
+	if c.cipher == "" {
+		return "No encryption metadata was recorded for that message."
+	}
+	signature := c.signature
+	if signature == "" {
+		signature = "none (message was not signed)"
+	}
+	pad := c.pad
+	if pad == "" {
+		pad = "none (not pad-encrypted)"
+	}
+	return fmt.Sprintf("Cipher: %s\nKey fingerprint: %s\nSignature: %s\nPad: %s", c.cipher, c.fingerprint, signature, pad)
+}
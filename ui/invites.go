@@ -0,0 +1,138 @@
+// invites.go
+// Package ui tracks invitations to invite-only channels and lets the user accept or decline them.
+
+package ui
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// inviteMsg reports an invitation pushed by the server: INVITE <channel> <fromID>
+type inviteMsg struct {
+	channel string
+	from    string
+}
+
+// pendingInvite is an invitation the user has not answered yet
+type pendingInvite struct {
+	channel string
+	from    string
+	at      time.Time
+}
+
+func init() {
+	registerCommand("/invite", commandSpec{
+		usage:   "/invite <channel> <ClientID>",
+		help:    "Invite a client to an invite-only channel (needs the INVITE capability)",
+		minArgs: 2,
+		run: func(m *model, args []string) tea.Cmd {
+			if !m.serverCaps["INVITE"] {
+				m.appendMessage("The server does not support INVITE.")
+				return nil
+			}
+			m.lastServerCommand = "INVITE"
+			m.writeLine(fmt.Sprintf("INVITE %s %s", args[0], args[1]))
+			return nil
+		},
+	})
+	registerCommand("/invites", commandSpec{
+		usage: "/invites",
+		help:  "List invitations waiting for an answer",
+		run: func(m *model, args []string) tea.Cmd {
+			if len(m.invites) == 0 {
+				m.appendMessage("No pending invitations.")
+				return nil
+			}
+			for _, invite := range m.invites {
+				m.appendMessage(fmt.Sprintf("%s invited you to %s %s ago", invite.from, invite.channel, time.Since(invite.at).Round(time.Second)))
+			}
+			return nil
+		},
+	})
+	registerCommand("/accept", commandSpec{
+		usage: "/accept [channel]",
+		help:  "Accept the oldest invitation, or the one to a channel",
+		run: func(m *model, args []string) tea.Cmd {
+			m.answerInvite("ACCEPT", args)
+			return nil
+		},
+	})
+	registerCommand("/decline", commandSpec{
+		usage: "/decline [channel]",
+		help:  "Decline the oldest invitation, or the one to a channel",
+		run: func(m *model, args []string) tea.Cmd {
+			m.answerInvite("DECLINE", args)
+			return nil
+		},
+	})
+}
+
+// parseInvite recognizes an invitation from the server: INVITE <channel> <fromID>
+func parseInvite(line string) (inviteMsg, bool) {
+	fields := strings.Fields(line)
+	if len(fields) != 3 || fields[0] != "INVITE" {
+		return inviteMsg{}, false
+	}
+	return inviteMsg{channel: fields[1], from: fields[2]}, true
+}
+
+// addInvite records an invitation, replacing an earlier one to the same channel
+func (m *model) addInvite(msg inviteMsg) {
+	for i, invite := range m.invites {
+		if invite.channel == msg.channel {
+			m.invites = append(m.invites[:i], m.invites[i+1:]...)
+			break
+		}
+	}
+	m.invites = append(m.invites, pendingInvite{channel: msg.channel, from: msg.from, at: time.Now()})
+	m.appendEntry(chatEntry{kind: entrySystem, content: fmt.Sprintf("%s invited you to %s. /accept or /decline", msg.from, msg.channel), highlight: true})
+}
+
+// answerInvite sends ACCEPT or DECLINE for the oldest invitation, or the one to the named channel
+func (m *model) answerInvite(verb string, args []string) {
+	if len(m.invites) == 0 {
+		m.appendMessage("No pending invitations.")
+		return
+	}
+	i := 0
+	if len(args) > 0 {
+		i = -1
+		for j, invite := range m.invites {
+			if invite.channel == args[0] {
+				i = j
+				break
+			}
+		}
+		if i < 0 {
+			m.appendMessage(fmt.Sprintf("No pending invitation to %s.", args[0]))
+			return
+		}
+	}
+	invite := m.invites[i]
+	if !m.writeLine(fmt.Sprintf("%s %s", verb, invite.channel)) {
+		return
+	}
+	m.invites = append(m.invites[:i], m.invites[i+1:]...)
+	if verb == "ACCEPT" {
+		m.appendMessage(fmt.Sprintf("Accepted the invitation to %s.", invite.channel))
+	} else {
+		m.appendMessage(fmt.Sprintf("Declined the invitation to %s.", invite.channel))
+	}
+}
+
+// inviteLine renders the pending invitations shown above the input
+func (m *model) inviteLine() string {
+	switch len(m.invites) {
+	case 0:
+		return ""
+	case 1:
+		invite := m.invites[0]
+		return fmt.Sprintf("Invitation to %s from %s — /accept or /decline", invite.channel, invite.from)
+	default:
+		return fmt.Sprintf("%d invitations pending — /invites to list, /accept or /decline [channel]", len(m.invites))
+	}
+}
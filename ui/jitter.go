@@ -0,0 +1,64 @@
+// jitter.go
+// Package ui adds bounded random delays to outgoing messages so send timing does not mirror typing.
+
+package ui
+
+import (
+	"crypto/rand"
+	"fmt"
+	"math/big"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// maxSendJitter bounds the random delay added to every outgoing message (0 disables)
+var maxSendJitter time.Duration
+
+func init() {
+	registerCommand("/jitter", commandSpec{
+		usage:   "/jitter <max|off> [conversation]",
+		help:    "Delay outgoing messages by a random time up to max, for all conversations or one",
+		minArgs: 1,
+		run: func(m *model, args []string) tea.Cmd {
+			conversation := "*"
+			if len(args) > 1 {
+				conversation = args[1]
+			}
+			if args[0] == "off" {
+				if conversation == "*" {
+					// Turning everything off also clears per-conversation settings
+					m.sendJitter = map[string]time.Duration{"*": 0}
+				} else {
+					m.sendJitter[conversation] = 0
+				}
+				m.appendMessage(fmt.Sprintf("Send jitter off for %s.", describeConversation(conversation)))
+				return nil
+			}
+			max, err := time.ParseDuration(args[0])
+			if err != nil || max < 0 {
+				m.appendMessage("Usage: " + knownCommands["/jitter"].usage)
+				return nil
+			}
+			m.sendJitter[conversation] = max
+			m.appendMessage(fmt.Sprintf("Messages to %s are now delayed by up to %s.", describeConversation(conversation), max))
+			return nil
+		},
+	})
+}
+
+// jitterFor picks a random delay for a message to the recipient, bounded by its conversation's setting
+func (m *model) jitterFor(recipientID string) time.Duration {
+	max, ok := m.sendJitter[recipientID]
+	if !ok {
+		max = m.sendJitter["*"]
+	}
+	if max <= 0 {
+		return 0
+	}
+	n, err := rand.Int(rand.Reader, big.NewInt(int64(max)+1))
+	if err != nil {
+		return max
+	}
+	return time.Duration(n.Int64())
+}
@@ -0,0 +1,84 @@
+// keybindings.go
+// Package ui maps key presses to UI actions, so the keys can be changed with -keys.
+
+package ui
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// keyActions are the actions that can be bound, with their default keys
+var keyActions = map[string][]string{
+	"quit":          {"ctrl+c", "esc"},
+	"picker":        {"ctrl+t"},
+	"server-buffer": {"f2"},
+	"sidebar":       {"f3"},
+	"security":      {"f4"},
+	"prev-tab":      {"ctrl+left"},
+	"next-tab":      {"ctrl+right"},
+	"expand":        {"ctrl+e"},
+	"bookmark":      {"alt+b"},
+	"reveal":        {"alt+r"},
+	"scroll-up":     {"pgup", "ctrl+u"},
+	"scroll-down":   {"pgdown", "ctrl+d"},
+	"top":           {"home", "ctrl+home"},
+	"bottom":        {"end", "ctrl+end"},
+}
+
+// keyBindings maps a key, as tea.KeyMsg.String names it, to its action
+var keyBindings = defaultKeyBindings()
+
+// defaultKeyBindings returns the bindings from the default keys of each action
+func defaultKeyBindings() map[string]string {
+	bindings := make(map[string]string)
+	for action, keys := range keyActions {
+		for _, key := range keys {
+			bindings[key] = action
+		}
+	}
+	return bindings
+}
+
+// keyActionNames returns the actions, sorted, for usage messages
+func keyActionNames() []string {
+	names := make([]string, 0, len(keyActions))
+	for action := range keyActions {
+		names = append(names, action)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// keysValue is the -keys flag: comma-separated action=key pairs. An action given a key loses its
+// default keys; several pairs for one action bind it to each key.
+type keysValue struct{}
+
+func (keysValue) String() string { return "" }
+
+func (keysValue) Set(value string) error {
+	bindings := defaultKeyBindings()
+	rebound := make(map[string]bool)
+	for _, pair := range strings.Split(value, ",") {
+		action, key, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		key = strings.ToLower(strings.TrimSpace(key))
+		if !ok || key == "" {
+			return fmt.Errorf("expected action=key, got %q", pair)
+		}
+		if _, known := keyActions[action]; !known {
+			return fmt.Errorf("unknown action %q; use one of %s", action, strings.Join(keyActionNames(), ", "))
+		}
+		if !rebound[action] {
+			rebound[action] = true
+			for bound, a := range bindings {
+				if a == action {
+					delete(bindings, bound)
+				}
+			}
+		}
+		bindings[key] = action
+	}
+	keyBindings = bindings
+	return nil
+}
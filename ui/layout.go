@@ -0,0 +1,67 @@
+// layout.go
+// Package ui sizes the viewport, sidebar, and input to the terminal, re-wrapping the
+// conversation when the width changes.
+
+package ui
+
+import (
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+const (
+	defaultWidth      = 80 // Screen width assumed until the terminal reports its size
+	minViewportWidth  = 20 // Narrowest the conversation gets on a small terminal
+	minViewportHeight = 3  // Fewest conversation lines shown on a small terminal
+	minInputWidth     = 10 // Narrowest the input field gets on a small terminal
+)
+
+// resize records the terminal size and lays the screen out for it
+func (m *model) resize(msg tea.WindowSizeMsg) {
+	m.width, m.height = msg.Width, msg.Height
+	m.layout()
+}
+
+// screenWidth returns the terminal width, or the default until the terminal reports it
+func (m *model) screenWidth() int {
+	if m.width > 0 {
+		return m.width
+	}
+	return defaultWidth
+}
+
+// layout gives the viewport the space the other sections leave and sizes the input to the
+// screen. A width change re-wraps the conversation. Until the terminal reports its size, the
+// viewport keeps its initial size.
+func (m *model) layout() {
+	if m.width == 0 || m.height == 0 {
+		return
+	}
+	m.input.Width = max(m.width-lipgloss.Width(m.input.Prompt)-1, minInputWidth)
+
+	width := m.width
+	if m.sidebar {
+		width -= sidebarWidth
+	}
+	width = max(width, minViewportWidth)
+	above, below := m.chrome()
+	chromeHeight := lipgloss.Height(strings.Join(append(above, below...), "\n"))
+	height := max(m.height-chromeHeight, minViewportHeight)
+	if width == m.viewport.Width && height == m.viewport.Height {
+		return
+	}
+	atBottom := m.viewport.AtBottom()
+	widthChanged := width != m.viewport.Width
+	m.viewport.Width, m.viewport.Height = width, height
+	if widthChanged {
+		m.refreshViewport()
+	}
+	if atBottom {
+		m.viewport.GotoBottom()
+	} else {
+		// Keep the offset within the content after a resize
+		m.viewport.SetYOffset(m.viewport.YOffset)
+	}
+}
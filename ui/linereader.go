@@ -0,0 +1,14 @@
+// linereader.go
+// Package ui sets the line length limit for the protocol reader so a malicious server cannot exhaust memory.
+
+package ui
+
+import "github.com/drewwalton19216801/padclient/protocol"
+
+// maxLineLength is the longest line accepted from the server, in bytes
+var maxLineLength = protocol.DefaultMaxLineLength
+
+// protocolErrorMsg reports a server line that broke the protocol and was dropped
+type protocolErrorMsg struct {
+	content string
+}
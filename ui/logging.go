@@ -0,0 +1,50 @@
+// logging.go
+// Package ui writes an optional log of the session's connection events, for diagnosing
+// problems after the fact. It records connects, disconnects, and errors, never message content.
+
+package ui
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+)
+
+var (
+	// logFile is the -log-file setting; empty disables the log
+	logFile string
+
+	// logLevel is the -log-level setting: debug, info, warn, or error
+	logLevel = "info"
+
+	// sessionLog receives the session's connection events; it discards them until openSessionLog
+	sessionLog = slog.New(slog.NewTextHandler(io.Discard, nil))
+)
+
+// openSessionLog opens -log-file for appending, readable only by us. It returns the function that
+// closes it. Amnesia mode refuses a log, since it never writes to disk.
+func openSessionLog(clientID string) (func(), error) {
+	if logFile == "" {
+		return func() {}, nil
+	}
+	if amnesia {
+		return nil, &fatalError{code: exitUsage, err: errors.New("-log-file writes to disk, which amnesia mode never does")}
+	}
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(logLevel)); err != nil {
+		return nil, &fatalError{code: exitUsage, err: fmt.Errorf("invalid -log-level %q; use debug, info, warn, or error", logLevel)}
+	}
+	file, err := os.OpenFile(logFile, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("error opening log file: %v", err)
+	}
+	sessionLog = slog.New(slog.NewTextHandler(file, &slog.HandlerOptions{Level: level}))
+	sessionLog.Info("session started", "id", clientID, "server", serverHost())
+	return func() {
+		sessionLog.Info("session ended")
+		sessionLog = slog.New(slog.NewTextHandler(io.Discard, nil))
+		file.Close()
+	}, nil
+}
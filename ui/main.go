@@ -0,0 +1,1475 @@
+// main.go
+// Package ui is the padclient application behind cmd/padclient. Main parses the flags and config
+// file and dispatches the subcommands; this file holds the Bubble Tea model of the interactive
+// client, its input handling, and its main loop. The wire format, ciphers, and connection it
+// builds on are in the protocol, crypto, and transport packages.
+
+package ui
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/progress"
+	"github.com/charmbracelet/bubbles/textinput"       // Text input component
+	"github.com/charmbracelet/bubbles/viewport"        // Viewport component for scrolling messages
+	tea "github.com/charmbracelet/bubbletea"           // Bubble Tea TUI framework
+	"github.com/charmbracelet/lipgloss"                // Styles and layout for the terminal
+	"github.com/drewwalton19216801/padclient/protocol" // Pad protocol lines and payloads
+	"github.com/drewwalton19216801/padclient/transport"
+)
+
+var (
+	address string // Server address
+)
+
+// serverHost returns the host part of the server address
+func serverHost() string {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return address
+	}
+	return host
+}
+
+const (
+	messageBuffer   = 256 // Server messages the reader can queue before it waits for the UI
+	maxMessageBatch = 64  // Most server messages handled in one update
+)
+
+// Define message types used in the Bubble Tea program
+type errMsg struct{ error }
+type connectedMsg struct {
+	conn         net.Conn
+	hashedSecret []byte
+	isOperator   bool
+	fingerprint  string // Fingerprint of the server's public key
+}
+type serverMsg struct {
+	content    string
+	isResponse bool // Whether the content is a multi-line command response
+}
+type operatorMsg struct {
+	content string
+}
+type kickedMsg struct{}
+type bannedMsg struct{}
+type disconnectMsg struct{}
+type serverBatchMsg []tea.Msg // Server messages that arrived together, oldest first
+type capabilitiesMsg struct {
+	capabilities []string
+}
+type incomingMessage struct {
+	senderID    string
+	content     string
+	isBroadcast bool
+	filter      *filterRule // Filter rule that matched the message, if any
+	info        cipherInfo  // How the message was encrypted
+}
+
+// Model represents the application's state
+type model struct {
+	isOperator      bool                     // Operator status
+	clientID        string                   // Client identifier
+	conn            net.Conn                 // Network connection
+	connCancel      context.CancelFunc       // Cancels the goroutines serving the current connection
+	writer          *connWriter              // Writes queued lines to the current connection
+	sealing         []*pendingSend           // Outgoing messages being encrypted, in the order sent
+	bulkConn        net.Conn                 // Second connection carrying bulk traffic, if attached
+	bulkCancel      context.CancelFunc       // Cancels the goroutines serving the bulk stream
+	bulkWriter      *connWriter              // Writes bulk lines to the bulk stream
+	input           textinput.Model          // Text input component for user commands
+	viewport        viewport.Model           // Viewport for displaying messages
+	entries         []chatEntry              // All messages to display in the viewport
+	entryLines      []int                    // Viewport line each entry starts on
+	entrySeq        int                      // Last entry sequence number handed out
+	flash           string                   // One-line status shown under the input until the next key press
+	history         []string                 // Command history
+	historyIndex    int                      // Current index in the history (-1 means not navigating)
+	hashedSecret    []byte                   // Hashed secret for AES encryption
+	pads            *padStore                // One-time pads shared with peers; nil when none are loaded
+	keys            *sessionKey              // Shared secret as seen by the message reader, swapped on rekey
+	rekeying        bool                     // Whether a key exchange with the server is in progress
+	lastRender      time.Time                // When the viewport was last rebuilt
+	renderPending   bool                     // Whether a viewport rebuild was deferred
+	renderScroll    bool                     // Whether the deferred rebuild should scroll to the bottom
+	renderScheduled bool                     // Whether a render tick is on its way
+	archive         *scrollbackArchive       // Entries evicted from memory, if any
+	archiveFailed   bool                     // Whether the archive could not be created, so nothing is evicted
+	pagedIn         int                      // Entries at the start of the buffer paged in from the archive
+	pagedFrom       int                      // Archive index of the first paged-in entry
+	unseen          int                      // Messages received since the last key press
+	mux             multiplexer              // Terminal multiplexer the client runs in
+	muxWindowName   string                   // Multiplexer window name to restore on exit
+	muxUnread       int                      // Unread count last shown in the window title
+	lastTitle       string                   // Terminal title last set
+	draining        bool                     // Whether a batch of server messages is being handled
+	resumeReading   bool                     // Whether a message in the batch asked to keep reading from the server
+	coverGen        int                      // Generation of the running cover traffic schedule
+	sendJitter      map[string]time.Duration // Maximum send delay by conversation ("*" for the default)
+	messageChan     chan tea.Msg             // Channel for incoming messages from the server
+
+	recentSenders []string             // Peers that recently messaged us, most recent first
+	peerLastSeen  map[string]time.Time // When each peer last messaged us
+	picker        fuzzyPicker          // Fuzzy picker state
+	confirm       *pendingConfirmation // Destructive command awaiting confirmation
+	outbox        []queuedSend         // Outgoing messages waiting out the undo window
+	outboxSeq     int                  // Last outbox sequence number handed out
+	awaitingAck   []int                // Outbox IDs written to the server and awaiting an ACK, oldest first
+	lastVerb      string               // Verb of the last line queued for the server
+	serverCaps    map[string]bool      // Protocol extensions advertised by the server
+
+	pins              map[string][]chatEntry        // Pinned messages by conversation
+	panel             string                        // Panel shown between the viewport and the input (empty for none)
+	pinsConversation  string                        // Conversation shown in the pinned panel (empty for all)
+	bookmarks         []int                         // Sequence numbers of bookmarked entries
+	watchKeywords     map[string]bool               // Lower-cased keywords that highlight messages
+	watched           []chatEntry                   // Messages that matched a watch keyword or mentioned us
+	filters           *messageFilters               // Filter rules applied to incoming messages
+	buffers           map[string][]chatEntry        // Named buffers: server notices and messages routed by filter rules
+	bufferUnread      map[string]int                // Unread entry counts by buffer
+	bufferName        string                        // Buffer shown in the buffer panel
+	mask              *contentMask                  // Wordlist masking applied at render time
+	translateAccepted bool                          // Whether the user accepted the translation privacy warning
+	ttsConversations  map[string]bool               // Text-to-speech enablement by conversation ("*" for the default)
+	motd              *motdMsg                      // The server's message of the day, once received
+	responses         []commandResponse             // Recent multi-line command responses, oldest first
+	lastServerCommand string                        // Verb of the last command passed through to the server
+	roster            map[string]*ClientInfo        // Connected clients from the last LIST, by ID
+	rosterSort        string                        // Column the roster pane is sorted by
+	presenceMuted     map[string]bool               // Join/part notice suppression by peer ("*" for the default)
+	operatorOnly      map[string]bool               // Commands known to be operator-only
+	shutdownAt        time.Time                     // When an announced shutdown or restart takes effect
+	restartAttempts   int                           // Reconnect attempts made since the announced shutdown
+	reconnectAttempts int                           // Reconnect attempts made since an unexpected disconnect
+	holdOutbox        bool                          // Whether outgoing messages are held until we reconnect
+	clockSkew         time.Duration                 // Local clock minus the server's, once measured
+	clockMeasured     bool                          // Whether the server has reported its time
+	verifiedPeers     map[string]bool               // Peers whose keys the user has verified
+	identity          *identity                     // Keypair that signs our outgoing messages
+	knownKeys         *keyStore                     // Identity keys pinned for peers
+	keyWarned         map[string]bool               // Peers already warned about for a bad signature or changed key
+	wal               *outboxLog                    // Write-ahead log of the outbox, if enabled
+	walPending        []queuedSend                  // Unsent messages recovered from the log, queued once connected
+	selfCheck         []string                      // Findings of the startup security self-check
+	protocolErrors    int                           // Server lines dropped for breaking the protocol this session
+	received          int                           // Messages received from other clients this session
+	reconnects        int                           // Reconnect attempts made this session
+	integrityFailures int                           // Messages that failed to decode or decrypt this session
+	quarantine        []quarantinedMessage          // Undecryptable messages held for retry or discard
+	search            *serverSearch                 // The most recent server-side search, if any
+	threadView        bool                          // Whether replies are collapsed under the message they reply to
+	polls             map[string]*poll              // Polls seen this session, by ID
+	latestPoll        string                        // ID of the poll Alt+digit votes in
+	topic             string                        // The room topic, if the server reported one
+	topicKnown        bool                          // Whether the server has reported the topic yet
+	invites           []pendingInvite               // Channel invitations waiting for an answer, oldest first
+	notifyLevels      map[string]string             // Notification level by conversation ("*" for the default)
+	archived          map[string]bool               // Conversations hidden from the recipient list
+	bans              []BanInfo                     // Bans from the last LISTBANS
+	chatHistory       *historyStore                 // Persistent encrypted history; nil when off
+	historyStart      int                           // Index of the first history record restored at startup
+	historyCursor     map[string]int                // Index of the oldest history record shown by /history, by conversation
+	seenMessages      map[string]time.Time          // Recently received messages by key, to match relayed alerts
+	seenRelays        map[string]time.Time          // Recently received relayed alerts by the key of the message they repeat
+	shownMessages     map[string]time.Time          // Recently shown messages by ID or content, to drop copies replayed after a reconnect
+	syncPeers         []string                      // Client IDs of the user's other devices
+	syncPending       syncUpdate                    // State changed since the last sync with the other devices
+	syncScheduled     bool                          // Whether a sync is waiting to be sent
+	draftSynced       string                        // Draft last sent to or received from the other devices
+	reconnectedAt     time.Time                     // When the connection was last re-established
+	transfers         map[transferKey]*fileTransfer // File transfers offered or running, by peer and ID
+	transferScheduled bool                          // Whether more file chunks are waiting to be queued
+	transferBar       progress.Model                // Progress bar drawn for file transfers
+	slowMode          time.Duration                 // Delay between messages to ALL the server enforces; 0 when off
+	rateLimit         rateLimitMsg                  // Rate limit the operator set for us, if any
+	nextSlow          time.Time                     // When slow mode next lets a message to ALL go out
+	paced             []time.Time                   // Send times of our recent messages, counted against the rate limit
+	paceTicking       bool                          // Whether the slow mode countdown is being refreshed
+	sidebar           bool                          // Whether the user list sidebar is shown
+	sidebarFocus      bool                          // Whether the arrow keys move the sidebar selection instead of the command history
+	sidebarIndex      int                           // Selected user in the sidebar
+	sidebarGen        int                           // Sidebar refresh schedule; bumped to stop the running one
+	sidebarRefreshing bool                          // Whether the pending LIST response is a sidebar refresh
+	serverFingerprint string                        // Fingerprint of the server's public key, once connected
+	tabs              []string                      // Open conversation tabs in the order they were opened
+	activeTab         string                        // Conversation shown in the viewport
+	tabUnread         map[string]int                // Unread messages by background tab
+	tabOffsets        map[string]int                // Scroll offset each tab was left at (-1 for the bottom)
+	desktopMuted      map[string]bool               // Desktop notification mutes by conversation ("*" for the default)
+	unfocused         bool                          // Whether the terminal reported losing focus
+	width             int                           // Terminal width, once reported
+	height            int                           // Terminal height, once reported
+	exitErr           error                         // Error that ended the session, which sets the exit code
+}
+
+// Main runs padclient: the UI, or the subcommand named by the first argument. It exits the
+// process when done; cmd/padclient calls it.
+func Main() {
+	defer stopTsnet()
+	flag.DurationVar(&undoWindow, "undo-window", undoWindow, "how long outgoing messages can be cancelled with /undo (0 disables)")
+	watch := flag.String("watch", "", "comma-separated keywords that highlight matching messages")
+	maskWords := flag.String("mask-words", "", "file of words (one per line) to mask in messages; enables masking")
+	flag.StringVar(&translateCommand, "translate-cmd", "", "command that reads a message on stdin and prints its translation for /translate")
+	flag.StringVar(&translateURL, "translate-url", "", "HTTP endpoint that receives a message as a POST body and returns its translation for /translate")
+	flag.StringVar(&ttsCommand, "tts-cmd", "", "text-to-speech command for announcing direct messages and mentions (e.g. say, espeak)")
+	flag.BoolVar(&desktopNotify, "desktop-notify", false, "show desktop notifications for direct messages and mentions while the terminal is not focused")
+	flag.DurationVar(&maxClockSkew, "max-skew", maxClockSkew, "warn when the local clock differs from the server's by more than this")
+	flag.DurationVar(&coverInterval, "cover-traffic", 0, "average interval between encrypted no-op cover messages (0 disables)")
+	flag.DurationVar(&maxSendJitter, "send-jitter", 0, "delay every outgoing message by a random time up to this (0 disables)")
+	flag.BoolVar(&amnesia, "amnesia", false, "keep all state in memory, never write history, exports, or logs to disk, and wipe it on exit")
+	flag.BoolVar(&skipSelfCheck, "skip-self-check", false, "do not print the startup security self-check (findings stay on the security dashboard)")
+	flag.StringVar(&walPath, "wal", "", "write-ahead log file that keeps unsent messages across crashes")
+	flag.IntVar(&maxLineLength, "max-line-length", maxLineLength, "longest line accepted from the server, in bytes; longer lines are dropped")
+	flag.IntVar(&maxRendersPerSecond, "render-rate", maxRendersPerSecond, "most viewport rebuilds per second while messages stream in (0 for no limit)")
+	flag.IntVar(&scrollbackLimit, "scrollback", scrollbackLimit, "messages kept in memory; older ones are paged in from an encrypted session archive (0 keeps everything)")
+	flag.StringVar(&cpuProfilePath, "cpuprofile", "", "write a CPU profile to this file while the client runs")
+	flag.StringVar(&pprofAddress, "pprof", "", "serve pprof profiles on this address, e.g. localhost:6060")
+	flag.BoolVar(&reconnectDisabled, "no-reconnect", false, "exit when the connection drops instead of reconnecting")
+	flag.IntVar(&maxReconnectAttempts, "reconnect-attempts", maxReconnectAttempts, "reconnect attempts before giving up after the connection drops (0 for no limit)")
+	flag.BoolVar(&muxDisabled, "no-mux", false, "do not integrate with tmux or GNU screen")
+	flag.BoolVar(&titleDisabled, "no-title", false, "do not set the terminal title")
+	flag.BoolVar(&historyEnabled, "history", false, "keep conversations across sessions in a history file encrypted with a passphrase")
+	flag.StringVar(&historyPath, "history-file", defaultHistoryPath(), "history file used with -history")
+	flag.StringVar(&allowServers, "allow-servers", "", "comma-separated servers the client may connect to, each optionally followed by =<key fingerprint> to pin it")
+	flag.BoolVar(&allowNonTailscale, "allow-non-tailscale", false, "connect without Tailscale; a warning banner stays on screen")
+	addTsnetFlags(flag.CommandLine)
+	flag.BoolVar(&discoverPeers, "discover", false, "list the tailnet peers running a pad server and pick one instead of passing the server")
+	flag.StringVar(&tailscaleSocket, "tailscale-socket", tailscaleSocket, "tailscaled LocalAPI socket used to check the server is reachable before connecting (empty to skip)")
+	flag.BoolVar(&insecureNewServer, "insecure-new-server", false, "allow connecting to a server missing from -allow-servers after confirming it")
+	flag.StringVar(&padDir, "pad-dir", defaultPadDir(), "directory holding one-time pads shared with peers")
+	flag.StringVar(&identityDir, "identity-dir", identityDir, "directory holding the identity key that signs messages, one per client ID")
+	flag.StringVar(&knownKeysPath, "known-keys", knownKeysPath, "file of identity keys pinned for peers the first time each signs a message")
+	flag.StringVar(&downloadDir, "download-dir", downloadDir, "directory files accepted from SENDFILE are saved to")
+	flag.StringVar(&themeName, "theme", themeName, "colors of the UI: "+strings.Join(themeNames(), ", "))
+	flag.Var(keysValue{}, "keys", "comma-separated action=key bindings replacing the default keys of those actions, e.g. sidebar=f5,quit=ctrl+q; actions: "+strings.Join(keyActionNames(), ", "))
+	flag.StringVar(&logFile, "log-file", "", "append connection events, never message content, to this file")
+	flag.StringVar(&logLevel, "log-level", logLevel, "least severe events written to -log-file: debug, info, warn, or error")
+	flag.StringVar(&identiconStyle, "identicon", identiconStyle, "identicon drawn for each sender from their key: "+strings.Join(identiconStyleNames(), ", ")+", or none")
+	flag.StringVar(&syncWith, "sync-with", "", "comma-separated client IDs of your other devices to sync read positions, drafts, and mute settings with")
+	flag.StringVar(&notifyLevelsPath, "notify-file", defaultNotifyLevelsPath(), "file that keeps per-conversation notification levels across sessions")
+	flag.BoolVar(&jsonErrors, "json-errors", false, "write fatal errors to stderr as JSON objects for scripts")
+	flag.BoolVar(&headless, "headless", false, "run without the UI: read commands from stdin and print messages to stdout, one per line")
+	flag.BoolVar(&headlessJSON, "json", false, "with -headless, print one JSON object per event")
+	// The config file is read before the flags are parsed; see configPathFromArgs
+	flag.String("config", defaultConfigPath(), "config file with default settings; command-line flags and arguments override it")
+	flag.Var(portValue{}, "port", "TCP port the server listens on (default 12345, or as found through DNS)")
+	flag.StringVar(&telemetryURL, "telemetry-url", "", "opt in to sending privacy-scrubbed error reports to this endpoint")
+	flag.Usage = func() {
+		fmt.Println("Usage: padclient [flags] [<YourID> [<TailscaleServer>]]")
+		fmt.Println("       padclient -discover [flags] [<YourID>]")
+		fmt.Println("       padclient completion bash|zsh|fish")
+		fmt.Println("       padclient update [-url <manifest>] [-check] [-force]")
+		fmt.Println("       padclient send -to <ID|ALL> -server <TailscaleServer> [-id <YourID>] <message>|-")
+		fmt.Println("       padclient tail -server <TailscaleServer> [-id <YourID>] [-json]")
+		fmt.Println("       padclient daemon -server <TailscaleServer> [-id <YourID>] [-socket <path>] [-http <addr>]")
+		fmt.Println("       padclient relay -a <TailscaleServer> -allow-a <IDs> -b <TailscaleServer> -allow-b <IDs> [-id <YourID>]")
+		fmt.Println("       padclient pad generate <size> -peer <ID> | import <file> -peer <ID> | list")
+		fmt.Println("       padclient bench [-messages <n>] [-size <bytes>] [-direct] [-base64]")
+		flag.PrintDefaults()
+	}
+	showVersion := flag.Bool("version", false, "print the client version and build information, then exit")
+	if len(os.Args) > 1 && os.Args[1] == "completion" {
+		// Generate a shell completion script from the flags defined above
+		if err := runCompletion(os.Stdout, os.Args[2:]); err != nil {
+			fmt.Println(err)
+			os.Exit(2)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "send" {
+		// Send one message and exit, for scripts and cron jobs
+		if err := runSend(os.Args[2:]); err != nil {
+			exitWith(err)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "tail" {
+		// Print incoming messages until interrupted, for piping into other tools
+		if err := runTail(os.Args[2:]); err != nil {
+			exitWith(err)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "daemon" {
+		// Stay connected without the UI and serve the local control API
+		if err := runDaemon(os.Args[2:]); err != nil {
+			exitWith(err)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "relay" {
+		// Carry messages between two servers
+		if err := runRelay(os.Args[2:]); err != nil {
+			exitWith(err)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "pad" {
+		// Manage one-time pads shared with peers
+		if err := runPad(os.Args[2:]); err != nil {
+			exitWith(err)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "bench" {
+		// Measure how fast incoming messages are read and decrypted
+		if err := runBench(os.Args[2:]); err != nil {
+			exitWith(err)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "update" {
+		// Replace this binary with the latest signed release
+		if err := runUpdate(os.Args[2:]); err != nil {
+			exitWith(err)
+		}
+		return
+	}
+	// Settings from the config file become flag defaults, so the command line overrides them.
+	// Subcommands read it themselves; see readSubcommandConfig
+	configFile := configPathFromArgs(flag.CommandLine, os.Args[1:])
+	config, err := loadConfig(configFile)
+	if err == nil {
+		err = config.apply(flag.CommandLine, configFile)
+	}
+	if err != nil {
+		exitWith(&fatalError{code: exitUsage, err: fmt.Errorf("Error reading config: %v", err)})
+	}
+	flag.Parse()
+	if *showVersion {
+		for _, line := range versionLines() {
+			fmt.Println(line)
+		}
+		return
+	}
+	if err := checkIdenticonStyle(identiconStyle); err != nil {
+		exitWith(err)
+	}
+	if err := applyTheme(themeName); err != nil {
+		exitWith(err)
+	}
+	clientID, serverIP := config.id, config.server
+	if flag.NArg() > 0 {
+		clientID = flag.Arg(0)
+	}
+	if flag.NArg() > 1 {
+		serverIP = flag.Arg(1)
+	}
+	if discoverPeers {
+		// Pick the server from the tailnet peers running one
+		if flag.NArg() > 1 {
+			exitWith(&fatalError{code: exitUsage, err: errors.New("-discover finds the server; pass only <YourID>")})
+		}
+		if serverIP, err = choosePadPeer(); err != nil {
+			exitWith(err)
+		}
+	}
+	if clientID == "" || serverIP == "" || flag.NArg() > 2 {
+		flag.Usage()
+		os.Exit(exitUsage)
+	}
+	address = net.JoinHostPort(serverIP, strconv.Itoa(serverPort))
+	if found, ok := discoverServer(serverIP); ok {
+		// The server's DNS records say where it listens
+		address = net.JoinHostPort(found.host, strconv.Itoa(found.port))
+		// Headless mode keeps stdout for events
+		status := os.Stdout
+		if headless {
+			status = os.Stderr
+		}
+		fmt.Fprintf(status, "Found %s at %s through its %s record.\n", serverIP, address, found.source)
+		if len(found.caps) > 0 {
+			fmt.Fprintf(status, "It advertises %s.\n", strings.Join(found.caps, ", "))
+		}
+	}
+	if err := checkServerAllowed(serverIP); err != nil {
+		exitWith(err)
+	}
+	if serverHost() != serverIP {
+		// A record pointing elsewhere must not lead around the allowlist
+		if err := checkServerAllowed(serverHost()); err != nil {
+			exitWith(err)
+		}
+	}
+
+	// Check if the local IP address belongs to a Tailscale interface
+	if err := requireTailscale(serverIP); err != nil {
+		exitWith(err)
+	}
+
+	// Ask Tailscale whether the server is reachable, for a clearer error than a dial timeout
+	if err := preflightTailnet(serverHost()); err != nil {
+		exitWith(err)
+	}
+
+	if headless {
+		// Scripts and bots drive the session from stdin instead of the UI
+		if err := runHeadless(clientID, serverIP); err != nil {
+			exitWith(err)
+		}
+		return
+	}
+
+	// Check local files and process settings before connecting
+	findings := selfCheck(sessionFiles())
+	if !skipSelfCheck {
+		printSelfCheck(findings)
+	}
+
+	m := &model{
+		clientID:         clientID,
+		historyIndex:     -1, // Initialize history index
+		peerLastSeen:     make(map[string]time.Time),
+		roster:           make(map[string]*ClientInfo),
+		presenceMuted:    make(map[string]bool),
+		verifiedPeers:    make(map[string]bool),
+		keyWarned:        make(map[string]bool),
+		sendJitter:       map[string]time.Duration{"*": maxSendJitter},
+		operatorOnly:     map[string]bool{"/announce": true, "/export-roster": true, "SLOWMODE": true, "RATELIMIT": true},
+		serverCaps:       make(map[string]bool),
+		polls:            make(map[string]*poll),
+		archived:         make(map[string]bool),
+		historyCursor:    make(map[string]int),
+		tabs:             []string{broadcastTab},
+		activeTab:        broadcastTab,
+		tabUnread:        make(map[string]int),
+		tabOffsets:       make(map[string]int),
+		seenMessages:     make(map[string]time.Time),
+		seenRelays:       make(map[string]time.Time),
+		shownMessages:    make(map[string]time.Time),
+		transfers:        make(map[transferKey]*fileTransfer),
+		transferBar:      newTransferBar(),
+		pins:             make(map[string][]chatEntry),
+		watchKeywords:    make(map[string]bool),
+		filters:          &messageFilters{},
+		buffers:          make(map[string][]chatEntry),
+		bufferUnread:     make(map[string]int),
+		mask:             newContentMask(),
+		ttsConversations: make(map[string]bool),
+		desktopMuted:     make(map[string]bool),
+		selfCheck:        findings,
+	}
+
+	if walPath != "" {
+		if amnesia {
+			fmt.Println("Ignoring -wal in amnesia mode.")
+		} else {
+			m.wal, m.walPending, err = openOutboxLog(walPath)
+			if err != nil {
+				exitWith(fmt.Errorf("Error opening outbox log: %v", err))
+			}
+			for _, queued := range m.walPending {
+				// Keep new outbox IDs clear of the recovered ones
+				m.outboxSeq = max(m.outboxSeq, queued.id)
+			}
+		}
+	}
+
+	m.mux = detectMultiplexer()
+	m.muxWindowName = windowName(m.mux)
+
+	m.notifyLevels, err = loadNotifyLevels()
+	if err != nil {
+		exitWith(fmt.Errorf("Error loading notification levels: %v", err))
+	}
+
+	if amnesia {
+		// Using a pad wipes the used bytes from disk, which amnesia mode never writes to
+		fmt.Println("Ignoring one-time pads in amnesia mode; direct messages use fresh one-time keys.")
+	} else {
+		m.pads, err = loadPads(padDir)
+		if err != nil {
+			exitWith(fmt.Errorf("Error loading pads: %v", err))
+		}
+	}
+
+	if m.identity, err = loadSigningIdentity(clientID); err != nil {
+		exitWith(err)
+	}
+	if m.knownKeys, err = loadKnownKeys(knownKeysPath); err != nil {
+		exitWith(fmt.Errorf("Error loading known keys: %v", err))
+	}
+	for _, peer := range m.knownKeys.verifiedPeers() {
+		m.verifiedPeers[peer] = true
+	}
+	m.syncPeers = parseSyncPeers(syncWith, clientID)
+	if dmKeys, err = newKeyAgreement(m.identity); err != nil {
+		exitWith(err)
+	}
+
+	if historyEnabled {
+		if amnesia {
+			fmt.Println("Ignoring -history in amnesia mode.")
+		} else {
+			m.chatHistory, err = openHistory(historyPath)
+			if err == nil {
+				err = m.restoreHistory()
+			}
+			if err != nil {
+				exitWith(fmt.Errorf("Error opening history: %v", err))
+			}
+		}
+	}
+
+	m.addWatchKeywords(*watch)
+	if *maskWords != "" {
+		if err := m.mask.loadWordlist(*maskWords); err != nil {
+			exitWith(fmt.Errorf("Error loading mask wordlist: %v", err))
+		}
+		m.mask.enabled = true
+	}
+
+	stopProfiling, err := startProfiling()
+	if err != nil {
+		exitWith(err)
+	}
+	closeLog, err := openSessionLog(clientID)
+	if err != nil {
+		exitWith(err)
+	}
+
+	// Initialize the Bubble Tea program with the model
+	var options []tea.ProgramOption
+	if amnesia {
+		// The alternate screen leaves nothing in the terminal's scrollback on exit
+		options = append(options, tea.WithAltScreen())
+	}
+	if desktopNotify {
+		// Desktop notifications are only shown while the terminal is not focused
+		options = append(options, tea.WithReportFocus())
+	}
+	p := tea.NewProgram(m, options...)
+	err = p.Start()
+	stopProfiling()
+	closeLog()
+	m.reportSession()
+	m.restoreWindowTitle()
+	m.archive.close()
+	m.chatHistory.close()
+	if amnesia {
+		m.wipe()
+	}
+	if err != nil {
+		exitWith(fmt.Errorf("Error: %v", err))
+	}
+	if m.exitErr != nil {
+		// The session ended because of an error already shown in the conversation
+		if jsonErrors {
+			exitWith(m.exitErr)
+		}
+		stopTsnet()
+		os.Exit(exitCodeFor(m.exitErr))
+	}
+}
+
+// Init initializes the model and starts the connection to the server
+func (m *model) Init() tea.Cmd {
+	// Initialize the text input component
+	m.input = textinput.New()
+	m.input.Placeholder = "Type a command"
+	m.input.CharLimit = 256
+	m.input.Width = 50
+	m.input.ShowSuggestions = true // Complete commands and peer IDs with Tab
+	m.updateSuggestions()
+	m.updatePrompt() // Set the initial prompt with client ID and operator status
+	m.input.Focus()
+
+	// Initialize the viewport for displaying messages
+	m.viewport = viewport.New(80, 20) // Width and Height of the viewport
+	m.viewport.YPosition = 0
+	m.viewport.HighPerformanceRendering = false      // Set to true if flickering occurs
+	m.viewport.SetContent("Connecting to server...") // Initial content
+
+	return tea.Batch(
+		connectToServer(m.clientID),
+		textinput.Blink, // Start blinking cursor
+	)
+}
+
+// Update handles incoming events and schedules any viewport rebuild they deferred
+func (m *model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	defer reportPanics()
+	if _, ok := msg.(tea.KeyMsg); ok {
+		// A key press means the user is looking at the conversation
+		m.unseen = 0
+	}
+	model, cmd := m.update(msg)
+	if seal := m.startSeals(); seal != nil {
+		cmd = tea.Batch(cmd, seal)
+	}
+	if render := m.scheduleRender(); render != nil {
+		cmd = tea.Batch(cmd, render)
+	}
+	if title := m.syncWindowTitle(); title != nil {
+		cmd = tea.Batch(cmd, title)
+	}
+	if title := m.syncTerminalTitle(); title != nil {
+		cmd = tea.Batch(cmd, title)
+	}
+	if sync := m.scheduleSync(); sync != nil {
+		cmd = tea.Batch(cmd, sync)
+	}
+	// Sections above and below the viewport may have grown or shrunk
+	m.layout()
+	return model, cmd
+}
+
+// update handles incoming events (keyboard input, server messages, etc.)
+func (m *model) update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	var cmd tea.Cmd
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		// A pending confirmation consumes the next key press
+		if m.confirm != nil {
+			return m.updateConfirmation(msg)
+		}
+		// Route key presses to the picker while it is open
+		if m.picker.active {
+			return m.updatePicker(msg)
+		}
+		m.flash = ""
+		// Alt+1 to Alt+9 vote in the most recent poll
+		if msg.Alt && msg.Type == tea.KeyRunes && len(msg.Runes) == 1 && msg.Runes[0] >= '1' && msg.Runes[0] <= '9' {
+			m.vote(int(msg.Runes[0] - '0'))
+			return m, nil
+		}
+		// Keys bound to an action, which -keys may change; see keybindings.go
+		switch keyBindings[msg.String()] {
+		case "quit":
+			// Exit the program
+			m.closeConnection()
+			return m, tea.Quit
+		case "bookmark":
+			// Bookmark the message at the top of the viewport
+			m.bookmarkVisible()
+			return m, nil
+		case "reveal":
+			// Reveal the most recent masked message
+			m.revealLatestMasked()
+			return m, nil
+		case "picker":
+			// Open the fuzzy recipient picker
+			m.openRecipientPicker()
+			return m, nil
+		case "server-buffer":
+			// Toggle the server notices buffer
+			m.toggleBuffer(serverBuffer)
+			return m, nil
+		case "sidebar":
+			// Toggle the user list sidebar
+			return m, m.toggleSidebar()
+		case "security":
+			// Toggle the security dashboard
+			m.togglePanel("security")
+			return m, nil
+		case "prev-tab":
+			// Switch to the previous conversation tab
+			m.cycleTab(-1)
+			return m, nil
+		case "next-tab":
+			// Switch to the next conversation tab
+			m.cycleTab(1)
+			return m, nil
+		case "expand":
+			// Expand the most recent collapsed message
+			m.expandLatestCollapsed()
+			return m, nil
+		case "scroll-up":
+			// Scroll viewport up
+			m.viewport.LineUp(1)
+			if m.viewport.AtTop() {
+				// Page older history in from the archive
+				m.pageOlder()
+			}
+			return m, nil
+		case "scroll-down":
+			// Scroll viewport down
+			m.viewport.LineDown(1)
+			if m.viewport.AtBottom() {
+				m.releasePaged()
+			}
+			return m, nil
+		case "top":
+			// Go to top of the viewport
+			m.viewport.GotoTop()
+			m.pageOlder()
+			return m, nil
+		case "bottom":
+			// Go to bottom of the viewport
+			m.viewport.GotoBottom()
+			m.releasePaged()
+			return m, nil
+		}
+		// Handle key presses for input and history
+		switch msg.Type {
+		case tea.KeyEnter:
+			// Handle command input when Enter is pressed
+			input := strings.TrimSpace(m.input.Value())
+			m.input.SetValue("")
+			return m.handleInput(input)
+		case tea.KeyUp:
+			if m.sidebarFocus {
+				// Select the previous user in the sidebar
+				m.moveSidebarSelection(-1)
+				return m, nil
+			}
+			// Navigate command history backward
+			if len(m.history) > 0 {
+				if m.historyIndex == -1 {
+					m.historyIndex = len(m.history) - 1
+				} else if m.historyIndex > 0 {
+					m.historyIndex--
+				}
+				m.input.SetValue(m.history[m.historyIndex])
+				m.input.CursorEnd()
+			}
+		case tea.KeyDown:
+			if m.sidebarFocus {
+				// Select the next user in the sidebar
+				m.moveSidebarSelection(1)
+				return m, nil
+			}
+			// Navigate command history forward
+			if len(m.history) > 0 && m.historyIndex != -1 {
+				if m.historyIndex < len(m.history)-1 {
+					m.historyIndex++
+					m.input.SetValue(m.history[m.historyIndex])
+				} else {
+					m.historyIndex = -1
+					m.input.SetValue("")
+				}
+				m.input.CursorEnd()
+			}
+		default:
+			// Update text input component
+			m.input, cmd = m.input.Update(msg)
+			// Reset history index when typing a new command
+			if msg.String() != "" && msg.Runes != nil {
+				m.historyIndex = -1
+				m.sidebarFocus = false // Arrows return to the command history
+			}
+		}
+		return m, cmd
+	case tea.WindowSizeMsg:
+		// Fit the layout to the resized terminal
+		m.resize(msg)
+		return m, nil
+	case syncTickMsg:
+		// Send read positions, the draft, and mute settings to the other devices
+		m.flushSync()
+		return m, nil
+	case transferPreparedMsg:
+		// Offer a file opened and hashed for SENDFILE
+		m.offerTransfer(msg)
+		return m, nil
+	case transferTickMsg:
+		// Queue more chunks of outgoing files
+		m.transferScheduled = false
+		return m, m.pumpTransfers()
+	case renderTickMsg:
+		// Perform the viewport rebuild deferred during a message storm
+		m.renderScheduled = false
+		if m.renderPending {
+			m.flushRender()
+		}
+		return m, nil
+	case serverBatchMsg:
+		// Handle a burst of server messages in one update
+		return m.handleBatch(msg)
+	case connectedMsg:
+		// Handle successful connection to the server
+		m.hashedSecret = msg.hashedSecret
+		m.keys = newSessionKey(msg.hashedSecret)
+		m.rekeying = false
+		m.serverFingerprint = msg.fingerprint
+		m.startConnection(msg.conn)
+		m.reconcileOperatorOnConnect(msg.isOperator) // Update the prompt to reflect operator status
+		if m.reconnects > 0 {
+			// The server may deliver messages we have already shown again
+			m.reconnectedAt = time.Now()
+		}
+		if m.expectingRestart() {
+			m.resumeAfterRestart()
+		}
+		if m.reconnecting() {
+			m.resumeAfterReconnect()
+		}
+		sessionLog.Info("connected", "address", address, "fingerprint", msg.fingerprint, "reconnects", m.reconnects)
+		m.appendMessage("Connected to the server. Type your commands below:")
+		if notice := pinNewServer(serverHost(), msg.fingerprint); notice != "" {
+			m.appendMessage(notice)
+		}
+		if m.isOperator {
+			m.appendMessage("You are the server operator. Type HELP to see available commands.")
+		} else {
+			m.appendMessage("Type HELP to see available commands.")
+		}
+		return m, tea.Batch(m.recoverOutbox(), m.startCoverTraffic(), m.waitForServer())
+	case tea.BlurMsg:
+		// The terminal lost focus, so messages go unseen until it is back
+		m.unfocused = true
+		return m, nil
+	case tea.FocusMsg:
+		m.unfocused = false
+		return m, nil
+	case desktopErrorMsg:
+		// Report the failure once instead of on every message
+		m.appendMessage(fmt.Sprintf("Error showing a desktop notification, desktop notifications are off for this session: %v", msg.err))
+		desktopNotify = false
+		return m, nil
+	case ttsErrorMsg:
+		// Report a failed text-to-speech announcement
+		m.appendMessage(fmt.Sprintf("Error running text-to-speech command: %v", msg.err))
+		return m, nil
+	case translationMsg:
+		// Show a finished translation under its message
+		m.applyTranslation(msg)
+		return m, nil
+	case flushOutboxMsg:
+		// Send a queued message once its undo window has passed
+		m.flushOutbox(msg.id)
+		return m, nil
+	case sealedMsg:
+		// Write outgoing messages that finished encrypting, in order
+		m.writeSealed()
+		return m, nil
+	case serverMsg:
+		// Handle general messages from the server, hiding ACKs for our own messages
+		if msg.isResponse && m.sidebarRefreshing {
+			// The sidebar's periodic LIST updates the roster quietly
+			m.sidebarRefreshing = false
+			m.lastServerCommand = ""
+			m.updateRoster(parseClientList(msg.content, time.Now()))
+			return m, m.waitForServer()
+		}
+		if msg.isResponse {
+			switch m.lastServerCommand {
+			case "LIST":
+				m.updateRoster(parseClientList(msg.content, time.Now()))
+			case "WHOIS":
+				if info, ok := parseWhois(msg.content, time.Now()); ok {
+					m.mergeClient(info)
+				}
+			case "LISTBANS":
+				m.bans = parseBanList(msg.content)
+			}
+			m.recordResponse(msg.content)
+		}
+		if !m.reconcileAck(msg.content) {
+			m.appendServerNotice(msg.content)
+		}
+		return m, m.waitForServer()
+	case sidebarTickMsg:
+		// Refresh the user list sidebar
+		return m, m.sidebarRefreshed(msg)
+	case coverTickMsg:
+		// Send the next cover message
+		return m, m.sendCover(msg)
+	case rekeyOfferMsg:
+		// Answer a key exchange started by the server
+		cmd := m.answerRekey(msg)
+		return m, tea.Batch(cmd, m.waitForServer())
+	case rekeyedMsg:
+		// The server switched to the new shared secret
+		m.finishRekey()
+		return m, m.waitForServer()
+	case rekeyTimeoutMsg:
+		m.abandonRekey()
+		return m, nil
+	case writeErrorMsg:
+		// The writer stopped; the reader reports the disconnect
+		m.appendMessage(fmt.Sprintf("Error writing to server: %v", msg.err))
+		m.writer = nil
+		return m, m.waitForServer()
+	case protocolErrorMsg:
+		// Report server lines that broke the protocol
+		m.protocolErrors++
+		m.appendMessage(msg.content)
+		return m, m.waitForServer()
+	case integrityFailureMsg:
+		// Hold messages that failed to decode or decrypt instead of printing the error inline
+		var noKey noAgreedKeyError
+		if errors.As(msg.err, &noKey) {
+			return m, tea.Batch(m.missingAgreedKey(msg, noKey.peer), m.waitForServer())
+		}
+		if errors.Is(msg.err, errIntegrityCheck) {
+			// Say so plainly rather than only flashing, since the message may have been tampered with
+			m.appendMessage(fmt.Sprintf("Message%s not shown: %v. It is held in /quarantine.", describeSender(msg.senderID), msg.err))
+		}
+		m.quarantineMessage(msg)
+		return m, m.waitForServer()
+	case presenceMsg:
+		// Track clients joining and leaving
+		m.applyPresence(msg)
+		return m, m.waitForServer()
+	case motdMsg:
+		// Show the message of the day as a framed block and keep it for /motd
+		m.motd = &msg
+		m.appendMessage(renderMOTD(msg))
+		return m, m.waitForServer()
+	case capabilitiesMsg:
+		// Record the protocol extensions the server supports
+		for _, capability := range msg.capabilities {
+			m.serverCaps[capability] = true
+		}
+		if m.serverCaps["VERSION"] {
+			// Let the server make compatibility decisions for this client
+			m.writeLine(versionAnnouncement())
+		}
+		if m.serverCaps["OPSTATUS"] {
+			// Confirm the operator status granted at registration
+			m.writeLine("OPSTATUS")
+		}
+		if m.serverCaps["TOPIC"] {
+			// Fetch the room topic for the status line
+			m.writeLine("TOPIC")
+		}
+		if m.serverCaps["TIME"] && !m.clockMeasured {
+			// Measure clock skew against the server
+			m.writeLine("TIME")
+		}
+		if m.serverCaps["BASE64"] {
+			// Have the server relay messages to us in base64 too
+			m.writeLine("ENCODING BASE64")
+		}
+		if m.serverCaps["BULK"] && m.bulkConn == nil {
+			// Ask for a second stream so transfers do not hold up chat
+			m.writeLine("BULK")
+		}
+		return m, m.waitForServer()
+	case bulkOfferMsg:
+		// Attach the bulk stream with the token the server issued
+		return m, tea.Batch(attachBulkStream(msg.token), m.waitForServer())
+	case bulkAttachedMsg:
+		if m.writer == nil {
+			// The session ended while attaching
+			msg.conn.Close()
+			return m, nil
+		}
+		return m, m.startBulk(msg.conn)
+	case bulkClosedMsg:
+		m.bulkStreamClosed(msg.err)
+		return m, nil
+	case searchResultsMsg:
+		// Show a page of server-side search results
+		m.applySearchResults(msg)
+		return m, m.waitForServer()
+	case inviteMsg:
+		// Hold the invitation until the user accepts or declines it
+		m.addInvite(msg)
+		return m, m.waitForServer()
+	case topicMsg:
+		// Show the room topic above the input
+		m.applyTopic(msg)
+		return m, m.waitForServer()
+	case slowModeMsg:
+		// Pace messages to ALL to the room's slow mode
+		m.applySlowMode(msg)
+		return m, m.waitForServer()
+	case rateLimitMsg:
+		// Pace our messages to the rate limit the operator set
+		m.applyRateLimit(msg)
+		return m, m.waitForServer()
+	case paceTickMsg:
+		// Refresh the slow mode countdown above the input
+		return m, m.paceTick()
+	case pinnedMsg:
+		// Store a pin shared by the server
+		msg.entry.at = time.Now()
+		m.pins[msg.conversation] = append(m.pins[msg.conversation], msg.entry)
+		return m, m.waitForServer()
+	case operatorMsg:
+		// Handle operator status change
+		m.isOperator = true
+		m.updatePrompt() // Update the prompt since operator status changed
+		m.appendMessage(msg.content)
+		return m, m.waitForServer()
+	case operatorStatusMsg:
+		// Reconcile operator status with the server's answer
+		if msg.rejection {
+			m.recordOperatorOnly(m.lastServerCommand)
+		}
+		m.setOperator(msg.isOperator)
+		return m, m.waitForServer()
+	case incomingMessage:
+		// Handle incoming messages from other clients
+		return m, tea.Batch(m.receiveMessage(msg), m.waitForServer())
+	case kickedMsg:
+		// Handle being kicked by the operator
+		m.appendMessage("You have been kicked from the server by the operator.")
+		m.exitErr = &fatalError{code: exitBanned, err: errors.New("kicked by the operator")}
+		m.closeConnection()
+		return m, tea.Quit
+	case bannedMsg:
+		// Handle being banned by the operator
+		m.appendMessage("You have been banned from the server by the operator.")
+		m.exitErr = transport.ErrBanned
+		m.closeConnection()
+		return m, tea.Quit
+	case disconnectMsg:
+		// Handle disconnection from the server
+		m.appendMessage("Disconnected from server.")
+		m.closeConnection()
+		if m.expectingRestart() {
+			// The server announced it was going away, so reconnect once it is back
+			m.conn = nil
+			return m, m.scheduleRestartReconnect()
+		}
+		return m, m.scheduleReconnect()
+	case serverTimeMsg:
+		// Warn when the local clock disagrees with the server's
+		m.checkClockSkew(msg)
+		return m, m.waitForServer()
+	case shutdownNoticeMsg:
+		// Count down to an announced shutdown or restart
+		return m, tea.Batch(m.applyShutdownNotice(msg), m.waitForServer())
+	case shutdownTickMsg:
+		// Refresh the countdown until we have reconnected
+		if m.expectingRestart() {
+			return m, shutdownTick()
+		}
+		return m, nil
+	case reconnectMsg:
+		// Dial the server again after an announced restart or an unexpected disconnect
+		sessionLog.Warn("reconnecting", "attempt", m.reconnectAttempts)
+		if m.reconnecting() {
+			m.appendMessage(fmt.Sprintf("Reconnecting (attempt %d)...", m.reconnectAttempts))
+		} else {
+			m.appendMessage("Reconnecting to the server...")
+		}
+		return m, connectToServer(m.clientID)
+	case errMsg:
+		// Handle errors
+		m.appendMessage(fmt.Sprintf("Error: %v", msg.error))
+		sessionLog.Error("disconnected", "error", msg.error)
+		m.closeConnection()
+		if m.expectingRestart() && m.conn == nil {
+			// The server is not back yet
+			return m, m.scheduleRestartReconnect()
+		}
+		if code := exitCodeFor(msg.error); m.reconnecting() && m.conn == nil && code != exitBanned && code != exitUntrusted {
+			// Back off and try again
+			return m, m.scheduleReconnect()
+		}
+		m.exitErr = msg.error
+		return m, tea.Quit
+	default:
+		return m, nil
+	}
+}
+
+// View renders the UI
+func (m *model) View() string {
+	conversation := m.viewport.View()
+	if m.sidebar {
+		// Render the user list beside the viewport
+		conversation = lipgloss.JoinHorizontal(lipgloss.Top, conversation, m.sidebarView())
+	}
+	above, below := m.chrome()
+	sections := append(append(above, conversation), below...)
+	return strings.Join(sections, "\n")
+}
+
+// chrome renders the sections above and below the viewport
+func (m *model) chrome() (above, below []string) {
+	if tabs := m.tabBar(); tabs != "" {
+		// Render the conversation tabs above the viewport
+		above = append(above, tabs)
+	}
+	if allowNonTailscale && !isLoopbackHost(serverHost()) {
+		// Keep the warning in view for the whole session
+		above = append(above, warningBannerStyle.Width(m.screenWidth()).Render(nonTailscaleWarning))
+	}
+	if panel := m.panelView(); panel != "" {
+		// Render the open panel between the viewport and the input
+		below = append(below, panel)
+	}
+	if topic := m.topicLine(); topic != "" {
+		// Render the room topic above the input
+		below = append(below, topic)
+	}
+	if limits := m.limitLine(); limits != "" {
+		// Render the active slow mode and rate limit above the input
+		below = append(below, limits)
+	}
+	if invites := m.inviteLine(); invites != "" {
+		// Render pending invitations above the input
+		below = append(below, invites)
+	}
+	if status := m.shutdownStatus(); status != "" {
+		// Render the shutdown countdown above the input
+		below = append(below, status)
+	}
+	// Render a progress bar for each running file transfer above the input
+	below = append(below, m.transferLines()...)
+	if badges := m.badges(); badges != "" {
+		// Render unread badges for buffers above the input
+		below = append(below, badges)
+	}
+	below = append(below, m.input.View()) // Render the input field below
+	if m.confirm != nil {
+		// Render the confirmation prompt below the input
+		below = append(below, m.confirm.prompt)
+	} else if m.picker.active {
+		// Render the recipient picker below the input
+		below = append(below, m.picker.View())
+	} else if hint := validateInput(m.input.Value()); hint != "" {
+		// Render the validation hint below the input
+		below = append(below, hint)
+	} else if m.flash != "" {
+		// Render the status line below the input
+		below = append(below, m.flash)
+	}
+	return above, below
+}
+
+// receiveMessage adds a decrypted message from another client to the conversation
+func (m *model) receiveMessage(msg incomingMessage) tea.Cmd {
+	if isCover(msg.content) {
+		// Drop cover traffic
+		return nil
+	}
+	env := openEnvelope(msg.content)
+	if env.vote != "" {
+		// Votes update the poll's tally instead of being shown
+		choice, _ := strconv.Atoi(env.body)
+		conversation := msg.senderID
+		if msg.isBroadcast {
+			conversation = "ALL"
+		}
+		m.recordVote(env.vote, conversation, msg.senderID, choice)
+		return nil
+	}
+	// Our own messages echoed back by the server confirm delivery
+	if msg.senderID == m.clientID && m.reconcileEcho(env.body) {
+		return nil
+	}
+	if m.duplicateAlert(msg.senderID, env) {
+		// Already shown, directly or relayed by one of our daemons
+		return nil
+	}
+	if m.replayedDuplicate(msg.senderID, msg.isBroadcast, env) {
+		// Delivered again by the server after a reconnect
+		return nil
+	}
+	if env.sync {
+		// State from another of our devices, not a message to show
+		m.applySync(msg, env.body)
+		return nil
+	}
+	if env.transfer != "" {
+		// Part of a file transfer, shown as progress rather than a message
+		return m.receiveTransfer(msg, env)
+	}
+	if env.padSync != "" {
+		// Compare our copy of the pad shared with the sender; the handshake carries nothing to show
+		m.receivePadSync(msg, env)
+		return nil
+	}
+	if env.keyOffer != "" {
+		// Agree a direct message key; handshakes carry nothing else to show
+		if retried := m.acceptKeyOffer(msg, env); env.handshake != "" {
+			return retried
+		}
+	}
+	kind := entryDirect
+	if msg.isBroadcast {
+		kind = entryBroadcast
+	}
+	if conversation := (chatEntry{kind: kind, sender: msg.senderID}).conversation(); m.archived[conversation] {
+		// A new message brings an archived conversation back
+		m.unarchive(conversation)
+	}
+	if env.poll != "" && len(env.options) >= 2 && len(env.options) <= maxPollOptions {
+		// Votes go back to the conversation the poll was asked in
+		conversation := msg.senderID
+		if msg.isBroadcast {
+			conversation = "ALL"
+		}
+		m.addPoll(env.poll, env.body, env.options, conversation)
+	} else {
+		env.poll = ""
+	}
+	m.rememberSender(msg.senderID)
+	entry := chatEntry{kind: kind, sender: msg.senderID, at: time.Now(), info: msg.info}
+	env.annotate(&entry)
+	m.checkSignature(&entry, msg.content)
+	// Only broadcasts from the operator are shown as announcements
+	entry.announcement = entry.announcement && msg.isBroadcast && m.isOperatorPeer(msg.senderID)
+	if m.applyFilter(msg.filter, &entry) {
+		// The message was routed into a filter buffer
+		return nil
+	}
+	if m.collapseRepeat(entry) {
+		// The message repeats the previous one and was counted instead
+		return nil
+	}
+	m.checkWatch(&entry)
+	m.appendEntry(entry)
+	m.received++
+	if m.notifyLevel(entry.conversation()) == "none" {
+		// Muted conversations do not count towards the unread total
+		return nil
+	}
+	m.unseen++
+	return tea.Batch(m.announce(entry), m.notifyMultiplexer(entry), m.notifyDesktop(entry))
+}
+
+// panelView renders the open panel, if any
+func (m *model) panelView() string {
+	switch m.panel {
+	case "pins":
+		return m.pinsView()
+	case "watched":
+		return m.watchedView()
+	case "buffer":
+		return m.bufferView()
+	case "roster":
+		return m.rosterView()
+	case "security":
+		return m.securityView()
+	case "quarantine":
+		return m.quarantineView()
+	case "search":
+		return m.searchView()
+	default:
+		return ""
+	}
+}
+
+// togglePanel opens the named panel, or closes it when it is already open
+func (m *model) togglePanel(name string) {
+	if m.panel == name {
+		m.panel = ""
+	} else {
+		m.panel = name
+	}
+}
+
+// handleInput processes the user input commands
+func (m *model) handleInput(input string) (tea.Model, tea.Cmd) {
+	parts := strings.Fields(input)
+	if len(parts) == 0 {
+		// Reset history index if the input is empty
+		m.historyIndex = -1
+		return m, nil
+	}
+
+	// Add the command to history if it's not empty
+	if input != "" {
+		m.history = append(m.history, input)
+	}
+	m.historyIndex = -1 // Reset history index
+
+	// Run client-side slash commands locally
+	if strings.HasPrefix(parts[0], "/") {
+		return m, m.runClientCommand(parts)
+	}
+
+	switch parts[0] {
+	case "SEND":
+		// Handle the SEND command to send messages
+		if len(parts) < 3 {
+			m.appendMessage("Invalid SEND command. Use: SEND <RecipientID|ALL> <Message>")
+			return m, nil
+		}
+		recipientID := parts[1]
+		messageText := strings.Join(parts[2:], " ")
+		return m, m.queueSend(recipientID, messageText)
+	case "SENDFILE":
+		// Offer a file to a client
+		return m, m.runClientCommand(parts)
+	case "SLOWMODE", "RATELIMIT":
+		// Check operator limits and send them with the interval in seconds
+		m.sendLimitCommand(parts)
+		return m, nil
+	case "HELP":
+		// Display help text
+		m.appendMessage("Available commands:")
+		m.appendMessage("SEND <RecipientID|ALL> <Message> - Send a message")
+		m.appendMessage("HELP - Print this help text")
+		m.appendMessage("EXIT - Exit the program")
+		for _, line := range clientCommandHelp() {
+			m.appendMessage(line)
+		}
+		m.appendMessage("Server commands:")
+		for _, line := range m.serverCommandHelp() {
+			m.appendMessage(line)
+		}
+		return m, nil
+	case "EXIT":
+		// Exit the client program, sending anything still in the outbox first
+		for _, queued := range m.outbox {
+			m.sendQueued(queued)
+		}
+		m.outbox = nil
+		m.sealAll()
+		m.writeLine("EXIT")
+		return m, m.closeAfterWrites()
+	default:
+		// Destructive operator commands need confirmation first
+		if destructiveCommands[parts[0]] {
+			m.requestConfirmation(input, parts)
+			return m, nil
+		}
+		// Pass other commands to the server and show the server buffer for the response
+		m.showBuffer(serverBuffer)
+		m.lastServerCommand = parts[0]
+		m.writeLine(input)
+		return m, nil
+	}
+}
+
+// encodeSend encrypts the message and returns the SEND line to write to the server,
+// along with a description of how it was encrypted
+func (m *model) encodeSend(recipientID, messageText string) (string, cipherInfo, error) {
+	m.startPadSync(recipientID)
+	return m.encodeSendWith(m.pads, recipientID, messageText)
+}
+
+// encodeSendWith is encodeSend with the pads it may use; nil keeps a message off the pad
+func (m *model) encodeSendWith(pads *padStore, recipientID, messageText string) (string, cipherInfo, error) {
+	messageText = m.identity.sign(m.clientID, recipientID, dmKeys.offer(m.clientID, recipientID, withMessageID(messageText)))
+	return encodeSendLineWith(m.payloadEncoder(), m.hashedSecret, pads, recipientID, messageText)
+}
+
+// encodeSendLine encrypts a message for the recipient with the shared secret, the pad shared with
+// the recipient, or a one-time key, and formats the SEND line with hex payloads.
+func encodeSendLine(hashedSecret []byte, pads *padStore, recipientID, messageText string) (string, cipherInfo, error) {
+	return encodeSendLineWith(protocol.EncodeHex, hashedSecret, pads, recipientID, messageText)
+}
+
+// encodeSendLineWith is encodeSendLine with the payload fields encoded by encode
+func encodeSendLineWith(encode protocol.Encoder, hashedSecret []byte, pads *padStore, recipientID, messageText string) (string, cipherInfo, error) {
+	if excluded, ok := parseExcept(recipientID); ok {
+		// Broadcasts with exclusions use the shared secret like any broadcast
+		payload, err := protocol.SealBroadcast(encode, hashedSecret, messageText)
+		if err != nil {
+			return "", cipherInfo{}, err
+		}
+		// Format: SENDEXCEPT <ID,ID...> <encrypted_hex>
+		return fmt.Sprintf("SENDEXCEPT %s %s", strings.Join(excluded, ","), payload), sharedKeyInfo(hashedSecret), nil
+	}
+	if recipientID == "ALL" {
+		// Encrypt the message using AES with the shared secret
+		payload, err := protocol.SealBroadcast(encode, hashedSecret, messageText)
+		if err != nil {
+			return "", cipherInfo{}, err
+		}
+		return fmt.Sprintf("SEND ALL %s", payload), sharedKeyInfo(hashedSecret), nil
+	}
+
+	if pads.has(recipientID) {
+		// Encrypt with unused bytes of the pad shared with the recipient
+		ref, ciphertext, mac, info, err := pads.encrypt(recipientID, []byte(messageText))
+		if err != nil {
+			return "", cipherInfo{}, err
+		}
+		// Format: SEND <ID> pad:<pad_id>:<offset>|<ciphertext_hex>|<mac_hex>
+		return fmt.Sprintf("SEND %s %s|%s|%s", recipientID, ref, encode(ciphertext), encode(mac)), info, nil
+	}
+
+	if agreed := dmKeys.agreed(recipientID); agreed != nil {
+		// Encrypt with the key agreed with the recipient, so no key travels with the message
+		ref, ciphertext, mac, info, err := encryptAgreed(agreed, []byte(messageText))
+		if err != nil {
+			return "", cipherInfo{}, err
+		}
+		// Format: SEND <ID> dh:<key_id>|<ciphertext_hex>|<mac_hex>
+		return fmt.Sprintf("SEND %s %s|%s|%s", recipientID, ref, encode(ciphertext), encode(mac)), info, nil
+	}
+	return encodeOneTimeKey(encode, recipientID, messageText)
+}
+
+// encodeOneTimeKey encrypts a direct message with a fresh XOR key sent along with it
+func encodeOneTimeKey(encode protocol.Encoder, recipientID, messageText string) (string, cipherInfo, error) {
+	// Format: SEND <RecipientID> <key_hex>|<ciphertext_hex>
+	payload, key, err := protocol.SealOneTimeKey(encode, messageText)
+	if err != nil {
+		return "", cipherInfo{}, err
+	}
+	return fmt.Sprintf("SEND %s %s", recipientID, payload), oneTimeKeyInfo(key), nil
+}
+
+// updatePrompt updates the prompt with the client ID and operator status
+func (m *model) updatePrompt() {
+	if m.isOperator {
+		m.input.Prompt = fmt.Sprintf("%s (op) > ", m.clientID)
+	} else {
+		m.input.Prompt = fmt.Sprintf("%s > ", m.clientID)
+	}
+}
+
+// connectToServer establishes the connection and performs client setup
+func connectToServer(clientID string) tea.Cmd {
+	return func() tea.Msg {
+		host := serverHost()
+		conn, err := dialTCP(address, 0)
+		if err != nil {
+			return errMsg{explainDialError(host, serverPort, err)}
+		}
+		c, err := transport.Handshake(conn, clientID, maxLineLength, func(pubKeyHex string) (string, error) {
+			// Refuse a server whose key differs from the pinned one before answering it
+			return verifyServerKey(host, pubKeyHex)
+		})
+		if err != nil {
+			conn.Close()
+			return errMsg{authFailure(err)}
+		}
+		return connectedMsg{conn: conn, hashedSecret: c.Secret, isOperator: c.Operator, fingerprint: c.Fingerprint}
+	}
+}
+
+// waitForServerMessage waits for a message from the server
+func waitForServerMessage(messageChan <-chan tea.Msg) tea.Cmd {
+	return func() tea.Msg {
+		msg := <-messageChan
+		// Drain whatever else has already arrived so bursts are handled in one update
+		batch := serverBatchMsg{msg}
+		for len(batch) < maxMessageBatch {
+			select {
+			case next := <-messageChan:
+				batch = append(batch, next)
+			default:
+				if len(batch) == 1 {
+					return msg
+				}
+				return batch
+			}
+		}
+		return batch
+	}
+}
+
+// waitForServer returns the command reading the next server message. While a batch is being
+// handled it only notes that reading should continue once the batch is done.
+func (m *model) waitForServer() tea.Cmd {
+	if m.draining {
+		m.resumeReading = true
+		return nil
+	}
+	return waitForServerMessage(m.messageChan)
+}
+
+// handleBatch runs every message of a batch through Update and then resumes reading once
+func (m *model) handleBatch(batch serverBatchMsg) (tea.Model, tea.Cmd) {
+	m.draining, m.resumeReading = true, false
+	cmds := make([]tea.Cmd, 0, len(batch)+1)
+	for _, msg := range batch {
+		_, cmd := m.Update(msg)
+		cmds = append(cmds, cmd)
+	}
+	m.draining = false
+	if m.resumeReading {
+		cmds = append(cmds, waitForServerMessage(m.messageChan))
+	}
+	return m, tea.Batch(cmds...)
+}
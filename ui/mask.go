@@ -0,0 +1,132 @@
+// mask.go
+// Package ui masks words from a user-configurable wordlist when rendering messages.
+
+package ui
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// contentMask replaces listed words with asterisks at render time
+type contentMask struct {
+	enabled bool            // Whether masking is applied
+	words   map[string]bool // Lower-cased words to mask
+	pattern *regexp.Regexp  // Compiled whole-word pattern for the words
+}
+
+func init() {
+	registerCommand("/mask", commandSpec{
+		usage:   "/mask on|off|list|add <word>|remove <word>",
+		help:    "Configure masking of words from the wordlist (Alt+R reveals a message)",
+		minArgs: 1,
+		run: func(m *model, args []string) tea.Cmd {
+			switch {
+			case args[0] == "on":
+				m.mask.enabled = true
+				m.appendMessage("Content masking enabled.")
+			case args[0] == "off":
+				m.mask.enabled = false
+				m.appendMessage("Content masking disabled.")
+			case args[0] == "list":
+				m.appendMessage(fmt.Sprintf("Masked words (%d): %s", len(m.mask.words), strings.Join(m.mask.list(), ", ")))
+			case args[0] == "add" && len(args) == 2:
+				m.mask.add(args[1])
+				m.appendMessage(fmt.Sprintf("Masking %q.", args[1]))
+			case args[0] == "remove" && len(args) == 2:
+				delete(m.mask.words, strings.ToLower(args[1]))
+				m.mask.compile()
+				m.appendMessage(fmt.Sprintf("No longer masking %q.", args[1]))
+			default:
+				m.appendMessage("Usage: " + knownCommands["/mask"].usage)
+				return nil
+			}
+			m.refreshViewport()
+			return nil
+		},
+	})
+}
+
+// newContentMask returns an empty, disabled mask
+func newContentMask() *contentMask {
+	return &contentMask{words: make(map[string]bool)}
+}
+
+// loadWordlist reads one word per line from path, ignoring blank lines and # comments
+func (c *contentMask) loadWordlist(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		word := strings.TrimSpace(scanner.Text())
+		if word == "" || strings.HasPrefix(word, "#") {
+			continue
+		}
+		c.words[strings.ToLower(word)] = true
+	}
+	c.compile()
+	return scanner.Err()
+}
+
+// add masks another word
+func (c *contentMask) add(word string) {
+	c.words[strings.ToLower(word)] = true
+	c.compile()
+}
+
+// list returns the masked words in sorted order
+func (c *contentMask) list() []string {
+	words := make([]string, 0, len(c.words))
+	for word := range c.words {
+		words = append(words, word)
+	}
+	sort.Strings(words)
+	return words
+}
+
+// compile rebuilds the whole-word pattern from the wordlist
+func (c *contentMask) compile() {
+	if len(c.words) == 0 {
+		c.pattern = nil
+		return
+	}
+	quoted := make([]string, 0, len(c.words))
+	for _, word := range c.list() {
+		quoted = append(quoted, regexp.QuoteMeta(word))
+	}
+	c.pattern = regexp.MustCompile(`(?i)\b(` + strings.Join(quoted, "|") + `)\b`)
+}
+
+// apply masks the listed words in text
+func (c *contentMask) apply(text string) (string, bool) {
+	if !c.enabled || c.pattern == nil || !c.pattern.MatchString(text) {
+		return text, false
+	}
+	return c.pattern.ReplaceAllStringFunc(text, func(word string) string {
+		return strings.Repeat("*", len([]rune(word)))
+	}), true
+}
+
+// revealLatestMasked shows the most recent masked message unmasked
+func (m *model) revealLatestMasked() {
+	for i := len(m.entries) - 1; i >= 0; i-- {
+		entry := &m.entries[i]
+		if entry.revealed || entry.kind == entrySystem {
+			continue
+		}
+		if _, masked := m.mask.apply(entry.content); masked {
+			entry.revealed = true
+			m.refreshViewport()
+			return
+		}
+	}
+}
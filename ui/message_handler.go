@@ -0,0 +1,355 @@
+// message_handler.go
+// Package ui handles reading and processing messages from the server.
+
+package ui
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/drewwalton19216801/padclient/crypto"
+	"github.com/drewwalton19216801/padclient/protocol"
+)
+
+// errIntegrityCheck reports a direct message that was altered or corrupted on the way: its HMAC does
+// not match the ciphertext, or, for a one-time key message, its signature does not match the text.
+// Such messages are not shown.
+var errIntegrityCheck = errors.New("integrity check failed")
+
+// readMessages continuously reads messages from the server and processes them until the
+// connection fails or ctx is cancelled.
+func readMessages(ctx context.Context, conn net.Conn, clientID string, keys *sessionKey, pads *padStore, filters *messageFilters, messageChan chan<- tea.Msg) {
+	defer reportPanics()
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	// Unblock the pending read once the connection is cancelled
+	go func() {
+		<-ctx.Done()
+		conn.Close()
+	}()
+	// send hands a message to the UI, in order, unless the connection was cancelled meanwhile
+	delivery := newOrderedDelivery(ctx, messageChan)
+	defer delivery.close()
+	send := delivery.send
+
+	reader := protocol.NewLineReader(conn, maxLineLength)
+	defer reader.Release()
+	var inMultiLineResponse bool = false
+	var multiLineBuffer []string
+	var inPublicKey bool // Whether we are reading a public key sent to rotate the shared secret
+	var pubKeyHex string
+	var searchPage *searchResultsMsg // Page of search results being read, if any
+	atConnect := true                // Whether nothing but the connect-time banner has arrived yet
+
+	for {
+		message, err := reader.Next()
+		var tooLong protocol.ErrLineTooLong
+		if errors.As(err, &tooLong) {
+			// Drop the oversized line and keep reading
+			send(protocolErrorMsg{content: fmt.Sprintf("Protocol error: dropped a server line (%v).", err)})
+			continue
+		}
+		if err != nil {
+			if ctx.Err() == nil {
+				// The connection failed rather than being closed by us
+				send(disconnectMsg{})
+			}
+			return
+		}
+		if message == "" {
+			continue
+		}
+
+		// Handle being registered as operator
+		if message == "REGISTERED as operator" {
+			send(operatorMsg{content: "You are registered as the server operator."})
+			continue
+		}
+
+		// Handle being kicked
+		if message == "KICKED You have been kicked by the operator" {
+			send(kickedMsg{})
+			return
+		}
+
+		// Handle being banned
+		if message == "BANNED You have been banned by the operator" {
+			send(bannedMsg{})
+			return
+		}
+
+		// Detect the start of a multi-line response
+		if message == "BEGIN_RESPONSE" {
+			inMultiLineResponse = true
+			multiLineBuffer = []string{}
+			continue // Skip printing the marker
+		}
+
+		// Detect the end of a multi-line response
+		if message == "END_RESPONSE" {
+			inMultiLineResponse = false
+			if title, body, ok := parseMOTD(multiLineBuffer, atConnect); ok {
+				// The connect-time banner or an explicit MOTD response
+				send(motdMsg{title: title, lines: body})
+			} else {
+				send(serverMsg{content: strings.Join(multiLineBuffer, "\n"), isResponse: true})
+			}
+			atConnect = false
+			continue // Skip printing the marker
+		}
+
+		if inMultiLineResponse {
+			multiLineBuffer = append(multiLineBuffer, message)
+			continue
+		}
+		atConnect = false
+
+		// Handle the server's public key for a key rotation
+		if message == "PUBLICKEY" {
+			inPublicKey = true
+			pubKeyHex = ""
+			continue
+		}
+		if inPublicKey {
+			if message == "END PUBLICKEY" {
+				inPublicKey = false
+				send(rekeyOfferMsg{pubKeyHex: pubKeyHex})
+			} else {
+				pubKeyHex = message
+			}
+			continue
+		}
+
+		// Handle the server confirming it switched to the new shared secret
+		if message == "CLIENTPUBKEY_RECEIVED" {
+			if keys.commit() {
+				send(rekeyedMsg{})
+			}
+			continue
+		}
+
+		hashedSecret := keys.get()
+
+		// Handle a page of search results from servers supporting the SEARCH extension
+		if header, ok := parseSearchHeader(message); ok {
+			searchPage = &header
+			continue
+		}
+		if searchPage != nil {
+			if message == "END SEARCHRESULTS" {
+				send(*searchPage)
+				searchPage = nil
+			} else if entry, err := parseSearchResult(message, hashedSecret); err != nil {
+				searchPage.failed++
+			} else {
+				searchPage.results = append(searchPage.results, entry)
+			}
+			continue
+		}
+
+		// Handle incoming messages from other clients first, since they make up most of the traffic
+		if protocol.IsMessage(message) {
+			relayed, ok := protocol.ParseMessage(message)
+			if !ok {
+				send(serverMsg{content: "Invalid message format. Ignoring."})
+				continue
+			}
+			// Decrypt on the crypto pool so a large payload does not stall reading
+			delivery.decrypt(func() tea.Msg {
+				msg, err := decodeMessage(relayed.Source(), relayed.Sender, clientID, relayed.Payload, hashedSecret, pads)
+				if err != nil {
+					// Keep the undecryptable message so it can be retried later
+					return integrityFailureMsg{source: relayed.Source(), senderID: relayed.Sender, payload: relayed.Payload, err: err}
+				}
+				var delivered tea.Msg
+				filters.deliver(func(msg tea.Msg) { delivered = msg }, msg)
+				return delivered
+			})
+			continue
+		}
+
+		// Handle the token for attaching a bulk stream: BULK <token>
+		if offer, ok := parseBulkOffer(message); ok {
+			send(offer)
+			continue
+		}
+
+		// Handle the server advertising protocol extensions
+		if strings.HasPrefix(message, "CAPABILITIES ") {
+			send(capabilitiesMsg{capabilities: strings.Fields(strings.TrimPrefix(message, "CAPABILITIES "))})
+			continue
+		}
+
+		// Handle pins shared by servers supporting the PIN extension: PINNED ALL <encrypted_hex>
+		if strings.HasPrefix(message, "PINNED ALL ") {
+			payload := strings.TrimPrefix(message, "PINNED ALL ")
+			content, err := decodePinned(payload, hashedSecret)
+			if err != nil {
+				send(integrityFailureMsg{source: "PINNED", payload: payload, err: err})
+				continue
+			}
+			send(pinnedMsg{conversation: "ALL", entry: chatEntry{kind: entrySystem, content: content}})
+			continue
+		}
+
+		// Handle the server telling us our operator status
+		if status, ok := parseOperatorStatus(message); ok {
+			send(status)
+			// Operator-only rejections are also shown as server notices
+			if strings.HasPrefix(message, "OPERATOR ") || message == "REGISTERED" {
+				continue
+			}
+		}
+
+		// Handle the server reporting its time
+		if serverTime, ok := parseServerTime(message, time.Now()); ok {
+			send(serverTime)
+			continue
+		}
+
+		// Handle announced shutdowns and restarts
+		if notice, ok := parseShutdownNotice(message); ok {
+			send(notice)
+			continue
+		}
+
+		// Handle invitations to invite-only channels
+		if invite, ok := parseInvite(message); ok {
+			send(invite)
+			continue
+		}
+
+		// Handle the server announcing the room topic
+		if topic, ok := parseTopic(message); ok {
+			send(topic)
+			continue
+		}
+
+		// Handle the room's slow mode and our rate limit
+		if slowMode, ok := parseSlowMode(message); ok {
+			send(slowMode)
+			continue
+		}
+		if limit, ok := parseRateLimit(message); ok {
+			send(limit)
+			continue
+		}
+
+		// Handle presence pushes for clients joining or leaving
+		if presence, ok := parsePresence(message); ok {
+			send(presence)
+			continue
+		}
+
+		// Handle other server messages
+		send(serverMsg{content: message})
+	}
+}
+
+// decodeMessage decrypts the payload of a MESSAGE or BROADCAST line from senderID to clientID
+func decodeMessage(source, senderID, clientID, encryptedData string, hashedSecret []byte, pads *padStore) (incomingMessage, error) {
+	isBroadcast := source == "BROADCAST"
+	if isBroadcast && !strings.Contains(encryptedData, "|") {
+		// Decrypt broadcast message using AES
+		plaintext, err := protocol.OpenBroadcast(hashedSecret, encryptedData)
+		if err != nil {
+			return incomingMessage{}, err
+		}
+		return incomingMessage{
+			senderID:    senderID,
+			content:     string(plaintext),
+			isBroadcast: true,
+			info:        sharedKeyInfo(hashedSecret),
+		}, nil
+	}
+
+	// Encrypted data format: key_hex|ciphertext_hex[|mac_hex], with any field possibly in base64.
+	// Messages encrypted with pad bytes or an agreed key carry a MAC keyed from that secret.
+	keyHex, ciphertextHex, ok := strings.Cut(encryptedData, "|")
+	if !ok {
+		return incomingMessage{}, fmt.Errorf("invalid message format")
+	}
+
+	if strings.HasPrefix(keyHex, padRefPrefix) {
+		// Encrypted with the pad shared with the sender
+		ciphertext, mac, err := decodeAuthenticated(ciphertextHex)
+		if err != nil {
+			return incomingMessage{}, err
+		}
+		plaintext, info, err := pads.decrypt(senderID, keyHex, ciphertext, mac)
+		if err != nil {
+			return incomingMessage{}, err
+		}
+		return incomingMessage{senderID: senderID, content: string(plaintext), isBroadcast: isBroadcast, info: info}, nil
+	}
+
+	if strings.HasPrefix(keyHex, dhRefPrefix) {
+		// Encrypted with the key agreed with the sender
+		ciphertext, mac, err := decodeAuthenticated(ciphertextHex)
+		if err != nil {
+			return incomingMessage{}, err
+		}
+		plaintext, info, err := decryptAgreed(senderID, keyHex, ciphertext, mac)
+		if err != nil {
+			return incomingMessage{}, err
+		}
+		return incomingMessage{senderID: senderID, content: string(plaintext), isBroadcast: isBroadcast, info: info}, nil
+	}
+
+	// Decrypt the message using XOR cipher
+	plaintext, key, err := protocol.OpenOneTimeKey(keyHex, ciphertextHex)
+	if err != nil {
+		return incomingMessage{}, err
+	}
+	// The key travels with the message, so anyone on the path could recompute a MAC; the sender's
+	// signature, which they cannot forge, is what shows the text was not changed
+	recipient := clientID
+	if isBroadcast {
+		recipient = "ALL"
+	}
+	if _, status := verifySignature(senderID, recipient, string(plaintext)); status == signatureInvalid {
+		return incomingMessage{}, fmt.Errorf("%w: the signature does not match the message, which was altered on the way or is not from %s", errIntegrityCheck, senderID)
+	}
+	return incomingMessage{
+		senderID:    senderID,
+		content:     string(plaintext),
+		isBroadcast: isBroadcast,
+		info:        oneTimeKeyInfo(key),
+	}, nil
+}
+
+// decodeAuthenticated decodes the ciphertext and MAC fields of a message encrypted with pad bytes
+// or an agreed key. Both formats always carry a MAC, so one without is rejected.
+func decodeAuthenticated(fields string) ([]byte, []byte, error) {
+	ciphertextHex, macHex, ok := strings.Cut(fields, "|")
+	if !ok {
+		return nil, nil, fmt.Errorf("%w: the message has no MAC, so it may have been altered", errIntegrityCheck)
+	}
+	ciphertext, err := protocol.DecodeField(ciphertextHex)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error decoding ciphertext: %v", err)
+	}
+	mac, err := protocol.DecodeField(macHex)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error decoding MAC: %v", err)
+	}
+	return ciphertext, mac, nil
+}
+
+// decodePinned decrypts the payload of a PINNED ALL line
+func decodePinned(encryptedData string, hashedSecret []byte) (string, error) {
+	ciphertext, err := protocol.DecodeField(encryptedData)
+	if err != nil {
+		return "", fmt.Errorf("error decoding pinned message: %v", err)
+	}
+	plaintext, err := crypto.DecryptAES(hashedSecret, ciphertext)
+	if err != nil {
+		return "", fmt.Errorf("error decrypting pinned message: %v", err)
+	}
+	return string(plaintext), nil
+}
@@ -0,0 +1,67 @@
+// motd.go
+// Package ui parses the server's connect-time banner (MOTD) and renders it as a framed block.
+
+package ui
+
+import (
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// motdMsg carries the server's message of the day
+type motdMsg struct {
+	title string
+	lines []string
+}
+
+var (
+	// motdFrameStyle frames the message of the day
+	motdFrameStyle = lipgloss.NewStyle().Border(lipgloss.RoundedBorder()).Padding(0, 1)
+	// motdTitleStyle renders the title line of the message of the day
+	motdTitleStyle = lipgloss.NewStyle().Bold(true).Underline(true)
+)
+
+func init() {
+	registerCommand("/motd", commandSpec{
+		usage: "/motd",
+		help:  "Show the server's message of the day again",
+		run: func(m *model, args []string) tea.Cmd {
+			if m.motd == nil {
+				m.appendMessage("The server has not sent a message of the day.")
+				return nil
+			}
+			m.appendMessage(renderMOTD(*m.motd))
+			return nil
+		},
+	})
+}
+
+// parseMOTD recognizes a multi-line response as the message of the day: either a response whose
+// first line is a "MOTD [title]" header, or the first response sent right after connecting.
+func parseMOTD(lines []string, atConnect bool) (string, []string, bool) {
+	if len(lines) == 0 {
+		return "", nil, false
+	}
+	if first := lines[0]; first == "MOTD" || strings.HasPrefix(first, "MOTD ") {
+		title := strings.TrimSpace(strings.TrimPrefix(first, "MOTD"))
+		if title == "" {
+			title = "Message of the day"
+		}
+		return title, lines[1:], true
+	}
+	if atConnect {
+		return "Message of the day", lines, true
+	}
+	return "", nil, false
+}
+
+// renderMOTD renders the message of the day as a framed block with a title
+func renderMOTD(motd motdMsg) string {
+	body := motdTitleStyle.Render(motd.title)
+	if len(motd.lines) > 0 {
+		body += "\n" + strings.Join(motd.lines, "\n")
+	}
+	return motdFrameStyle.Render(body)
+}
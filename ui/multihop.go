@@ -0,0 +1,188 @@
+// multihop.go
+// Package ui implements the relay subcommand, which connects to two servers and carries messages
+// from allowlisted senders between them, bridging pad networks on different tailnets.
+
+package ui
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/drewwalton19216801/padclient/transport"
+)
+
+// maxRelayHops is how many relays a message may pass through, so relays cannot loop messages forever
+const maxRelayHops = 3
+
+// hopSide is one of the two servers a relay is connected to
+type hopSide struct {
+	label        string          // Short name used in forwarding annotations and the log, e.g. "a"
+	server       string          // Server address
+	conn         net.Conn        // Connection to the server
+	hashedSecret []byte          // Shared secret for this server
+	allow        map[string]bool // Senders on this server whose messages are relayed
+	messages     chan tea.Msg    // Messages read from the server
+	done         chan struct{}   // Closed when the reader stops
+}
+
+// runRelay connects to two servers and relays between them until interrupted or disconnected:
+// padclient relay -a <host> -allow-a <IDs> -b <host> -allow-b <IDs> [-id <YourID>]
+func runRelay(args []string) error {
+	flags := flag.NewFlagSet("relay", flag.ContinueOnError)
+	serverA := flags.String("a", "", "first server to connect to")
+	serverB := flags.String("b", "", "second server to connect to")
+	allowA := flags.String("allow-a", "", "comma-separated IDs on the first server whose messages are relayed")
+	allowB := flags.String("allow-b", "", "comma-separated IDs on the second server whose messages are relayed")
+	flags.Var(portValue{}, "port", "TCP port both servers listen on (default 12345, or as found through DNS)")
+	hostname, _ := os.Hostname()
+	clientID := flags.String("id", "relay-"+hostname, "client ID to register as on both servers")
+	timeout := flags.Duration("timeout", 30*time.Second, "how long to wait for each connection and key exchange")
+	flags.StringVar(&knownKeysPath, "known-keys", knownKeysPath, "file of identity keys pinned for peers the first time each signs a message")
+	flags.BoolVar(&jsonErrors, "json-errors", true, "write errors to stderr as JSON objects")
+	flags.BoolVar(&allowNonTailscale, "allow-non-tailscale", false, "connect without Tailscale; traffic is only protected by the client's own encryption")
+	addTsnetFlags(flags)
+	addAllowlistFlags(flags)
+	if err := readSubcommandConfig(flags, args); err != nil {
+		return err
+	}
+	if err := flags.Parse(args); err != nil {
+		return &fatalError{code: exitUsage, err: err}
+	}
+	usage := errors.New("usage: padclient relay -a <host> -allow-a <IDs> -b <host> -allow-b <IDs> [-id <YourID>]")
+	if *serverA == "" || *serverB == "" || flags.NArg() > 0 {
+		return &fatalError{code: exitUsage, err: usage}
+	}
+	a := &hopSide{label: "a", server: *serverA, allow: parseAllowlist(*allowA)}
+	b := &hopSide{label: "b", server: *serverB, allow: parseAllowlist(*allowB)}
+	if len(a.allow) == 0 && len(b.allow) == 0 {
+		// Relaying everyone's messages must be asked for explicitly, one ID at a time
+		return &fatalError{code: exitUsage, err: errors.New("list the IDs to relay with -allow-a and -allow-b")}
+	}
+	if err := checkServersAllowed(*serverA, *serverB); err != nil {
+		return err
+	}
+	if err := requireTailscale(*serverA, *serverB); err != nil {
+		return err
+	}
+	signatures, err := newSignatureChecker()
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	for _, side := range []*hopSide{a, b} {
+		conn, hashedSecret, err := dialServer(side.server, *clientID, *timeout)
+		if err != nil {
+			return fmt.Errorf("%s: %w", side.server, err)
+		}
+		conn.SetDeadline(time.Time{})
+		defer conn.Close()
+		side.conn, side.hashedSecret = conn, hashedSecret
+		side.messages = make(chan tea.Msg, messageBuffer)
+		side.done = make(chan struct{})
+		go func(side *hopSide) {
+			readMessages(ctx, side.conn, *clientID, newSessionKey(side.hashedSecret), nil, nil, side.messages)
+			close(side.done)
+		}(side)
+	}
+	fmt.Printf("Relaying between %s and %s as %s.\n", a.server, b.server, *clientID)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-a.done:
+			return &fatalError{code: exitConnect, err: fmt.Errorf("disconnected from %s", a.server)}
+		case <-b.done:
+			return &fatalError{code: exitConnect, err: fmt.Errorf("disconnected from %s", b.server)}
+		case msg := <-a.messages:
+			if err := relayHop(a, b, *clientID, signatures, msg); err != nil {
+				return err
+			}
+		case msg := <-b.messages:
+			if err := relayHop(b, a, *clientID, signatures, msg); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// parseAllowlist parses a comma-separated list of IDs
+func parseAllowlist(list string) map[string]bool {
+	allow := make(map[string]bool)
+	for _, id := range strings.Split(list, ",") {
+		if id = strings.TrimSpace(id); id != "" {
+			allow[id] = true
+		}
+	}
+	return allow
+}
+
+// relayHop carries a message read from one server to the other. Broadcasts are broadcast on the
+// other server; direct messages to the relay of the form "@<ID> <text>" go to that ID there.
+// Allowlisted IDs are only trusted as far as their signatures go: a message that may be spoofed is
+// not relayed. Keys are pinned per server, as the same ID on two servers may be two people.
+func relayHop(from, to *hopSide, clientID string, signatures *signatureChecker, msg tea.Msg) error {
+	switch msg := msg.(type) {
+	case kickedMsg:
+		return &fatalError{code: exitBanned, err: fmt.Errorf("kicked from %s", from.server)}
+	case bannedMsg:
+		return fmt.Errorf("%w from %s", transport.ErrBanned, from.server)
+	case integrityFailureMsg:
+		fmt.Fprintf(os.Stderr, "Dropped a message from %s on %s that could not be decrypted: %v\n", msg.senderID, from.server, msg.err)
+		return nil
+	case incomingMessage:
+		if msg.senderID == clientID || !from.allow[msg.senderID] {
+			return nil
+		}
+		env := openEnvelope(msg.content)
+		if isControlEnvelope(msg.content, env) || env.poll != "" {
+			// Polls, device sync, handshakes, and file transfers are tied to one server's conversations
+			return nil
+		}
+		status, notice := signatures.check(msg.senderID+"@"+from.server, clientID, msg)
+		if notice != "" {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", from.server, notice)
+		}
+		if status.suspect() {
+			fmt.Fprintf(os.Stderr, "Not relaying a message from %s on %s: its signature is %s\n", msg.senderID, from.server, status.name())
+			return nil
+		}
+		if env.hops >= maxRelayHops {
+			fmt.Fprintf(os.Stderr, "Not relaying a message from %s on %s: it has already passed through %d relays\n", msg.senderID, from.server, env.hops)
+			return nil
+		}
+		recipient := "ALL"
+		if !msg.isBroadcast {
+			target, body, ok := strings.Cut(env.body, " ")
+			if !ok || !strings.HasPrefix(target, "@") || len(target) < 2 {
+				return nil
+			}
+			recipient, env.body = target[1:], body
+		}
+		forwarded := env.forwardedFrom
+		if forwarded == "" {
+			forwarded = msg.senderID + "@" + from.server
+		}
+		relayed := envelope{body: env.body, forwardedFrom: forwarded, thread: env.thread, hops: env.hops + 1}
+		line, _, err := encodeSendLine(to.hashedSecret, nil, recipient, relayed.seal())
+		if err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintf(to.conn, "%s\n", line); err != nil {
+			return err
+		}
+		fmt.Printf("[%s] %s %s -> %s %s\n", time.Now().Format("15:04:05"), from.label, msg.senderID, to.label, recipient)
+	}
+	return nil
+}
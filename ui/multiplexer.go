@@ -0,0 +1,166 @@
+// multiplexer.go
+// Package ui integrates with tmux and GNU screen: unread counts in the window title,
+// notifications in the status line, and clipboard passthrough.
+
+package ui
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/aymanbagabas/go-osc52/v2"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// multiplexer is the terminal multiplexer the client runs in
+type multiplexer int
+
+const (
+	muxNone multiplexer = iota
+	muxTmux
+	muxScreen
+)
+
+// muxDisabled turns the multiplexer integration off
+var muxDisabled bool
+
+func init() {
+	registerCommand("/copy", commandSpec{
+		usage:   "/copy <n>",
+		help:    "Copy the nth most recent message to the clipboard",
+		minArgs: 1,
+		run: func(m *model, args []string) tea.Cmd {
+			entry, err := m.messageByNumber(args[0])
+			if err != nil {
+				m.appendMessage(err.Error())
+				return nil
+			}
+			m.flash = "Copied to the clipboard."
+			return copyToClipboard(entry.content, m.mux)
+		},
+	})
+}
+
+// detectMultiplexer reports which multiplexer, if any, the client runs in
+func detectMultiplexer() multiplexer {
+	switch {
+	case muxDisabled:
+		return muxNone
+	case os.Getenv("TMUX") != "":
+		return muxTmux
+	case os.Getenv("STY") != "":
+		return muxScreen
+	default:
+		return muxNone
+	}
+}
+
+// muxCommand runs a multiplexer command in the background, ignoring failures
+func muxCommand(name string, args ...string) tea.Cmd {
+	return func() tea.Msg {
+		exec.Command(name, args...).Run()
+		return nil
+	}
+}
+
+// windowName returns the current tmux window name so it can be restored on exit
+func windowName(mux multiplexer) string {
+	if mux != muxTmux {
+		return ""
+	}
+	out, err := exec.Command("tmux", "display-message", "-p", "-t", os.Getenv("TMUX_PANE"), "#W").Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// setWindowTitle names the multiplexer window
+func setWindowTitle(mux multiplexer, title string) tea.Cmd {
+	switch mux {
+	case muxTmux:
+		return muxCommand("tmux", "rename-window", "-t", os.Getenv("TMUX_PANE"), title)
+	case muxScreen:
+		return muxCommand("screen", "-X", "title", title)
+	default:
+		return nil
+	}
+}
+
+// restoreWindowTitle puts back the window name the client started with
+func (m *model) restoreWindowTitle() {
+	switch {
+	case m.mux == muxTmux && m.muxWindowName != "":
+		exec.Command("tmux", "rename-window", "-t", os.Getenv("TMUX_PANE"), m.muxWindowName).Run()
+	case m.mux == muxScreen:
+		exec.Command("screen", "-X", "title", "").Run()
+	}
+}
+
+// muxNotify shows a notice in the multiplexer's status line
+func muxNotify(mux multiplexer, text string) tea.Cmd {
+	switch mux {
+	case muxTmux:
+		return muxCommand("tmux", "display-message", "-t", os.Getenv("TMUX_PANE"), text)
+	case muxScreen:
+		return muxCommand("screen", "-X", "echo", text)
+	default:
+		return nil
+	}
+}
+
+// copyToClipboard sets the terminal clipboard with an OSC 52 sequence, wrapped so the
+// multiplexer passes it through to the outer terminal
+func copyToClipboard(text string, mux multiplexer) tea.Cmd {
+	return func() tea.Msg {
+		seq := osc52.New(text)
+		switch mux {
+		case muxTmux:
+			seq = seq.Tmux()
+		case muxScreen:
+			seq = seq.Screen()
+		}
+		// Stderr is the same terminal but is not used by the renderer
+		seq.WriteTo(os.Stderr)
+		return nil
+	}
+}
+
+// unreadCount is the number of messages the user has not looked at yet
+func (m *model) unreadCount() int {
+	count := m.unseen
+	for _, unread := range m.bufferUnread {
+		count += unread
+	}
+	return count
+}
+
+// syncWindowTitle updates the multiplexer window title when the unread count changes
+func (m *model) syncWindowTitle() tea.Cmd {
+	if m.mux == muxNone {
+		return nil
+	}
+	unread := m.unreadCount()
+	if unread == m.muxUnread {
+		return nil
+	}
+	m.muxUnread = unread
+	title := "padclient"
+	if unread > 0 {
+		title = fmt.Sprintf("padclient (%d)", unread)
+	}
+	return setWindowTitle(m.mux, title)
+}
+
+// notifyMultiplexer shows direct messages and mentions in the multiplexer's status line
+func (m *model) notifyMultiplexer(entry chatEntry) tea.Cmd {
+	if m.mux == muxNone {
+		return nil
+	}
+	if !m.shouldNotify(entry) {
+		return nil
+	}
+	return muxNotify(m.mux, fmt.Sprintf("padclient: %s: %s", entry.sender, entry.content))
+}
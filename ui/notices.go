@@ -0,0 +1,80 @@
+// notices.go
+// Package ui routes server notices into a dedicated "server" buffer and tracks unread badges for buffers.
+
+package ui
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// serverBuffer is the buffer that collects server notices
+const serverBuffer = "server"
+
+// maxPanelEntries is the number of most recent entries a buffer panel shows
+const maxPanelEntries = 12
+
+func init() {
+	registerCommand("/server", commandSpec{
+		usage: "/server",
+		help:  "Toggle the server notices buffer (also F2)",
+		run: func(m *model, args []string) tea.Cmd {
+			m.toggleBuffer(serverBuffer)
+			return nil
+		},
+	})
+}
+
+// addToBuffer appends an entry to a named buffer, counting it as unread unless the buffer is open
+func (m *model) addToBuffer(name string, entry chatEntry) {
+	m.buffers[name] = append(m.buffers[name], entry)
+	if m.panel != "buffer" || m.bufferName != name {
+		m.bufferUnread[name]++
+	}
+}
+
+// appendServerNotice routes a server notice into the server buffer
+func (m *model) appendServerNotice(content string) {
+	m.addToBuffer(serverBuffer, chatEntry{kind: entrySystem, content: content, at: time.Now()})
+}
+
+// toggleBuffer opens the named buffer in the panel, or closes it when it is already open
+func (m *model) toggleBuffer(name string) {
+	if m.panel == "buffer" && m.bufferName == name {
+		m.panel = ""
+		return
+	}
+	m.panel = "buffer"
+	m.bufferName = name
+	m.bufferUnread[name] = 0
+}
+
+// showBuffer opens the named buffer in the panel without toggling it closed
+func (m *model) showBuffer(name string) {
+	m.panel = "buffer"
+	m.bufferName = name
+	m.bufferUnread[name] = 0
+}
+
+// badges renders the unread counts of buffers with new entries
+func (m *model) badges() string {
+	var names []string
+	for name, unread := range m.bufferUnread {
+		if unread > 0 {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return ""
+	}
+	sort.Strings(names)
+	badges := make([]string, 0, len(names))
+	for _, name := range names {
+		badges = append(badges, fmt.Sprintf("%s (%d new)", name, m.bufferUnread[name]))
+	}
+	return "Unread: " + strings.Join(badges, " · ") + " — F2 or /buffer <name> to view"
+}
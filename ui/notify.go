@@ -0,0 +1,150 @@
+// notify.go
+// Package ui keeps a notification level for each conversation and remembers it across sessions.
+
+package ui
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// notifyLevelNames are the notification levels a conversation can have
+var notifyLevelNames = []string{"all", "mentions", "none"}
+
+// notifyLevelsPath is the file notification levels are saved to (empty keeps them for this session only)
+var notifyLevelsPath string
+
+func init() {
+	registerCommand("/notify", commandSpec{
+		usage: "/notify [all|mentions|none] [conversation]",
+		help:  "Show or set which messages notify you, for all conversations or one",
+		run: func(m *model, args []string) tea.Cmd {
+			if len(args) == 0 {
+				m.listNotifyLevels()
+				return nil
+			}
+			level := strings.ToLower(args[0])
+			if !containsString(notifyLevelNames, level) {
+				m.appendMessage("Usage: " + knownCommands["/notify"].usage)
+				return nil
+			}
+			conversation := "*"
+			if len(args) > 1 {
+				conversation = args[1]
+			}
+			if conversation == "*" {
+				// Setting every conversation also clears per-conversation levels
+				m.notifyLevels = map[string]string{"*": level}
+			} else {
+				m.notifyLevels[conversation] = level
+			}
+			if err := saveNotifyLevels(m.notifyLevels); err != nil {
+				m.appendMessage(fmt.Sprintf("Error saving notification levels: %v", err))
+			}
+			m.syncMuteSettings()
+			m.appendMessage(fmt.Sprintf("Notifications for %s: %s.", describeConversation(conversation), level))
+			return nil
+		},
+	})
+}
+
+// defaultNotifyLevelsPath returns the notification levels file in the user's config directory
+func defaultNotifyLevelsPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "padclient", "notify.json")
+}
+
+// loadNotifyLevels reads the saved notification levels, returning none if the file does not exist yet
+func loadNotifyLevels() (map[string]string, error) {
+	levels := make(map[string]string)
+	if notifyLevelsPath == "" || amnesia {
+		return levels, nil
+	}
+	data, err := os.ReadFile(notifyLevelsPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return levels, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &levels); err != nil {
+		return nil, fmt.Errorf("%s: %v", notifyLevelsPath, err)
+	}
+	return levels, nil
+}
+
+// saveNotifyLevels writes the notification levels, replacing the file atomically
+func saveNotifyLevels(levels map[string]string) error {
+	if notifyLevelsPath == "" || amnesia {
+		return nil
+	}
+	data, err := json.MarshalIndent(levels, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(notifyLevelsPath), 0700); err != nil {
+		return err
+	}
+	tmp := notifyLevelsPath + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, notifyLevelsPath)
+}
+
+// notifyLevel returns the conversation's notification level. Unless set otherwise, direct
+// conversations notify for every message and broadcasts only for mentions.
+func (m *model) notifyLevel(conversation string) string {
+	if level, ok := m.notifyLevels[conversation]; ok {
+		return level
+	}
+	if level, ok := m.notifyLevels["*"]; ok {
+		return level
+	}
+	if conversation == "ALL" {
+		return "mentions"
+	}
+	return "all"
+}
+
+// shouldNotify reports whether an incoming entry should notify the user at its conversation's level
+func (m *model) shouldNotify(entry chatEntry) bool {
+	switch m.notifyLevel(entry.conversation()) {
+	case "all":
+		return true
+	case "mentions":
+		match, _ := m.watchMatch(entry.content)
+		return match == "mention"
+	default:
+		return false
+	}
+}
+
+// listNotifyLevels shows the default level and every per-conversation level
+func (m *model) listNotifyLevels() {
+	if level, ok := m.notifyLevels["*"]; ok {
+		m.appendMessage(fmt.Sprintf("Notifications for all conversations: %s.", level))
+	} else {
+		m.appendMessage("Notifications: all messages in direct conversations, mentions in broadcasts.")
+	}
+	var conversations []string
+	for conversation := range m.notifyLevels {
+		if conversation != "*" {
+			conversations = append(conversations, conversation)
+		}
+	}
+	sort.Strings(conversations)
+	for _, conversation := range conversations {
+		m.appendMessage(fmt.Sprintf("  %s: %s", describeConversation(conversation), m.notifyLevels[conversation]))
+	}
+}
@@ -0,0 +1,227 @@
+// oneshot.go
+// Package ui implements the send subcommand, which delivers one message without starting the UI.
+
+package ui
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/drewwalton19216801/padclient/protocol"
+	"github.com/drewwalton19216801/padclient/transport"
+	"github.com/drewwalton19216801/tailutils"
+)
+
+// sendChunkSize is the default largest message sent at once; longer stdin input is split
+const sendChunkSize = 4096
+
+// sendMaxSize is the default largest stdin input accepted
+const sendMaxSize = 256 * 1024
+
+// runSend connects, sends one message, and waits for the server to acknowledge it:
+// padclient send -to <ID|ALL> -server <host> [-id <YourID>] "message"
+// With "-" as the message, the body is read from stdin and sent in chunks.
+func runSend(args []string) error {
+	flags := flag.NewFlagSet("send", flag.ContinueOnError)
+	to := flags.String("to", "", "recipient ID, or ALL to broadcast")
+	server := flags.String("server", "", "Tailscale server to connect to")
+	flags.Var(portValue{}, "port", "TCP port the server listens on (default 12345, or as found through DNS)")
+	hostname, _ := os.Hostname()
+	clientID := flags.String("id", "send-"+hostname, "client ID to register as")
+	timeout := flags.Duration("timeout", 30*time.Second, "how long to wait for the connection and the acknowledgement")
+	flags.BoolVar(&jsonErrors, "json-errors", true, "write errors to stderr as JSON objects")
+	flags.BoolVar(&allowNonTailscale, "allow-non-tailscale", false, "connect without Tailscale; traffic is only protected by the client's own encryption")
+	addTsnetFlags(flags)
+	addAllowlistFlags(flags)
+	flags.StringVar(&padDir, "pad-dir", defaultPadDir(), "directory holding one-time pads shared with peers")
+	chunkSize := flags.Int("chunk-size", sendChunkSize, "largest message sent at once when reading stdin, in bytes")
+	maxSize := flags.Int("max-size", sendMaxSize, "largest input accepted from stdin, in bytes")
+	if err := readSubcommandConfig(flags, args); err != nil {
+		return err
+	}
+	if err := flags.Parse(args); err != nil {
+		return &fatalError{code: exitUsage, err: err}
+	}
+	messageText := strings.Join(flags.Args(), " ")
+	if *to == "" || *server == "" || messageText == "" {
+		return &fatalError{code: exitUsage, err: errors.New(`usage: padclient send -to <ID|ALL> -server <host> [-id <YourID>] "message"|-`)}
+	}
+	chunks := []string{messageText}
+	if messageText == "-" {
+		if *chunkSize < utf8.UTFMax || *maxSize < 1 {
+			return &fatalError{code: exitUsage, err: errors.New("-chunk-size and -max-size are too small")}
+		}
+		body, err := readStdinBody(os.Stdin, *maxSize)
+		if err != nil {
+			return err
+		}
+		chunks = chunkMessage(body, *chunkSize)
+	}
+
+	if err := checkServersAllowed(*server); err != nil {
+		return err
+	}
+	if err := requireTailscale(*server); err != nil {
+		return err
+	}
+	pads, err := loadPads(padDir)
+	if err != nil {
+		return fmt.Errorf("error loading pads: %v", err)
+	}
+	id, err := loadSigningIdentity(*clientID)
+	if err != nil {
+		return err
+	}
+
+	// One deadline covers the handshake, the send, and the acknowledgement
+	conn, hashedSecret, err := dialServer(*server, *clientID, *timeout)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	reader := protocol.NewLineReader(conn, maxLineLength)
+	defer reader.Release()
+	if pads.startSync(*to) {
+		// Let the peer check their copy of the pad before it is used
+		line, err := padSyncLine(protocol.EncodeHex, pads, id, *clientID, *to, "hello")
+		if err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintf(conn, "%s\n", line); err != nil {
+			return err
+		}
+		if err := awaitAck(reader, *clientID, *to); err != nil {
+			return err
+		}
+	}
+	for i, chunk := range chunks {
+		line, _, err := encodeSendLine(hashedSecret, pads, *to, id.sign(*clientID, *to, withMessageID(chunk)))
+		if err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintf(conn, "%s\n", line); err != nil {
+			return err
+		}
+		// Wait for each chunk so they arrive in order and a rejection stops the rest
+		if err := awaitAck(reader, *clientID, *to); err != nil {
+			if len(chunks) > 1 {
+				return fmt.Errorf("chunk %d of %d: %w", i+1, len(chunks), err)
+			}
+			return err
+		}
+	}
+	fmt.Fprintf(conn, "EXIT\n")
+	return nil
+}
+
+// readStdinBody reads a message body of at most maxSize bytes, without its trailing newlines
+func readStdinBody(r io.Reader, maxSize int) (string, error) {
+	data, err := io.ReadAll(io.LimitReader(r, int64(maxSize)+1))
+	if err != nil {
+		return "", fmt.Errorf("error reading stdin: %v", err)
+	}
+	if len(data) > maxSize {
+		return "", &fatalError{code: exitUsage, err: fmt.Errorf("stdin is larger than %d bytes; raise -max-size to send it", maxSize)}
+	}
+	body := strings.TrimRight(string(data), "\r\n")
+	if strings.TrimSpace(body) == "" {
+		return "", &fatalError{code: exitUsage, err: errors.New("nothing to send on stdin")}
+	}
+	return body, nil
+}
+
+// chunkMessage splits text into pieces of at most size bytes, breaking after a newline where
+// possible and never inside a UTF-8 character
+func chunkMessage(text string, size int) []string {
+	var chunks []string
+	for len(text) > size {
+		if newline := strings.LastIndexByte(text[:size+1], '\n'); newline > 0 {
+			// The newline itself ends the chunk
+			chunks = append(chunks, text[:newline])
+			text = text[newline+1:]
+			continue
+		}
+		cut := size
+		for cut > 0 && !utf8.RuneStart(text[cut]) {
+			cut--
+		}
+		chunks = append(chunks, text[:cut])
+		text = text[cut:]
+	}
+	return append(chunks, text)
+}
+
+// awaitAck reads server lines until our message is acknowledged, rejected, or echoed back
+func awaitAck(reader *protocol.LineReader, clientID, recipientID string) error {
+	for {
+		line, err := reader.Next()
+		var tooLong protocol.ErrLineTooLong
+		if errors.As(err, &tooLong) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		switch {
+		case isAckLine(line):
+			return nil
+		case isErrorLine(line):
+			return fmt.Errorf("server rejected the message: %s", line)
+		case strings.HasPrefix(line, "BANNED"), strings.HasPrefix(line, "KICKED"):
+			return fmt.Errorf("%w: %s", transport.ErrBanned, line)
+		case recipientID == "ALL" && strings.HasPrefix(line, "BROADCAST from "+clientID+":"):
+			// Servers without ACKs echo broadcasts back to the sender
+			return nil
+		}
+	}
+}
+
+// dialServer connects to the server and registers as clientID. The timeout covers the dial and the
+// key exchange, and stays set on the returned connection as its deadline.
+func dialServer(server, clientID string, timeout time.Duration) (net.Conn, []byte, error) {
+	conn, err := dialTCP(resolveServer(server), timeout)
+	if err != nil {
+		return nil, nil, explainDialError(server, serverPort, err)
+	}
+	conn.SetDeadline(time.Now().Add(timeout))
+	c, err := transport.Handshake(conn, clientID, maxLineLength, func(pubKeyHex string) (string, error) {
+		return verifyServerKey(server, pubKeyHex)
+	})
+	if err != nil {
+		conn.Close()
+		return nil, nil, authFailure(err)
+	}
+	return conn, c.Secret, nil
+}
+
+// requireTailscale fails unless this machine has a Tailscale address. Servers on this machine are
+// allowed without one for development, and -allow-non-tailscale allows any server. With -tsnet it
+// starts the embedded Tailscale node instead.
+func requireTailscale(servers ...string) error {
+	if useTsnet {
+		// The embedded node joins the tailnet itself
+		return startTsnet()
+	}
+	if allLoopback(servers) {
+		return nil
+	}
+	if allowNonTailscale {
+		fmt.Fprintln(os.Stderr, nonTailscaleWarning)
+		return nil
+	}
+	isTailscale, err := tailutils.HasTailscaleIP()
+	if err != nil {
+		return &fatalError{code: exitNotTailscale, err: fmt.Errorf("error checking local IP address: %v", err)}
+	}
+	if !isTailscale {
+		return &fatalError{code: exitNotTailscale, err: errors.New("please connect to a Tailscale network")}
+	}
+	return nil
+}
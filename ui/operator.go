@@ -0,0 +1,78 @@
+// operator.go
+// Package ui keeps the operator status shown in the prompt in sync with what the server reports.
+
+package ui
+
+import (
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// operatorStatusMsg reports the operator status the server currently grants us
+type operatorStatusMsg struct {
+	isOperator bool
+	rejection  bool // Whether the status comes from a command rejected as operator-only
+}
+
+func init() {
+	registerCommand("/opstatus", commandSpec{
+		usage: "/opstatus",
+		help:  "Ask the server whether you are the operator (needs the OPSTATUS capability)",
+		run: func(m *model, args []string) tea.Cmd {
+			if !m.serverCaps["OPSTATUS"] {
+				m.appendMessage("The server does not support OPSTATUS; operator status comes from registration.")
+				return nil
+			}
+			m.writeLine("OPSTATUS")
+			return nil
+		},
+	})
+}
+
+// parseOperatorStatus recognizes lines that tell us our operator status: "OPERATOR yes|no"
+// (the OPSTATUS answer), a plain "REGISTERED" mid-session, or a rejection of an operator-only command.
+func parseOperatorStatus(line string) (operatorStatusMsg, bool) {
+	switch {
+	case line == "OPERATOR yes":
+		return operatorStatusMsg{isOperator: true}, true
+	case line == "OPERATOR no", line == "REGISTERED":
+		return operatorStatusMsg{isOperator: false}, true
+	}
+	lower := strings.ToLower(line)
+	if strings.Contains(lower, "operator only") || strings.Contains(lower, "only the operator") ||
+		strings.Contains(lower, "not the operator") || strings.Contains(lower, "not an operator") {
+		return operatorStatusMsg{isOperator: false, rejection: true}, true
+	}
+	return operatorStatusMsg{}, false
+}
+
+// setOperator reconciles the operator status with the server's answer, updating the prompt and
+// telling the user when it changed.
+func (m *model) setOperator(isOperator bool) {
+	if m.isOperator == isOperator {
+		return
+	}
+	m.isOperator = isOperator
+	m.updatePrompt()
+	m.updateSuggestions() // Operator-only commands are offered to operators only
+	if isOperator {
+		m.appendMessage("You are now the server operator.")
+	} else {
+		m.appendMessage("You are no longer the server operator.")
+	}
+}
+
+// reconcileOperatorOnConnect applies the operator status from registration, noting when a
+// previous session's status was not restored, and re-requests it when the server supports it.
+func (m *model) reconcileOperatorOnConnect(registeredAsOperator bool) {
+	wasOperator := m.isOperator
+	m.isOperator = registeredAsOperator
+	m.updatePrompt()
+	if wasOperator && !registeredAsOperator {
+		m.appendMessage("Operator status was not restored after reconnecting.")
+	}
+	if m.serverCaps["OPSTATUS"] {
+		m.writeLine("OPSTATUS")
+	}
+}
@@ -0,0 +1,205 @@
+// outbox.go
+// Package ui holds outgoing messages in a send queue for a short undo window before writing them to the server.
+
+package ui
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// undoWindow is how long outgoing messages wait in the outbox before being sent
+var undoWindow = 3 * time.Second
+
+// queuedSend is an outgoing message waiting for its undo window to pass
+type queuedSend struct {
+	id          int       // Outbox sequence number
+	recipientID string    // Recipient ID or ALL
+	messageText string    // Plaintext message body
+	sendAt      time.Time // When the undo window ends
+}
+
+// flushOutboxMsg fires when a queued message's undo window has passed
+type flushOutboxMsg struct {
+	id int
+}
+
+func init() {
+	registerCommand("/undo", commandSpec{
+		usage: "/undo",
+		help:  "Cancel the most recent message that has not been sent yet",
+		run: func(m *model, args []string) tea.Cmd {
+			m.undoLastSend()
+			return nil
+		},
+	})
+}
+
+// queueSend places a message in the outbox and schedules it to be sent after the undo window
+func (m *model) queueSend(recipientID, messageText string) tea.Cmd {
+	m.outboxSeq++
+	// Random jitter keeps the send time from revealing when Enter was pressed
+	delay := undoWindow + m.jitterFor(recipientID)
+	// Slow mode and rate limits hold the message back rather than have the server reject it
+	delay, tick := m.pace(recipientID, delay)
+	queued := queuedSend{id: m.outboxSeq, recipientID: recipientID, messageText: messageText, sendAt: time.Now().Add(delay)}
+	m.logQueued(queued)
+	// Echo the message locally right away
+	entry := chatEntry{
+		kind:      entryOutgoing,
+		recipient: recipientID,
+		status:    statusQueued,
+		outboxID:  queued.id,
+	}
+	openEnvelope(messageText).annotate(&entry)
+	m.appendEntry(entry)
+	if delay <= 0 && !m.holdOutbox {
+		m.sendQueued(queued)
+		return tick
+	}
+	m.outbox = append(m.outbox, queued)
+	return tea.Batch(tick, tea.Tick(delay, func(time.Time) tea.Msg {
+		return flushOutboxMsg{id: queued.id}
+	}))
+}
+
+// flushOutbox sends the queued message once its undo window has passed
+func (m *model) flushOutbox(id int) {
+	if m.holdOutbox {
+		// Held messages are sent once we reconnect
+		return
+	}
+	for i, queued := range m.outbox {
+		if queued.id == id {
+			m.outbox = append(m.outbox[:i], m.outbox[i+1:]...)
+			m.sendQueued(queued)
+			return
+		}
+	}
+}
+
+// releaseOutbox stops holding outgoing messages and sends those whose undo window has passed
+func (m *model) releaseOutbox() {
+	m.holdOutbox = false
+	now := time.Now()
+	var waiting []queuedSend
+	for _, queued := range m.outbox {
+		if queued.sendAt.After(now) {
+			waiting = append(waiting, queued)
+			continue
+		}
+		m.sendQueued(queued)
+	}
+	m.outbox = waiting
+}
+
+// sendQueued hands a queued message to the crypto pool; it is written to the server once it
+// and every message sent before it have been encrypted
+func (m *model) sendQueued(queued queuedSend) {
+	m.sealing = append(m.sealing, &pendingSend{queued: queued, done: make(chan struct{})})
+}
+
+// finishSend writes an encrypted message to the server
+func (m *model) finishSend(p *pendingSend) {
+	queued := p.queued
+	if p.err != nil {
+		m.setDeliveryStatus(queued.id, statusFailed)
+		m.appendMessage(fmt.Sprintf("Error: %v", p.err))
+		return
+	}
+	if !m.writeLine(p.line) {
+		m.setDeliveryStatus(queued.id, statusFailed)
+		return
+	}
+	if entry := m.outgoingEntry(queued.id); entry != nil {
+		entry.info = p.info
+		m.saveHistory(*entry)
+	}
+	if warning := m.pads.lowPadWarning(queued.recipientID); warning != "" {
+		m.appendMessage(warning)
+	}
+	// Wait for the server to acknowledge the message
+	m.setDeliveryStatus(queued.id, statusPending)
+	m.awaitingAck = append(m.awaitingAck, queued.id)
+}
+
+// reconcileAck matches a server line against the oldest message awaiting an ACK.
+// It reports whether the line was an acknowledgement that should not be displayed. An error only
+// fails the message when a SEND or SENDEXCEPT was the last line written; after any other command,
+// the error is taken to answer that command instead.
+func (m *model) reconcileAck(line string) bool {
+	if len(m.awaitingAck) == 0 {
+		return false
+	}
+	oldest := m.awaitingAck[0]
+	switch {
+	case isAckLine(line):
+		m.awaitingAck = m.awaitingAck[1:]
+		m.setDeliveryStatus(oldest, statusDelivered)
+		return true
+	case isErrorLine(line) && strings.HasPrefix(m.lastVerb, "SEND"):
+		m.awaitingAck = m.awaitingAck[1:]
+		m.setDeliveryStatus(oldest, statusFailed)
+	}
+	return false
+}
+
+// reconcileEcho treats the server echoing one of our own messages back as its ACK.
+// It reports whether the echo matched a pending message and should not be displayed.
+func (m *model) reconcileEcho(content string) bool {
+	for i, id := range m.awaitingAck {
+		entry := m.outgoingEntry(id)
+		if entry != nil && entry.content == content {
+			m.awaitingAck = append(m.awaitingAck[:i], m.awaitingAck[i+1:]...)
+			m.setDeliveryStatus(id, statusDelivered)
+			return true
+		}
+	}
+	return false
+}
+
+// isAckLine reports whether a server line acknowledges a SEND
+func isAckLine(line string) bool {
+	for _, prefix := range []string{"ACK", "SENT", "Message sent"} {
+		if strings.HasPrefix(line, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// isErrorLine reports whether a server line reports an error
+func isErrorLine(line string) bool {
+	return strings.HasPrefix(line, "ERROR") || strings.HasPrefix(line, "Error")
+}
+
+// undoLastSend removes the most recently queued message from the outbox
+func (m *model) undoLastSend() {
+	if len(m.outbox) == 0 {
+		m.appendMessage("Nothing to undo.")
+		return
+	}
+	last := m.outbox[len(m.outbox)-1]
+	m.outbox = m.outbox[:len(m.outbox)-1]
+	m.setDeliveryStatus(last.id, statusCancelled)
+}
+
+// writeLine queues a single protocol line for the connection's writer and reports whether it was queued.
+// Write errors arrive later as a writeErrorMsg.
+func (m *model) writeLine(line string) bool {
+	if m.writer == nil {
+		m.appendMessage("Not connected to the server.")
+		return false
+	}
+	select {
+	case m.writer.lines <- line:
+		m.lastVerb, _, _ = strings.Cut(line, " ")
+		return true
+	default:
+		m.appendMessage("Too many lines are waiting to be sent to the server; try again shortly.")
+		return false
+	}
+}
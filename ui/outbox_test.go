@@ -0,0 +1,28 @@
+package ui
+
+import "testing"
+
+// TestReconcileAckOnlyFailsSendsOnError checks that an error answering another command leaves the
+// message awaiting its ACK alone, while one answering a SEND fails it
+func TestReconcileAckOnlyFailsSendsOnError(t *testing.T) {
+	m := &model{
+		entries:     []chatEntry{{kind: entryOutgoing, outboxID: 1, status: statusPending}},
+		awaitingAck: []int{1},
+		writer:      &connWriter{lines: make(chan string, writeQueue)},
+		// Leave the viewport, which the test does not set up, alone
+		renderScheduled: true,
+	}
+	m.writeLine("KICK bob")
+	if m.reconcileAck("ERROR You are not the operator") {
+		t.Error("the error answering KICK was hidden")
+	}
+	if got := m.entries[0].status; got != statusPending || len(m.awaitingAck) != 1 {
+		t.Fatalf("after an error answering KICK, status = %v and %d awaiting; want pending and 1", got, len(m.awaitingAck))
+	}
+
+	m.writeLine("SEND bob 00|00")
+	m.reconcileAck("ERROR Recipient not found")
+	if got := m.entries[0].status; got != statusFailed || len(m.awaitingAck) != 0 {
+		t.Fatalf("after an error answering SEND, status = %v and %d awaiting; want failed and 0", got, len(m.awaitingAck))
+	}
+}
@@ -0,0 +1,386 @@
+// pad.go
+// Package ui manages pre-shared one-time pad files: direct messages to a peer who shares a pad are
+// encrypted with unused pad bytes, which are wiped from disk once used.
+
+package ui
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/drewwalton19216801/padclient/crypto"
+)
+
+// padDir is the directory holding pad files and their state
+var padDir string
+
+// padRefPrefix starts the key field of a pad-encrypted SEND: pad:<id>:<offset>|<ciphertext_hex>
+const padRefPrefix = "pad:"
+
+// padWipedMin is the shortest run of zero pad bytes taken to mean the bytes were already used
+const padWipedMin = 8
+
+// padWarnLevels are the fractions of our half of a pad at which a low-pad warning is shown
+var padWarnLevels = []float64{0.25, 0.10, 0.01}
+
+// padState is the stored state of a pad shared with one peer. The pad is split in two halves so
+// each side encrypts with its own bytes and the peers never need to agree on an offset.
+type padState struct {
+	ID       string `json:"id"`               // First 8 bytes of the pad's SHA-256 digest at creation, in hex
+	Peer     string `json:"peer"`             // Peer the pad is shared with
+	Size     int64  `json:"size"`             // Pad length in bytes
+	Half     int    `json:"half"`             // Half we encrypt with: 0 for the side that generated the pad, 1 for the side that imported it
+	Sent     int64  `json:"sent"`             // Bytes of our half used so far
+	Received int64  `json:"received"`         // Bytes of the peer's half received so far, up to the end of the latest message
+	Desync   string `json:"desync,omitempty"` // Why the pad was found out of sync with the peer's copy; such a pad is refused
+	warned   int    // Number of padWarnLevels already announced this session
+}
+
+// padStore holds the pads in padDir. It is shared by the UI and the message reader.
+type padStore struct {
+	mu     sync.Mutex
+	dir    string
+	pads   map[string]*padState // By peer
+	synced map[string]bool      // Peers the pad sync handshake was exchanged with this session
+}
+
+func init() {
+	registerCommand("/pads", commandSpec{
+		usage: "/pads",
+		help:  "Show the one-time pads shared with peers and how much of each is left",
+		run: func(m *model, args []string) tea.Cmd {
+			lines := m.pads.summary()
+			if len(lines) == 0 {
+				m.appendMessage(fmt.Sprintf("No pads in %s. Create one with: padclient pad generate <size> -peer <ID>", padDir))
+				return nil
+			}
+			for _, line := range lines {
+				m.appendMessage(line)
+			}
+			return nil
+		},
+	})
+}
+
+// defaultPadDir returns the pad directory in the user's config directory
+func defaultPadDir() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "padclient", "pads")
+}
+
+// validPeerName reports whether a peer ID can be used as a pad file name
+func validPeerName(peer string) bool {
+	return peer != "" && peer != "." && peer != ".." && peer != "ALL" && filepath.Base(peer) == peer && !strings.ContainsAny(peer, `/\:`)
+}
+
+// loadPads reads the state of every pad in dir. A missing directory holds no pads.
+func loadPads(dir string) (*padStore, error) {
+	store := &padStore{dir: dir, pads: make(map[string]*padState), synced: make(map[string]bool)}
+	if dir == "" {
+		return store, nil
+	}
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		var state padState
+		if err := json.Unmarshal(data, &state); err != nil {
+			return nil, fmt.Errorf("%s: %v", path, err)
+		}
+		if !validPeerName(state.Peer) || state.Size < 2 || (state.Half != 0 && state.Half != 1) {
+			return nil, fmt.Errorf("%s: invalid pad state", path)
+		}
+		store.pads[state.Peer] = &state
+	}
+	return store, nil
+}
+
+// padPath returns the path of the pad file shared with a peer
+func (s *padStore) padPath(peer string) string {
+	return filepath.Join(s.dir, peer+".pad")
+}
+
+// save writes a pad's state, replacing the file atomically
+func (s *padStore) save(state *padState) error {
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(s.dir, state.Peer+".json")
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// halfRange returns the byte range of a pad half
+func (p *padState) halfRange(half int) (start, end int64) {
+	middle := p.Size / 2
+	if half == 0 {
+		return 0, middle
+	}
+	return middle, p.Size
+}
+
+// remaining returns the unused bytes of our half
+func (p *padState) remaining() int64 {
+	start, end := p.halfRange(p.Half)
+	return end - start - p.Sent
+}
+
+// has reports whether a pad is shared with the peer
+func (s *padStore) has(peer string) bool {
+	if s == nil {
+		return false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.pads[peer]
+	return ok
+}
+
+// consume reads n bytes of pad at offset and overwrites them with zeros so they can never be used again
+func (s *padStore) consume(peer string, offset int64, n int) ([]byte, error) {
+	key, err := s.read(peer, offset, n)
+	if err != nil {
+		return nil, err
+	}
+	return key, s.wipe(peer, offset, n)
+}
+
+// read reads n bytes of pad at offset without using them up. It fails if the bytes were already
+// wiped; runs shorter than padWipedMin are not checked, since fresh pad bytes are zero often enough
+// to cause false alarms.
+func (s *padStore) read(peer string, offset int64, n int) ([]byte, error) {
+	file, err := os.Open(s.padPath(peer))
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	key := make([]byte, n)
+	if _, err := file.ReadAt(key, offset); err != nil {
+		return nil, fmt.Errorf("error reading pad: %v", err)
+	}
+	used := n >= padWipedMin
+	for _, b := range key {
+		if b != 0 {
+			used = false
+			break
+		}
+	}
+	if used {
+		return nil, errors.New("pad bytes were already used; the message may be a replay")
+	}
+	return key, nil
+}
+
+// wipe overwrites n bytes of pad at offset with zeros so they can never be used again
+func (s *padStore) wipe(peer string, offset int64, n int) error {
+	file, err := os.OpenFile(s.padPath(peer), os.O_RDWR, 0)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	if _, err := file.WriteAt(make([]byte, n), offset); err != nil {
+		return fmt.Errorf("error wiping used pad bytes: %v", err)
+	}
+	return file.Sync()
+}
+
+// encrypt encrypts plaintext with the next unused bytes of our half of the peer's pad and returns
+// the pad reference to send with it, and the MAC of the ciphertext. The MAC key comes from the
+// pad bytes following the message's, so each message uses len(plaintext)+crypto.MACSize bytes.
+func (s *padStore) encrypt(peer string, plaintext []byte) (string, []byte, []byte, cipherInfo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	state := s.pads[peer]
+	if state.Desync != "" {
+		return "", nil, nil, cipherInfo{}, padDesyncError{peer: peer, reason: state.Desync}
+	}
+	n := len(plaintext) + crypto.MACSize
+	if int64(n) > state.remaining() {
+		return "", nil, nil, cipherInfo{}, fmt.Errorf("the pad shared with %s has %d bytes left, too few for this message; generate a new pad", peer, state.remaining())
+	}
+	start, _ := state.halfRange(state.Half)
+	offset := start + state.Sent
+	// Record the bytes as used before using them, so a crash cannot lead to reuse
+	state.Sent += int64(n)
+	if err := s.save(state); err != nil {
+		state.Sent -= int64(n)
+		return "", nil, nil, cipherInfo{}, fmt.Errorf("error saving pad state: %v", err)
+	}
+	key, err := s.consume(peer, offset, n)
+	if err != nil {
+		return "", nil, nil, cipherInfo{}, err
+	}
+	ref := fmt.Sprintf("%s%s:%d", padRefPrefix, state.ID, offset)
+	ciphertext := crypto.EncryptXOR(plaintext, key)
+	mac := crypto.SumMAC(crypto.MACKey(key[len(plaintext):]), ciphertext)
+	return ref, ciphertext, mac, padInfo(state, offset, len(plaintext)), nil
+}
+
+// decrypt checks the MAC of a message from the peer and decrypts it with their half of the shared
+// pad. A MAC that does not match fails with errIntegrityCheck. The pad bytes are only wiped once the
+// MAC matches, so a forged message cannot use up pad the real message needs.
+func (s *padStore) decrypt(peer, ref string, ciphertext, mac []byte) ([]byte, cipherInfo, error) {
+	id, offsetText, ok := strings.Cut(strings.TrimPrefix(ref, padRefPrefix), ":")
+	offset, err := strconv.ParseInt(offsetText, 10, 64)
+	if !ok || err != nil {
+		return nil, cipherInfo{}, fmt.Errorf("invalid pad reference %q", ref)
+	}
+	if s == nil {
+		return nil, cipherInfo{}, fmt.Errorf("%s sent a pad-encrypted message, but no pads are loaded", peer)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	state, ok := s.pads[peer]
+	if !ok || state.ID != id {
+		return nil, cipherInfo{}, fmt.Errorf("%s used pad %s, which is not shared with them here", peer, id)
+	}
+	if state.Desync != "" {
+		return nil, cipherInfo{}, padDesyncError{peer: peer, reason: state.Desync}
+	}
+	// The peer encrypts with the half we do not use
+	start, end := state.halfRange(1 - state.Half)
+	n := len(ciphertext) + crypto.MACSize
+	if offset < start || offset+int64(n) > end {
+		return nil, cipherInfo{}, fmt.Errorf("pad offset %d is outside %s's half of the pad", offset, peer)
+	}
+	key, err := s.read(peer, offset, n)
+	if err != nil {
+		return nil, cipherInfo{}, err
+	}
+	if !crypto.CheckMAC(crypto.MACKey(key[len(ciphertext):]), ciphertext, mac) {
+		return nil, cipherInfo{}, fmt.Errorf("%w: the message was altered or corrupted on the way", errIntegrityCheck)
+	}
+	if err := s.wipe(peer, offset, n); err != nil {
+		return nil, cipherInfo{}, err
+	}
+	// Record how far the peer's half was received, for the next sync handshake
+	if received := offset + int64(n) - start; received > state.Received {
+		state.Received = received
+		if err := s.save(state); err != nil {
+			return nil, cipherInfo{}, fmt.Errorf("error saving pad state: %v", err)
+		}
+	}
+	return crypto.EncryptXOR(ciphertext, key), padInfo(state, offset, len(ciphertext)), nil
+}
+
+// padInfo describes a message encrypted with pad bytes
+func padInfo(state *padState, offset int64, n int) cipherInfo {
+	return cipherInfo{
+		cipher:      fmt.Sprintf("one-time pad (%d bytes of pre-shared pad material), authenticated with HMAC-SHA256", n),
+		fingerprint: "pad " + state.ID,
+		pad:         fmt.Sprintf("%s at offset %d", state.ID, offset),
+	}
+}
+
+// lowPadWarning returns a warning the first time our half of the peer's pad drops below each warning level
+func (s *padStore) lowPadWarning(peer string) string {
+	if s == nil {
+		return ""
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	state, ok := s.pads[peer]
+	if !ok {
+		return ""
+	}
+	start, end := state.halfRange(state.Half)
+	left := float64(state.remaining()) / float64(end-start)
+	warning := ""
+	for state.warned < len(padWarnLevels) && left < padWarnLevels[state.warned] {
+		warning = fmt.Sprintf("Warning: the pad shared with %s is running out (%s left). Generate a new pad soon.", peer, formatSize(state.remaining()))
+		state.warned++
+	}
+	return warning
+}
+
+// summary returns one line per pad describing how much is left
+func (s *padStore) summary() []string {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	peers := make([]string, 0, len(s.pads))
+	for peer := range s.pads {
+		peers = append(peers, peer)
+	}
+	sort.Strings(peers)
+	lines := make([]string, 0, len(peers))
+	for _, peer := range peers {
+		state := s.pads[peer]
+		start, end := state.halfRange(state.Half)
+		line := fmt.Sprintf("%s: pad %s, %s of %s left (%.0f%%)", peer, state.ID,
+			formatSize(state.remaining()), formatSize(end-start), 100*float64(state.remaining())/float64(end-start))
+		if state.Desync != "" {
+			line += ", out of sync: " + state.Desync
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// padID identifies a pad by the first 8 bytes of its SHA-256 digest
+func padID(r io.Reader) (string, int64, error) {
+	hash := sha256.New()
+	size, err := io.Copy(hash, r)
+	if err != nil {
+		return "", 0, err
+	}
+	return hex.EncodeToString(hash.Sum(nil)[:8]), size, nil
+}
+
+// parseSize parses a size such as 4096, 512KB, or 10MB. Units are powers of 1024.
+func parseSize(text string) (int64, error) {
+	units := []struct {
+		suffix     string
+		multiplier int64
+	}{{"GB", 1 << 30}, {"G", 1 << 30}, {"MB", 1 << 20}, {"M", 1 << 20}, {"KB", 1 << 10}, {"K", 1 << 10}, {"B", 1}}
+	upper := strings.ToUpper(strings.TrimSpace(text))
+	multiplier := int64(1)
+	for _, unit := range units {
+		if number, ok := strings.CutSuffix(upper, unit.suffix); ok {
+			upper, multiplier = strings.TrimSpace(number), unit.multiplier
+			break
+		}
+	}
+	n, err := strconv.ParseInt(upper, 10, 64)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid size %q", text)
+	}
+	return n * multiplier, nil
+}
+
+// formatSize formats a byte count for display
+func formatSize(n int64) string {
+	switch {
+	case n >= 1<<20:
+		return fmt.Sprintf("%.1f MB", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%.1f KB", float64(n)/(1<<10))
+	default:
+		return fmt.Sprintf("%d bytes", n)
+	}
+}
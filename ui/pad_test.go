@@ -0,0 +1,160 @@
+package ui
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/drewwalton19216801/padclient/crypto"
+)
+
+// sharePad writes the same random pad of size bytes for alice and bob in temp dirs, as generating
+// it on alice's side and importing it on bob's does, and returns both stores
+func sharePad(t *testing.T, size int64) (alice, bob *padStore) {
+	t.Helper()
+	pad := make([]byte, size)
+	if _, err := rand.Read(pad); err != nil {
+		t.Fatal(err)
+	}
+	id, _, err := padID(bytes.NewReader(pad))
+	if err != nil {
+		t.Fatal(err)
+	}
+	open := func(peer string, half int) *padStore {
+		dir := t.TempDir()
+		if err := os.WriteFile(filepath.Join(dir, peer+".pad"), pad, 0600); err != nil {
+			t.Fatal(err)
+		}
+		store := &padStore{dir: dir}
+		if err := store.save(&padState{ID: id, Peer: peer, Size: size, Half: half}); err != nil {
+			t.Fatal(err)
+		}
+		loaded, err := loadPads(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return loaded
+	}
+	return open("bob", 0), open("alice", 1)
+}
+
+// padBytes returns n bytes of the pad a store shares with peer, at offset
+func padBytes(t *testing.T, s *padStore, peer string, offset int64, n int) []byte {
+	t.Helper()
+	data, err := os.ReadFile(s.padPath(peer))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data[offset : offset+int64(n)]
+}
+
+// TestPadHalves checks that each side encrypts with its own half of the pad, the generating side
+// with the first and the importing side with the second, and that the other side can decrypt it
+func TestPadHalves(t *testing.T) {
+	for _, size := range []int64{256, 257} {
+		alice, bob := sharePad(t, size)
+		tests := []struct {
+			name       string
+			from, to   *padStore
+			peer, self string
+			start      int64
+		}{
+			{"generator", alice, bob, "bob", "alice", 0},
+			{"importer", bob, alice, "alice", "bob", size / 2},
+		}
+		for _, tt := range tests {
+			ref, ciphertext, mac, _, err := tt.from.encrypt(tt.peer, []byte("hello"))
+			if err != nil {
+				t.Fatalf("%s, size %d: %v", tt.name, size, err)
+			}
+			if want := padRefPrefix + tt.from.pads[tt.peer].ID + ":" + strconv.FormatInt(tt.start, 10); ref != want {
+				t.Errorf("%s, size %d: pad reference %q, want %q", tt.name, size, ref, want)
+			}
+			plaintext, _, err := tt.to.decrypt(tt.self, ref, ciphertext, mac)
+			if err != nil || string(plaintext) != "hello" {
+				t.Errorf("%s, size %d: decrypted %q, %v", tt.name, size, plaintext, err)
+			}
+		}
+	}
+}
+
+// TestPadDecrypt checks that a message is only accepted with a matching MAC from the peer's half at
+// an offset not used before, and that a forged message leaves the pad bytes for the real one
+func TestPadDecrypt(t *testing.T) {
+	alice, bob := sharePad(t, 256)
+	ref, ciphertext, mac, _, err := alice.encrypt("bob", []byte("hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	forged := append([]byte{}, ciphertext...)
+	forged[0] ^= 1
+	n := len(ciphertext) + crypto.MACSize
+	tests := []struct {
+		name       string
+		ref        string
+		ciphertext []byte
+		want       string // Substring of the error; "" for success
+	}{
+		{"forged", ref, forged, "integrity check failed"},
+		{"own half", padRefPrefix + alice.pads["bob"].ID + ":128", ciphertext, "outside"},
+		{"other pad", padRefPrefix + "0000000000000000:0", ciphertext, "not shared"},
+		{"malformed", padRefPrefix + "x", ciphertext, "invalid pad reference"},
+		{"genuine", ref, ciphertext, ""},
+		{"reused", ref, ciphertext, "already used"},
+	}
+	for _, tt := range tests {
+		before := append([]byte{}, padBytes(t, bob, "alice", 0, n)...)
+		plaintext, _, err := bob.decrypt("alice", tt.ref, tt.ciphertext, mac)
+		if tt.want == "" {
+			if err != nil || string(plaintext) != "hello" {
+				t.Errorf("%s: decrypted %q, %v", tt.name, plaintext, err)
+			}
+			if !bytes.Equal(padBytes(t, bob, "alice", 0, n), make([]byte, n)) {
+				t.Errorf("%s: the used pad bytes were not wiped", tt.name)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: got %v, want an error containing %q", tt.name, err, tt.want)
+		}
+		if tt.name == "forged" && !errors.Is(err, errIntegrityCheck) {
+			t.Errorf("%s: %v is not errIntegrityCheck", tt.name, err)
+		}
+		// Nothing is wiped before the MAC is checked
+		if tt.name != "reused" && !bytes.Equal(padBytes(t, bob, "alice", 0, n), before) {
+			t.Errorf("%s: pad bytes were wiped by a rejected message", tt.name)
+		}
+	}
+	if got := bob.pads["alice"].Received; got != int64(n) {
+		t.Errorf("received %d bytes of alice's half, want %d", got, n)
+	}
+}
+
+// TestPadExhaustion checks that encrypt uses up our half, refuses a message that does not fit in
+// what is left, and saves the bytes used so a reload never reuses them
+func TestPadExhaustion(t *testing.T) {
+	alice, _ := sharePad(t, 2*(2*(5+crypto.MACSize)+10))
+	for i := 0; i < 2; i++ {
+		if _, _, _, _, err := alice.encrypt("bob", []byte("hello")); err != nil {
+			t.Fatalf("message %d: %v", i, err)
+		}
+	}
+	if got := alice.pads["bob"].remaining(); got != 10 {
+		t.Errorf("%d bytes left, want 10", got)
+	}
+	if _, _, _, _, err := alice.encrypt("bob", []byte("hello")); err == nil || !strings.Contains(err.Error(), "10 bytes left") {
+		t.Errorf("a message that does not fit gave %v", err)
+	}
+	reloaded, err := loadPads(alice.dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := reloaded.pads["bob"].Sent, alice.pads["bob"].Sent; got != want {
+		t.Errorf("reloaded %d bytes sent, want %d", got, want)
+	}
+}
@@ -0,0 +1,187 @@
+// padcmd.go
+// Package ui implements the pad subcommand, which generates, imports, and lists one-time pads.
+
+package ui
+
+import (
+	"crypto/rand"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// maxPadSize is the largest pad the pad subcommand generates
+const maxPadSize = 1 << 30
+
+// runPad runs a pad subcommand:
+//
+//	padclient pad generate <size> -peer <ID> [-out <file>]
+//	padclient pad import <file> -peer <ID>
+//	padclient pad list
+func runPad(args []string) error {
+	usage := errors.New("usage: padclient pad generate <size> -peer <ID> [-out <file>] | import <file> -peer <ID> | list")
+	if len(args) == 0 {
+		return &fatalError{code: exitUsage, err: usage}
+	}
+	flags := flag.NewFlagSet("pad "+args[0], flag.ContinueOnError)
+	flags.StringVar(&padDir, "pad-dir", defaultPadDir(), "directory holding pad files")
+	peer := flags.String("peer", "", "peer the pad is shared with")
+	out := flags.String("out", "", "where to write the copy of a generated pad to give to the peer")
+	if err := readSubcommandConfig(flags, padFlagArgs(flags, args[1:])); err != nil {
+		return err
+	}
+	// Accept the size or file before the flags as well as after them
+	var positional []string
+	rest := args[1:]
+	for len(rest) > 0 {
+		if err := flags.Parse(rest); err != nil {
+			return &fatalError{code: exitUsage, err: err}
+		}
+		rest = flags.Args()
+		if len(rest) > 0 {
+			positional = append(positional, rest[0])
+			rest = rest[1:]
+		}
+	}
+	if padDir == "" {
+		return errors.New("no pad directory; pass -pad-dir")
+	}
+
+	switch {
+	case args[0] == "generate" && len(positional) == 1 && validPeerName(*peer):
+		size, err := parseSize(positional[0])
+		if err != nil {
+			return &fatalError{code: exitUsage, err: err}
+		}
+		if size < 2 || size > maxPadSize {
+			return &fatalError{code: exitUsage, err: fmt.Errorf("pad size must be between 2 bytes and %s", formatSize(maxPadSize))}
+		}
+		return generatePad(size, *peer, *out)
+	case args[0] == "import" && len(positional) == 1 && validPeerName(*peer):
+		id, err := importPad(positional[0], *peer, 1)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Imported pad %s shared with %s. Delete %s once you have checked the import.\n", id, *peer, positional[0])
+		return nil
+	case args[0] == "list" && len(positional) == 0:
+		store, err := loadPads(padDir)
+		if err != nil {
+			return err
+		}
+		lines := store.summary()
+		if len(lines) == 0 {
+			fmt.Printf("No pads in %s.\n", padDir)
+		}
+		for _, line := range lines {
+			fmt.Println(line)
+		}
+		return nil
+	default:
+		return &fatalError{code: exitUsage, err: usage}
+	}
+}
+
+// generatePad writes a new random pad to out for the peer and installs our own copy
+func generatePad(size int64, peer, out string) error {
+	if out == "" {
+		out = fmt.Sprintf("padclient-%s.pad", peer)
+	}
+	file, err := os.OpenFile(out, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+	_, err = io.CopyN(file, rand.Reader, size)
+	if syncErr := file.Sync(); err == nil {
+		err = syncErr
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(out)
+		return fmt.Errorf("error writing pad: %v", err)
+	}
+	id, err := importPad(out, peer, 0)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Generated pad %s (%s) shared with %s.\n", id, formatSize(size), peer)
+	fmt.Printf("Give %s to %s over a trusted channel (e.g. in person on removable media); they run:\n", out, peer)
+	fmt.Printf("  padclient pad import %s -peer <YourID>\n", filepath.Base(out))
+	fmt.Printf("Then delete every other copy of %s.\n", out)
+	return nil
+}
+
+// importPad copies a pad file into the pad directory for the peer, encrypting with the given half
+func importPad(path, peer string, half int) (string, error) {
+	store, err := loadPads(padDir)
+	if err != nil {
+		return "", err
+	}
+	if store.has(peer) {
+		return "", fmt.Errorf("a pad is already shared with %s; remove %s first", peer, store.padPath(peer))
+	}
+	if err := os.MkdirAll(padDir, 0700); err != nil {
+		return "", err
+	}
+	src, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer src.Close()
+	id, size, err := padID(src)
+	if err != nil {
+		return "", err
+	}
+	if size < 2 {
+		return "", fmt.Errorf("%s is too small to be a pad", path)
+	}
+	if _, err := src.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	dst, err := os.OpenFile(store.padPath(peer), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return "", err
+	}
+	_, err = io.Copy(dst, src)
+	if syncErr := dst.Sync(); err == nil {
+		err = syncErr
+	}
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = store.save(&padState{ID: id, Peer: peer, Size: size, Half: half})
+	}
+	if err != nil {
+		os.Remove(store.padPath(peer))
+		return "", fmt.Errorf("error importing pad: %v", err)
+	}
+	return id, nil
+}
+
+// padFlagArgs drops the size or file from the arguments to a pad subcommand, wherever it comes
+// among the flags, so -config is found after it too
+func padFlagArgs(flags *flag.FlagSet, args []string) []string {
+	var kept []string
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" {
+			break
+		}
+		if arg == "-" || !strings.HasPrefix(arg, "-") {
+			continue
+		}
+		kept = append(kept, arg)
+		if name := strings.TrimLeft(arg, "-"); !strings.Contains(name, "=") && (name == "config" || takesValue(flags.Lookup(name))) && i+1 < len(args) {
+			kept = append(kept, args[i+1])
+			i++
+		}
+	}
+	return kept
+}
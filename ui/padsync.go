@@ -0,0 +1,161 @@
+// padsync.go
+// Package ui keeps the one-time pads shared with peers in step. Before the first pad-encrypted
+// message of a session, the two sides exchange the pad ID and how much of each half they have used
+// and received. A pad whose copies disagree, because bytes would be reused or messages went
+// missing, is refused from then on instead of producing garbage.
+
+package ui
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/drewwalton19216801/padclient/protocol"
+)
+
+// padDesyncError reports a pad that was found out of sync with the peer's copy
+type padDesyncError struct {
+	peer   string
+	reason string
+}
+
+func (e padDesyncError) Error() string {
+	return fmt.Sprintf("the pad shared with %s is out of sync (%s); remove it and share a new pad", e.peer, e.reason)
+}
+
+// startSync reports whether a sync handshake is due before pad-encrypted messages to or from peer,
+// which is once per session, and records that it was started
+func (s *padStore) startSync(peer string) bool {
+	if s == nil {
+		return false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.pads[peer]; !ok || s.synced[peer] {
+		return false
+	}
+	s.synced[peer] = true
+	return true
+}
+
+// syncEnvelope returns the handshake describing our copy of the pad shared with peer: its ID, the
+// bytes of our half used, and the bytes of their half received, with the reason if it is refused
+func (s *padStore) syncEnvelope(peer, kind string) envelope {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	state := s.pads[peer]
+	return envelope{
+		padSync:   fmt.Sprintf("%s:%d:%d", state.ID, state.Sent, state.Received),
+		padDesync: state.Desync,
+		handshake: kind,
+	}
+}
+
+// checkSync compares the peer's handshake with our copy of the pad shared with them. A copy that
+// disagrees is marked out of sync, and saved so, and the returned error says why. Messages the
+// peer sent before the handshake arrive before it, so everything they used must have been received;
+// our messages may still be on their way, so they may have received less than we used.
+func (s *padStore) checkSync(peer string, env envelope) error {
+	id, counts, _ := strings.Cut(env.padSync, ":")
+	sentText, receivedText, _ := strings.Cut(counts, ":")
+	sent, err := strconv.ParseInt(sentText, 10, 64)
+	received, err2 := strconv.ParseInt(receivedText, 10, 64)
+	if err != nil || err2 != nil {
+		return fmt.Errorf("invalid pad sync from %s", peer)
+	}
+	if s == nil {
+		return fmt.Errorf("%s shares pad %s, but no pads are loaded", peer, id)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	state, ok := s.pads[peer]
+	if !ok {
+		return fmt.Errorf("%s shares pad %s, which is not loaded here", peer, id)
+	}
+	s.synced[peer] = true
+	if state.Desync != "" {
+		return padDesyncError{peer: peer, reason: state.Desync}
+	}
+	reason := ""
+	switch {
+	case env.padDesync != "":
+		reason = fmt.Sprintf("%s refused it: %s", peer, env.padDesync)
+	case id != state.ID:
+		reason = fmt.Sprintf("%s has pad %s, but pad %s is loaded here", peer, id, state.ID)
+	case sent < state.Received:
+		reason = fmt.Sprintf("%s has used %s of their half, but %s of it was already received; they would reuse pad bytes", peer, formatSize(sent), formatSize(state.Received))
+	case received > state.Sent:
+		reason = fmt.Sprintf("%s received %s of our half, but only %s of it is used here; we would reuse pad bytes", peer, formatSize(received), formatSize(state.Sent))
+	case sent > state.Received:
+		reason = fmt.Sprintf("%s of messages from %s never arrived", formatSize(sent-state.Received), peer)
+	default:
+		return nil
+	}
+	state.Desync = reason
+	if err := s.save(state); err != nil {
+		return fmt.Errorf("error saving pad state: %v", err)
+	}
+	return padDesyncError{peer: peer, reason: reason}
+}
+
+// padSyncLine returns the line carrying a pad sync handshake to peer: "hello" asks for their copy's
+// state in return and "reply" answers one. It uses a one-time key, so it never uses up pad bytes.
+func padSyncLine(encode protocol.Encoder, pads *padStore, id *identity, clientID, peer, kind string) (string, error) {
+	text := pads.syncEnvelope(peer, kind).seal()
+	line, _, err := encodeOneTimeKey(encode, peer, id.sign(clientID, peer, withMessageID(text)))
+	return line, err
+}
+
+// answerPadSync checks a pad sync handshake from a peer. It must carry a valid signature from the
+// key pinned for the peer, so nobody else can mark the pad out of sync. It returns the reply to
+// send, if any, and an error describing a pad found out of sync.
+func answerPadSync(encode protocol.Encoder, pads *padStore, id *identity, keys *keyStore, clientID string, msg incomingMessage, env envelope) (string, error) {
+	key, status := verifySignature(msg.senderID, clientID, msg.content)
+	if status == signatureValid {
+		status, _ = keys.check(msg.senderID, key)
+	}
+	if !status.trusted() {
+		return "", fmt.Errorf("ignored a pad sync from %s that is not signed with their pinned identity key", msg.senderID)
+	}
+	checkErr := pads.checkSync(msg.senderID, env)
+	var desync padDesyncError
+	if checkErr != nil && !errors.As(checkErr, &desync) {
+		return "", checkErr
+	}
+	if env.handshake != "hello" {
+		return "", checkErr
+	}
+	// The reply carries our refusal too, so both sides stop using the pad
+	reply, err := padSyncLine(encode, pads, id, clientID, msg.senderID, "reply")
+	if err != nil {
+		return "", err
+	}
+	return reply, checkErr
+}
+
+// startPadSync sends the pad sync hello ahead of the first pad-encrypted message to peer this session
+func (m *model) startPadSync(peer string) {
+	if !m.pads.startSync(peer) {
+		return
+	}
+	line, err := padSyncLine(m.payloadEncoder(), m.pads, m.identity, m.clientID, peer, "hello")
+	if err != nil {
+		m.appendMessage(fmt.Sprintf("Error sending pad sync to %s: %v", peer, err))
+		return
+	}
+	m.writeLine(line)
+}
+
+// receivePadSync checks a pad sync handshake from the sender, who must sign it with their pinned
+// identity key, and answers a hello
+func (m *model) receivePadSync(msg incomingMessage, env envelope) {
+	reply, err := answerPadSync(m.payloadEncoder(), m.pads, m.identity, m.knownKeys, m.clientID, msg, env)
+	if reply != "" {
+		m.writeLine(reply)
+	}
+	if err != nil {
+		m.appendMessage(fmt.Sprintf("Warning: %v.", err))
+	}
+}
@@ -0,0 +1,117 @@
+package ui
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/drewwalton19216801/padclient/protocol"
+)
+
+// TestCheckSync compares a peer's pad sync handshake with our copy of the pad and checks that any
+// disagreement marks the pad out of sync, on disk too, so later messages refuse it
+func TestCheckSync(t *testing.T) {
+	tests := []struct {
+		name           string
+		sent, received int64  // Our copy's state
+		padSync        string // The peer's handshake, with "ID" replaced by the pad ID
+		padDesync      string
+		want           string // Substring of the error; "" when the copies agree
+		desync         bool   // Whether the pad is refused from then on
+	}{
+		{"in step", 40, 30, "ID:30:40", "", "", false},
+		{"our messages on their way", 40, 30, "ID:30:20", "", "", false},
+		{"peer would reuse", 40, 30, "ID:20:40", "", "they would reuse pad bytes", true},
+		{"we would reuse", 40, 30, "ID:30:50", "", "we would reuse pad bytes", true},
+		{"missing messages", 40, 30, "ID:35:40", "", "never arrived", true},
+		{"ID mismatch", 40, 30, "0000000000000000:30:40", "", "but pad", true},
+		{"peer refused", 40, 30, "ID:30:40", "bytes were reused", "bob refused it: bytes were reused", true},
+		{"malformed", 40, 30, "ID:x:40", "", "invalid pad sync", false},
+	}
+	for _, tt := range tests {
+		alice, _ := sharePad(t, 256)
+		state := alice.pads["bob"]
+		state.Sent, state.Received = tt.sent, tt.received
+		env := envelope{padSync: strings.Replace(tt.padSync, "ID", state.ID, 1), padDesync: tt.padDesync}
+		err := alice.checkSync("bob", env)
+		if tt.want == "" {
+			if err != nil {
+				t.Errorf("%s: %v", tt.name, err)
+			}
+		} else if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: got %v, want an error containing %q", tt.name, err, tt.want)
+		}
+		var desync padDesyncError
+		if errors.As(err, &desync) != tt.desync {
+			t.Errorf("%s: %v is a padDesyncError: %v, want %v", tt.name, err, !tt.desync, tt.desync)
+		}
+		reloaded, err := loadPads(alice.dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := reloaded.pads["bob"].Desync != ""; got != tt.desync {
+			t.Errorf("%s: saved as out of sync: %v, want %v", tt.name, got, tt.desync)
+		}
+		if _, _, _, _, err := alice.encrypt("bob", []byte("hello")); tt.desync && !errors.As(err, &desync) {
+			t.Errorf("%s: encrypting with the refused pad gave %v", tt.name, err)
+		}
+	}
+}
+
+// TestAnswerPadSync checks that a pad sync handshake is only answered when it is signed by the key
+// pinned for the sender, or by the first key seen from them
+func TestAnswerPadSync(t *testing.T) {
+	bobID, err := newIdentity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	impostor, err := newIdentity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	aliceID, err := newIdentity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name   string
+		signer *identity // nil for an unsigned handshake
+		pin    *identity // Key pinned for bob beforehand, if any
+		want   string    // Substring of the error; "" when a reply is sent
+	}{
+		{"pinned key", bobID, bobID, ""},
+		{"first key", bobID, nil, ""},
+		{"unsigned", nil, nil, "not signed with their pinned identity key"},
+		{"unsigned with a pinned key", nil, bobID, "not signed with their pinned identity key"},
+		{"other key", impostor, bobID, "not signed with their pinned identity key"},
+	}
+	for _, tt := range tests {
+		alice, _ := sharePad(t, 256)
+		keys, err := loadKnownKeys("")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tt.pin != nil {
+			if _, err := keys.check("bob", tt.pin.public); err != nil {
+				t.Fatal(err)
+			}
+		}
+		env := envelope{padSync: fmt.Sprintf("%s:0:0", alice.pads["bob"].ID), handshake: "hello"}
+		content := withMessageID(env.seal())
+		if tt.signer != nil {
+			content = tt.signer.sign("bob", "alice", content)
+		}
+		msg := incomingMessage{senderID: "bob", content: content}
+		reply, err := answerPadSync(protocol.EncodeHex, alice, aliceID, keys, "alice", msg, openEnvelope(content))
+		if tt.want == "" {
+			if err != nil || !strings.HasPrefix(reply, "SEND bob ") {
+				t.Errorf("%s: reply %q, %v", tt.name, reply, err)
+			}
+			continue
+		}
+		if reply != "" || err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: reply %q, error %v, want no reply and an error containing %q", tt.name, reply, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+// peers.go
+// Package ui implements -discover, which lists the tailnet peers with a pad server listening and
+// lets the user pick one instead of typing its Tailscale IP.
+
+package ui
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/x/term"
+)
+
+// discoverPeers lists tailnet peers running a pad server and asks which one to connect to
+var discoverPeers bool
+
+// peerProbeTimeout bounds the dial to each peer's pad server port
+const peerProbeTimeout = 2 * time.Second
+
+// padPeer is a tailnet peer with a pad server listening
+type padPeer struct {
+	name string // MagicDNS name, or the host name when MagicDNS is off
+	ip   string // First Tailscale IP
+}
+
+// findPadPeers asks tailscaled for the online peers and returns those accepting connections on the
+// pad server port, sorted by name
+func findPadPeers() ([]padPeer, error) {
+	if tailscaleSocket == "" {
+		return nil, errors.New("no tailscaled LocalAPI socket on this system; pass -tailscale-socket")
+	}
+	var status tailnetStatus
+	if err := localAPI(http.MethodGet, "/localapi/v0/status", &status); err != nil {
+		return nil, &fatalError{code: exitNotTailscale, err: fmt.Errorf("error asking tailscaled for peers: %v", err)}
+	}
+	if status.BackendState != "Running" {
+		return nil, &fatalError{code: exitNotTailscale, err: fmt.Errorf("Tailscale is not running on this machine (state %s)", status.BackendState)}
+	}
+
+	var (
+		mu    sync.Mutex
+		wg    sync.WaitGroup
+		found []padPeer
+	)
+	for _, peer := range status.Peer {
+		if peer == nil || !peer.Online || len(peer.TailscaleIPs) == 0 {
+			continue
+		}
+		candidate := padPeer{name: strings.TrimSuffix(peer.DNSName, "."), ip: peer.TailscaleIPs[0]}
+		if candidate.name == "" {
+			candidate.name = peer.HostName
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			conn, err := net.DialTimeout("tcp", net.JoinHostPort(candidate.ip, strconv.Itoa(serverPort)), peerProbeTimeout)
+			if err != nil {
+				return
+			}
+			conn.Close()
+			mu.Lock()
+			found = append(found, candidate)
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+	sort.Slice(found, func(i, j int) bool { return found[i].name < found[j].name })
+	return found, nil
+}
+
+// choosePadPeer lists the peers running a pad server and returns the one the user picks on the
+// terminal. A single peer is picked without asking.
+func choosePadPeer() (string, error) {
+	fmt.Fprintf(os.Stderr, "Looking for pad servers on port %d across the tailnet...\n", serverPort)
+	peers, err := findPadPeers()
+	if err != nil {
+		return "", err
+	}
+	switch len(peers) {
+	case 0:
+		return "", &fatalError{code: exitConnect, err: fmt.Errorf("no online tailnet peer accepts connections on port %d", serverPort)}
+	case 1:
+		fmt.Fprintf(os.Stderr, "Found %s (%s).\n", peers[0].name, peers[0].ip)
+		return peers[0].address(), nil
+	}
+	for i, peer := range peers {
+		fmt.Fprintf(os.Stderr, "%3d. %s (%s)\n", i+1, peer.name, peer.ip)
+	}
+	if !term.IsTerminal(os.Stdin.Fd()) {
+		return "", &fatalError{code: exitUsage, err: errors.New("several pad servers found and no terminal to choose one; pass the server instead of -discover")}
+	}
+	fmt.Fprintf(os.Stderr, "Connect to which server? [1-%d] ", len(peers))
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	choice, err := strconv.Atoi(strings.TrimSpace(answer))
+	if err != nil || choice < 1 || choice > len(peers) {
+		return "", &fatalError{code: exitUsage, err: fmt.Errorf("no server %q", strings.TrimSpace(answer))}
+	}
+	return peers[choice-1].address(), nil
+}
+
+// address returns the peer's MagicDNS name when it resolves, and its Tailscale IP otherwise
+func (p padPeer) address() string {
+	if _, err := net.LookupHost(p.name); err == nil {
+		return p.name
+	}
+	return p.ip
+}
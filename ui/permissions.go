@@ -0,0 +1,49 @@
+// permissions.go
+// Package ui remembers which server commands were rejected as operator-only and hides them from non-operators.
+
+package ui
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// unavailableStyle greys out commands the user cannot currently run
+var unavailableStyle = lipgloss.NewStyle().Faint(true)
+
+// recordOperatorOnly remembers that the server rejected a command as operator-only
+func (m *model) recordOperatorOnly(command string) {
+	if command == "" || m.operatorOnly[command] {
+		return
+	}
+	m.operatorOnly[command] = true
+	m.updateSuggestions()
+}
+
+// commandAvailable reports whether the command is worth offering to the user right now
+func (m *model) commandAvailable(command string) bool {
+	return m.isOperator || !m.operatorOnly[command]
+}
+
+// serverCommandHelp returns HELP lines for the server commands, greying out the ones the
+// server has rejected as operator-only while we are not the operator.
+func (m *model) serverCommandHelp() []string {
+	var names []string
+	for name, spec := range knownCommands {
+		if spec.run == nil && name != "SEND" && name != "HELP" && name != "EXIT" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	var lines []string
+	for _, name := range names {
+		line := knownCommands[name].usage
+		if !m.commandAvailable(name) {
+			line = unavailableStyle.Render(fmt.Sprintf("%s (operator only)", line))
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
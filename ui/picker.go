@@ -0,0 +1,207 @@
+// picker.go
+// Package ui implements the fuzzy picker used to choose recipients (Ctrl+T) and other items.
+
+package ui
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// maxRecentSenders is the number of recent senders remembered for the picker
+const maxRecentSenders = 20
+
+// maxPickerResults is the number of candidates rendered in the picker
+const maxPickerResults = 8
+
+// fuzzyPicker holds the state of a fuzzy finder over a list of labels
+type fuzzyPicker struct {
+	active     bool                          // Whether the picker is open
+	title      string                        // Title shown above the query
+	query      string                        // Current filter text
+	candidates []string                      // All selectable labels
+	notes      []string                      // Note shown after each candidate, if any
+	muted      []bool                        // Whether each candidate is shown greyed out
+	matches    []int                         // Indexes of candidates matching the query, best first
+	selected   int                           // Index of the highlighted match
+	onSelect   func(m *model, candidate int) // Called with the chosen candidate index
+}
+
+// openPicker opens a fuzzy picker over the candidates
+func (m *model) openPicker(title string, candidates []string, onSelect func(m *model, candidate int)) {
+	m.picker = fuzzyPicker{
+		active:     true,
+		title:      title,
+		candidates: candidates,
+		onSelect:   onSelect,
+	}
+	m.picker.filter()
+}
+
+// openRecipientPicker opens the picker over the known peers and fills in a SEND command for the choice
+func (m *model) openRecipientPicker() {
+	candidates := m.pickerCandidates()
+	m.openPicker("Pick recipient", candidates, func(m *model, candidate int) {
+		m.input.SetValue(fmt.Sprintf("SEND %s ", candidates[candidate]))
+		m.input.CursorEnd()
+	})
+	// Show each conversation's notification level, greying out muted ones
+	m.picker.notes = make([]string, len(candidates))
+	m.picker.muted = make([]bool, len(candidates))
+	for i, id := range candidates {
+		switch m.notifyLevel(id) {
+		case "none":
+			m.picker.notes[i] = " (muted)"
+			m.picker.muted[i] = true
+		case "mentions":
+			m.picker.notes[i] = " (mentions only)"
+		}
+	}
+}
+
+// pickerCandidates returns the peers the picker can choose from, recent senders first
+func (m *model) pickerCandidates() []string {
+	return m.knownPeers()
+}
+
+// rememberSender moves the sender to the front of the recent senders list
+func (m *model) rememberSender(senderID string) {
+	recent := []string{senderID}
+	for _, id := range m.recentSenders {
+		if id != senderID {
+			recent = append(recent, id)
+		}
+	}
+	if len(recent) > maxRecentSenders {
+		recent = recent[:maxRecentSenders]
+	}
+	m.recentSenders = recent
+	m.peerLastSeen[senderID] = time.Now()
+	m.updateSuggestions()
+}
+
+// updatePicker handles key presses while the picker is open
+func (m *model) updatePicker(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.Type {
+	case tea.KeyEsc, tea.KeyCtrlC, tea.KeyCtrlT:
+		// Close the picker without choosing
+		m.picker.active = false
+	case tea.KeyEnter, tea.KeyTab:
+		// Hand the highlighted candidate to the picker's owner
+		m.picker.active = false
+		if len(m.picker.matches) > 0 {
+			m.picker.onSelect(m, m.picker.matches[m.picker.selected])
+		}
+	case tea.KeyUp, tea.KeyCtrlP:
+		if m.picker.selected > 0 {
+			m.picker.selected--
+		}
+	case tea.KeyDown, tea.KeyCtrlN:
+		if m.picker.selected < min(len(m.picker.matches), maxPickerResults)-1 {
+			m.picker.selected++
+		}
+	case tea.KeyBackspace:
+		if len(m.picker.query) > 0 {
+			runes := []rune(m.picker.query)
+			m.picker.query = string(runes[:len(runes)-1])
+			m.picker.filter()
+		}
+	case tea.KeyRunes, tea.KeySpace:
+		m.picker.query += string(msg.Runes)
+		m.picker.filter()
+	}
+	return m, nil
+}
+
+// filter recomputes the matches for the current query
+func (p *fuzzyPicker) filter() {
+	type scored struct {
+		score int
+		order int
+	}
+	var results []scored
+	for i, label := range p.candidates {
+		score, ok := fuzzyScore(p.query, label)
+		if ok {
+			results = append(results, scored{score: score, order: i})
+		}
+	}
+	// Best score first, keeping candidate order for ties
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].score != results[j].score {
+			return results[i].score > results[j].score
+		}
+		return results[i].order < results[j].order
+	})
+	p.matches = p.matches[:0]
+	for _, r := range results {
+		p.matches = append(p.matches, r.order)
+	}
+	p.selected = 0
+}
+
+// fuzzyScore reports whether query is a case-insensitive subsequence of target,
+// scoring consecutive runs and prefix matches higher.
+func fuzzyScore(query, target string) (int, bool) {
+	q := []rune(strings.ToLower(query))
+	t := []rune(strings.ToLower(target))
+	score := 0
+	qi := 0
+	lastMatch := -1
+	for ti := 0; ti < len(t) && qi < len(q); ti++ {
+		if t[ti] != q[qi] {
+			continue
+		}
+		score++
+		if lastMatch == ti-1 {
+			// Consecutive characters are worth more
+			score += 2
+		}
+		if ti == 0 {
+			// Matching the start of the ID is worth the most
+			score += 3
+		}
+		lastMatch = ti
+		qi++
+	}
+	if qi < len(q) {
+		return 0, false
+	}
+	return score, true
+}
+
+// View renders the picker as a list below the input
+func (p fuzzyPicker) View() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s: %s\n", p.title, p.query)
+	if len(p.matches) == 0 {
+		b.WriteString("  (no matches)")
+		return b.String()
+	}
+	for i, candidate := range p.matches {
+		if i >= maxPickerResults {
+			fmt.Fprintf(&b, "  ... %d more", len(p.matches)-maxPickerResults)
+			break
+		}
+		cursor := "  "
+		if i == p.selected {
+			cursor = "> "
+		}
+		label := p.candidates[candidate]
+		if p.notes != nil {
+			label += p.notes[candidate]
+		}
+		if p.muted != nil && p.muted[candidate] {
+			label = unavailableStyle.Render(label)
+		}
+		b.WriteString(cursor + label)
+		if i < len(p.matches)-1 {
+			b.WriteString("\n")
+		}
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
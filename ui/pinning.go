@@ -0,0 +1,178 @@
+// pinning.go
+// Package ui restricts the client to an allowlist of servers, optionally pinned to the
+// fingerprint of each server's public key, so a mistyped or spoofed address is refused.
+
+package ui
+
+import (
+	"bufio"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/charmbracelet/x/term"
+)
+
+// allowServers is the allow-servers setting: comma-separated server addresses, each optionally
+// followed by "=" and the fingerprint of the server's public key
+var allowServers string
+
+// insecureNewServer allows connecting to a server missing from the allowlist once the user confirms it
+var insecureNewServer bool
+
+// serverPins holds the allowed servers and their pinned fingerprints ("" when only the address is
+// allowed). It is nil when no allowlist is configured, which allows every server.
+var serverPins map[string]string
+
+// serverPinsMu guards serverPins, which connection attempts read and pin to
+var serverPinsMu sync.Mutex
+
+// confirmedNewServer is the server missing from the allowlist that the user confirmed, if any
+var confirmedNewServer string
+
+// parseServerAllowlist parses the allow-servers setting
+func parseServerAllowlist(list string) (map[string]string, error) {
+	pins := make(map[string]string)
+	for _, item := range strings.Split(list, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		host, fingerprint, _ := strings.Cut(item, "=")
+		host, fingerprint = strings.ToLower(strings.TrimSpace(host)), strings.ToLower(strings.TrimSpace(fingerprint))
+		if fingerprint != "" && !validFingerprint(fingerprint) {
+			return nil, fmt.Errorf("invalid fingerprint %q for %s; expected 8 colon-separated hex bytes", fingerprint, host)
+		}
+		pins[host] = fingerprint
+	}
+	return pins, nil
+}
+
+// validFingerprint reports whether s has the form keyFingerprint produces
+func validFingerprint(s string) bool {
+	parts := strings.Split(s, ":")
+	if len(parts) != 8 {
+		return false
+	}
+	for _, part := range parts {
+		if b, err := hex.DecodeString(part); err != nil || len(b) != 1 {
+			return false
+		}
+	}
+	return true
+}
+
+// checkServerAllowed refuses a server missing from the allowlist, unless -insecure-new-server is
+// given and the user confirms it on the terminal. A confirmed server is pinned to the key it
+// presents first, for the rest of the session.
+func checkServerAllowed(server string) error {
+	pins, err := parseServerAllowlist(allowServers)
+	if err != nil {
+		return &fatalError{code: exitUsage, err: fmt.Errorf("allow-servers: %v", err)}
+	}
+	if len(pins) == 0 {
+		return nil
+	}
+	if serverPins == nil {
+		serverPins = pins
+	}
+	if _, ok := pins[strings.ToLower(server)]; ok {
+		return nil
+	}
+	if !insecureNewServer {
+		return &fatalError{code: exitUntrusted, err: fmt.Errorf("%s is not in allow-servers; pass -insecure-new-server to connect anyway", server)}
+	}
+	if !term.IsTerminal(os.Stdin.Fd()) {
+		return &fatalError{code: exitUntrusted, err: fmt.Errorf("no terminal to confirm the new server %s", server)}
+	}
+	fmt.Fprintf(os.Stderr, "%s is not in your allowlist of servers. Connect anyway? [y/N] ", server)
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	if answer = strings.ToLower(strings.TrimSpace(answer)); answer != "y" && answer != "yes" {
+		return &fatalError{code: exitUntrusted, err: fmt.Errorf("did not connect to the new server %s", server)}
+	}
+	confirmedNewServer = strings.ToLower(server)
+	serverPins[confirmedNewServer] = ""
+	return nil
+}
+
+// checkServersAllowed runs checkServerAllowed for each server a subcommand connects to, and for
+// the host the server's DNS records point to, so a record pointing elsewhere cannot lead around
+// the allowlist
+func checkServersAllowed(servers ...string) error {
+	for _, server := range servers {
+		if err := checkServerAllowed(server); err != nil {
+			return err
+		}
+		if found, ok := discoverServer(server); ok && !strings.EqualFold(found.host, server) {
+			if err := checkServerAllowed(found.host); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// addAllowlistFlags adds the server allowlist flags to a subcommand's flag set. Their defaults come
+// from the config file, which main reads before running the subcommand.
+func addAllowlistFlags(flags *flag.FlagSet) {
+	flags.StringVar(&allowServers, "allow-servers", allowServers, "comma-separated servers the client may connect to, each optionally followed by =<key fingerprint> to pin it")
+	flags.BoolVar(&insecureNewServer, "insecure-new-server", insecureNewServer, "allow connecting to a server missing from -allow-servers after confirming it")
+}
+
+// verifyServerKey checks the server's public key against the fingerprint pinned for it and returns
+// the fingerprint. Servers allowed by address only accept any key.
+func verifyServerKey(server, pubKeyHex string) (string, error) {
+	key, err := hex.DecodeString(pubKeyHex)
+	if err != nil {
+		return "", fmt.Errorf("error decoding server's public key: %v", err)
+	}
+	fingerprint := keyFingerprint(key)
+	serverPinsMu.Lock()
+	defer serverPinsMu.Unlock()
+	if serverPins == nil {
+		return fingerprint, nil
+	}
+	pinned, ok := serverPins[strings.ToLower(server)]
+	if !ok {
+		return "", &fatalError{code: exitUntrusted, err: fmt.Errorf("%s is not in allow-servers", server)}
+	}
+	if pinned != "" && pinned != fingerprint {
+		return "", &fatalError{code: exitUntrusted, err: fmt.Errorf("the key of %s has fingerprint %s, not the pinned %s; refusing to connect", server, fingerprint, pinned)}
+	}
+	return fingerprint, nil
+}
+
+// serverPinStatus describes how the server the client is connected to is trusted
+func (m *model) serverPinStatus() string {
+	host := serverHost()
+	serverPinsMu.Lock()
+	defer serverPinsMu.Unlock()
+	switch pinned, ok := serverPins[strings.ToLower(host)]; {
+	case serverPins == nil:
+		return "no allowlist"
+	case !ok:
+		return "not allowed"
+	case strings.ToLower(host) == confirmedNewServer:
+		return "new server, pinned for this session"
+	case pinned == "":
+		return "allowed by address, not pinned"
+	default:
+		return "pinned"
+	}
+}
+
+// pinNewServer pins a server confirmed with -insecure-new-server to the key it presented, so a
+// reconnect to a different key is refused, and returns how to allow it permanently
+func pinNewServer(server, fingerprint string) string {
+	serverPinsMu.Lock()
+	defer serverPinsMu.Unlock()
+	host := strings.ToLower(server)
+	if host != confirmedNewServer || serverPins[host] != "" {
+		return ""
+	}
+	serverPins[host] = fingerprint
+	return fmt.Sprintf("%s is not in your allowlist and is pinned to key %s for this session. To trust it, add %s=%s to allow-servers in your config file.", server, fingerprint, host, fingerprint)
+}
@@ -0,0 +1,154 @@
+// pins.go
+// Package ui lets users pin important messages and view them in a per-conversation pinned panel.
+
+package ui
+
+import (
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/drewwalton19216801/padclient/crypto"
+)
+
+// pinnedMsg carries a pin pushed by a server that supports the PIN extension
+type pinnedMsg struct {
+	conversation string
+	entry        chatEntry
+}
+
+func init() {
+	registerCommand("/pin", commandSpec{
+		usage:   "/pin <n>",
+		help:    "Pin the nth most recent message (1 is the latest)",
+		minArgs: 1,
+		run: func(m *model, args []string) tea.Cmd {
+			entry, err := m.messageByNumber(args[0])
+			if err != nil {
+				m.appendMessage(err.Error())
+				return nil
+			}
+			m.pinEntry(*entry)
+			return nil
+		},
+	})
+	registerCommand("/unpin", commandSpec{
+		usage:   "/unpin <conversation> <i>",
+		help:    "Remove the ith pin from a conversation's pinned panel",
+		minArgs: 2,
+		run: func(m *model, args []string) tea.Cmd {
+			conversation := args[0]
+			i, err := strconv.Atoi(args[1])
+			if err != nil || i < 1 || i > len(m.pins[conversation]) {
+				m.appendMessage(fmt.Sprintf("No pin %s in %s.", args[1], conversation))
+				return nil
+			}
+			m.pins[conversation] = append(m.pins[conversation][:i-1], m.pins[conversation][i:]...)
+			m.appendMessage(fmt.Sprintf("Unpinned message %d from %s.", i, conversation))
+			return nil
+		},
+	})
+	registerCommand("/pins", commandSpec{
+		usage: "/pins [conversation]",
+		help:  "Toggle the pinned messages panel, optionally for one conversation",
+		run: func(m *model, args []string) tea.Cmd {
+			conversation := ""
+			if len(args) > 0 {
+				conversation = args[0]
+			}
+			if m.panel == "pins" && m.pinsConversation != conversation {
+				// Switch conversations without closing the panel
+				m.pinsConversation = conversation
+				return nil
+			}
+			m.pinsConversation = conversation
+			m.togglePanel("pins")
+			return nil
+		},
+	})
+}
+
+// conversation returns the conversation an entry belongs to: the peer ID, ALL for broadcasts,
+// or an empty string for notices.
+func (e chatEntry) conversation() string {
+	switch e.kind {
+	case entryDirect:
+		return e.sender
+	case entryBroadcast:
+		return "ALL"
+	case entryOutgoing:
+		if _, ok := parseExcept(e.recipient); ok {
+			return "ALL"
+		}
+		return e.recipient
+	default:
+		return ""
+	}
+}
+
+// messageByNumber returns the nth most recent chat message, where 1 is the latest
+func (m *model) messageByNumber(arg string) (*chatEntry, error) {
+	n, err := strconv.Atoi(arg)
+	if err != nil || n < 1 {
+		return nil, fmt.Errorf("invalid message number %q", arg)
+	}
+	for i := len(m.entries) - 1; i >= 0; i-- {
+		entry := &m.entries[i]
+		if entry.kind == entrySystem || entry.status == statusCancelled {
+			continue
+		}
+		n--
+		if n == 0 {
+			return entry, nil
+		}
+	}
+	return nil, fmt.Errorf("there is no message %s", arg)
+}
+
+// pinEntry stores a pin locally and shares broadcast pins with servers that support it
+func (m *model) pinEntry(entry chatEntry) {
+	conversation := entry.conversation()
+	m.pins[conversation] = append(m.pins[conversation], entry)
+	m.appendMessage(fmt.Sprintf("Pinned to %s: %s", conversation, entry.content))
+
+	// Only broadcast pins are shared, since the server can already read broadcasts
+	if conversation == "ALL" && m.serverCaps["PIN"] {
+		encrypted, err := crypto.EncryptAES(m.hashedSecret, []byte(entry.render()))
+		if err != nil {
+			m.appendMessage(fmt.Sprintf("Error encrypting pin: %v", err))
+			return
+		}
+		m.writeLine(fmt.Sprintf("PIN ALL %s", hex.EncodeToString(encrypted)))
+	}
+}
+
+// pinsView renders the pinned panel for the selected conversation, or all conversations
+func (m *model) pinsView() string {
+	var b strings.Builder
+	title := "Pinned messages"
+	if m.pinsConversation != "" {
+		title += " in " + m.pinsConversation
+	}
+	b.WriteString(title + " (/pins to close):")
+	var conversations []string
+	for conversation := range m.pins {
+		if m.pinsConversation == "" || conversation == m.pinsConversation {
+			conversations = append(conversations, conversation)
+		}
+	}
+	sort.Strings(conversations)
+	count := 0
+	for _, conversation := range conversations {
+		for i, entry := range m.pins[conversation] {
+			fmt.Fprintf(&b, "\n  %s #%d [%s] %s", conversation, i+1, entry.at.Format("15:04"), entry.render())
+			count++
+		}
+	}
+	if count == 0 {
+		b.WriteString("\n  (nothing pinned)")
+	}
+	return b.String()
+}
@@ -0,0 +1,159 @@
+// polls.go
+// Package ui runs lightweight polls: a poll is a message whose envelope lists the options, and votes
+// are envelopes naming the poll that are tallied instead of shown.
+
+package ui
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// maxPollOptions is the most options a poll can have, one per Alt+digit key
+const maxPollOptions = 9
+
+// poll is a poll seen in this session and its votes so far
+type poll struct {
+	id           string
+	question     string
+	options      []string
+	conversation string         // Where votes are sent
+	votes        map[string]int // Chosen option (from 1) by voter
+}
+
+func init() {
+	registerCommand("/poll", commandSpec{
+		usage:   `/poll "question" <option> <option> [option...]`,
+		help:    "Ask a question with up to 9 options; peers vote with Alt+1 to Alt+9",
+		minArgs: 3,
+		run: func(m *model, args []string) tea.Cmd {
+			fields := splitQuoted(strings.Join(args, " "))
+			if len(fields) < 3 || len(fields) > maxPollOptions+1 {
+				m.appendMessage(fmt.Sprintf("A poll needs a question and 2 to %d options.", maxPollOptions))
+				return nil
+			}
+			id := make([]byte, 6)
+			if _, err := rand.Read(id); err != nil {
+				m.appendMessage(fmt.Sprintf("Error creating poll: %v", err))
+				return nil
+			}
+			p := m.addPoll(hex.EncodeToString(id), fields[0], fields[1:], "ALL")
+			return m.queueSend("ALL", envelope{body: p.question, poll: p.id, options: p.options}.seal())
+		},
+	})
+	registerCommand("/vote", commandSpec{
+		usage:   "/vote <option>",
+		help:    "Vote in the most recent poll",
+		minArgs: 1,
+		run: func(m *model, args []string) tea.Cmd {
+			choice, err := strconv.Atoi(args[0])
+			if err != nil {
+				m.appendMessage(fmt.Sprintf("Invalid option %q.", args[0]))
+				return nil
+			}
+			m.vote(choice)
+			return nil
+		},
+	})
+}
+
+// splitQuoted splits text into fields at spaces, keeping double-quoted runs together
+func splitQuoted(text string) []string {
+	var fields []string
+	var current strings.Builder
+	quoted, inField := false, false
+	for _, r := range text {
+		switch {
+		case r == '"':
+			quoted = !quoted
+			inField = true
+		case r == ' ' && !quoted:
+			if inField {
+				fields = append(fields, current.String())
+				current.Reset()
+				inField = false
+			}
+		default:
+			current.WriteRune(r)
+			inField = true
+		}
+	}
+	if inField {
+		fields = append(fields, current.String())
+	}
+	return fields
+}
+
+// addPoll records a poll, keeping the existing record if the poll is already known
+func (m *model) addPoll(id, question string, options []string, conversation string) *poll {
+	if p, ok := m.polls[id]; ok {
+		return p
+	}
+	p := &poll{id: id, question: question, options: options, conversation: conversation, votes: make(map[string]int)}
+	m.polls[id] = p
+	m.latestPoll = id
+	return p
+}
+
+// vote casts our vote in the most recent poll and sends it to the poll's conversation
+func (m *model) vote(choice int) {
+	p, ok := m.polls[m.latestPoll]
+	if !ok {
+		m.appendMessage("There is no poll to vote in.")
+		return
+	}
+	if choice < 1 || choice > len(p.options) {
+		m.appendMessage(fmt.Sprintf("The poll has options 1 to %d.", len(p.options)))
+		return
+	}
+	// Votes skip the outbox, since they are tallied rather than shown as messages
+	line, _, err := m.encodeSend(p.conversation, envelope{body: strconv.Itoa(choice), vote: p.id}.seal())
+	if err != nil {
+		m.appendMessage(fmt.Sprintf("Error: %v", err))
+		return
+	}
+	if m.writeLine(line) {
+		m.recordVote(p.id, p.conversation, m.clientID, choice)
+		m.flash = fmt.Sprintf("Voted for %q", p.options[choice-1])
+	}
+}
+
+// recordVote tallies a voter's choice, replacing any earlier vote of theirs. A vote must arrive in
+// the conversation the poll was asked in, so a direct message cannot stuff a broadcast poll.
+func (m *model) recordVote(id, conversation, voter string, choice int) {
+	p, ok := m.polls[id]
+	if !ok || conversation != p.conversation || choice < 1 || choice > len(p.options) {
+		return
+	}
+	p.votes[voter] = choice
+	m.requestRender(false)
+}
+
+// pollTally renders the live results shown under a poll
+func (m *model) pollTally(id string) string {
+	p, ok := m.polls[id]
+	if !ok {
+		return ""
+	}
+	counts := make([]int, len(p.options))
+	for _, choice := range p.votes {
+		counts[choice-1]++
+	}
+	var b strings.Builder
+	for i, option := range p.options {
+		marker := " "
+		if p.votes[m.clientID] == i+1 {
+			marker = "*"
+		}
+		fmt.Fprintf(&b, "\n  %s%d. %s — %d vote(s)", marker, i+1, option, counts[i])
+	}
+	if id == m.latestPoll {
+		fmt.Fprintf(&b, "\n  (Alt+1 to Alt+%d to vote)", len(p.options))
+	}
+	return b.String()
+}
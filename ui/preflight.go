@@ -0,0 +1,222 @@
+// preflight.go
+// Package ui asks the local Tailscale daemon, through its LocalAPI, whether the server is
+// reachable on the tailnet before dialing, and explains dial timeouts caused by ACLs.
+
+package ui
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// allowNonTailscale lets the client connect without Tailscale, e.g. over another private overlay
+var allowNonTailscale bool
+
+// nonTailscaleWarning is shown while connected without the Tailscale check
+const nonTailscaleWarning = "WARNING: -allow-non-tailscale is set. The connection is not checked to run over Tailscale, so only the client's own encryption protects it and the server may be reachable by anyone on its network."
+
+// allLoopback reports whether every server is on this machine, which needs no tailnet
+func allLoopback(servers []string) bool {
+	for _, server := range servers {
+		if !isLoopbackHost(server) {
+			return false
+		}
+	}
+	return len(servers) > 0
+}
+
+// tailscaleSocket is the LocalAPI socket of tailscaled (empty skips the preflight)
+var tailscaleSocket = defaultTailscaleSocket()
+
+// localAPITimeout bounds each LocalAPI request, so a wedged daemon cannot delay connecting
+const localAPITimeout = 5 * time.Second
+
+// tailnetStatus is the part of the LocalAPI status the preflight needs
+type tailnetStatus struct {
+	BackendState string                  // "Running" once logged in and connected
+	Self         *tailnetPeer            // This machine
+	Peer         map[string]*tailnetPeer // Peers in this machine's network map, by public key
+}
+
+// tailnetPeer is a machine in the network map
+type tailnetPeer struct {
+	HostName     string
+	DNSName      string
+	TailscaleIPs []string
+	Online       bool
+}
+
+// tailnetPing is the LocalAPI's answer to a ping
+type tailnetPing struct {
+	Err            string
+	LatencySeconds float64
+}
+
+// defaultTailscaleSocket returns where tailscaled listens for LocalAPI requests on this system.
+// Windows serves the LocalAPI on a named pipe, which the preflight does not use.
+func defaultTailscaleSocket() string {
+	switch runtime.GOOS {
+	case "linux", "freebsd", "openbsd":
+		return "/var/run/tailscale/tailscaled.sock"
+	case "darwin":
+		return "/var/run/tailscaled.socket"
+	default:
+		return ""
+	}
+}
+
+// localAPI makes a LocalAPI request and decodes the JSON answer into out
+func localAPI(method, path string, out any) error {
+	client := &http.Client{
+		Timeout: localAPITimeout,
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var dialer net.Dialer
+				return dialer.DialContext(ctx, "unix", tailscaleSocket)
+			},
+		},
+	}
+	// tailscaled only answers requests addressed to this host name
+	request, err := http.NewRequest(method, "http://local-tailscaled.sock"+path, nil)
+	if err != nil {
+		return err
+	}
+	response, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("LocalAPI %s: %s", path, response.Status)
+	}
+	return json.NewDecoder(response.Body).Decode(out)
+}
+
+// findPeer returns the machine in the network map with the given Tailscale IP, MagicDNS name, or host name
+func (s *tailnetStatus) findPeer(host string) *tailnetPeer {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	peers := []*tailnetPeer{s.Self}
+	for _, peer := range s.Peer {
+		peers = append(peers, peer)
+	}
+	for _, peer := range peers {
+		if peer == nil {
+			continue
+		}
+		if containsString(peer.TailscaleIPs, host) {
+			return peer
+		}
+		dnsName := strings.ToLower(strings.TrimSuffix(peer.DNSName, "."))
+		if dnsName == host || strings.EqualFold(peer.HostName, host) || strings.HasPrefix(dnsName, host+".") {
+			return peer
+		}
+	}
+	return nil
+}
+
+// preflightTailnet checks that the server is a peer this machine can reach before dialing it. When
+// the LocalAPI cannot be reached, as when tailscaled runs elsewhere, or with -tsnet, the check is
+// skipped.
+func preflightTailnet(host string) error {
+	if tailscaleSocket == "" || useTsnet {
+		return nil
+	}
+	if _, err := os.Stat(tailscaleSocket); err != nil {
+		return nil
+	}
+	var status tailnetStatus
+	if err := localAPI(http.MethodGet, "/localapi/v0/status", &status); err != nil {
+		return nil
+	}
+	if status.BackendState != "Running" {
+		return &fatalError{code: exitNotTailscale, err: fmt.Errorf("Tailscale is not running on this machine (state %s)", status.BackendState)}
+	}
+	peer := status.findPeer(host)
+	if peer == nil && net.ParseIP(host) == nil {
+		// A name served by regular DNS rather than MagicDNS
+		if addrs, err := net.LookupHost(host); err == nil {
+			for _, addr := range addrs {
+				if peer = status.findPeer(addr); peer != nil {
+					break
+				}
+			}
+			if peer == nil && !anyTailnetAddress(addrs) {
+				return nil
+			}
+		}
+	}
+	if peer == nil {
+		if ip := net.ParseIP(host); ip != nil && !isTailnetAddress(ip) {
+			// Not a tailnet address, so the network map says nothing about it
+			return nil
+		}
+		return &fatalError{code: exitConnect, err: fmt.Errorf("%s is not in this machine's tailnet map: it is not on the tailnet, or the tailnet ACLs do not allow this machine to reach it", host)}
+	}
+	if peer != status.Self && !peer.Online {
+		return &fatalError{code: exitConnect, err: fmt.Errorf("%s is on the tailnet but offline", host)}
+	}
+	return nil
+}
+
+// tailnetRanges hold the addresses Tailscale assigns to machines
+var tailnetRanges = []*net.IPNet{
+	{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)},
+	{IP: net.ParseIP("fd7a:115c:a1e0::"), Mask: net.CIDRMask(48, 128)},
+}
+
+// isTailnetAddress reports whether ip is in a range Tailscale assigns to machines
+func isTailnetAddress(ip net.IP) bool {
+	for _, r := range tailnetRanges {
+		if r.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// anyTailnetAddress reports whether any of the addresses is a tailnet address
+func anyTailnetAddress(addrs []string) bool {
+	for _, addr := range addrs {
+		if ip := net.ParseIP(addr); ip != nil && isTailnetAddress(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// explainDialError turns a dial timeout into an ACL diagnosis when the server's machine still
+// answers Tailscale pings, which means the tailnet drops packets to the server's port
+func explainDialError(host string, port int, err error) error {
+	var netErr net.Error
+	if !errors.As(err, &netErr) || !netErr.Timeout() || tailscaleSocket == "" || useTsnet {
+		return err
+	}
+	ip := host
+	if net.ParseIP(host) == nil {
+		var status tailnetStatus
+		if localAPI(http.MethodGet, "/localapi/v0/status", &status) != nil {
+			return err
+		}
+		peer := status.findPeer(host)
+		if peer == nil || len(peer.TailscaleIPs) == 0 {
+			return err
+		}
+		ip = peer.TailscaleIPs[0]
+	}
+	// TSMP pings are answered by the peer's tailscaled, below its packet filter
+	var pong tailnetPing
+	query := url.Values{"ip": {ip}, "type": {"TSMP"}}
+	if localAPI(http.MethodPost, "/localapi/v0/ping?"+query.Encode(), &pong) != nil || pong.Err != "" {
+		return err
+	}
+	return &fatalError{code: exitConnect, err: fmt.Errorf("blocked by ACL: %s answers Tailscale pings but port %d timed out, so the tailnet ACLs probably do not allow this machine to reach that port (%v)", host, port, err)}
+}
@@ -0,0 +1,87 @@
+// presence.go
+// Package ui turns presence pushes from the server into join/part notices and roster updates.
+
+package ui
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// presenceMsg reports a client joining or leaving the server
+type presenceMsg struct {
+	clientID string
+	joined   bool
+}
+
+func init() {
+	registerCommand("/joins", commandSpec{
+		usage:   "/joins on|off [conversation]",
+		help:    "Show or suppress join/part notices, for everyone or one peer",
+		minArgs: 1,
+		run: func(m *model, args []string) tea.Cmd {
+			conversation := "*"
+			if len(args) > 1 {
+				conversation = args[1]
+			}
+			switch args[0] {
+			case "on":
+				if conversation == "*" {
+					m.presenceMuted = make(map[string]bool)
+				} else {
+					m.presenceMuted[conversation] = false
+				}
+			case "off":
+				m.presenceMuted[conversation] = true
+			default:
+				m.appendMessage("Usage: " + knownCommands["/joins"].usage)
+				return nil
+			}
+			m.syncMuteSettings()
+			m.appendMessage(fmt.Sprintf("Join/part notices %s for %s.", args[0], describeConversation(conversation)))
+			return nil
+		},
+	})
+}
+
+// parsePresence recognizes presence pushes: "PRESENCE JOIN|LEAVE <ID>", "JOINED <ID>", or "LEFT <ID>"
+func parsePresence(line string) (presenceMsg, bool) {
+	fields := strings.Fields(line)
+	switch {
+	case len(fields) >= 3 && fields[0] == "PRESENCE" && (fields[1] == "JOIN" || fields[1] == "LEAVE"):
+		return presenceMsg{clientID: fields[2], joined: fields[1] == "JOIN"}, true
+	case len(fields) >= 2 && (fields[0] == "JOINED" || fields[0] == "LEFT"):
+		return presenceMsg{clientID: fields[1], joined: fields[0] == "JOINED"}, true
+	}
+	return presenceMsg{}, false
+}
+
+// presenceNoticesMuted reports whether join/part notices are suppressed for a peer
+func (m *model) presenceNoticesMuted(clientID string) bool {
+	if muted, ok := m.presenceMuted[clientID]; ok {
+		return muted
+	}
+	return m.presenceMuted["*"]
+}
+
+// applyPresence updates the roster and shows a join/part notice unless suppressed
+func (m *model) applyPresence(msg presenceMsg) {
+	now := time.Now()
+	if msg.joined {
+		m.mergeClient(ClientInfo{ID: msg.clientID, ConnectedAt: now, UpdatedAt: now})
+	} else {
+		delete(m.roster, msg.clientID)
+		m.updateSuggestions()
+	}
+	if m.presenceNoticesMuted(msg.clientID) {
+		return
+	}
+	if msg.joined {
+		m.appendMessage(fmt.Sprintf("%s joined", msg.clientID))
+	} else {
+		m.appendMessage(fmt.Sprintf("%s left", msg.clientID))
+	}
+}
@@ -0,0 +1,51 @@
+// profile.go
+// Package ui exposes optional CPU profiling and a pprof endpoint for diagnosing performance.
+
+package ui
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	_ "net/http/pprof" // Registers the /debug/pprof handlers on the default mux
+	"os"
+	"runtime/pprof"
+)
+
+var (
+	cpuProfilePath string // File to write a CPU profile to while the client runs
+	pprofAddress   string // Address to serve /debug/pprof on
+)
+
+// startProfiling starts the requested profilers and returns a function that stops them
+func startProfiling() (func(), error) {
+	stop := func() {}
+	if cpuProfilePath != "" {
+		file, err := os.Create(cpuProfilePath)
+		if err != nil {
+			return nil, fmt.Errorf("error creating CPU profile: %v", err)
+		}
+		if err := pprof.StartCPUProfile(file); err != nil {
+			file.Close()
+			return nil, fmt.Errorf("error starting CPU profile: %v", err)
+		}
+		stop = func() {
+			pprof.StopCPUProfile()
+			file.Close()
+		}
+	}
+	if pprofAddress != "" {
+		listener, err := net.Listen("tcp", pprofAddress)
+		if err != nil {
+			stop()
+			return nil, fmt.Errorf("error starting pprof endpoint: %v", err)
+		}
+		go http.Serve(listener, nil)
+		stopCPU := stop
+		stop = func() {
+			listener.Close()
+			stopCPU()
+		}
+	}
+	return stop, nil
+}
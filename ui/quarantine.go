@@ -0,0 +1,143 @@
+// quarantine.go
+// Package ui keeps undecryptable messages in a quarantine where they can be retried or discarded.
+
+package ui
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// integrityFailureMsg reports an incoming message that could not be decoded or decrypted
+type integrityFailureMsg struct {
+	source   string // MESSAGE, BROADCAST, or PINNED
+	senderID string
+	payload  string // Encrypted data as received
+	err      error
+}
+
+// quarantinedMessage is an undecryptable message held for review
+type quarantinedMessage struct {
+	at       time.Time
+	source   string
+	senderID string
+	payload  string
+	reason   string
+}
+
+func init() {
+	registerCommand("/quarantine", commandSpec{
+		usage: "/quarantine [retry [i] | discard <i|all>]",
+		help:  "Review, retry, or discard messages that failed to decrypt",
+		run: func(m *model, args []string) tea.Cmd {
+			switch {
+			case len(args) == 0:
+				m.togglePanel("quarantine")
+			case args[0] == "retry" && len(args) <= 2:
+				if len(args) == 1 {
+					return m.retryQuarantine(-1)
+				}
+				i, err := strconv.Atoi(args[1])
+				if err != nil || i < 1 || i > len(m.quarantine) {
+					m.appendMessage(fmt.Sprintf("No quarantined message %s.", args[1]))
+					return nil
+				}
+				return m.retryQuarantine(i - 1)
+			case args[0] == "discard" && len(args) == 2 && args[1] == "all":
+				m.appendMessage(fmt.Sprintf("Discarded %d quarantined message(s).", len(m.quarantine)))
+				m.quarantine = nil
+			case args[0] == "discard" && len(args) == 2:
+				i, err := strconv.Atoi(args[1])
+				if err != nil || i < 1 || i > len(m.quarantine) {
+					m.appendMessage(fmt.Sprintf("No quarantined message %s.", args[1]))
+					return nil
+				}
+				m.quarantine = append(m.quarantine[:i-1], m.quarantine[i:]...)
+				m.appendMessage(fmt.Sprintf("Discarded quarantined message %d.", i))
+			default:
+				m.appendMessage("Usage: " + knownCommands["/quarantine"].usage)
+			}
+			return nil
+		},
+	})
+}
+
+// quarantineMessage holds an undecryptable message and points the user at the quarantine
+func (m *model) quarantineMessage(msg integrityFailureMsg) {
+	m.integrityFailures++
+	m.quarantine = append(m.quarantine, quarantinedMessage{
+		at:       time.Now(),
+		source:   msg.source,
+		senderID: msg.senderID,
+		payload:  msg.payload,
+		reason:   msg.err.Error(),
+	})
+	m.flash = fmt.Sprintf("Quarantined an undecryptable message%s. /quarantine to review.", describeSender(msg.senderID))
+}
+
+// describeSender returns " from <ID>" for a known sender
+func describeSender(senderID string) string {
+	if senderID == "" {
+		return ""
+	}
+	return " from " + senderID
+}
+
+// retryQuarantine decrypts the ith quarantined message again with the current keys, or every
+// quarantined message when i is negative. Messages that now decrypt are delivered.
+func (m *model) retryQuarantine(i int) tea.Cmd {
+	var cmds []tea.Cmd
+	var kept []quarantinedMessage
+	recovered := 0
+	for j, held := range m.quarantine {
+		if i >= 0 && j != i {
+			kept = append(kept, held)
+			continue
+		}
+		if held.source == "PINNED" {
+			content, err := decodePinned(held.payload, m.hashedSecret)
+			if err != nil {
+				held.reason = err.Error()
+				kept = append(kept, held)
+				continue
+			}
+			m.pins["ALL"] = append(m.pins["ALL"], chatEntry{kind: entrySystem, content: content, at: held.at})
+			recovered++
+			continue
+		}
+		msg, err := decodeMessage(held.source, held.senderID, m.clientID, held.payload, m.hashedSecret, m.pads)
+		if err != nil {
+			held.reason = err.Error()
+			kept = append(kept, held)
+			continue
+		}
+		recovered++
+		if msg.filter = m.filters.match(msg); msg.filter != nil && msg.filter.action == filterHide {
+			continue
+		}
+		cmds = append(cmds, m.receiveMessage(msg))
+	}
+	m.quarantine = kept
+	m.appendMessage(fmt.Sprintf("Recovered %d quarantined message(s); %d remain.", recovered, len(kept)))
+	return tea.Batch(cmds...)
+}
+
+// quarantineView renders the quarantined messages
+func (m *model) quarantineView() string {
+	lines := []string{"Quarantine (/quarantine retry [i] | discard <i|all>, /quarantine to close):"}
+	if len(m.quarantine) == 0 {
+		lines = append(lines, "  (empty)")
+	}
+	for i, held := range m.quarantine {
+		if i == maxPanelEntries {
+			lines = append(lines, fmt.Sprintf("  ... %d more", len(m.quarantine)-maxPanelEntries))
+			break
+		}
+		lines = append(lines, fmt.Sprintf("  %d. [%s] %s%s: %s", i+1, held.at.Format("15:04"), held.source, describeSender(held.senderID), held.reason))
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,67 @@
+// reconnect.go
+// Package ui reconnects after an unexpected disconnect, backing off exponentially between attempts
+// and holding outgoing messages until the connection is back.
+
+package ui
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// reconnectBaseDelay is the wait before the first reconnect attempt; it doubles with each attempt
+const reconnectBaseDelay = time.Second
+
+// reconnectMaxDelay caps the wait between reconnect attempts
+const reconnectMaxDelay = time.Minute
+
+// reconnectDisabled makes the client exit on an unexpected disconnect instead of reconnecting
+var reconnectDisabled bool
+
+// maxReconnectAttempts is how many reconnect attempts are made before giving up (0 for no limit)
+var maxReconnectAttempts = 10
+
+// reconnectDelay returns the wait before the given attempt (1 for the first): the base delay
+// doubled for each earlier attempt, capped, with up to 20% random jitter so clients dropped
+// together do not all redial at once
+func reconnectDelay(attempt int) time.Duration {
+	delay := reconnectMaxDelay
+	if shift := attempt - 1; shift < 16 {
+		delay = min(reconnectBaseDelay<<shift, reconnectMaxDelay)
+	}
+	return delay + time.Duration(rand.Int63n(int64(delay)/5+1))
+}
+
+// reconnecting reports whether the client is recovering from an unexpected disconnect
+func (m *model) reconnecting() bool {
+	return m.reconnectAttempts > 0
+}
+
+// scheduleReconnect holds the outbox and schedules the next reconnect attempt, or gives up
+func (m *model) scheduleReconnect() tea.Cmd {
+	if reconnectDisabled {
+		return tea.Quit
+	}
+	if maxReconnectAttempts > 0 && m.reconnectAttempts >= maxReconnectAttempts {
+		m.appendMessage(fmt.Sprintf("Could not reconnect after %d attempts. Giving up.", m.reconnectAttempts))
+		m.exitErr = &fatalError{code: exitConnect, err: errors.New("could not reconnect to the server")}
+		return tea.Quit
+	}
+	m.conn = nil
+	m.holdOutbox = true
+	m.reconnectAttempts++
+	m.reconnects++
+	delay := reconnectDelay(m.reconnectAttempts)
+	m.appendMessage(fmt.Sprintf("Retrying in %s. Outgoing messages are held until the connection is back.", delay.Round(100*time.Millisecond)))
+	return tea.Tick(delay, func(time.Time) tea.Msg { return reconnectMsg{} })
+}
+
+// resumeAfterReconnect resets the backoff and sends held messages whose undo window has passed
+func (m *model) resumeAfterReconnect() {
+	m.reconnectAttempts = 0
+	m.releaseOutbox()
+}
@@ -0,0 +1,173 @@
+// rekey.go
+// Package ui rotates the shared secret with the server through a fresh key exchange, and restarts
+// the key agreement with a peer.
+
+package ui
+
+import (
+	"crypto/ecdh"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/drewwalton19216801/padclient/crypto"
+)
+
+// rekeyTimeout bounds how long outgoing messages are held waiting for the server to switch keys
+const rekeyTimeout = 15 * time.Second
+
+// rekeyOfferMsg carries a fresh public key sent by the server to start a key exchange
+type rekeyOfferMsg struct {
+	pubKeyHex string
+}
+
+// rekeyedMsg reports that the server switched to the new shared secret
+type rekeyedMsg struct{}
+
+// rekeyTimeoutMsg fires when the server has not confirmed a key exchange in time
+type rekeyTimeoutMsg struct{}
+
+// sessionKey is the shared secret used with the server, shared between the UI and the message reader.
+// A new key is staged when the exchange starts and committed once the server confirms it.
+type sessionKey struct {
+	mu      sync.RWMutex
+	current []byte
+	pending []byte
+}
+
+func init() {
+	registerCommand("/rekey", commandSpec{
+		usage:   "/rekey <ID|ALL>",
+		help:    "Rotate encryption keys with a peer or the server",
+		minArgs: 1,
+		run: func(m *model, args []string) tea.Cmd {
+			if !strings.EqualFold(args[0], "ALL") {
+				m.rekeyPeer(args[0])
+				return nil
+			}
+			if !m.serverCaps["REKEY"] {
+				m.appendMessage("The server does not support key rotation. Reconnect to negotiate a fresh shared secret.")
+				return nil
+			}
+			if m.rekeying {
+				m.appendMessage("A key exchange is already in progress.")
+				return nil
+			}
+			if !m.writeLine("REKEY") {
+				return nil
+			}
+			m.rekeying = true
+			m.appendMessage("Requested a fresh key exchange with the server. Outgoing messages are held until both sides have switched.")
+			return rekeyTimer()
+		},
+	})
+}
+
+// rekeyPeer starts a new key agreement with peer. The agreed key is derived from both identity
+// keys, so the handshake yields the same key unless one of them changed; it brings back a peer that
+// restarted or lost the key. Messages keep using the current key until the peer answers.
+func (m *model) rekeyPeer(peer string) {
+	switch {
+	case peer == m.clientID:
+		m.appendMessage("There is no key to agree with yourself.")
+	case m.pads.has(peer):
+		m.appendMessage(fmt.Sprintf("Direct messages to %s use the pad you share, whose bytes are never reused, so there is no key to rotate.", peer))
+	case dmKeys == nil:
+		m.appendMessage("Key agreement is not available without an identity key.")
+	default:
+		m.sendKeyHandshake(peer, "hello")
+		m.appendMessage(fmt.Sprintf("Started a new key agreement with %s. Messages to them use the agreed key once they answer; older clients keep getting one-time keys.", peer))
+	}
+}
+
+// newSessionKey returns a session key holding the secret negotiated at connect
+func newSessionKey(secret []byte) *sessionKey {
+	return &sessionKey{current: secret}
+}
+
+// get returns the key currently in use
+func (k *sessionKey) get() []byte {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	return k.current
+}
+
+// stage sets the key to switch to once the server confirms the exchange
+func (k *sessionKey) stage(secret []byte) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.pending = secret
+}
+
+// commit switches to the staged key, reporting whether there was one
+func (k *sessionKey) commit() bool {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if k.pending == nil {
+		return false
+	}
+	k.current, k.pending = k.pending, nil
+	return true
+}
+
+// rekeyTimer waits for the server to confirm a key exchange
+func rekeyTimer() tea.Cmd {
+	return tea.Tick(rekeyTimeout, func(time.Time) tea.Msg {
+		return rekeyTimeoutMsg{}
+	})
+}
+
+// answerRekey completes the client side of a key exchange started by the server's public key.
+// Outgoing messages are held until the server confirms it switched.
+func (m *model) answerRekey(offer rekeyOfferMsg) tea.Cmd {
+	clientPrivKey, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		m.appendMessage(fmt.Sprintf("Error generating ECDH key: %v", err))
+		return nil
+	}
+	secret, err := crypto.SharedKey(clientPrivKey, offer.pubKeyHex)
+	if err != nil {
+		m.appendMessage(fmt.Sprintf("Key exchange failed: %v", err))
+		return nil
+	}
+	m.keys.stage(secret)
+	if !m.writeLine("CLIENTPUBKEY") || !m.writeLine(hex.EncodeToString(clientPrivKey.PublicKey().Bytes())) || !m.writeLine("END CLIENTPUBKEY") {
+		m.keys.stage(nil)
+		return nil
+	}
+	m.holdOutbox = true
+	if m.rekeying {
+		// The timer is already running for a rotation we asked for
+		return nil
+	}
+	m.rekeying = true
+	return rekeyTimer()
+}
+
+// finishRekey switches to the new shared secret and releases held messages
+func (m *model) finishRekey() {
+	previous := m.hashedSecret
+	m.hashedSecret = m.keys.get()
+	m.rekeying = false
+	if m.shutdownAt.IsZero() {
+		m.releaseOutbox()
+	}
+	m.appendMessage(fmt.Sprintf("Shared secret rotated: key %s replaced by %s.", keyFingerprint(previous), keyFingerprint(m.hashedSecret)))
+}
+
+// abandonRekey gives up on a key exchange the server never confirmed
+func (m *model) abandonRekey() {
+	if !m.rekeying {
+		return
+	}
+	m.keys.stage(nil)
+	m.rekeying = false
+	if m.shutdownAt.IsZero() {
+		m.releaseOutbox()
+	}
+	m.appendMessage(fmt.Sprintf("The server did not confirm the key exchange within %s; still using key %s.", rekeyTimeout, keyFingerprint(m.hashedSecret)))
+}
@@ -0,0 +1,98 @@
+// relay.go
+// Package ui relays mentions from a daemon to the user's interactive client on another device,
+// and drops the copy of an alert the interactive client has already shown.
+
+package ui
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"time"
+)
+
+// relayDedupWindow is how long a relayed alert and the message it relays are matched up
+const relayDedupWindow = 10 * time.Minute
+
+// relayer forwards messages that mention the user to their interactive client
+type relayer struct {
+	to    string               // Client ID of the user's interactive client
+	words []string             // Lowercase words that make a message an alert
+	sent  map[string]time.Time // Alerts already relayed, by message key
+}
+
+// newRelayer relays messages mentioning to, or any of the comma-separated words, to the client to
+func newRelayer(to, words string) *relayer {
+	r := &relayer{to: to, words: []string{strings.ToLower(to)}, sent: make(map[string]time.Time)}
+	for _, word := range strings.Split(words, ",") {
+		if word = strings.ToLower(strings.TrimSpace(word)); word != "" {
+			r.words = append(r.words, word)
+		}
+	}
+	return r
+}
+
+// messageKey identifies a message by its sender and text: the first 6 bytes of their SHA-256
+// digest in hex. Thread replies and relayed alerts refer to messages by this key.
+func messageKey(sender, content string) string {
+	sum := sha256.Sum256([]byte(sender + "\x00" + content))
+	return hex.EncodeToString(sum[:6])
+}
+
+// alert returns the sealed DM relaying a message, if it is an alert that was not relayed yet
+func (r *relayer) alert(sender string, env envelope) (string, bool) {
+	if sender == r.to || env.relay != "" {
+		// The user saw it already, or it is another relay's alert
+		return "", false
+	}
+	lower := strings.ToLower(env.body)
+	matched := false
+	for _, word := range r.words {
+		if strings.Contains(lower, word) {
+			matched = true
+			break
+		}
+	}
+	if !matched {
+		return "", false
+	}
+	now := time.Now()
+	pruneSeen(r.sent, now)
+	key := messageKey(sender, env.body)
+	if _, ok := r.sent[key]; ok {
+		return "", false
+	}
+	r.sent[key] = now
+	return envelope{body: env.body, forwardedFrom: sender, relay: key}.seal(), true
+}
+
+// duplicateAlert reports whether a message was already shown, either directly or as an alert
+// relayed from one of the user's own daemons, so it is not shown twice
+func (m *model) duplicateAlert(sender string, env envelope) bool {
+	now := time.Now()
+	pruneSeen(m.seenMessages, now)
+	pruneSeen(m.seenRelays, now)
+	if env.relay != "" {
+		_, shown := m.seenMessages[env.relay]
+		_, relayed := m.seenRelays[env.relay]
+		m.seenRelays[env.relay] = now
+		return shown || relayed
+	}
+	key := messageKey(sender, env.body)
+	m.seenMessages[key] = now
+	if _, relayed := m.seenRelays[key]; relayed {
+		// The alert arrived first; forget it so a genuine repeat is shown
+		delete(m.seenRelays, key)
+		return true
+	}
+	return false
+}
+
+// pruneSeen forgets keys seen longer ago than the dedup window
+func pruneSeen(seen map[string]time.Time, now time.Time) {
+	for key, at := range seen {
+		if now.Sub(at) > relayDedupWindow {
+			delete(seen, key)
+		}
+	}
+}
@@ -0,0 +1,55 @@
+// render.go
+// Package ui coalesces viewport rebuilds so message storms do not stall the TUI.
+
+package ui
+
+import (
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// maxRendersPerSecond bounds how often the viewport is rebuilt while messages stream in
+var maxRendersPerSecond = 10
+
+// renderTickMsg fires when a deferred viewport rebuild is due
+type renderTickMsg struct{}
+
+// renderInterval is the minimum time between viewport rebuilds
+func renderInterval() time.Duration {
+	if maxRendersPerSecond <= 0 {
+		return 0
+	}
+	return time.Second / time.Duration(maxRendersPerSecond)
+}
+
+// requestRender rebuilds the viewport now, or defers the rebuild when the last one was too recent.
+// scroll asks for the viewport to end up at the bottom.
+func (m *model) requestRender(scroll bool) {
+	m.renderScroll = m.renderScroll || scroll
+	if !m.renderScheduled && time.Since(m.lastRender) >= renderInterval() {
+		m.flushRender()
+		return
+	}
+	m.renderPending = true
+}
+
+// flushRender performs any pending viewport rebuild immediately
+func (m *model) flushRender() {
+	m.refreshViewport()
+	if m.renderScroll {
+		m.viewport.GotoBottom() // Scroll to the bottom to show the new message
+	}
+	m.renderPending, m.renderScroll = false, false
+}
+
+// scheduleRender returns a command that performs a deferred rebuild once the interval has passed
+func (m *model) scheduleRender() tea.Cmd {
+	if !m.renderPending || m.renderScheduled {
+		return nil
+	}
+	m.renderScheduled = true
+	return tea.Tick(renderInterval()-time.Since(m.lastRender), func(time.Time) tea.Msg {
+		return renderTickMsg{}
+	})
+}
@@ -0,0 +1,103 @@
+// responses.go
+// Package ui keeps recent multi-line command responses so they can be shown again with /show.
+
+package ui
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// maxResponses is the number of multi-line responses kept for /show
+const maxResponses = 20
+
+// commandResponse is a multi-line response and the command that produced it
+type commandResponse struct {
+	command string    // Server command the response answered, if known
+	content string    // Response text
+	at      time.Time // When the response arrived
+}
+
+func init() {
+	registerCommand("/show", commandSpec{
+		usage: "/show [command] [n]",
+		help:  "Show the nth most recent multi-line response again, optionally only for one command (e.g. /show LIST 2)",
+		run: func(m *model, args []string) tea.Cmd {
+			command, n := "", 1
+			for _, arg := range args {
+				if i, err := strconv.Atoi(arg); err == nil {
+					n = i
+				} else {
+					command = strings.ToUpper(arg)
+				}
+			}
+			response, ok := m.responseByNumber(command, n)
+			if !ok {
+				m.appendMessage(fmt.Sprintf("No response %d%s. Type /responses to list them.", n, forCommand(command)))
+				return nil
+			}
+			m.appendMessage(fmt.Sprintf("%s response from %s:\n%s", response.label(), response.at.Format("15:04:05"), response.content))
+			return nil
+		},
+	})
+	registerCommand("/responses", commandSpec{
+		usage: "/responses",
+		help:  "List the multi-line responses that /show can display",
+		run: func(m *model, args []string) tea.Cmd {
+			if len(m.responses) == 0 {
+				m.appendMessage("No responses yet.")
+				return nil
+			}
+			for i := len(m.responses) - 1; i >= 0; i-- {
+				response := m.responses[i]
+				lines := strings.Count(response.content, "\n") + 1
+				m.appendMessage(fmt.Sprintf("%d. %s at %s (%d lines)", len(m.responses)-i, response.label(), response.at.Format("15:04:05"), lines))
+			}
+			return nil
+		},
+	})
+}
+
+// forCommand formats an optional command filter for notices
+func forCommand(command string) string {
+	if command == "" {
+		return ""
+	}
+	return " for " + command
+}
+
+// label names the command a response answered
+func (r commandResponse) label() string {
+	if r.command == "" {
+		return "Server"
+	}
+	return r.command
+}
+
+// recordResponse keeps a multi-line response, attributing it to the last command sent to the server
+func (m *model) recordResponse(content string) {
+	m.responses = append(m.responses, commandResponse{command: m.lastServerCommand, content: content, at: time.Now()})
+	if len(m.responses) > maxResponses {
+		m.responses = m.responses[len(m.responses)-maxResponses:]
+	}
+	m.lastServerCommand = ""
+}
+
+// responseByNumber returns the nth most recent response, optionally only for one command
+func (m *model) responseByNumber(command string, n int) (commandResponse, bool) {
+	for i := len(m.responses) - 1; i >= 0 && n > 0; i-- {
+		response := m.responses[i]
+		if command != "" && response.command != command {
+			continue
+		}
+		n--
+		if n == 0 {
+			return response, true
+		}
+	}
+	return commandResponse{}, false
+}
@@ -0,0 +1,313 @@
+// roster.go
+// Package ui parses LIST responses into ClientInfo records that feed the roster, the picker, and input completion.
+
+package ui
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/x/ansi"
+)
+
+// ClientInfo describes a client connected to the server, as reported by LIST
+type ClientInfo struct {
+	ID          string        // Client identifier
+	Address     string        // Network address, if the server reports it
+	Operator    bool          // Whether the client is the server operator
+	Idle        time.Duration // Idle time, if the server reports it
+	ConnectedAt time.Time     // When the client connected, if the server reports it
+	UpdatedAt   time.Time     // When the record was last refreshed
+}
+
+// rosterSortKeys are the columns the roster pane can be sorted by
+var rosterSortKeys = []string{"id", "addr", "op", "idle", "connected"}
+
+// idTakingCommands are the commands whose first argument is a client ID, for completion
+var idTakingCommands = []string{"SEND", "SENDFILE", "KICK", "BAN", "UNBAN", "WHOIS", "RATELIMIT"}
+
+func init() {
+	registerCommand("/roster", commandSpec{
+		usage: "/roster [id|addr|op|idle|connected]",
+		help:  "Toggle the roster pane, optionally sorted by a column",
+		run: func(m *model, args []string) tea.Cmd {
+			if len(args) > 0 {
+				key := strings.ToLower(args[0])
+				if !containsString(rosterSortKeys, key) {
+					m.appendMessage("Sort by one of: " + strings.Join(rosterSortKeys, ", "))
+					return nil
+				}
+				m.rosterSort = key
+				m.panel = "roster"
+				return nil
+			}
+			m.togglePanel("roster")
+			return nil
+		},
+	})
+}
+
+// parseClientList parses a LIST response into client records. It accepts one client per line,
+// either as "key=value" fields (id=, addr=, op=, idle=) or as an ID followed by free-form
+// details such as an address, "(operator)", or "idle 5m". Header lines ending in ":" are skipped.
+func parseClientList(content string, now time.Time) []ClientInfo {
+	var clients []ClientInfo
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		line = strings.TrimLeft(line, "-*• ")
+		if line == "" || strings.HasSuffix(line, ":") {
+			continue
+		}
+		if info, ok := parseClientLine(line); ok {
+			info.UpdatedAt = now
+			clients = append(clients, info)
+		}
+	}
+	return clients
+}
+
+// parseWhois parses a WHOIS response of "Key: value" lines into a client record
+func parseWhois(content string, now time.Time) (ClientInfo, bool) {
+	info := ClientInfo{UpdatedAt: now}
+	for _, line := range strings.Split(content, "\n") {
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		switch strings.ToLower(strings.TrimSpace(key)) {
+		case "id", "client", "clientid":
+			info.ID = value
+		case "address", "addr":
+			info.Address = value
+		case "operator", "op":
+			info.Operator = value == "true" || value == "yes"
+		case "idle":
+			info.Idle, _ = time.ParseDuration(value)
+		case "connected", "connected at", "since":
+			info.ConnectedAt = parseTimestamp(value, now)
+		}
+	}
+	return info, info.ID != ""
+}
+
+// parseTimestamp parses an RFC 3339 timestamp, Unix seconds, or a duration ago
+func parseTimestamp(value string, now time.Time) time.Time {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t
+	}
+	var seconds int64
+	if _, err := fmt.Sscanf(value, "%d", &seconds); err == nil && fmt.Sprint(seconds) == value {
+		return time.Unix(seconds, 0)
+	}
+	if ago, err := time.ParseDuration(strings.TrimSuffix(value, " ago")); err == nil {
+		return now.Add(-ago)
+	}
+	return time.Time{}
+}
+
+// parseClientLine parses a single LIST line
+func parseClientLine(line string) (ClientInfo, bool) {
+	var info ClientInfo
+	fields := strings.Fields(strings.NewReplacer(",", " ", "(", " ", ")", " ", "[", " ", "]", " ").Replace(line))
+	for i := 0; i < len(fields); i++ {
+		field := fields[i]
+		key, value, hasValue := strings.Cut(field, "=")
+		lower := strings.ToLower(key)
+		switch {
+		case hasValue && lower == "id":
+			info.ID = value
+		case hasValue && (lower == "addr" || lower == "address"):
+			info.Address = value
+		case hasValue && (lower == "op" || lower == "operator"):
+			info.Operator = value == "true" || value == "yes" || value == "1"
+		case hasValue && lower == "idle":
+			info.Idle, _ = time.ParseDuration(value)
+		case hasValue && (lower == "connected" || lower == "since"):
+			info.ConnectedAt = parseTimestamp(value, time.Now())
+		case lower == "operator" || lower == "op":
+			info.Operator = true
+		case lower == "idle" && i+1 < len(fields):
+			if idle, err := time.ParseDuration(fields[i+1]); err == nil {
+				info.Idle = idle
+				i++
+			}
+		case isAddress(field):
+			info.Address = field
+		case info.ID == "" && !hasValue:
+			info.ID = field
+		}
+	}
+	return info, info.ID != ""
+}
+
+// isAddress reports whether the field looks like an IP address or host:port
+func isAddress(field string) bool {
+	if net.ParseIP(field) != nil {
+		return true
+	}
+	host, _, err := net.SplitHostPort(field)
+	return err == nil && net.ParseIP(host) != nil
+}
+
+// updateRoster replaces the roster with the clients from a LIST response
+func (m *model) updateRoster(clients []ClientInfo) {
+	roster := make(map[string]*ClientInfo, len(clients))
+	for i := range clients {
+		roster[clients[i].ID] = &clients[i]
+	}
+	m.roster = roster
+	m.updateSuggestions()
+}
+
+// mergeClient updates one roster record, keeping details the new record does not report
+func (m *model) mergeClient(info ClientInfo) {
+	existing, ok := m.roster[info.ID]
+	if !ok {
+		m.roster[info.ID] = &info
+		m.updateSuggestions()
+		return
+	}
+	if info.Address != "" {
+		existing.Address = info.Address
+	}
+	if !info.ConnectedAt.IsZero() {
+		existing.ConnectedAt = info.ConnectedAt
+	}
+	existing.Operator = info.Operator
+	existing.Idle = info.Idle
+	existing.UpdatedAt = info.UpdatedAt
+}
+
+// idleFor returns a client's idle time, counting time since the record was refreshed and
+// taking into account messages we received from them since.
+func (m *model) idleFor(info *ClientInfo, now time.Time) time.Duration {
+	if info.Idle == 0 && info.UpdatedAt.IsZero() {
+		return 0
+	}
+	idle := info.Idle + now.Sub(info.UpdatedAt)
+	if lastSeen, ok := m.peerLastSeen[info.ID]; ok && now.Sub(lastSeen) < idle {
+		idle = now.Sub(lastSeen)
+	}
+	return idle
+}
+
+// rosterList returns the roster sorted by ID
+func (m *model) rosterList() []*ClientInfo {
+	list := make([]*ClientInfo, 0, len(m.roster))
+	for _, info := range m.roster {
+		list = append(list, info)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].ID < list[j].ID })
+	return list
+}
+
+// sortedRoster returns the roster sorted by the pane's sort column, ties broken by ID
+func (m *model) sortedRoster(now time.Time) []*ClientInfo {
+	list := m.rosterList()
+	sort.SliceStable(list, func(i, j int) bool {
+		a, b := list[i], list[j]
+		switch m.rosterSort {
+		case "addr":
+			return a.Address < b.Address
+		case "op":
+			return a.Operator && !b.Operator
+		case "idle":
+			return m.idleFor(a, now) < m.idleFor(b, now)
+		case "connected":
+			return a.ConnectedAt.Before(b.ConnectedAt)
+		}
+		return false
+	})
+	return list
+}
+
+// rosterView renders the roster pane
+func (m *model) rosterView() string {
+	now := time.Now()
+	sortKey := m.rosterSort
+	if sortKey == "" {
+		sortKey = "id"
+	}
+	lines := []string{
+		fmt.Sprintf("Roster, sorted by %s (/roster <column> to sort, /roster to close):", sortKey),
+		fmt.Sprintf("  %s%-16s %-22s %-3s %-9s %s", strings.Repeat(" ", ansi.StringWidth(identicon(""))), "ID", "ADDRESS", "OP", "IDLE", "CONNECTED"),
+	}
+	if len(m.roster) == 0 {
+		lines = append(lines, "  (empty; type LIST or WHOIS <ID> to refresh)")
+	}
+	for _, info := range m.sortedRoster(now) {
+		op, idle, connected := "", "", ""
+		if info.Operator {
+			op = "yes"
+		}
+		if d := m.idleFor(info, now); d > 0 {
+			idle = d.Round(time.Second).String()
+		}
+		if !info.ConnectedAt.IsZero() {
+			connected = info.ConnectedAt.Local().Format("Jan 2 15:04")
+		}
+		// The identicon of the key pinned for the client, if it has signed a message
+		lines = append(lines, fmt.Sprintf("  %s%-16s %-22s %-3s %-9s %s", identicon(m.knownKeys.pinned(info.ID)), info.ID, info.Address, op, idle, connected))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// containsString reports whether list contains s
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// knownPeers returns recent senders followed by the rest of the roster, excluding ourselves and archived conversations
+func (m *model) knownPeers() []string {
+	seen := map[string]bool{m.clientID: true}
+	// Archived conversations stay out of the list until they get a new message
+	for id := range m.archived {
+		seen[id] = true
+	}
+	var peers []string
+	for _, id := range m.recentSenders {
+		if !seen[id] {
+			seen[id] = true
+			peers = append(peers, id)
+		}
+	}
+	for _, info := range m.rosterList() {
+		if !seen[info.ID] {
+			seen[info.ID] = true
+			peers = append(peers, info.ID)
+		}
+	}
+	return peers
+}
+
+// updateSuggestions refreshes the input's Tab completions from the known commands and peers
+func (m *model) updateSuggestions() {
+	var suggestions []string
+	for name := range knownCommands {
+		if m.commandAvailable(name) {
+			suggestions = append(suggestions, name)
+		}
+	}
+	peers := m.knownPeers()
+	for _, command := range idTakingCommands {
+		if !m.commandAvailable(command) {
+			continue
+		}
+		for _, id := range peers {
+			suggestions = append(suggestions, command+" "+id+" ")
+		}
+	}
+	suggestions = append(suggestions, "SEND ALL ")
+	sort.Strings(suggestions)
+	m.input.SetSuggestions(suggestions)
+}
@@ -0,0 +1,156 @@
+// rosterexport.go
+// Package ui parses LISTBANS responses and lets operators export the roster and bans to JSON or CSV.
+
+package ui
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// BanInfo describes a ban, as reported by LISTBANS
+type BanInfo struct {
+	ID       string    `json:"id,omitempty"`        // Banned client identifier, if the ban is by ID
+	Address  string    `json:"address,omitempty"`   // Banned address, if the server reports it
+	BannedAt time.Time `json:"banned_at,omitempty"` // When the ban was made, if the server reports it
+}
+
+// rosterExport is the JSON form of an exported roster
+type rosterExport struct {
+	ExportedAt time.Time        `json:"exported_at"`
+	ExportedBy string           `json:"exported_by"`
+	Clients    []exportedClient `json:"clients"`
+	Bans       []BanInfo        `json:"bans"`
+}
+
+// exportedClient is the JSON form of a roster record
+type exportedClient struct {
+	ID          string    `json:"id"`
+	Address     string    `json:"address,omitempty"`
+	Operator    bool      `json:"operator"`
+	IdleSeconds int       `json:"idle_seconds"`
+	ConnectedAt time.Time `json:"connected_at,omitempty"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+func init() {
+	registerCommand("/export-roster", commandSpec{
+		usage: "/export-roster [json|csv] [path]",
+		help:  "Export the roster and bans from the last LIST and LISTBANS (operator only)",
+		run: func(m *model, args []string) tea.Cmd {
+			if !m.isOperator {
+				m.appendMessage("Only the server operator can export the roster.")
+				return nil
+			}
+			if amnesia {
+				m.appendMessage("Export is disabled in amnesia mode.")
+				return nil
+			}
+			format := "json"
+			if len(args) > 0 {
+				format = strings.ToLower(args[0])
+			}
+			if format != "json" && format != "csv" {
+				m.appendMessage("Usage: " + knownCommands["/export-roster"].usage)
+				return nil
+			}
+			path := fmt.Sprintf("padclient-roster-%s.%s", time.Now().Format("20060102-150405"), format)
+			if len(args) > 1 {
+				path = args[1]
+			}
+			if len(m.roster) == 0 && len(m.bans) == 0 {
+				m.appendMessage("Nothing to export yet. Run LIST and LISTBANS first.")
+				return nil
+			}
+			if err := m.exportRoster(format, path); err != nil {
+				m.appendMessage(fmt.Sprintf("Error exporting roster: %v", err))
+				return nil
+			}
+			m.appendMessage(fmt.Sprintf("Exported %d client(s) and %d ban(s) to %s", len(m.roster), len(m.bans), path))
+			return nil
+		},
+	})
+}
+
+// parseBanList parses a LISTBANS response into ban records, one per line, with the same
+// field formats as LIST. Header lines ending in ":" are skipped.
+func parseBanList(content string) []BanInfo {
+	var bans []BanInfo
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		line = strings.TrimLeft(line, "-*• ")
+		if line == "" || strings.HasSuffix(line, ":") {
+			continue
+		}
+		info, _ := parseClientLine(line)
+		if info.ID == "" && info.Address == "" {
+			continue
+		}
+		bans = append(bans, BanInfo{ID: info.ID, Address: info.Address, BannedAt: info.ConnectedAt})
+	}
+	return bans
+}
+
+// exportRoster writes the roster and bans to path as JSON or CSV
+func (m *model) exportRoster(format, path string) error {
+	now := time.Now()
+	export := rosterExport{ExportedAt: now, ExportedBy: m.clientID, Clients: []exportedClient{}, Bans: m.bans}
+	if export.Bans == nil {
+		export.Bans = []BanInfo{}
+	}
+	for _, info := range m.rosterList() {
+		export.Clients = append(export.Clients, exportedClient{
+			ID:          info.ID,
+			Address:     info.Address,
+			Operator:    info.Operator,
+			IdleSeconds: int(m.idleFor(info, now).Seconds()),
+			ConnectedAt: info.ConnectedAt,
+			UpdatedAt:   info.UpdatedAt,
+		})
+	}
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	if format == "json" {
+		encoder := json.NewEncoder(file)
+		encoder.SetIndent("", "  ")
+		err = encoder.Encode(export)
+	} else {
+		err = writeRosterCSV(file, export)
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// writeRosterCSV writes clients and bans as rows of one table, told apart by the first column
+func writeRosterCSV(file *os.File, export rosterExport) error {
+	w := csv.NewWriter(file)
+	w.Write([]string{"record", "id", "address", "operator", "idle_seconds", "connected_at", "banned_at"})
+	for _, client := range export.Clients {
+		w.Write([]string{"client", client.ID, client.Address, strconv.FormatBool(client.Operator),
+			strconv.Itoa(client.IdleSeconds), formatExportTime(client.ConnectedAt), ""})
+	}
+	for _, ban := range export.Bans {
+		w.Write([]string{"ban", ban.ID, ban.Address, "", "", "", formatExportTime(ban.BannedAt)})
+	}
+	w.Flush()
+	return w.Error()
+}
+
+// formatExportTime formats a time for CSV, leaving unknown times empty
+func formatExportTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
@@ -0,0 +1,291 @@
+// scrollback.go
+// Package ui bounds the scrollback kept in memory, spilling older entries to an encrypted
+// session archive and paging them back in as the user scrolls up.
+
+package ui
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/drewwalton19216801/padclient/crypto"
+)
+
+// scrollbackLimit is the number of entries kept in memory (0 keeps everything)
+var scrollbackLimit = 5000
+
+// scrollbackPage is how many archived entries are paged in at a time
+const scrollbackPage = 200
+
+// archivedEntry is the stored form of a chatEntry, holding every field so a paged-in entry
+// renders, and answers /info, as it did before it was evicted
+type archivedEntry struct {
+	Seq          int             `json:"seq"`
+	Kind         entryKind       `json:"kind"`
+	Sender       string          `json:"sender,omitempty"`
+	Recipient    string          `json:"recipient,omitempty"`
+	Content      string          `json:"content"`
+	At           time.Time       `json:"at"`
+	Status       int             `json:"status,omitempty"`
+	OutboxID     int             `json:"outbox_id,omitempty"`
+	Expanded     bool            `json:"expanded,omitempty"`
+	Highlight    bool            `json:"highlight,omitempty"`
+	Folded       bool            `json:"folded,omitempty"`
+	Color        string          `json:"color,omitempty"`
+	Repeats      []time.Time     `json:"repeats,omitempty"`
+	Revealed     bool            `json:"revealed,omitempty"`
+	Translation  string          `json:"translation,omitempty"`
+	Info         archivedInfo    `json:"info"`
+	Forwarded    string          `json:"forwarded_from,omitempty"`
+	Thread       string          `json:"thread,omitempty"`
+	PollID       string          `json:"poll_id,omitempty"`
+	Announcement bool            `json:"announcement,omitempty"`
+	Signature    signatureStatus `json:"signature,omitempty"`
+	Signer       string          `json:"signer,omitempty"`
+}
+
+// archivedInfo is the stored form of a cipherInfo
+type archivedInfo struct {
+	Cipher      string `json:"cipher,omitempty"`
+	Fingerprint string `json:"fingerprint,omitempty"`
+	Signature   string `json:"signature,omitempty"`
+	Pad         string `json:"pad,omitempty"`
+}
+
+// scrollbackArchive stores evicted entries in a temporary file, each sealed with a key that
+// only exists in memory, so the file is unreadable once the session ends.
+type scrollbackArchive struct {
+	file    *os.File
+	key     []byte
+	offsets []int64 // File offset of each record, oldest first
+	end     int64
+}
+
+// newScrollbackArchive creates the archive file for this session
+func newScrollbackArchive() (*scrollbackArchive, error) {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	file, err := os.CreateTemp("", "padclient-scrollback-*")
+	if err != nil {
+		return nil, err
+	}
+	return &scrollbackArchive{file: file, key: key}, nil
+}
+
+// marshalEntry encodes an entry, with every field, for storing outside memory
+func marshalEntry(entry chatEntry) ([]byte, error) {
+	return json.Marshal(archivedEntry{
+		Seq:         entry.seq,
+		Kind:        entry.kind,
+		Sender:      entry.sender,
+		Recipient:   entry.recipient,
+		Content:     entry.content,
+		At:          entry.at,
+		Status:      int(entry.status),
+		OutboxID:    entry.outboxID,
+		Expanded:    entry.expanded,
+		Highlight:   entry.highlight,
+		Folded:      entry.folded,
+		Color:       entry.color,
+		Repeats:     entry.repeats,
+		Revealed:    entry.revealed,
+		Translation: entry.translation,
+		Info: archivedInfo{
+			Cipher:      entry.info.cipher,
+			Fingerprint: entry.info.fingerprint,
+			Signature:   entry.info.signature,
+			Pad:         entry.info.pad,
+		},
+		Forwarded:    entry.forwardedFrom,
+		Thread:       entry.thread,
+		PollID:       entry.pollID,
+		Announcement: entry.announcement,
+		Signature:    entry.signature,
+		Signer:       entry.signer,
+	})
+}
+
+// unmarshalEntry decodes an entry stored by marshalEntry
+func unmarshalEntry(plaintext []byte) (chatEntry, error) {
+	var stored archivedEntry
+	if err := json.Unmarshal(plaintext, &stored); err != nil {
+		return chatEntry{}, err
+	}
+	return chatEntry{
+		seq:         stored.Seq,
+		kind:        stored.Kind,
+		sender:      stored.Sender,
+		recipient:   stored.Recipient,
+		content:     stored.Content,
+		at:          stored.At,
+		status:      deliveryStatus(stored.Status),
+		outboxID:    stored.OutboxID,
+		expanded:    stored.Expanded,
+		highlight:   stored.Highlight,
+		folded:      stored.Folded,
+		color:       stored.Color,
+		repeats:     stored.Repeats,
+		revealed:    stored.Revealed,
+		translation: stored.Translation,
+		info: cipherInfo{
+			cipher:      stored.Info.Cipher,
+			fingerprint: stored.Info.Fingerprint,
+			signature:   stored.Info.Signature,
+			pad:         stored.Info.Pad,
+		},
+		forwardedFrom: stored.Forwarded,
+		thread:        stored.Thread,
+		pollID:        stored.PollID,
+		announcement:  stored.Announcement,
+		signature:     stored.Signature,
+		signer:        stored.Signer,
+	}, nil
+}
+
+// sealEntry encrypts an entry under key as a record: a 4-byte big-endian length, then the sealed entry
+func sealEntry(key []byte, entry chatEntry) ([]byte, error) {
+	plaintext, err := marshalEntry(entry)
+	if err != nil {
+		return nil, err
+	}
+	sealed, err := crypto.EncryptAES(key, plaintext)
+	if err != nil {
+		return nil, err
+	}
+	record := binary.BigEndian.AppendUint32(nil, uint32(len(sealed)))
+	return append(record, sealed...), nil
+}
+
+// openEntry reads and decrypts the record sealEntry wrote at offset, returning the entry and
+// the record's length
+func openEntry(r io.ReaderAt, offset int64, key []byte) (chatEntry, int64, error) {
+	var size [4]byte
+	if _, err := r.ReadAt(size[:], offset); err != nil {
+		return chatEntry{}, 0, err
+	}
+	sealed := make([]byte, binary.BigEndian.Uint32(size[:]))
+	if _, err := io.ReadFull(io.NewSectionReader(r, offset+4, int64(len(sealed))), sealed); err != nil {
+		return chatEntry{}, 0, err
+	}
+	plaintext, err := crypto.DecryptAES(key, sealed)
+	if err != nil {
+		return chatEntry{}, 0, err
+	}
+	entry, err := unmarshalEntry(plaintext)
+	if err != nil {
+		return chatEntry{}, 0, err
+	}
+	return entry, 4 + int64(len(sealed)), nil
+}
+
+// append seals an entry and writes it after the previous ones
+func (a *scrollbackArchive) append(entry chatEntry) error {
+	record, err := sealEntry(a.key, entry)
+	if err != nil {
+		return err
+	}
+	if _, err := a.file.WriteAt(record, a.end); err != nil {
+		return err
+	}
+	a.offsets = append(a.offsets, a.end)
+	a.end += int64(len(record))
+	return nil
+}
+
+// read returns the archived entries with indexes from..to-1
+func (a *scrollbackArchive) read(from, to int) ([]chatEntry, error) {
+	entries := make([]chatEntry, 0, to-from)
+	for i := from; i < to; i++ {
+		entry, _, err := openEntry(a.file, a.offsets[i], a.key)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// close removes the archive file and forgets its key
+func (a *scrollbackArchive) close() {
+	if a == nil {
+		return
+	}
+	for i := range a.key {
+		a.key[i] = 0
+	}
+	a.file.Close()
+	os.Remove(a.file.Name())
+}
+
+// evictScrollback moves the oldest entries beyond the scrollback limit out of memory.
+// In amnesia mode nothing is written to disk, so evicted entries are dropped.
+func (m *model) evictScrollback() {
+	// Evict a page at a time so the buffer is not copied on every new message
+	if scrollbackLimit <= 0 || len(m.entries) <= scrollbackLimit+scrollbackPage {
+		return
+	}
+	excess := len(m.entries) - scrollbackLimit
+	if m.archive == nil && !amnesia && !m.archiveFailed {
+		archive, err := newScrollbackArchive()
+		if err != nil {
+			// Keep everything in memory rather than lose history
+			m.archiveFailed = true
+			m.appendMessage(fmt.Sprintf("Error creating the scrollback archive: %v", err))
+			return
+		}
+		m.archive = archive
+	}
+	if m.archive != nil {
+		for _, entry := range m.entries[:excess] {
+			if err := m.archive.append(entry); err != nil {
+				m.appendMessage(fmt.Sprintf("Error writing the scrollback archive: %v", err))
+				return
+			}
+		}
+	}
+	m.entries = append(m.entries[:0:0], m.entries[excess:]...)
+}
+
+// pageOlder loads the next page of archived entries above the oldest one in memory
+func (m *model) pageOlder() {
+	if m.archive == nil {
+		return
+	}
+	if m.pagedIn == 0 {
+		m.pagedFrom = len(m.archive.offsets)
+	}
+	if m.pagedFrom == 0 {
+		m.flash = "Start of the session."
+		return
+	}
+	from := max(0, m.pagedFrom-scrollbackPage)
+	older, err := m.archive.read(from, m.pagedFrom)
+	if err != nil {
+		m.flash = fmt.Sprintf("Error reading the scrollback archive: %v", err)
+		return
+	}
+	m.entries = append(older, m.entries...)
+	m.pagedIn += len(older)
+	m.pagedFrom = from
+	m.refreshViewport()
+	// Keep the entry that was at the top in place
+	m.viewport.SetYOffset(m.entryLines[len(older)])
+}
+
+// releasePaged drops entries paged in from the archive, since they are still stored there
+func (m *model) releasePaged() {
+	if m.pagedIn == 0 {
+		return
+	}
+	m.entries = append(m.entries[:0:0], m.entries[m.pagedIn:]...)
+	m.pagedIn = 0
+	m.refreshViewport()
+	m.viewport.GotoBottom()
+}
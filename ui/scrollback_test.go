@@ -0,0 +1,65 @@
+package ui
+
+import (
+	"bytes"
+	"crypto/rand"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// TestSealEntryKeepsEveryField seals an entry with every field set and checks that it opens
+// unchanged, so an entry paged back in from the archive is the one that was evicted
+func TestSealEntryKeepsEveryField(t *testing.T) {
+	at := time.Date(2026, 10, 16, 9, 30, 0, 0, time.UTC)
+	entry := chatEntry{
+		seq:           7,
+		kind:          entryDirect,
+		sender:        "bob",
+		recipient:     "alice",
+		content:       "hello",
+		at:            at,
+		status:        statusFailed,
+		outboxID:      3,
+		expanded:      true,
+		highlight:     true,
+		folded:        true,
+		color:         "red",
+		repeats:       []time.Time{at.Add(time.Second)},
+		revealed:      true,
+		translation:   "hallo",
+		info:          cipherInfo{cipher: "AES-256-CBC", fingerprint: "ab:cd", signature: "valid", pad: "p1 at 0"},
+		forwardedFrom: "carol",
+		thread:        "t1",
+		pollID:        "poll1",
+		announcement:  true,
+		signature:     signatureVerified,
+		signer:        "12:34",
+	}
+	// A field added to chatEntry must be set here, and so archived too
+	value := reflect.ValueOf(entry)
+	for i := 0; i < value.NumField(); i++ {
+		if value.Field(i).IsZero() {
+			t.Fatalf("chatEntry.%s is not set in the test entry", value.Type().Field(i).Name)
+		}
+	}
+
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		t.Fatal(err)
+	}
+	record, err := sealEntry(key, entry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	opened, size, err := openEntry(bytes.NewReader(record), 0, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size != int64(len(record)) {
+		t.Errorf("record length %d, want %d", size, len(record))
+	}
+	if !reflect.DeepEqual(opened, entry) {
+		t.Errorf("opened %+v, want %+v", opened, entry)
+	}
+}
@@ -0,0 +1,171 @@
+// search.go
+// Package ui proxies searches to servers that keep message history and pages through their results.
+
+package ui
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/drewwalton19216801/padclient/crypto"
+)
+
+// searchResultsMsg carries one page of results for a server-side search
+type searchResultsMsg struct {
+	page    int         // Page number, starting at 1
+	pages   int         // Total number of pages
+	results []chatEntry // Matching messages on this page
+	failed  int         // Results that could not be decrypted
+}
+
+// serverSearch is the state of the most recent server-side search
+type serverSearch struct {
+	query   string
+	page    int
+	pages   int
+	results []chatEntry
+	failed  int
+	waiting bool // Whether a page has been requested and not yet received
+}
+
+func init() {
+	registerCommand("/ssearch", commandSpec{
+		usage:   "/ssearch <query> | next | prev | page <n> | close",
+		help:    "Search the server's message history (needs the SEARCH capability)",
+		minArgs: 1,
+		run: func(m *model, args []string) tea.Cmd {
+			if !m.serverCaps["SEARCH"] {
+				m.appendMessage("The server does not support SEARCH.")
+				return nil
+			}
+			search := m.search
+			switch {
+			case search != nil && len(args) == 1 && args[0] == "next":
+				m.requestSearchPage(search.page + 1)
+			case search != nil && len(args) == 1 && args[0] == "prev":
+				m.requestSearchPage(search.page - 1)
+			case search != nil && len(args) == 2 && args[0] == "page":
+				page, err := strconv.Atoi(args[1])
+				if err != nil {
+					m.appendMessage(fmt.Sprintf("Invalid page %q.", args[1]))
+					return nil
+				}
+				m.requestSearchPage(page)
+			case len(args) == 1 && args[0] == "close":
+				m.search = nil
+				if m.panel == "search" {
+					m.panel = ""
+				}
+			default:
+				m.search = &serverSearch{query: strings.Join(args, " ")}
+				m.requestSearchPage(1)
+			}
+			return nil
+		},
+	})
+}
+
+// requestSearchPage asks the server for a page of the current search: SEARCH <page> <query>
+func (m *model) requestSearchPage(page int) {
+	search := m.search
+	if page < 1 || (search.pages > 0 && page > search.pages) {
+		m.appendMessage(fmt.Sprintf("There is no page %d.", page))
+		return
+	}
+	if m.writeLine(fmt.Sprintf("SEARCH %d %s", page, search.query)) {
+		search.waiting = true
+		search.page = page
+		m.panel = "search"
+	}
+}
+
+// parseSearchHeader recognizes the line opening a page of results: SEARCHRESULTS <page> <pages>
+func parseSearchHeader(line string) (searchResultsMsg, bool) {
+	fields := strings.Fields(line)
+	if len(fields) != 3 || fields[0] != "SEARCHRESULTS" {
+		return searchResultsMsg{}, false
+	}
+	page, err := strconv.Atoi(fields[1])
+	if err != nil {
+		return searchResultsMsg{}, false
+	}
+	pages, err := strconv.Atoi(fields[2])
+	if err != nil {
+		return searchResultsMsg{}, false
+	}
+	return searchResultsMsg{page: page, pages: pages}, true
+}
+
+// parseSearchResult decodes one result line: RESULT <unix_time> <ALL|sender> <from> <encrypted_hex>.
+// Results are encrypted with our shared secret, like pins.
+func parseSearchResult(line string, hashedSecret []byte) (chatEntry, error) {
+	fields := strings.Fields(line)
+	if len(fields) != 5 || fields[0] != "RESULT" {
+		return chatEntry{}, fmt.Errorf("malformed search result")
+	}
+	unix, err := strconv.ParseInt(fields[1], 10, 64)
+	if err != nil {
+		return chatEntry{}, fmt.Errorf("malformed search result time %q", fields[1])
+	}
+	ciphertext, err := hex.DecodeString(fields[4])
+	if err != nil {
+		return chatEntry{}, fmt.Errorf("error decoding search result: %v", err)
+	}
+	plaintext, err := crypto.DecryptAES(hashedSecret, ciphertext)
+	if err != nil {
+		return chatEntry{}, fmt.Errorf("error decrypting search result: %v", err)
+	}
+	entry := chatEntry{kind: entryDirect, sender: fields[3], at: time.Unix(unix, 0)}
+	openEnvelope(string(plaintext)).annotate(&entry)
+	if fields[2] == "ALL" {
+		entry.kind = entryBroadcast
+	}
+	return entry, nil
+}
+
+// applySearchResults stores a page of results if it belongs to the current search
+func (m *model) applySearchResults(msg searchResultsMsg) {
+	search := m.search
+	if search == nil || !search.waiting {
+		return
+	}
+	search.waiting = false
+	search.page = msg.page
+	search.pages = msg.pages
+	search.results = msg.results
+	search.failed = msg.failed
+	m.panel = "search"
+}
+
+// searchView renders the current page of server-side search results
+func (m *model) searchView() string {
+	search := m.search
+	if search == nil {
+		return "No server search (/ssearch <query> to start one)."
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "Server search for %q", search.query)
+	if search.pages > 0 {
+		fmt.Fprintf(&b, ", page %d of %d", search.page, search.pages)
+	}
+	b.WriteString(" (/ssearch next|prev, /ssearch close to close):")
+	switch {
+	case search.waiting:
+		b.WriteString("\n  (searching…)")
+	case len(search.results) == 0:
+		b.WriteString("\n  (no matches)")
+	}
+	if !search.waiting {
+		for _, entry := range search.results {
+			fmt.Fprintf(&b, "\n  [%s] %s", entry.at.Local().Format("2006-01-02 15:04"), entry.render())
+		}
+		if search.failed > 0 {
+			fmt.Fprintf(&b, "\n  (%d result(s) could not be decrypted)", search.failed)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,89 @@
+// security.go
+// Package ui summarizes the session's security state in a dashboard panel.
+
+package ui
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func init() {
+	registerCommand("/security", commandSpec{
+		usage: "/security",
+		help:  "Toggle the security dashboard (also F4)",
+		run: func(m *model, args []string) tea.Cmd {
+			m.togglePanel("security")
+			return nil
+		},
+	})
+}
+
+// securityView renders the security dashboard
+func (m *model) securityView() string {
+	lines := []string{"Security status (F4 or /security to close):"}
+
+	// Cipher suites in use for each kind of conversation
+	if m.hashedSecret != nil {
+		lines = append(lines, fmt.Sprintf("  Broadcasts:       %s, key %s", sharedKeyInfo(m.hashedSecret).cipher, keyFingerprint(m.hashedSecret)))
+	} else {
+		lines = append(lines, "  Broadcasts:       not connected")
+	}
+	if len(m.pads.summary()) > 0 {
+		lines = append(lines, "  Direct messages:  pre-shared one-time pad with peers listed below; a fresh one-time key per message to others")
+	} else {
+		lines = append(lines, "  Direct messages:  XOR with a fresh one-time key per message")
+	}
+	if agreed := dmKeys.agreedPeers(); len(agreed) > 0 {
+		lines = append(lines, "  Agreed DM keys:   "+strings.Join(agreed, ", ")+" (AES with an X25519 key instead of one-time keys)")
+	}
+
+	var verified []string
+	for peer, ok := range m.verifiedPeers {
+		if ok {
+			verified = append(verified, peer)
+		}
+	}
+	sort.Strings(verified)
+	if len(verified) == 0 {
+		lines = append(lines, "  Verified peers:   none")
+	} else {
+		lines = append(lines, "  Verified peers:   "+strings.Join(verified, ", "))
+	}
+	if pads := m.pads.summary(); len(pads) == 0 {
+		lines = append(lines, "  Pad remaining:    no pad loaded")
+	} else {
+		for i, pad := range pads {
+			label := "  Pad remaining:    "
+			if i > 0 {
+				label = "                    "
+			}
+			lines = append(lines, label+pad)
+		}
+	}
+	if m.serverFingerprint != "" {
+		lines = append(lines, "  Server key:       "+m.serverFingerprint+" ("+m.serverPinStatus()+")")
+	}
+	if m.identity != nil {
+		lines = append(lines, "  Identity key:     "+m.identity.fingerprint()+" (signs every outgoing message)")
+	}
+	lines = append(lines, "  Keystore:         none (keys are derived when connecting)")
+	if len(m.selfCheck) == 0 {
+		lines = append(lines, "  Self-check:       passed")
+	} else {
+		lines = append(lines, fmt.Sprintf("  Self-check:       %d issue(s)", len(m.selfCheck)))
+		for _, finding := range m.selfCheck {
+			lines = append(lines, "    "+finding)
+		}
+	}
+	if amnesia {
+		lines = append(lines, "  Amnesia:          on (nothing is written to disk; state is wiped on exit)")
+	}
+
+	lines = append(lines, fmt.Sprintf("  Integrity failures this session: %d (%d in quarantine; /quarantine to review)", m.integrityFailures, len(m.quarantine)))
+	lines = append(lines, fmt.Sprintf("  Protocol errors this session:    %d", m.protocolErrors))
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,77 @@
+// selfcheck.go
+// Package ui runs a security self-check of local files and process settings before connecting.
+
+package ui
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+)
+
+// skipSelfCheck suppresses the self-check summary printed before connecting
+var skipSelfCheck bool
+
+// sensitiveFile is a local file whose contents must stay private
+type sensitiveFile struct {
+	label string // What the file holds, for findings
+	path  string
+}
+
+// selfCheck inspects the sensitive files and process settings and returns its findings
+func selfCheck(files []sensitiveFile) []string {
+	var findings []string
+	for _, file := range files {
+		info, err := os.Stat(file.path)
+		if err != nil {
+			continue // Missing files have nothing to leak
+		}
+		if perm := info.Mode().Perm(); permissionBitsEnforced && perm&0o077 != 0 {
+			findings = append(findings, fmt.Sprintf("%s %s is accessible to other users (mode %04o); run chmod 600 %s", file.label, file.path, perm, file.path))
+		}
+	}
+	if pprofAddress != "" {
+		if host, _, err := net.SplitHostPort(pprofAddress); err == nil && host != "localhost" && !net.ParseIP(host).IsLoopback() {
+			findings = append(findings, fmt.Sprintf("the pprof endpoint %s is reachable from other machines; bind it to localhost", pprofAddress))
+		}
+	}
+	if finding := coreDumpFinding(); finding != "" {
+		findings = append(findings, finding)
+	}
+	return findings
+}
+
+// sessionFiles lists the sensitive files the client knows about
+func sessionFiles() []sensitiveFile {
+	var files []sensitiveFile
+	if walPath != "" {
+		files = append(files, sensitiveFile{label: "Outbox log", path: walPath})
+	}
+	if historyEnabled && historyPath != "" {
+		files = append(files, sensitiveFile{label: "Chat history", path: historyPath})
+	}
+	// Conversation exports written by /export in the working directory
+	exports, _ := filepath.Glob("padclient-export-*.md")
+	for _, path := range exports {
+		files = append(files, sensitiveFile{label: "Exported history", path: path})
+	}
+	// Roster exports written by /export-roster
+	rosters, _ := filepath.Glob("padclient-roster-*")
+	for _, path := range rosters {
+		files = append(files, sensitiveFile{label: "Exported roster", path: path})
+	}
+	return files
+}
+
+// printSelfCheck prints a summary of the findings before connecting
+func printSelfCheck(findings []string) {
+	if len(findings) == 0 {
+		fmt.Println("Security self-check passed.")
+		return
+	}
+	fmt.Printf("Security self-check found %d issue(s):\n", len(findings))
+	for _, finding := range findings {
+		fmt.Printf("  - %s\n", finding)
+	}
+}
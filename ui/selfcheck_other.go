@@ -0,0 +1,15 @@
+// selfcheck_other.go
+// Package ui skips the Unix-only file mode and core dump checks on other systems.
+
+//go:build !unix
+
+package ui
+
+// permissionBitsEnforced is false where mode bits do not reflect access control, as on Windows,
+// where every writable file reports mode 0666 and access is governed by ACLs instead.
+const permissionBitsEnforced = false
+
+// coreDumpFinding has nothing to check on this system
+func coreDumpFinding() string {
+	return ""
+}
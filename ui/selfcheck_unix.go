@@ -0,0 +1,30 @@
+// selfcheck_unix.go
+// Package ui checks whether the process may write core dumps on Unix systems.
+
+//go:build unix
+
+package ui
+
+import (
+	"fmt"
+	"syscall"
+)
+
+// permissionBitsEnforced reports whether file mode bits control who can read a file
+const permissionBitsEnforced = true
+
+// coreDumpFinding reports core dumps being enabled, since a crash would write keys and messages to disk.
+// In amnesia mode core dumps are disabled for the process instead.
+func coreDumpFinding() string {
+	var limit syscall.Rlimit
+	if err := syscall.Getrlimit(syscall.RLIMIT_CORE, &limit); err != nil || limit.Cur == 0 {
+		return ""
+	}
+	if amnesia {
+		limit.Cur = 0
+		if err := syscall.Setrlimit(syscall.RLIMIT_CORE, &limit); err == nil {
+			return ""
+		}
+	}
+	return fmt.Sprintf("core dumps are enabled (ulimit -c is %d); a crash could write keys and messages to disk. Run ulimit -c 0 before starting", limit.Cur)
+}
@@ -0,0 +1,116 @@
+// shutdown.go
+// Package ui handles announced server shutdowns and restarts: it counts down, holds the outbox,
+// and reconnects once the announced window has passed instead of treating the disconnect as fatal.
+
+package ui
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// defaultShutdownWindow is assumed when a shutdown notice does not announce how long it takes
+const defaultShutdownWindow = 30 * time.Second
+
+// restartRetryInterval is how long to wait between reconnect attempts after a restart
+const restartRetryInterval = 5 * time.Second
+
+// maxRestartAttempts is how many reconnect attempts are made after an announced restart
+const maxRestartAttempts = 12
+
+// shutdownNoticeMsg reports that the server announced a shutdown or restart
+type shutdownNoticeMsg struct {
+	restart bool          // Whether the server announced a restart rather than a shutdown
+	window  time.Duration // How long until the server goes away
+	reason  string        // The full notice text
+}
+
+// shutdownTickMsg refreshes the shutdown countdown
+type shutdownTickMsg struct{}
+
+// reconnectMsg asks the model to dial the server again
+type reconnectMsg struct{}
+
+// shutdownDurationPattern matches the announced window, e.g. "30", "30s", "in 2 minutes"
+var shutdownDurationPattern = regexp.MustCompile(`(?i)\b(\d+)\s*(s|sec|secs|seconds?|m|min|mins|minutes?)?\b`)
+
+// parseShutdownNotice recognizes "SHUTDOWN ..." and "RESTART ..." notices from the server
+func parseShutdownNotice(line string) (shutdownNoticeMsg, bool) {
+	verb, rest, _ := strings.Cut(line, " ")
+	if verb != "SHUTDOWN" && verb != "RESTART" {
+		return shutdownNoticeMsg{}, false
+	}
+	notice := shutdownNoticeMsg{restart: verb == "RESTART", window: defaultShutdownWindow, reason: line}
+	if match := shutdownDurationPattern.FindStringSubmatch(rest); match != nil {
+		n, _ := strconv.Atoi(match[1])
+		unit := time.Second
+		if strings.HasPrefix(strings.ToLower(match[2]), "m") {
+			unit = time.Minute
+		}
+		notice.window = time.Duration(n) * unit
+	}
+	return notice, true
+}
+
+// shutdownTick schedules the next countdown refresh
+func shutdownTick() tea.Cmd {
+	return tea.Tick(time.Second, func(time.Time) tea.Msg { return shutdownTickMsg{} })
+}
+
+// applyShutdownNotice starts the countdown and holds the outbox until we reconnect
+func (m *model) applyShutdownNotice(notice shutdownNoticeMsg) tea.Cmd {
+	alreadyCounting := !m.shutdownAt.IsZero()
+	m.shutdownAt = time.Now().Add(notice.window)
+	m.holdOutbox = true
+	m.appendMessage(fmt.Sprintf("Server notice: %s. Outgoing messages are held; the client will reconnect afterwards.", notice.reason))
+	if alreadyCounting {
+		return nil
+	}
+	return shutdownTick()
+}
+
+// shutdownStatus renders the countdown shown above the input
+func (m *model) shutdownStatus() string {
+	if m.shutdownAt.IsZero() {
+		return ""
+	}
+	remaining := time.Until(m.shutdownAt).Round(time.Second)
+	if remaining > 0 {
+		return fmt.Sprintf("Server going away in %s — %d message(s) held", remaining, len(m.outbox))
+	}
+	return fmt.Sprintf("Waiting for the server to come back (attempt %d of %d)", m.restartAttempts, maxRestartAttempts)
+}
+
+// expectingRestart reports whether a disconnect was announced and should be followed by a reconnect
+func (m *model) expectingRestart() bool {
+	return !m.shutdownAt.IsZero()
+}
+
+// scheduleRestartReconnect waits out the rest of the announced window, then reconnects
+func (m *model) scheduleRestartReconnect() tea.Cmd {
+	if m.restartAttempts >= maxRestartAttempts {
+		m.appendMessage("The server did not come back. Giving up.")
+		m.exitErr = &fatalError{code: exitConnect, err: errors.New("the server did not come back after a restart")}
+		return tea.Quit
+	}
+	m.restartAttempts++
+	m.reconnects++
+	delay := time.Until(m.shutdownAt)
+	if delay < restartRetryInterval {
+		delay = restartRetryInterval
+	}
+	return tea.Tick(delay, func(time.Time) tea.Msg { return reconnectMsg{} })
+}
+
+// resumeAfterRestart clears the countdown and sends held messages whose undo window has passed
+func (m *model) resumeAfterRestart() {
+	m.shutdownAt = time.Time{}
+	m.restartAttempts = 0
+	m.releaseOutbox()
+}
@@ -0,0 +1,145 @@
+// sidebar.go
+// Package ui shows connected clients in a sidebar beside the conversation, refreshed with a
+// periodic LIST, and lets the user pick a recipient from it with the arrow keys.
+
+package ui
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// sidebarWidth is how many columns the sidebar takes from the viewport, border included
+const sidebarWidth = 24
+
+// sidebarRefresh is how often the sidebar asks the server for a new LIST while it is open
+const sidebarRefresh = 30 * time.Second
+
+// sidebarActiveIdle is how long a client may be idle and still be shown as active
+const sidebarActiveIdle = 5 * time.Minute
+
+var (
+	sidebarStyle         = lipgloss.NewStyle().Border(lipgloss.NormalBorder(), false, false, false, true).PaddingLeft(1)
+	sidebarTitleStyle    = lipgloss.NewStyle().Bold(true)
+	sidebarSelectedStyle = lipgloss.NewStyle().Reverse(true)
+)
+
+// sidebarTickMsg asks for the next sidebar refresh
+type sidebarTickMsg struct {
+	gen int // Refresh schedule the tick belongs to
+}
+
+func init() {
+	registerCommand("/users", commandSpec{
+		usage: "/users",
+		help:  "Toggle the user list sidebar (also F3)",
+		run: func(m *model, args []string) tea.Cmd {
+			return m.toggleSidebar()
+		},
+	})
+}
+
+// toggleSidebar opens the sidebar with the selection focused, or closes it
+func (m *model) toggleSidebar() tea.Cmd {
+	m.sidebar = !m.sidebar
+	m.sidebarFocus = m.sidebar
+	m.sidebarGen++
+	m.layout()
+	if !m.sidebar {
+		return nil
+	}
+	m.refreshSidebar()
+	return sidebarTick(m.sidebarGen)
+}
+
+// sidebarTick waits for the next sidebar refresh
+func sidebarTick(gen int) tea.Cmd {
+	return tea.Tick(sidebarRefresh, func(time.Time) tea.Msg {
+		return sidebarTickMsg{gen: gen}
+	})
+}
+
+// refreshSidebar asks the server for a new LIST, unless another command's response is pending.
+// The response updates the roster without being shown in the server buffer.
+func (m *model) refreshSidebar() {
+	if m.writer == nil || m.reconnecting() || m.lastServerCommand != "" {
+		return
+	}
+	if m.writeLine("LIST") {
+		m.lastServerCommand = "LIST"
+		m.sidebarRefreshing = true
+	}
+}
+
+// sidebarRefreshed handles the tick of a sidebar refresh and schedules the next
+func (m *model) sidebarRefreshed(msg sidebarTickMsg) tea.Cmd {
+	if msg.gen != m.sidebarGen || !m.sidebar {
+		// The sidebar was closed or reopened
+		return nil
+	}
+	m.refreshSidebar()
+	return sidebarTick(m.sidebarGen)
+}
+
+// sidebarUsers returns the clients listed in the sidebar: operators first, then by ID
+func (m *model) sidebarUsers() []*ClientInfo {
+	var operators, others []*ClientInfo
+	for _, info := range m.rosterList() {
+		if info.Operator {
+			operators = append(operators, info)
+		} else {
+			others = append(others, info)
+		}
+	}
+	return append(operators, others...)
+}
+
+// moveSidebarSelection moves the selection by delta and prefills a SEND to the selected user
+func (m *model) moveSidebarSelection(delta int) {
+	users := m.sidebarUsers()
+	if len(users) == 0 {
+		m.flash = "No users yet; the list refreshes every " + sidebarRefresh.String() + "."
+		return
+	}
+	m.sidebarIndex = min(max(m.sidebarIndex+delta, 0), len(users)-1)
+	m.input.SetValue("SEND " + users[m.sidebarIndex].ID + " ")
+	m.input.CursorEnd()
+}
+
+// sidebarView renders the user list beside the viewport
+func (m *model) sidebarView() string {
+	now := time.Now()
+	users := m.sidebarUsers()
+	width := sidebarWidth - 2 // Border and padding
+	lines := []string{sidebarTitleStyle.Render(fmt.Sprintf("Users (%d)", len(users)))}
+	if len(users) == 0 {
+		lines = append(lines, "(waiting for LIST)")
+	}
+	for i, info := range users {
+		// Presence: filled when active recently, hollow when idle
+		presence := "●"
+		if m.idleFor(info, now) > sidebarActiveIdle {
+			presence = "○"
+		}
+		badge := " "
+		if info.Operator {
+			badge = "@"
+		}
+		line := presence + " " + badge + info.ID
+		if len([]rune(line)) > width {
+			line = string([]rune(line)[:width-1]) + "…"
+		}
+		if m.sidebarFocus && i == m.sidebarIndex {
+			line = sidebarSelectedStyle.Render(line)
+		}
+		lines = append(lines, line)
+	}
+	if len(lines) > m.viewport.Height {
+		lines = lines[:m.viewport.Height]
+	}
+	return sidebarStyle.Width(sidebarWidth - 1).Height(m.viewport.Height).Render(strings.Join(lines, "\n"))
+}
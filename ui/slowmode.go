@@ -0,0 +1,260 @@
+// slowmode.go
+// Package ui lets operators set the room's slow mode and per-client rate limits on servers with
+// the SLOWMODE and RATELIMIT extensions, and paces our own messages to the limits the server
+// announces, so they wait in the outbox instead of being rejected.
+
+package ui
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// limitStyle renders the slow mode and rate limit line shown above the input
+var limitStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("3"))
+
+// slowModeMsg reports the room's slow mode: "SLOWMODE <seconds>", 0 when it is off
+type slowModeMsg struct {
+	delay time.Duration
+}
+
+// rateLimitMsg reports the rate limit the operator set for us: "RATELIMIT <messages> <seconds>",
+// or "RATELIMIT 0" when it is lifted
+type rateLimitMsg struct {
+	messages int
+	per      time.Duration
+}
+
+// paceTickMsg refreshes the countdown to the next message slow mode allows
+type paceTickMsg struct{}
+
+// parseSlowMode recognizes the server announcing the slow mode
+func parseSlowMode(line string) (slowModeMsg, bool) {
+	rest, ok := strings.CutPrefix(line, "SLOWMODE ")
+	if !ok {
+		return slowModeMsg{}, false
+	}
+	seconds, err := strconv.Atoi(strings.TrimSpace(rest))
+	if err != nil || seconds < 0 {
+		return slowModeMsg{}, false
+	}
+	return slowModeMsg{delay: time.Duration(seconds) * time.Second}, true
+}
+
+// parseRateLimit recognizes the server announcing the rate limit set for us
+func parseRateLimit(line string) (rateLimitMsg, bool) {
+	rest, ok := strings.CutPrefix(line, "RATELIMIT ")
+	if !ok {
+		return rateLimitMsg{}, false
+	}
+	fields := strings.Fields(rest)
+	if len(fields) == 1 && fields[0] == "0" {
+		return rateLimitMsg{}, true
+	}
+	if len(fields) != 2 {
+		return rateLimitMsg{}, false
+	}
+	messages, err := strconv.Atoi(fields[0])
+	seconds, err2 := strconv.Atoi(fields[1])
+	if err != nil || err2 != nil || messages < 0 || seconds <= 0 {
+		return rateLimitMsg{}, false
+	}
+	return rateLimitMsg{messages: messages, per: time.Duration(seconds) * time.Second}, true
+}
+
+// parseLimitInterval parses an interval typed by the operator: "30", "30s", "2m", or "off"
+func parseLimitInterval(text string) (time.Duration, error) {
+	if strings.EqualFold(text, "off") {
+		return 0, nil
+	}
+	if seconds, err := strconv.Atoi(text); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, nil
+	}
+	d, err := time.ParseDuration(text)
+	if err != nil || d < 0 || d%time.Second != 0 {
+		return 0, fmt.Errorf("invalid interval %q; use seconds or a duration such as 30s or 2m", text)
+	}
+	return d, nil
+}
+
+// parseRateLimitSpec parses "<messages>/<interval>" typed by the operator, or "off"
+func parseRateLimitSpec(text string) (int, time.Duration, error) {
+	if strings.EqualFold(text, "off") {
+		return 0, 0, nil
+	}
+	count, interval, ok := strings.Cut(text, "/")
+	messages, err := strconv.Atoi(count)
+	if !ok || err != nil || messages <= 0 {
+		return 0, 0, fmt.Errorf("invalid rate limit %q; use <messages>/<interval> such as 5/1m, or off", text)
+	}
+	per, err := parseLimitInterval(interval)
+	if err != nil {
+		return 0, 0, err
+	}
+	if per == 0 {
+		return 0, 0, fmt.Errorf("invalid rate limit %q; the interval must be longer than zero", text)
+	}
+	return messages, per, nil
+}
+
+// sendLimitCommand checks and sends an operator's SLOWMODE or RATELIMIT command, with the
+// interval in seconds as the server expects
+func (m *model) sendLimitCommand(parts []string) {
+	verb := parts[0]
+	spec := knownCommands[verb]
+	if !m.serverCaps[verb] {
+		m.appendMessage(fmt.Sprintf("The server does not support %s.", verb))
+		return
+	}
+	if len(parts)-1 < spec.minArgs {
+		m.appendMessage("Usage: " + spec.usage)
+		return
+	}
+	var line string
+	switch verb {
+	case "SLOWMODE":
+		delay, err := parseLimitInterval(parts[1])
+		if err != nil {
+			m.appendMessage(err.Error())
+			return
+		}
+		line = fmt.Sprintf("SLOWMODE %d", int(delay.Seconds()))
+	case "RATELIMIT":
+		messages, per, err := parseRateLimitSpec(parts[2])
+		if err != nil {
+			m.appendMessage(err.Error())
+			return
+		}
+		line = fmt.Sprintf("RATELIMIT %s %d %d", parts[1], messages, int(per.Seconds()))
+	}
+	// The server decides who may set limits and announces the result
+	m.showBuffer(serverBuffer)
+	m.lastServerCommand = verb
+	m.writeLine(line)
+}
+
+// applySlowMode records the room's slow mode, noting changes in the conversation
+func (m *model) applySlowMode(msg slowModeMsg) {
+	if msg.delay == m.slowMode {
+		return
+	}
+	m.slowMode = msg.delay
+	if msg.delay == 0 {
+		m.nextSlow = time.Time{}
+		m.appendMessage("Slow mode is off.")
+		return
+	}
+	m.appendMessage(fmt.Sprintf("Slow mode is on: one message to ALL every %s. Messages sent sooner wait in the outbox.", describeInterval(msg.delay)))
+}
+
+// applyRateLimit records the rate limit set for us, noting changes in the conversation
+func (m *model) applyRateLimit(msg rateLimitMsg) {
+	if msg == m.rateLimit {
+		return
+	}
+	m.rateLimit = msg
+	if msg.messages == 0 {
+		m.paced = nil
+		m.appendMessage("Your rate limit was lifted.")
+		return
+	}
+	m.appendMessage(fmt.Sprintf("The operator limited you to %d message(s) every %s. Messages over the limit wait in the outbox.", msg.messages, describeInterval(msg.per)))
+}
+
+// pace pushes back the send of a message to recipientID, due after delay, until slow mode and
+// our rate limit allow it, and records it against them. Operators are not limited.
+func (m *model) pace(recipientID string, delay time.Duration) (time.Duration, tea.Cmd) {
+	if m.isOperator || (m.slowMode == 0 && m.rateLimit.messages == 0) {
+		return delay, nil
+	}
+	now := time.Now()
+	sendAt := now.Add(delay)
+	_, except := parseExcept(recipientID)
+	room := recipientID == "ALL" || except
+	if m.slowMode > 0 && room && sendAt.Before(m.nextSlow) {
+		sendAt = m.nextSlow
+	}
+	if limit := m.rateLimit; limit.messages > 0 {
+		// Forget sends that no longer count, then wait for the oldest counted one to age out
+		kept := m.paced[:0]
+		for _, at := range m.paced {
+			if at.After(now.Add(-limit.per)) {
+				kept = append(kept, at)
+			}
+		}
+		m.paced = kept
+		if n := len(m.paced); n >= limit.messages {
+			if earliest := m.paced[n-limit.messages].Add(limit.per); sendAt.Before(earliest) {
+				sendAt = earliest
+			}
+		}
+		m.paced = append(m.paced, sendAt)
+		sort.Slice(m.paced, func(i, j int) bool { return m.paced[i].Before(m.paced[j]) })
+	}
+	if m.slowMode > 0 && room {
+		m.nextSlow = sendAt.Add(m.slowMode)
+	}
+	paced := sendAt.Sub(now)
+	if paced <= delay {
+		return delay, m.startPaceTick()
+	}
+	m.flash = fmt.Sprintf("Held by the server's limits: this message goes out in %s.", paced.Round(time.Second))
+	return paced, m.startPaceTick()
+}
+
+// startPaceTick starts refreshing the countdown shown above the input, unless it is running
+func (m *model) startPaceTick() tea.Cmd {
+	if m.paceTicking || m.slowMode == 0 {
+		return nil
+	}
+	m.paceTicking = true
+	return tea.Tick(time.Second, func(time.Time) tea.Msg { return paceTickMsg{} })
+}
+
+// paceTick refreshes the countdown while slow mode still holds the next message back
+func (m *model) paceTick() tea.Cmd {
+	m.paceTicking = false
+	if time.Until(m.nextSlow) <= 0 {
+		return nil
+	}
+	return m.startPaceTick()
+}
+
+// limitLine renders the active slow mode and rate limit shown above the input
+func (m *model) limitLine() string {
+	var parts []string
+	if m.slowMode > 0 {
+		text := "Slow mode: one message to ALL every " + describeInterval(m.slowMode)
+		if m.isOperator {
+			text += " (operators are exempt)"
+		} else if wait := time.Until(m.nextSlow); wait > 0 {
+			text += fmt.Sprintf(", next in %s", wait.Round(time.Second))
+		}
+		parts = append(parts, text)
+	}
+	if m.rateLimit.messages > 0 {
+		parts = append(parts, fmt.Sprintf("Rate limit: %d message(s) every %s", m.rateLimit.messages, describeInterval(m.rateLimit.per)))
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	return limitStyle.Render(strings.Join(parts, " · "))
+}
+
+// describeInterval formats a limit interval compactly: 30s, 2m, 1m30s, 1h
+func describeInterval(d time.Duration) string {
+	switch {
+	case d%time.Hour == 0:
+		return fmt.Sprintf("%dh", d/time.Hour)
+	case d%time.Minute == 0 && d < time.Hour:
+		return fmt.Sprintf("%dm", d/time.Minute)
+	default:
+		return d.String()
+	}
+}
@@ -0,0 +1,72 @@
+// styles.go
+// Package ui defines the lipgloss styles used when rendering the UI.
+
+package ui
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+var (
+	// highlightStyle marks messages that mention us or match a watch keyword
+	highlightStyle = lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("11"))
+
+	// warningBannerStyle marks warnings that stay on screen for the whole session
+	warningBannerStyle = lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("15")).Background(lipgloss.Color("9"))
+)
+
+// themeName is the -theme setting
+var themeName = "default"
+
+// theme holds the colors of the styles that carry meaning. An empty color leaves the style
+// uncolored and marks it with reverse video or an underline instead.
+type theme struct {
+	highlight string // Mentions and watch keywords
+	warning   string // Background of the warning banner
+	announce  string // Border of operator announcements
+	limit     string // Slow mode and rate limit line
+}
+
+// themes are the -theme presets by name. The default suits a dark background.
+var themes = map[string]theme{
+	"default": {highlight: "11", warning: "9", announce: "9", limit: "3"},
+	"light":   {highlight: "4", warning: "1", announce: "1", limit: "130"},
+	"mono":    {},
+}
+
+// themeNames returns the theme presets, sorted, for usage messages
+func themeNames() []string {
+	names := make([]string, 0, len(themes))
+	for name := range themes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// applyTheme rebuilds the styles from the named preset
+func applyTheme(name string) error {
+	t, ok := themes[name]
+	if !ok {
+		return &fatalError{code: exitUsage, err: fmt.Errorf("unknown theme %q; use one of %s", name, strings.Join(themeNames(), ", "))}
+	}
+	highlightStyle = lipgloss.NewStyle().Bold(true)
+	warningBannerStyle = lipgloss.NewStyle().Bold(true)
+	announceStyle = lipgloss.NewStyle().Border(lipgloss.ThickBorder()).Bold(true).Padding(0, 1)
+	limitStyle = lipgloss.NewStyle()
+	if t == (theme{}) {
+		highlightStyle = highlightStyle.Underline(true)
+		warningBannerStyle = warningBannerStyle.Reverse(true)
+		limitStyle = limitStyle.Italic(true)
+		return nil
+	}
+	highlightStyle = highlightStyle.Foreground(lipgloss.Color(t.highlight))
+	warningBannerStyle = warningBannerStyle.Foreground(lipgloss.Color("15")).Background(lipgloss.Color(t.warning))
+	announceStyle = announceStyle.BorderForeground(lipgloss.Color(t.announce))
+	limitStyle = limitStyle.Foreground(lipgloss.Color(t.limit))
+	return nil
+}
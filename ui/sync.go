@@ -0,0 +1,179 @@
+// sync.go
+// Package ui keeps read positions, the unsent draft, and mute settings in step across the user's
+// own devices, by sending them as encrypted direct messages between the devices' client IDs.
+
+package ui
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// syncWith is the -sync-with setting: comma-separated client IDs of the user's other devices
+var syncWith string
+
+// syncDelay is how long changes are gathered before they are sent to the other devices, so typing
+// a draft sends one update rather than one per key
+const syncDelay = 2 * time.Second
+
+// syncUpdate is the state sent to the other devices; each field is only set when it changed
+type syncUpdate struct {
+	Read   map[string]time.Time `json:"read,omitempty"`   // Conversations read up to a time
+	Draft  *string              `json:"draft,omitempty"`  // Text of the input line
+	Notify map[string]string    `json:"notify,omitempty"` // Notification levels, replacing the device's own
+	Joins  map[string]bool      `json:"joins,omitempty"`  // Suppressed join/part notices, replacing the device's own
+}
+
+// syncTickMsg sends the state gathered since the last sync
+type syncTickMsg struct{}
+
+// parseSyncPeers parses the -sync-with setting, leaving out our own client ID
+func parseSyncPeers(list, clientID string) []string {
+	var peers []string
+	for _, peer := range strings.Split(list, ",") {
+		if peer = strings.TrimSpace(peer); peer != "" && peer != clientID && !containsString(peers, peer) {
+			peers = append(peers, peer)
+		}
+	}
+	return peers
+}
+
+// markRead records that a conversation was read up to now, for the other devices
+func (m *model) markRead(conversation string) {
+	if len(m.syncPeers) == 0 || conversation == "" {
+		return
+	}
+	if m.syncPending.Read == nil {
+		m.syncPending.Read = make(map[string]time.Time)
+	}
+	m.syncPending.Read[conversation] = time.Now()
+}
+
+// syncMuteSettings queues the notification levels and join/part settings for the other devices
+func (m *model) syncMuteSettings() {
+	if len(m.syncPeers) == 0 {
+		return
+	}
+	m.syncPending.Notify = make(map[string]string, len(m.notifyLevels))
+	for conversation, level := range m.notifyLevels {
+		m.syncPending.Notify[conversation] = level
+	}
+	m.syncPending.Joins = make(map[string]bool, len(m.presenceMuted))
+	for conversation, muted := range m.presenceMuted {
+		m.syncPending.Joins[conversation] = muted
+	}
+}
+
+// scheduleSync starts the timer that sends pending changes, if there are any and it is not running.
+// The Update wrapper calls it, so drafts are picked up after any key press.
+func (m *model) scheduleSync() tea.Cmd {
+	if len(m.syncPeers) == 0 || m.syncScheduled {
+		return nil
+	}
+	p := m.syncPending
+	if p.Read == nil && p.Notify == nil && p.Joins == nil && m.input.Value() == m.draftSynced {
+		return nil
+	}
+	m.syncScheduled = true
+	return tea.Tick(syncDelay, func(time.Time) tea.Msg { return syncTickMsg{} })
+}
+
+// flushSync sends the pending changes to every other device. While disconnected they stay
+// pending and are sent once the connection is back.
+func (m *model) flushSync() {
+	m.syncScheduled = false
+	if m.writer == nil {
+		return
+	}
+	update := m.syncPending
+	if draft := m.input.Value(); draft != m.draftSynced {
+		update.Draft = &draft
+	}
+	data, err := json.Marshal(update)
+	if err != nil {
+		m.appendMessage(fmt.Sprintf("Error encoding sync update: %v", err))
+		return
+	}
+	for _, peer := range m.syncPeers {
+		line, _, err := m.encodeSend(peer, envelope{body: string(data), sync: true}.seal())
+		if err != nil {
+			m.appendMessage(fmt.Sprintf("Error syncing with %s: %v", peer, err))
+			return
+		}
+		if !m.writeLine(line) {
+			return
+		}
+	}
+	if update.Draft != nil {
+		m.draftSynced = *update.Draft
+	}
+	m.syncPending = syncUpdate{}
+}
+
+// applySync applies state sent by another of the user's devices. Updates are only accepted as
+// direct messages from a -sync-with device signed with our own identity key, so another client
+// cannot change our settings by registering under a device's ID.
+func (m *model) applySync(msg incomingMessage, body string) {
+	if msg.isBroadcast || !containsString(m.syncPeers, msg.senderID) {
+		return
+	}
+	key, status := verifySignature(msg.senderID, m.clientID, msg.content)
+	if status != signatureValid || m.identity == nil || !bytes.Equal(key, m.identity.public) {
+		if !m.keyWarned[msg.senderID] {
+			m.keyWarned[msg.senderID] = true
+			m.appendMessage(fmt.Sprintf("Ignored sync updates from %s, which are not signed with your identity key. Copy %s.key from %s to the other device to sync with it.", msg.senderID, m.clientID, identityDir))
+		}
+		return
+	}
+	var update syncUpdate
+	if err := json.Unmarshal([]byte(body), &update); err != nil {
+		m.appendMessage(fmt.Sprintf("Ignored a malformed sync update from %s: %v", msg.senderID, err))
+		return
+	}
+	for conversation, at := range update.Read {
+		m.applyRead(conversation, at)
+	}
+	if update.Draft != nil {
+		// Only replace a draft the user has not changed since the last sync
+		if value := m.input.Value(); value == "" || value == m.draftSynced {
+			m.input.SetValue(*update.Draft)
+			m.input.CursorEnd()
+		}
+		m.draftSynced = *update.Draft
+	}
+	if update.Notify != nil {
+		m.notifyLevels = update.Notify
+		if err := saveNotifyLevels(m.notifyLevels); err != nil {
+			m.appendMessage(fmt.Sprintf("Error saving notification levels: %v", err))
+		}
+	}
+	if update.Joins != nil {
+		m.presenceMuted = update.Joins
+	}
+	if update.Notify != nil || update.Joins != nil {
+		m.flash = "Mute settings synced from " + msg.senderID
+	}
+}
+
+// applyRead clears the unread count of messages in a background tab that another device has read
+func (m *model) applyRead(conversation string, at time.Time) {
+	if _, ok := m.tabUnread[conversation]; !ok {
+		return
+	}
+	unread := 0
+	for _, entry := range m.entries {
+		if (entry.kind == entryDirect || entry.kind == entryBroadcast) && entry.conversation() == conversation && entry.at.After(at) {
+			unread++
+		}
+	}
+	if unread == 0 {
+		delete(m.tabUnread, conversation)
+	} else if unread < m.tabUnread[conversation] {
+		m.tabUnread[conversation] = unread
+	}
+}
@@ -0,0 +1,137 @@
+// tabs.go
+// Package ui gives the broadcast channel and each direct-message peer a tab of their own, with
+// its own scroll position and unread counter, instead of interleaving every conversation.
+
+package ui
+
+import (
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// broadcastTab is the tab of the broadcast channel, which is always open
+const broadcastTab = "ALL"
+
+var (
+	tabStyle       = lipgloss.NewStyle().Padding(0, 1)
+	activeTabStyle = tabStyle.Reverse(true).Bold(true)
+)
+
+func init() {
+	registerCommand("/tab", commandSpec{
+		usage: "/tab [name]",
+		help:  "Switch to the tab of ALL or a peer (also Ctrl+Left/Right), or list the tabs",
+		run: func(m *model, args []string) tea.Cmd {
+			if len(args) == 0 {
+				m.appendMessage("Tabs: " + strings.Join(m.tabs, ", ") + ". Switch with /tab <name> or Ctrl+Left/Right.")
+				return nil
+			}
+			name := args[0]
+			for _, tab := range m.tabs {
+				if strings.EqualFold(tab, name) {
+					name = tab
+					break
+				}
+			}
+			m.switchTab(name)
+			return nil
+		},
+	})
+}
+
+// inActiveTab reports whether an entry is shown in the active tab. Notices belong to no
+// conversation and are shown in every tab.
+func (m *model) inActiveTab(entry chatEntry) bool {
+	tab := entry.conversation()
+	return tab == "" || tab == m.activeTab
+}
+
+// trackTab opens a tab for a new conversation and counts unread messages in background tabs.
+// Sending a message switches to the recipient's tab so the message can be seen.
+func (m *model) trackTab(entry chatEntry) {
+	tab := entry.conversation()
+	if tab == "" {
+		return
+	}
+	if tab == m.activeTab {
+		if !m.unfocused {
+			m.markRead(tab)
+		}
+		return
+	}
+	switch entry.kind {
+	case entryOutgoing:
+		m.switchTab(tab)
+	case entryDirect, entryBroadcast:
+		m.openTab(tab)
+		m.tabUnread[tab]++
+	}
+}
+
+// openTab adds a tab if it is not open yet
+func (m *model) openTab(name string) {
+	if !containsString(m.tabs, name) {
+		m.tabs = append(m.tabs, name)
+	}
+}
+
+// switchTab shows another tab, restoring where it was scrolled to
+func (m *model) switchTab(name string) {
+	if name == m.activeTab {
+		return
+	}
+	// Remember the position of the tab being left; -1 follows new messages at the bottom
+	if m.viewport.AtBottom() {
+		m.tabOffsets[m.activeTab] = -1
+	} else {
+		m.tabOffsets[m.activeTab] = m.viewport.YOffset
+	}
+	m.openTab(name)
+	m.activeTab = name
+	delete(m.tabUnread, name)
+	m.markRead(name)
+	m.refreshViewport()
+	if offset, ok := m.tabOffsets[name]; ok && offset >= 0 {
+		m.viewport.SetYOffset(offset)
+	} else {
+		m.viewport.GotoBottom()
+	}
+}
+
+// cycleTab switches to the next (delta 1) or previous (delta -1) tab
+func (m *model) cycleTab(delta int) {
+	current := 0
+	for i, tab := range m.tabs {
+		if tab == m.activeTab {
+			current = i
+		}
+	}
+	next := (current + delta + len(m.tabs)) % len(m.tabs)
+	m.switchTab(m.tabs[next])
+}
+
+// tabBar renders the tab names above the viewport, with unread counts for background tabs
+func (m *model) tabBar() string {
+	if len(m.tabs) < 2 {
+		return ""
+	}
+	tabs := make([]string, 0, len(m.tabs))
+	for _, tab := range m.tabs {
+		label := tab
+		if unread := m.tabUnread[tab]; unread > 0 {
+			label = fmt.Sprintf("%s (%d)", tab, unread)
+		}
+		if tab == m.activeTab {
+			tabs = append(tabs, activeTabStyle.Render(label))
+		} else if m.archived[tab] && m.tabUnread[tab] == 0 {
+			// Archived conversations stay out of the way until they have news
+			continue
+		} else {
+			tabs = append(tabs, tabStyle.Render(label))
+		}
+	}
+	return lipgloss.JoinHorizontal(lipgloss.Top, tabs...)
+}
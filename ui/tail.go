@@ -0,0 +1,143 @@
+// tail.go
+// Package ui implements the tail subcommand, which prints decrypted incoming messages to stdout.
+
+package ui
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/drewwalton19216801/padclient/protocol"
+	"github.com/drewwalton19216801/padclient/transport"
+)
+
+// tailRecord is the JSON form of a message printed by tail -json
+type tailRecord struct {
+	Time          time.Time `json:"time"`
+	From          string    `json:"from"`
+	Broadcast     bool      `json:"broadcast"`
+	Text          string    `json:"text"`
+	ForwardedFrom string    `json:"forwarded_from,omitempty"`
+	Signature     string    `json:"signature,omitempty"` // "valid", "verified", "new-key", "changed", "invalid", or "missing"
+}
+
+// runTail connects and prints incoming messages until interrupted or disconnected:
+// padclient tail -server <host> [-id <YourID>] [-json]
+func runTail(args []string) error {
+	flags := flag.NewFlagSet("tail", flag.ContinueOnError)
+	server := flags.String("server", "", "Tailscale server to connect to")
+	flags.Var(portValue{}, "port", "TCP port the server listens on (default 12345, or as found through DNS)")
+	hostname, _ := os.Hostname()
+	clientID := flags.String("id", "tail-"+hostname, "client ID to register as")
+	asJSON := flags.Bool("json", false, "print one JSON object per message")
+	timeout := flags.Duration("timeout", 30*time.Second, "how long to wait for the connection and key exchange")
+	flags.StringVar(&knownKeysPath, "known-keys", knownKeysPath, "file of identity keys pinned for peers the first time each signs a message")
+	flags.BoolVar(&jsonErrors, "json-errors", true, "write errors to stderr as JSON objects")
+	flags.BoolVar(&allowNonTailscale, "allow-non-tailscale", false, "connect without Tailscale; traffic is only protected by the client's own encryption")
+	addTsnetFlags(flags)
+	addAllowlistFlags(flags)
+	if err := readSubcommandConfig(flags, args); err != nil {
+		return err
+	}
+	if err := flags.Parse(args); err != nil {
+		return &fatalError{code: exitUsage, err: err}
+	}
+	if *server == "" || flags.NArg() > 0 {
+		return &fatalError{code: exitUsage, err: errors.New("usage: padclient tail -server <host> [-id <YourID>] [-json]")}
+	}
+	if err := checkServersAllowed(*server); err != nil {
+		return err
+	}
+	if err := requireTailscale(*server); err != nil {
+		return err
+	}
+	signatures, err := newSignatureChecker()
+	if err != nil {
+		return err
+	}
+	// Peers offer a key with their first direct message; answering lets them stop sending one-time keys
+	id, err := loadSigningIdentity(*clientID)
+	if err != nil {
+		return err
+	}
+	if dmKeys, err = newKeyAgreement(id); err != nil {
+		return err
+	}
+
+	conn, hashedSecret, err := dialServer(*server, *clientID, *timeout)
+	if err != nil {
+		return err
+	}
+	conn.SetDeadline(time.Time{})
+
+	// Stop on Ctrl+C; the reader closes the connection when the context ends
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	messages := make(chan tea.Msg, messageBuffer)
+	done := make(chan struct{})
+	go func() {
+		readMessages(ctx, conn, *clientID, newSessionKey(hashedSecret), nil, nil, messages)
+		close(done)
+	}()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-done:
+			return &fatalError{code: exitConnect, err: errors.New("disconnected from the server")}
+		case msg := <-messages:
+			// Only this loop writes to the connection
+			reply, notice := answerKeyAgreement(protocol.EncodeHex, id, signatures.keys, *clientID, msg)
+			if reply != "" {
+				fmt.Fprintf(conn, "%s\n", reply)
+			}
+			if notice != "" {
+				fmt.Fprintln(os.Stderr, notice)
+			}
+			if err := printTailMessage(os.Stdout, msg, *clientID, signatures, *asJSON); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// printTailMessage prints an incoming message, marked as the UI marks its signature; warnings about
+// signatures and other server messages are reported on stderr or ignored
+func printTailMessage(out io.Writer, msg tea.Msg, clientID string, signatures *signatureChecker, asJSON bool) error {
+	switch msg := msg.(type) {
+	case incomingMessage:
+		env := openEnvelope(msg.content)
+		if isControlEnvelope(msg.content, env) {
+			return nil
+		}
+		status, notice := signatures.check(msg.senderID, clientID, msg)
+		if notice != "" {
+			fmt.Fprintln(os.Stderr, notice)
+		}
+		record := tailRecord{Time: time.Now(), From: msg.senderID, Broadcast: msg.isBroadcast, Text: env.body, ForwardedFrom: env.forwardedFrom, Signature: status.name()}
+		if asJSON {
+			return json.NewEncoder(out).Encode(record)
+		}
+		entry := chatEntry{kind: entryDirect, sender: msg.senderID, forwardedFrom: env.forwardedFrom, content: env.body, signature: status}
+		if msg.isBroadcast {
+			entry.kind = entryBroadcast
+		}
+		_, err := fmt.Fprintf(out, "[%s] %s\n", record.Time.Format("15:04:05"), entry.render())
+		return err
+	case integrityFailureMsg:
+		fmt.Fprintf(os.Stderr, "Dropped a message from %s that could not be decrypted: %v\n", msg.senderID, msg.err)
+	case kickedMsg:
+		return &fatalError{code: exitBanned, err: errors.New("kicked by the operator")}
+	case bannedMsg:
+		return transport.ErrBanned
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+// telemetry.go
+// Package ui sends opt-in, privacy-scrubbed error reports to a configurable endpoint.
+
+package ui
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"runtime"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// telemetryURL is the endpoint error reports are posted to (empty disables reporting)
+var telemetryURL string
+
+// telemetryTimeout bounds how long sending a report may delay the client
+const telemetryTimeout = 3 * time.Second
+
+// telemetryReport is the JSON body of an error report. It never contains message content,
+// client IDs, server addresses, or key material.
+type telemetryReport struct {
+	Client   string         `json:"client"`   // Client version
+	Platform string         `json:"platform"` // GOOS/GOARCH
+	Event    string         `json:"event"`    // "panic" or "session"
+	Detail   string         `json:"detail,omitempty"`
+	Stack    []string       `json:"stack,omitempty"` // Function and file:line per frame
+	Counts   map[string]int `json:"counts,omitempty"`
+}
+
+func init() {
+	registerCommand("/telemetry", commandSpec{
+		usage: "/telemetry",
+		help:  "Show whether error reporting is on and what it sends",
+		run: func(m *model, args []string) tea.Cmd {
+			if !telemetryEnabled() {
+				m.appendMessage("Error reporting is off. Start with -telemetry-url to opt in.")
+				return nil
+			}
+			m.appendMessage(fmt.Sprintf("Error reporting to %s is on. Reports hold the client version, platform, panic types and stack frames, and counts of decryption failures, protocol errors, and reconnect attempts; never message content, IDs, addresses, or keys.", telemetryURL))
+			return nil
+		},
+	})
+}
+
+// telemetryEnabled reports whether the user opted in. Amnesia mode never reports.
+func telemetryEnabled() bool {
+	return telemetryURL != "" && !amnesia
+}
+
+// sendTelemetry posts a report, waiting at most telemetryTimeout
+func sendTelemetry(report telemetryReport) error {
+	report.Client = version
+	report.Platform = runtime.GOOS + "/" + runtime.GOARCH
+	body, err := json.Marshal(report)
+	if err != nil {
+		return err
+	}
+	client := &http.Client{Timeout: telemetryTimeout}
+	resp, err := client.Post(telemetryURL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// reportPanics reports a panic in progress and lets it continue. It must be deferred directly.
+func reportPanics() {
+	r := recover()
+	if r == nil {
+		return
+	}
+	if telemetryEnabled() {
+		// Only the panic's type is sent, since its value may quote message content
+		sendTelemetry(telemetryReport{Event: "panic", Detail: fmt.Sprintf("%T", r), Stack: scrubbedStack()})
+	}
+	panic(r)
+}
+
+// scrubbedStack returns the current goroutine's frames as function names and file:line, without arguments
+func scrubbedStack() []string {
+	pcs := make([]uintptr, 32)
+	n := runtime.Callers(3, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+	var stack []string
+	for {
+		frame, more := frames.Next()
+		stack = append(stack, fmt.Sprintf("%s %s:%d", frame.Function, filepath.Base(frame.File), frame.Line))
+		if !more {
+			break
+		}
+	}
+	return stack
+}
+
+// reportSession sends a summary of the session's errors on exit, if there were any worth reporting
+func (m *model) reportSession() {
+	if !telemetryEnabled() || (m.integrityFailures == 0 && m.protocolErrors == 0 && m.reconnects < 3) {
+		return
+	}
+	sendTelemetry(telemetryReport{Event: "session", Counts: map[string]int{
+		"decryption_failures": m.integrityFailures,
+		"messages_received":   m.received,
+		"protocol_errors":     m.protocolErrors,
+		"reconnect_attempts":  m.reconnects,
+	}})
+}
@@ -0,0 +1,111 @@
+// threads.go
+// Package ui threads replies to a message and can collapse them under it in the buffer.
+
+package ui
+
+import (
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// threadPreviewLength is how many characters of the parent message are quoted above a reply
+const threadPreviewLength = 60
+
+func init() {
+	registerCommand("/thread", commandSpec{
+		usage:   "/thread <n> <message>",
+		help:    "Reply to the nth most recent message in its thread",
+		minArgs: 2,
+		run: func(m *model, args []string) tea.Cmd {
+			entry, err := m.messageByNumber(args[0])
+			if err != nil {
+				m.appendMessage(err.Error())
+				return nil
+			}
+			if entry.kind == entrySystem {
+				m.appendMessage(fmt.Sprintf("Message %s is a notice and cannot be replied to.", args[0]))
+				return nil
+			}
+			// Replies to a reply join the thread it belongs to
+			thread := entry.thread
+			if thread == "" {
+				thread = m.threadKey(*entry)
+			}
+			body := strings.Join(args[1:], " ")
+			return m.queueSend(entry.conversation(), envelope{body: body, thread: thread}.seal())
+		},
+	})
+	registerCommand("/threads", commandSpec{
+		usage: "/threads",
+		help:  "Toggle collapsing replies under the message they reply to",
+		run: func(m *model, args []string) tea.Cmd {
+			m.threadView = !m.threadView
+			if m.threadView {
+				m.appendMessage("Replies are now shown under the message they reply to.")
+			} else {
+				m.appendMessage("Replies are now shown in the order they arrived.")
+			}
+			return nil
+		},
+	})
+}
+
+// threadKey identifies the thread started by a message. Every client derives the same key from
+// the sender and text, so messages need no IDs of their own.
+func (m *model) threadKey(entry chatEntry) string {
+	sender := entry.sender
+	if entry.kind == entryOutgoing {
+		sender = m.clientID
+	}
+	return messageKey(sender, entry.content)
+}
+
+// threadIndex finds the buffered messages that replies refer to, returning each thread's parent
+// by key and each parent's replies in arrival order.
+func (m *model) threadIndex() (map[string]int, map[int][]int) {
+	wanted := make(map[string]bool)
+	for _, entry := range m.entries {
+		if entry.thread != "" {
+			wanted[entry.thread] = true
+		}
+	}
+	if len(wanted) == 0 {
+		return nil, nil
+	}
+	parents := make(map[string]int)
+	replies := make(map[int][]int)
+	for i, entry := range m.entries {
+		if entry.thread != "" {
+			if parent, ok := parents[entry.thread]; ok {
+				replies[parent] = append(replies[parent], i)
+			}
+			continue
+		}
+		if entry.kind == entrySystem {
+			continue
+		}
+		if key := m.threadKey(entry); wanted[key] {
+			parents[key] = i
+		}
+	}
+	return parents, replies
+}
+
+// threadPreview quotes the start of a parent message above a reply
+func threadPreview(entry chatEntry) string {
+	text, _, _ := strings.Cut(entry.render(), "\n")
+	if runes := []rune(text); len(runes) > threadPreviewLength {
+		text = string(runes[:threadPreviewLength]) + "…"
+	}
+	return text
+}
+
+// renderReply formats a reply shown under its parent in the thread view
+func (m *model) renderReply(entry chatEntry) string {
+	if !entry.revealed {
+		entry.content, _ = m.mask.apply(entry.content)
+	}
+	return "\n  ↳ " + strings.ReplaceAll(entry.render(), "\n", "\n    ")
+}
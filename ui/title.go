@@ -0,0 +1,43 @@
+// title.go
+// Package ui keeps the terminal title showing the server, unread count, and connection state.
+
+package ui
+
+import (
+	"fmt"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// titleDisabled stops the client from setting the terminal title
+var titleDisabled bool
+
+// terminalTitle formats the title for the current state, e.g. "padclient — 100.64.0.1 (3)"
+func (m *model) terminalTitle() string {
+	title := "padclient — " + serverHost()
+	switch {
+	case m.writer == nil && (m.expectingRestart() || m.reconnecting()):
+		title += " [reconnecting]"
+	case m.writer == nil && m.conn == nil:
+		title += " [connecting]"
+	case m.writer == nil:
+		title += " [disconnected]"
+	}
+	if unread := m.unreadCount(); unread > 0 {
+		title += fmt.Sprintf(" (%d)", unread)
+	}
+	return title
+}
+
+// syncTerminalTitle sets the terminal title with an OSC sequence whenever it changes
+func (m *model) syncTerminalTitle() tea.Cmd {
+	if titleDisabled {
+		return nil
+	}
+	title := m.terminalTitle()
+	if title == m.lastTitle {
+		return nil
+	}
+	m.lastTitle = title
+	return tea.SetWindowTitle(title)
+}
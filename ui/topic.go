@@ -0,0 +1,72 @@
+// topic.go
+// Package ui shows the room topic above the input and lets permitted users change it.
+
+package ui
+
+import (
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// topicStyle renders the topic line shown above the input
+var topicStyle = lipgloss.NewStyle().Italic(true)
+
+// topicMsg reports the room topic the server announced
+type topicMsg struct {
+	topic string
+}
+
+func init() {
+	registerCommand("/topic", commandSpec{
+		usage: "/topic [new topic]",
+		help:  "Show the room topic and edit it in the input, or set a new topic (needs the TOPIC capability)",
+		run: func(m *model, args []string) tea.Cmd {
+			if !m.serverCaps["TOPIC"] {
+				m.appendMessage("The server does not support TOPIC.")
+				return nil
+			}
+			if len(args) == 0 {
+				// Put the current topic in the input so it can be edited in place
+				m.input.SetValue("/topic " + m.topic)
+				m.input.CursorEnd()
+				return nil
+			}
+			// The server decides who may change the topic and announces the result
+			m.lastServerCommand = "TOPIC"
+			m.writeLine("TOPIC " + strings.Join(args, " "))
+			return nil
+		},
+	})
+}
+
+// parseTopic recognizes the server announcing the topic: "TOPIC <topic>", or "TOPIC" when it is unset
+func parseTopic(line string) (topicMsg, bool) {
+	if line != "TOPIC" && !strings.HasPrefix(line, "TOPIC ") {
+		return topicMsg{}, false
+	}
+	return topicMsg{topic: strings.TrimSpace(strings.TrimPrefix(line, "TOPIC"))}, true
+}
+
+// applyTopic records a topic change, noting it in the conversation unless it is the first report
+func (m *model) applyTopic(msg topicMsg) {
+	if m.topicKnown && msg.topic != m.topic {
+		if msg.topic == "" {
+			m.appendMessage("The topic was cleared.")
+		} else {
+			m.appendMessage(fmt.Sprintf("The topic is now: %s", msg.topic))
+		}
+	}
+	m.topic = msg.topic
+	m.topicKnown = true
+}
+
+// topicLine renders the topic shown above the input
+func (m *model) topicLine() string {
+	if m.topic == "" {
+		return ""
+	}
+	return topicStyle.Render("Topic: " + m.topic)
+}
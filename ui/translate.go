@@ -0,0 +1,138 @@
+// translate.go
+// Package ui pipes messages through a user-configured translation command or HTTP API.
+
+package ui
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os/exec"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+var (
+	translateCommand string // External command that reads text on stdin and prints the translation
+	translateURL     string // HTTP endpoint that receives text as a POST body and returns the translation
+)
+
+// translateTimeout bounds how long a translation may take
+const translateTimeout = 15 * time.Second
+
+// translationMsg carries the result of a translation back to the model
+type translationMsg struct {
+	seq         int
+	translation string
+	err         error
+}
+
+func init() {
+	registerCommand("/translate", commandSpec{
+		usage:   "/translate <n>",
+		help:    "Translate the nth most recent message with the configured translator",
+		minArgs: 1,
+		run: func(m *model, args []string) tea.Cmd {
+			if translateCommand == "" && translateURL == "" {
+				m.appendMessage("No translator configured. Start with -translate-cmd or -translate-url.")
+				return nil
+			}
+			entry, err := m.messageByNumber(args[0])
+			if err != nil {
+				m.appendMessage(err.Error())
+				return nil
+			}
+			seq, text := entry.seq, entry.content
+			if m.translateAccepted {
+				return translate(seq, text)
+			}
+			// Plaintext leaves the machine, so ask once per session
+			m.askConfirmation("/translate "+args[0],
+				fmt.Sprintf("Privacy warning: the decrypted message will be sent in plaintext to %s.", translatorName()),
+				func(m *model) tea.Cmd {
+					m.translateAccepted = true
+					return translate(seq, text)
+				})
+			return nil
+		},
+	})
+}
+
+// translatorName describes where translations are sent
+func translatorName() string {
+	if translateCommand != "" {
+		return fmt.Sprintf("the command %q", translateCommand)
+	}
+	return translateURL
+}
+
+// translate runs the configured translator on text in the background
+func translate(seq int, text string) tea.Cmd {
+	return func() tea.Msg {
+		ctx, cancel := context.WithTimeout(context.Background(), translateTimeout)
+		defer cancel()
+		var translation string
+		var err error
+		if translateCommand != "" {
+			translation, err = translateWithCommand(ctx, text)
+		} else {
+			translation, err = translateWithHTTP(ctx, text)
+		}
+		return translationMsg{seq: seq, translation: strings.TrimSpace(translation), err: err}
+	}
+}
+
+// translateWithCommand pipes text through the external translation command
+func translateWithCommand(ctx context.Context, text string) (string, error) {
+	fields := strings.Fields(translateCommand)
+	cmd := exec.CommandContext(ctx, fields[0], fields[1:]...)
+	cmd.Stdin = strings.NewReader(text)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("%v: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return string(output), nil
+}
+
+// translateWithHTTP posts text to the translation endpoint and returns the response body
+func translateWithHTTP(ctx context.Context, text string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, translateURL, strings.NewReader(text))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("translator returned %s", resp.Status)
+	}
+	return string(body), nil
+}
+
+// applyTranslation stores a translation on its entry so it renders inline
+func (m *model) applyTranslation(msg translationMsg) {
+	if msg.err != nil {
+		m.appendMessage(fmt.Sprintf("Error translating message: %v", msg.err))
+		return
+	}
+	index := m.entryBySeq(msg.seq)
+	if index < 0 {
+		m.appendMessage("The translated message is no longer in the buffer.")
+		return
+	}
+	m.entries[index].translation = msg.translation
+	m.refreshViewport()
+}
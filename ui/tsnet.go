@@ -0,0 +1,115 @@
+// tsnet.go
+// Package ui dials the server directly, or through an embedded Tailscale node with -tsnet for
+// containers and machines without the Tailscale daemon. The node comes from the separate
+// cmd/padclient-tsnet module, which sets StartTsnet, so the Tailscale libraries stay out of this one.
+
+package ui
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Settings of the embedded Tailscale node
+var (
+	useTsnet      bool   // Dial through an embedded tsnet node instead of the host's network
+	tsnetAuthKey  string // Auth key the node logs in with; TS_AUTHKEY is used when empty
+	tsnetDir      string // Directory keeping the node's state between runs
+	tsnetHostname string // Machine name of the node on the tailnet
+)
+
+// tsnetStartTimeout bounds logging in and bringing the embedded node up
+const tsnetStartTimeout = 2 * time.Minute
+
+// TsnetNode is a running embedded Tailscale node
+type TsnetNode interface {
+	Dial(ctx context.Context, network, address string) (net.Conn, error)
+	Close() error
+}
+
+// TsnetConfig describes the embedded node -tsnet asks for
+type TsnetConfig struct {
+	Hostname  string
+	Dir       string
+	AuthKey   string
+	Ephemeral bool                             // Removed from the tailnet once it goes offline
+	UserLogf  func(format string, args ...any) // Login URLs and other messages meant for the user
+}
+
+// StartTsnet brings an embedded node up. It is nil unless the client is built from
+// cmd/padclient-tsnet, which sets it before calling Main.
+var StartTsnet func(ctx context.Context, config TsnetConfig) (TsnetNode, error)
+
+// tsnetNode is the running embedded node, if any
+var tsnetNode TsnetNode
+
+// dialContext dials the network; -tsnet replaces it with the embedded node's dialer
+var dialContext = (&net.Dialer{}).DialContext
+
+// dialTCP connects to a TCP address, giving up after timeout unless it is 0
+func dialTCP(address string, timeout time.Duration) (net.Conn, error) {
+	ctx := context.Background()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	return dialContext(ctx, "tcp", address)
+}
+
+// addTsnetFlags registers the embedded Tailscale node's flags
+func addTsnetFlags(flags *flag.FlagSet) {
+	hostname, _ := os.Hostname()
+	flags.BoolVar(&useTsnet, "tsnet", false, "connect through an embedded Tailscale node instead of requiring Tailscale on this machine")
+	flags.StringVar(&tsnetAuthKey, "tsnet-authkey", "", "auth key the embedded node logs in with (default $TS_AUTHKEY, or a login URL is printed)")
+	flags.StringVar(&tsnetDir, "tsnet-dir", defaultTsnetDir(), "directory keeping the embedded node's state")
+	flags.StringVar(&tsnetHostname, "tsnet-hostname", "padclient-"+hostname, "machine name of the embedded node on the tailnet")
+}
+
+// defaultTsnetDir returns ~/.config/padclient/tsnet, or the platform's equivalent
+func defaultTsnetDir() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "padclient", "tsnet")
+}
+
+// startTsnet brings the embedded node up and routes all dials through it
+func startTsnet() error {
+	if tsnetNode != nil {
+		return nil
+	}
+	if StartTsnet == nil {
+		return &fatalError{code: exitUsage, err: errors.New("this build does not include the embedded Tailscale node; build cmd/padclient-tsnet instead (see the README)")}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), tsnetStartTimeout)
+	defer cancel()
+	node, err := StartTsnet(ctx, TsnetConfig{
+		Hostname:  tsnetHostname,
+		Dir:       tsnetDir,
+		AuthKey:   tsnetAuthKey,
+		Ephemeral: amnesia,
+		UserLogf:  func(format string, args ...any) { fmt.Fprintf(os.Stderr, format+"\n", args...) },
+	})
+	if err != nil {
+		return &fatalError{code: exitNotTailscale, err: fmt.Errorf("error starting the embedded Tailscale node: %v", err)}
+	}
+	tsnetNode = node
+	dialContext = node.Dial
+	return nil
+}
+
+// stopTsnet takes the embedded node offline
+func stopTsnet() {
+	if tsnetNode != nil {
+		tsnetNode.Close()
+		tsnetNode = nil
+	}
+}
@@ -0,0 +1,102 @@
+// tts.go
+// Package ui speaks incoming direct messages and mentions through an external text-to-speech command.
+
+package ui
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// ttsCommand is the text-to-speech command; the text to speak is passed as its last argument
+var ttsCommand string
+
+// ttsErrorMsg reports a failed text-to-speech announcement
+type ttsErrorMsg struct{ err error }
+
+func init() {
+	registerCommand("/tts", commandSpec{
+		usage:   "/tts on|off [conversation]",
+		help:    "Speak direct messages and mentions aloud, for all conversations or one",
+		minArgs: 1,
+		run: func(m *model, args []string) tea.Cmd {
+			if ttsCommand == "" {
+				m.appendMessage("No text-to-speech command configured. Start with -tts-cmd.")
+				return nil
+			}
+			conversation := "*"
+			if len(args) > 1 {
+				conversation = args[1]
+			}
+			switch args[0] {
+			case "on":
+				m.ttsConversations[conversation] = true
+			case "off":
+				if conversation == "*" {
+					// Turning everything off also clears per-conversation settings
+					m.ttsConversations = make(map[string]bool)
+				} else {
+					m.ttsConversations[conversation] = false
+				}
+			default:
+				m.appendMessage("Usage: " + knownCommands["/tts"].usage)
+				return nil
+			}
+			m.appendMessage(fmt.Sprintf("Text-to-speech %s for %s.", args[0], describeConversation(conversation)))
+			return nil
+		},
+	})
+}
+
+// describeConversation names a conversation key in notices
+func describeConversation(conversation string) string {
+	switch conversation {
+	case "*":
+		return "all conversations"
+	case "ALL":
+		return "broadcasts"
+	default:
+		return conversation
+	}
+}
+
+// ttsEnabled reports whether announcements are on for the conversation
+func (m *model) ttsEnabled(conversation string) bool {
+	if enabled, ok := m.ttsConversations[conversation]; ok {
+		return enabled
+	}
+	return m.ttsConversations["*"]
+}
+
+// announce speaks an incoming direct message or mention when enabled for its conversation
+func (m *model) announce(entry chatEntry) tea.Cmd {
+	if ttsCommand == "" || !m.ttsEnabled(entry.conversation()) {
+		return nil
+	}
+	if !m.shouldNotify(entry) {
+		return nil
+	}
+	text := fmt.Sprintf("Message from %s: %s", entry.sender, entry.content)
+	if match, _ := m.watchMatch(entry.content); entry.kind == entryBroadcast && match == "mention" {
+		text = fmt.Sprintf("%s mentioned you: %s", entry.sender, entry.content)
+	} else if entry.kind == entryBroadcast {
+		text = fmt.Sprintf("Broadcast from %s: %s", entry.sender, entry.content)
+	}
+	masked, _ := m.mask.apply(text)
+	return speak(masked)
+}
+
+// speak runs the text-to-speech command in the background
+func speak(text string) tea.Cmd {
+	return func() tea.Msg {
+		fields := strings.Fields(ttsCommand)
+		cmd := exec.Command(fields[0], append(fields[1:], text)...)
+		if err := cmd.Run(); err != nil {
+			return ttsErrorMsg{err}
+		}
+		return nil
+	}
+}
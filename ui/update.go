@@ -0,0 +1,240 @@
+// update.go
+// Package ui implements "padclient update", which replaces the binary with a signed release.
+
+package ui
+
+import (
+	"cmp"
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	// updateURL is the default release manifest URL, set at build time with -ldflags "-X github.com/drewwalton19216801/padclient/ui.updateURL=..."
+	updateURL string
+	// updatePublicKey is the hex Ed25519 key release binaries are signed with, set at build time
+	// with -ldflags "-X github.com/drewwalton19216801/padclient/ui.updatePublicKey=..."
+	updatePublicKey string
+)
+
+// maxUpdateSize bounds the size of a downloaded release binary
+const maxUpdateSize = 200 << 20
+
+// releaseManifest describes the latest release
+type releaseManifest struct {
+	Version string                  `json:"version"`
+	Assets  map[string]releaseAsset `json:"assets"` // Keyed by "<GOOS>/<GOARCH>"
+}
+
+// releaseAsset is the binary for one platform
+type releaseAsset struct {
+	URL       string `json:"url"`
+	Signature string `json:"signature"` // Base64 Ed25519 signature over signedRelease
+}
+
+// signedRelease returns what a release binary's signature covers: the release version, the
+// platform, and the SHA-256 of the binary. Binding the version and platform means a validly
+// signed old release cannot be served as a new one, nor one platform's binary as another's.
+func signedRelease(version, platform string, binary []byte) []byte {
+	sum := sha256.Sum256(binary)
+	return []byte("padclient-release-v1\x00" + version + "\x00" + platform + "\x00" + hex.EncodeToString(sum[:]))
+}
+
+// parseSemver parses a version of the form v1.2.3, with an optional -prerelease suffix
+func parseSemver(v string) (numbers [3]int, prerelease string, ok bool) {
+	core, ok := strings.CutPrefix(v, "v")
+	if !ok {
+		return numbers, "", false
+	}
+	core, prerelease, _ = strings.Cut(core, "-")
+	parts := strings.Split(core, ".")
+	if len(parts) != 3 {
+		return numbers, "", false
+	}
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return numbers, "", false
+		}
+		numbers[i] = n
+	}
+	return numbers, prerelease, true
+}
+
+// compareSemver returns -1, 0, or 1 as version a is older than, the same as, or newer than b.
+// A prerelease is older than the release it leads up to.
+func compareSemver(a, b string) int {
+	aNumbers, aPre, _ := parseSemver(a)
+	bNumbers, bPre, _ := parseSemver(b)
+	for i := range aNumbers {
+		if c := cmp.Compare(aNumbers[i], bNumbers[i]); c != 0 {
+			return c
+		}
+	}
+	switch {
+	case aPre == bPre:
+		return 0
+	case aPre == "":
+		return 1
+	case bPre == "":
+		return -1
+	}
+	return strings.Compare(aPre, bPre)
+}
+
+// runUpdate handles "padclient update [-url <manifest>] [-check] [-force]"
+func runUpdate(args []string) error {
+	flags := flag.NewFlagSet("update", flag.ContinueOnError)
+	manifestURL := flags.String("url", updateURL, "release manifest URL")
+	checkOnly := flags.Bool("check", false, "only report whether an update is available")
+	force := flags.Bool("force", false, "reinstall even if the release matches the running version")
+	flags.BoolVar(&jsonErrors, "json-errors", jsonErrors, "write errors to stderr as JSON objects")
+	if err := readSubcommandConfig(flags, args); err != nil {
+		return err
+	}
+	if err := flags.Parse(args); err != nil {
+		return &fatalError{code: exitUsage, err: err}
+	}
+	if *manifestURL == "" {
+		return &fatalError{code: exitUsage, err: errors.New("no release manifest URL; pass -url")}
+	}
+	publicKey, err := hex.DecodeString(updatePublicKey)
+	if err != nil || len(publicKey) != ed25519.PublicKeySize {
+		return errors.New("this build has no release signing key, so updates cannot be verified")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+	body, err := download(ctx, *manifestURL, 1<<20)
+	if err != nil {
+		return fmt.Errorf("error fetching release manifest: %v", err)
+	}
+	var manifest releaseManifest
+	if err := json.Unmarshal(body, &manifest); err != nil {
+		return fmt.Errorf("error parsing release manifest: %v", err)
+	}
+	if _, _, ok := parseSemver(manifest.Version); !ok {
+		return &fatalError{code: exitBadRelease, err: fmt.Errorf("release version %q is not of the form v1.2.3", manifest.Version)}
+	}
+	// A development build is older than any release
+	if _, _, ok := parseSemver(version); ok {
+		switch c := compareSemver(manifest.Version, version); {
+		case c < 0:
+			return &fatalError{code: exitBadRelease, err: fmt.Errorf("release %s is older than the running %s; not installing", manifest.Version, version)}
+		case c == 0 && !*force:
+			fmt.Printf("padclient %s is up to date.\n", version)
+			return nil
+		}
+	}
+	platform := runtime.GOOS + "/" + runtime.GOARCH
+	asset, ok := manifest.Assets[platform]
+	if !ok {
+		return fmt.Errorf("release %s has no binary for %s", manifest.Version, platform)
+	}
+	if *checkOnly {
+		fmt.Printf("padclient %s is available (running %s).\n", manifest.Version, version)
+		return nil
+	}
+
+	// Verify the binary before it touches the disk
+	binary, err := download(ctx, asset.URL, maxUpdateSize)
+	if err != nil {
+		return fmt.Errorf("error downloading release: %v", err)
+	}
+	signature, err := base64.StdEncoding.DecodeString(asset.Signature)
+	if err != nil || !ed25519.Verify(publicKey, signedRelease(manifest.Version, platform, binary), signature) {
+		return &fatalError{code: exitBadRelease, err: fmt.Errorf("the signature of release %s for %s does not verify; not installing", manifest.Version, platform)}
+	}
+	if err := replaceExecutable(binary); err != nil {
+		return fmt.Errorf("error installing release: %v", err)
+	}
+	fmt.Printf("Updated padclient %s to %s.\n", version, manifest.Version)
+	return nil
+}
+
+// download fetches url, refusing bodies larger than limit
+func download(ctx context.Context, url string, limit int64) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s returned %s", url, resp.Status)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(body)) > limit {
+		return nil, fmt.Errorf("%s is larger than %d bytes", url, limit)
+	}
+	return body, nil
+}
+
+// replaceExecutable atomically swaps the running binary for the new one
+func replaceExecutable(binary []byte) error {
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	if exe, err = filepath.EvalSymlinks(exe); err != nil {
+		return err
+	}
+	// Write next to the binary so the rename stays on one filesystem
+	tmp, err := os.CreateTemp(filepath.Dir(exe), ".padclient-update-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(binary); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0755); err != nil {
+		return err
+	}
+	if runtime.GOOS != "windows" {
+		return os.Rename(tmp.Name(), exe)
+	}
+	// A running executable cannot be replaced on Windows, but it can be moved aside
+	old := exe + ".old"
+	os.Remove(old)
+	if err := os.Rename(exe, old); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), exe); err != nil {
+		// Put the old binary back rather than leave none
+		if restoreErr := os.Rename(old, exe); restoreErr != nil {
+			return fmt.Errorf("%v; the previous binary is left at %s: %v", err, old, restoreErr)
+		}
+		return err
+	}
+	return nil
+}
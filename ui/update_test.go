@@ -0,0 +1,29 @@
+package ui
+
+import "testing"
+
+// TestCompareSemver checks the ordering used to refuse releases older than the running version
+func TestCompareSemver(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"v1.2.3", "v1.2.3", 0},
+		{"v1.2.4", "v1.2.3", 1},
+		{"v1.10.0", "v1.9.9", 1},
+		{"v2.0.0", "v10.0.0", -1},
+		{"v1.2.3-rc.1", "v1.2.3", -1},
+		{"v1.2.3", "v1.2.3-rc.1", 1},
+		{"v1.2.3-rc.2", "v1.2.3-rc.1", 1},
+	}
+	for _, test := range tests {
+		if got := compareSemver(test.a, test.b); got != test.want {
+			t.Errorf("compareSemver(%q, %q) = %d, want %d", test.a, test.b, got, test.want)
+		}
+	}
+	for _, v := range []string{"dev", "1.2.3", "v1.2", "v1.2.x", "v1.-2.3"} {
+		if _, _, ok := parseSemver(v); ok {
+			t.Errorf("parseSemver(%q) accepted it", v)
+		}
+	}
+}
@@ -0,0 +1,140 @@
+// validation.go
+// Package ui validates commands as they are typed and produces the one-line hint shown under the input.
+
+package ui
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// commandSpec describes the arguments a command expects
+type commandSpec struct {
+	usage   string                                // Usage string shown in hints
+	minArgs int                                   // Minimum number of arguments after the verb
+	help    string                                // Description shown by HELP for client commands
+	run     func(m *model, args []string) tea.Cmd // Handler for client-side slash commands
+}
+
+// knownCommands lists the client and server commands the input understands
+var knownCommands = map[string]commandSpec{
+	"SEND":       {usage: "SEND <RecipientID|ALL> <Message>", minArgs: 2},
+	"HELP":       {usage: "HELP"},
+	"EXIT":       {usage: "EXIT"},
+	"LIST":       {usage: "LIST"},
+	"SERVERHELP": {usage: "SERVERHELP"},
+	"KICK":       {usage: "KICK <ClientID>", minArgs: 1},
+	"BAN":        {usage: "BAN <ClientID>", minArgs: 1},
+	"UNBAN":      {usage: "UNBAN <ClientID>", minArgs: 1},
+	"LISTBANS":   {usage: "LISTBANS"},
+	"SHUTDOWN":   {usage: "SHUTDOWN"},
+	"WHOIS":      {usage: "WHOIS <ClientID>", minArgs: 1},
+	"SLOWMODE":   {usage: "SLOWMODE <interval|off>", minArgs: 1},
+	"RATELIMIT":  {usage: "RATELIMIT <ClientID> <messages>/<interval>|off", minArgs: 2},
+}
+
+// registerCommand adds a client-side slash command to the known commands
+func registerCommand(name string, spec commandSpec) {
+	knownCommands[name] = spec
+}
+
+// runClientCommand runs a registered slash command
+func (m *model) runClientCommand(parts []string) tea.Cmd {
+	spec, ok := knownCommands[parts[0]]
+	if !ok || spec.run == nil {
+		m.appendMessage(fmt.Sprintf("Unknown command %s. Type HELP to see available commands.", parts[0]))
+		return nil
+	}
+	args := parts[1:]
+	if len(args) < spec.minArgs {
+		m.appendMessage("Usage: " + spec.usage)
+		return nil
+	}
+	return spec.run(m, args)
+}
+
+// clientCommandHelp returns the HELP lines for the registered slash commands
+func clientCommandHelp() []string {
+	var names []string
+	for name, spec := range knownCommands {
+		if spec.run != nil {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	var lines []string
+	for _, name := range names {
+		spec := knownCommands[name]
+		lines = append(lines, fmt.Sprintf("%s - %s", spec.usage, spec.help))
+	}
+	return lines
+}
+
+// validateInput returns a hint describing what is wrong with the partially typed input,
+// or an empty string when the input looks fine.
+func validateInput(input string) string {
+	parts := strings.Fields(input)
+	if len(parts) == 0 {
+		return ""
+	}
+	verb := parts[0]
+	// Do not complain about the verb while it is still being typed
+	typingVerb := len(parts) == 1 && !strings.HasSuffix(input, " ")
+
+	spec, ok := knownCommands[verb]
+	if !ok && strings.HasPrefix(verb, "/") {
+		if typingVerb {
+			return ""
+		}
+		return fmt.Sprintf("Unknown client command %s; type HELP for commands", verb)
+	}
+	if !ok {
+		if upper := strings.ToUpper(verb); upper != verb {
+			if _, ok := knownCommands[upper]; ok {
+				return fmt.Sprintf("Commands are uppercase: did you mean %s?", upper)
+			}
+		}
+		if typingVerb {
+			return ""
+		}
+		return fmt.Sprintf("Unknown command %s (it will be sent to the server as-is; type HELP for commands)", verb)
+	}
+
+	args := parts[1:]
+	switch verb {
+	case "SEND":
+		if len(args) == 0 {
+			if typingVerb {
+				return ""
+			}
+			return "Missing recipient: " + spec.usage
+		}
+		if len(args) == 1 {
+			return "Message body is empty: " + spec.usage
+		}
+	case "SLOWMODE":
+		if len(args) > 0 {
+			if _, err := parseLimitInterval(args[0]); err != nil {
+				return err.Error()
+			}
+		} else if !typingVerb {
+			return "Missing argument: " + spec.usage
+		}
+	case "RATELIMIT":
+		if len(args) > 1 {
+			if _, _, err := parseRateLimitSpec(args[1]); err != nil {
+				return err.Error()
+			}
+		} else if !typingVerb {
+			return "Missing argument: " + spec.usage
+		}
+	default:
+		if len(args) < spec.minArgs && !typingVerb {
+			return "Missing argument: " + spec.usage
+		}
+	}
+	return ""
+}
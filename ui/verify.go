@@ -0,0 +1,163 @@
+// verify.go
+// Package ui implements /verify, which shows our identity key and a peer's side by side with a
+// short authentication string to compare over another channel, and records the peer as verified.
+
+package ui
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// sasEmoji are the symbols of the short authentication string, one per 6 bits of its digest
+var sasEmoji = []string{
+	"🐶", "🐱", "🦁", "🐴", "🦄", "🐷", "🐘", "🐰", "🐼", "🐓", "🐧", "🐢", "🐟", "🐙", "🦋", "🌷",
+	"🌳", "🌵", "🍄", "🌏", "🌙", "☁️", "🔥", "🍌", "🍎", "🍓", "🌽", "🍕", "🎂", "❤️", "😀", "🤖",
+	"🎩", "👓", "🔧", "🎅", "👍", "☂️", "⌛", "⏰", "🎁", "💡", "📕", "✏️", "📎", "✂️", "🔒", "🔑",
+	"🔨", "☎️", "🏁", "🚂", "🚲", "✈️", "🚀", "🏆", "⚽", "🎸", "🎺", "🔔", "⚓", "🎧", "📁", "📌",
+}
+
+// sasLength is the number of emoji shown; 7 emoji carry 42 bits
+const sasLength = 7
+
+func init() {
+	registerCommand("/verify", commandSpec{
+		usage:   "/verify <peer> [confirm|revoke]",
+		help:    "Compare identity keys with a peer, then confirm to mark them verified",
+		minArgs: 1,
+		run: func(m *model, args []string) tea.Cmd {
+			peer := args[0]
+			action := ""
+			if len(args) > 1 {
+				action = strings.ToLower(args[1])
+			}
+			switch action {
+			case "":
+				m.showVerification(peer)
+			case "confirm":
+				m.confirmVerification(peer)
+			case "revoke":
+				if err := m.knownKeys.setVerified(peer, "", false); err != nil {
+					m.appendMessage(fmt.Sprintf("Error saving known keys: %v", err))
+					return nil
+				}
+				delete(m.verifiedPeers, peer)
+				m.appendMessage(fmt.Sprintf("%s is no longer verified.", peer))
+			default:
+				m.appendMessage("Usage: " + knownCommands["/verify"].usage)
+			}
+			return nil
+		},
+	})
+}
+
+// showVerification prints both identity keys and the short authentication string derived from them
+func (m *model) showVerification(peer string) {
+	if m.identity == nil {
+		m.appendMessage("This client has no identity key.")
+		return
+	}
+	encoded, state := m.knownKeys.describe(peer)
+	if encoded == "" {
+		m.appendMessage(fmt.Sprintf("No signed message from %s yet, so there is no key to verify. Ask them to send you one.", peer))
+		return
+	}
+	key, _ := base64.StdEncoding.DecodeString(encoded)
+	emoji, digits := shortAuthString(m.identity.public, key)
+	m.appendMessage(strings.Join([]string{
+		fmt.Sprintf("Verifying %s:", peer),
+		"  Your key:       " + m.identity.fingerprint(),
+		fmt.Sprintf("  %s's key: %s (%s)", peer, keyFingerprint(key), state),
+		"  Safety emoji:   " + emoji,
+		"  Safety number:  " + digits,
+		fmt.Sprintf("Ask %s to run /verify %s and compare over a channel you trust (in person or a call). If everything matches, type /verify %s confirm.", peer, m.clientID, peer),
+	}, "\n"))
+}
+
+// confirmVerification marks the key a peer signs with as verified, accepting it in place of the
+// pinned one if it changed
+func (m *model) confirmVerification(peer string) {
+	encoded, _ := m.knownKeys.describe(peer)
+	if encoded == "" {
+		m.appendMessage(fmt.Sprintf("No signed message from %s yet, so there is no key to verify.", peer))
+		return
+	}
+	if err := m.knownKeys.setVerified(peer, encoded, true); err != nil {
+		m.appendMessage(fmt.Sprintf("Error saving known keys: %v", err))
+		return
+	}
+	m.verifiedPeers[peer] = true
+	delete(m.keyWarned, peer)
+	key, _ := base64.StdEncoding.DecodeString(encoded)
+	m.appendMessage(fmt.Sprintf("%s is verified with key %s. Their messages are marked ✓; a message signed with any other key is marked !.", peer, keyFingerprint(key)))
+}
+
+// shortAuthString derives the emoji and digits both sides see for a pair of identity keys. The
+// keys are hashed in a fixed order, so it does not matter who runs /verify.
+func shortAuthString(a, b []byte) (string, string) {
+	first, second := a, b
+	if string(first) > string(second) {
+		first, second = second, first
+	}
+	digest := sha256.Sum256([]byte("padclient-sas-v1\x00" + string(first) + string(second)))
+	bits := binary.BigEndian.Uint64(digest[:8])
+	emoji := make([]string, sasLength)
+	for i := range emoji {
+		emoji[i] = sasEmoji[bits>>(58-6*i)&63]
+	}
+	groups := make([]string, 4)
+	for i := range groups {
+		groups[i] = fmt.Sprintf("%05d", binary.BigEndian.Uint32(digest[8+4*i:])%100000)
+	}
+	return strings.Join(emoji, " "), strings.Join(groups, " ")
+}
+
+// describe returns the key a peer currently signs with and how it is trusted. A key that differs
+// from the pinned one is returned, so it can be compared and accepted.
+func (k *keyStore) describe(peer string) (string, string) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	pinned, presented := k.keys[peer], k.presented[peer]
+	switch {
+	case presented != "" && pinned != "" && presented != pinned:
+		old, _ := base64.StdEncoding.DecodeString(pinned)
+		return presented, "CHANGED from the pinned " + keyFingerprint(old)
+	case pinned == "":
+		return "", ""
+	case k.verified[peer]:
+		return pinned, "verified"
+	default:
+		return pinned, "pinned, not verified"
+	}
+}
+
+// setVerified marks a peer verified with the given key, pinning it, or clears the mark
+func (k *keyStore) setVerified(peer, key string, verified bool) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if verified {
+		k.keys[peer] = key
+		k.verified[peer] = true
+	} else {
+		delete(k.verified, peer)
+	}
+	return k.save()
+}
+
+// verifiedPeers returns the peers whose pinned keys are verified
+func (k *keyStore) verifiedPeers() []string {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	var peers []string
+	for peer, ok := range k.verified {
+		if ok {
+			peers = append(peers, peer)
+		}
+	}
+	return peers
+}
@@ -0,0 +1,87 @@
+// version.go
+// Package ui reports the client version, supported protocol versions, and build information.
+
+package ui
+
+import (
+	"fmt"
+	"runtime/debug"
+	"sort"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// version is the client version, set at build time with -ldflags "-X github.com/drewwalton19216801/padclient/ui.version=v1.2.3"
+var version = "dev"
+
+// protocolVersion is the version of the padserve line protocol the client speaks
+const protocolVersion = 1
+
+// supportedExtensions are the protocol extensions the client understands, as the server names them
+// in CAPABILITIES
+var supportedExtensions = []string{
+	"BASE64", "BULK", "EXCEPT", "INVITE", "MOTD", "OPSTATUS", "PIN", "PRESENCE", "RATELIMIT",
+	"REKEY", "SEARCH", "SHUTDOWN", "SLOWMODE", "TIME", "TOPIC", "VERSION",
+}
+
+func init() {
+	registerCommand("/version", commandSpec{
+		usage: "/version",
+		help:  "Show the client version, protocol support, and build information",
+		run: func(m *model, args []string) tea.Cmd {
+			for _, line := range versionLines() {
+				m.appendMessage(line)
+			}
+			var serverCaps []string
+			for capability := range m.serverCaps {
+				serverCaps = append(serverCaps, capability)
+			}
+			sort.Strings(serverCaps)
+			if len(serverCaps) == 0 {
+				m.appendMessage("Server extensions: none advertised")
+			} else {
+				m.appendMessage("Server extensions: " + strings.Join(serverCaps, " "))
+			}
+			return nil
+		},
+	})
+}
+
+// versionLines describes the client version and build
+func versionLines() []string {
+	lines := []string{
+		fmt.Sprintf("padclient %s", version),
+		fmt.Sprintf("Protocol: padserve line protocol v%d; extensions %s", protocolVersion, strings.Join(supportedExtensions, " ")),
+	}
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return lines
+	}
+	build := []string{info.GoVersion}
+	settings := make(map[string]string)
+	for _, setting := range info.Settings {
+		settings[setting.Key] = setting.Value
+	}
+	if revision := settings["vcs.revision"]; revision != "" {
+		if len(revision) > 12 {
+			revision = revision[:12]
+		}
+		if settings["vcs.modified"] == "true" {
+			revision += "+dirty"
+		}
+		build = append(build, "commit "+revision)
+	}
+	if built := settings["vcs.time"]; built != "" {
+		build = append(build, built)
+	}
+	if goos, goarch := settings["GOOS"], settings["GOARCH"]; goos != "" {
+		build = append(build, goos+"/"+goarch)
+	}
+	return append(lines, "Build: "+strings.Join(build, ", "))
+}
+
+// versionAnnouncement is the line telling a server that supports it which client version this is
+func versionAnnouncement() string {
+	return fmt.Sprintf("VERSION padclient/%s protocol/%d %s", version, protocolVersion, strings.Join(supportedExtensions, " "))
+}
@@ -0,0 +1,63 @@
+package ui
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// TestSupportedExtensions checks the extensions announced in VERSION against the capabilities the
+// client looks up in serverCaps before sending a command, so a new extension is not left out
+func TestSupportedExtensions(t *testing.T) {
+	// sendLimitCommand looks the capability up by the command's verb
+	used := map[string]bool{"SLOWMODE": true, "RATELIMIT": true}
+	// The server pushes these lines on its own; the client only has to understand them
+	pushed := map[string]bool{"MOTD": true, "PRESENCE": true, "SHUTDOWN": true}
+
+	paths, err := filepath.Glob("*.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fset := token.NewFileSet()
+	for _, path := range paths {
+		if strings.HasSuffix(path, "_test.go") {
+			continue
+		}
+		file, err := parser.ParseFile(fset, path, nil, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ast.Inspect(file, func(node ast.Node) bool {
+			index, ok := node.(*ast.IndexExpr)
+			if !ok {
+				return true
+			}
+			selector, ok := index.X.(*ast.SelectorExpr)
+			literal, isLiteral := index.Index.(*ast.BasicLit)
+			if ok && selector.Sel.Name == "serverCaps" && isLiteral && literal.Kind == token.STRING {
+				name, _ := strconv.Unquote(literal.Value)
+				used[name] = true
+			}
+			return true
+		})
+	}
+
+	for name := range used {
+		if !slices.Contains(supportedExtensions, name) {
+			t.Errorf("the client uses the %s extension, but supportedExtensions does not list it", name)
+		}
+	}
+	for _, name := range supportedExtensions {
+		if !used[name] && !pushed[name] {
+			t.Errorf("supportedExtensions lists %s, which the client never uses", name)
+		}
+	}
+	if !slices.IsSorted(supportedExtensions) || len(slices.Compact(slices.Clone(supportedExtensions))) != len(supportedExtensions) {
+		t.Errorf("supportedExtensions is not sorted without duplicates: %v", supportedExtensions)
+	}
+}
@@ -0,0 +1,162 @@
+// wal.go
+// Package ui keeps a write-ahead log of the outbox so unsent messages survive a crash.
+
+package ui
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// walPath is the outbox write-ahead log file (empty disables the log)
+var walPath string
+
+// walRecord is one line of the write-ahead log
+type walRecord struct {
+	Op          string `json:"op"` // "queue" when a message enters the outbox, "done" once it is settled
+	ID          int    `json:"id"`
+	RecipientID string `json:"to,omitempty"`
+	MessageText string `json:"text,omitempty"`
+}
+
+// outboxLog appends outbox changes to the write-ahead log, syncing each record to disk
+type outboxLog struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+// openOutboxLog replays the log at path, returning the messages that were queued but never settled,
+// and compacts the log so it only holds those messages.
+func openOutboxLog(path string) (*outboxLog, []queuedSend, error) {
+	pending, err := replayOutboxLog(path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Rewrite the log with only the unsettled messages, then swap it in atomically
+	tmp := path + ".tmp"
+	file, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, nil, err
+	}
+	log := &outboxLog{file: file}
+	for _, queued := range pending {
+		if err := log.write(walRecord{Op: "queue", ID: queued.id, RecipientID: queued.recipientID, MessageText: queued.messageText}); err != nil {
+			file.Close()
+			return nil, nil, err
+		}
+	}
+	// Close before renaming, since Windows cannot rename a file that is still open
+	if err := file.Close(); err != nil {
+		return nil, nil, err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return nil, nil, err
+	}
+	log.file, err = os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, nil, err
+	}
+	return log, pending, nil
+}
+
+// replayOutboxLog reads the log and returns the queued messages without a matching done record
+func replayOutboxLog(path string) ([]queuedSend, error) {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var order []int
+	queued := make(map[int]queuedSend)
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var record walRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			// A torn final record from a crash mid-write; everything before it is intact
+			break
+		}
+		switch record.Op {
+		case "queue":
+			if _, ok := queued[record.ID]; !ok {
+				order = append(order, record.ID)
+			}
+			queued[record.ID] = queuedSend{id: record.ID, recipientID: record.RecipientID, messageText: record.MessageText}
+		case "done":
+			delete(queued, record.ID)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	var pending []queuedSend
+	for _, id := range order {
+		if q, ok := queued[id]; ok {
+			pending = append(pending, q)
+			delete(queued, id)
+		}
+	}
+	return pending, nil
+}
+
+// write appends a record and syncs it to disk before returning
+func (l *outboxLog) write(record walRecord) error {
+	if l == nil {
+		return nil
+	}
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, err := l.file.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	return l.file.Sync()
+}
+
+// logQueued records a message entering the outbox
+func (m *model) logQueued(queued queuedSend) {
+	err := m.wal.write(walRecord{Op: "queue", ID: queued.id, RecipientID: queued.recipientID, MessageText: queued.messageText})
+	if err != nil {
+		m.appendMessage(fmt.Sprintf("Error writing the outbox log: %v", err))
+	}
+}
+
+// logSettled records an outbox message being delivered, failed, or cancelled
+func (m *model) logSettled(id int) {
+	if id == 0 {
+		return
+	}
+	if err := m.wal.write(walRecord{Op: "done", ID: id}); err != nil {
+		m.appendMessage(fmt.Sprintf("Error writing the outbox log: %v", err))
+	}
+}
+
+// recoverOutbox queues the messages a previous session left unsent
+func (m *model) recoverOutbox() tea.Cmd {
+	pending := m.walPending
+	m.walPending = nil
+	if len(pending) == 0 {
+		return nil
+	}
+	m.appendMessage(fmt.Sprintf("Recovered %d unsent message(s) from the outbox log; they will be sent again.", len(pending)))
+	var cmds []tea.Cmd
+	for _, queued := range pending {
+		// The old record is settled and the message is logged again under its new outbox ID
+		m.logSettled(queued.id)
+		cmds = append(cmds, m.queueSend(queued.recipientID, queued.messageText))
+	}
+	return tea.Batch(cmds...)
+}
@@ -0,0 +1,108 @@
+// watch.go
+// Package ui highlights and collects incoming messages that mention us or match watch keywords.
+
+package ui
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// maxWatchedEntries is the number of watched messages kept for the watched view
+const maxWatchedEntries = 100
+
+func init() {
+	registerCommand("/watch", commandSpec{
+		usage:   "/watch add|remove|list [keyword]",
+		help:    "Manage keywords that highlight and collect matching messages",
+		minArgs: 1,
+		run: func(m *model, args []string) tea.Cmd {
+			switch {
+			case args[0] == "list":
+				m.appendMessage("Watch keywords: " + strings.Join(m.watchKeywordList(), ", "))
+			case args[0] == "add" && len(args) > 1:
+				keyword := strings.ToLower(strings.Join(args[1:], " "))
+				m.watchKeywords[keyword] = true
+				m.appendMessage(fmt.Sprintf("Watching for %q.", keyword))
+			case args[0] == "remove" && len(args) > 1:
+				keyword := strings.ToLower(strings.Join(args[1:], " "))
+				delete(m.watchKeywords, keyword)
+				m.appendMessage(fmt.Sprintf("No longer watching for %q.", keyword))
+			default:
+				m.appendMessage("Usage: /watch add|remove|list [keyword]")
+			}
+			return nil
+		},
+	})
+	registerCommand("/watched", commandSpec{
+		usage: "/watched",
+		help:  "Toggle the view of messages that matched a watch keyword or mentioned you",
+		run: func(m *model, args []string) tea.Cmd {
+			m.togglePanel("watched")
+			return nil
+		},
+	})
+}
+
+// addWatchKeywords adds comma-separated keywords to the watch list
+func (m *model) addWatchKeywords(list string) {
+	for _, keyword := range strings.Split(list, ",") {
+		if keyword = strings.ToLower(strings.TrimSpace(keyword)); keyword != "" {
+			m.watchKeywords[keyword] = true
+		}
+	}
+}
+
+// watchKeywordList returns the watch keywords in sorted order
+func (m *model) watchKeywordList() []string {
+	keywords := make([]string, 0, len(m.watchKeywords))
+	for keyword := range m.watchKeywords {
+		keywords = append(keywords, keyword)
+	}
+	sort.Strings(keywords)
+	return keywords
+}
+
+// watchMatch returns what an incoming message matched: our own ID or a watch keyword
+func (m *model) watchMatch(content string) (string, bool) {
+	lower := strings.ToLower(content)
+	if m.clientID != "" && strings.Contains(lower, strings.ToLower(m.clientID)) {
+		return "mention", true
+	}
+	for _, keyword := range m.watchKeywordList() {
+		if strings.Contains(lower, keyword) {
+			return keyword, true
+		}
+	}
+	return "", false
+}
+
+// checkWatch highlights an incoming entry that matches, notifies the user, and collects it
+func (m *model) checkWatch(entry *chatEntry) {
+	match, ok := m.watchMatch(entry.content)
+	if !ok {
+		return
+	}
+	entry.highlight = true
+	m.watched = append(m.watched, *entry)
+	if len(m.watched) > maxWatchedEntries {
+		m.watched = m.watched[len(m.watched)-maxWatchedEntries:]
+	}
+	m.flash = fmt.Sprintf("Watched (%s) from %s: %s", match, entry.sender, entry.content)
+}
+
+// watchedView renders the collected watched messages
+func (m *model) watchedView() string {
+	var b strings.Builder
+	b.WriteString("Watched messages (/watched to close):")
+	if len(m.watched) == 0 {
+		b.WriteString("\n  (nothing yet)")
+	}
+	for _, entry := range m.watched {
+		fmt.Fprintf(&b, "\n  [%s] %s", entry.at.Format("15:04"), entry.render())
+	}
+	return b.String()
+}